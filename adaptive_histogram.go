@@ -0,0 +1,232 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tally
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultAdaptiveSamples       = 1000
+	defaultAdaptiveBucketCount   = 16
+	defaultAdaptiveLowerQuantile = 0.01
+	defaultAdaptiveUpperQuantile = 0.999
+)
+
+// AdaptiveBucketOptions is a set of options for an adaptive histogram.
+type AdaptiveBucketOptions struct {
+	// Samples is the number of samples observed before selecting the
+	// buckets, it defaults to 1000.
+	Samples int
+	// Count is the number of buckets, it defaults to 16.
+	Count int
+	// LowerQuantile and UpperQuantile are the quantiles of the observed
+	// samples bounding the buckets, they default to 0.01 and 0.999.
+	LowerQuantile float64
+	UpperQuantile float64
+}
+
+// NewAdaptiveHistogram returns a histogram of s whose buckets are selected
+// from its first samples, for workloads where sensible buckets aren't known
+// upfront. The first opts.Samples samples are buffered, then the histogram
+// is created with opts.Count buckets spaced logarithmically between the
+// observed lower and upper quantiles, or linearly if the lower quantile
+// isn't positive, and the buffered samples are recorded to it. Nothing is
+// reported until then.
+//
+// The buckets are duration buckets if the first sample is a duration, and
+// value buckets otherwise.
+func NewAdaptiveHistogram(s Scope, name string, opts AdaptiveBucketOptions) Histogram {
+	if opts.Samples <= 0 {
+		opts.Samples = defaultAdaptiveSamples
+	}
+	if opts.Count <= 0 {
+		opts.Count = defaultAdaptiveBucketCount
+	}
+	if opts.LowerQuantile <= 0 {
+		opts.LowerQuantile = defaultAdaptiveLowerQuantile
+	}
+	if opts.UpperQuantile <= 0 || opts.UpperQuantile > 1 {
+		opts.UpperQuantile = defaultAdaptiveUpperQuantile
+	}
+
+	return &adaptiveHistogram{
+		scope:   s,
+		name:    name,
+		opts:    opts,
+		samples: make([]adaptiveSample, 0, opts.Samples),
+	}
+}
+
+type adaptiveSample struct {
+	value    float64
+	duration time.Duration
+	isValue  bool
+}
+
+type adaptiveHistogram struct {
+	scope Scope
+	name  string
+	opts  AdaptiveBucketOptions
+
+	// histogram holds the histogram once the buckets are selected.
+	histogram atomic.Value
+
+	mu      sync.Mutex
+	samples []adaptiveSample
+}
+
+func (h *adaptiveHistogram) RecordValue(value float64) {
+	if hist, ok := h.histogram.Load().(Histogram); ok {
+		hist.RecordValue(value)
+		return
+	}
+	h.record(adaptiveSample{value: value, isValue: true})
+}
+
+func (h *adaptiveHistogram) RecordDuration(value time.Duration) {
+	if hist, ok := h.histogram.Load().(Histogram); ok {
+		hist.RecordDuration(value)
+		return
+	}
+	h.record(adaptiveSample{duration: value})
+}
+
+// Buckets returns the selected buckets, or nil until they are selected.
+func (h *adaptiveHistogram) Buckets() Buckets {
+	if hist, ok := h.histogram.Load().(Histogram); ok {
+		return HistogramBuckets(hist)
+	}
+	return nil
+}
+
+func (h *adaptiveHistogram) Start() Stopwatch {
+	return NewStopwatch(globalNow(), h)
+}
+
+func (h *adaptiveHistogram) RecordStopwatch(stopwatchStart time.Time) {
+	h.RecordDuration(globalNow().Sub(stopwatchStart))
+}
+
+func (h *adaptiveHistogram) record(sample adaptiveSample) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	// The buckets may have been selected while waiting for the lock.
+	if hist, ok := h.histogram.Load().(Histogram); ok {
+		recordAdaptiveSample(hist, sample)
+		return
+	}
+
+	h.samples = append(h.samples, sample)
+	if len(h.samples) < h.opts.Samples {
+		return
+	}
+
+	hist := h.scope.Histogram(h.name, h.buckets())
+	for _, sample := range h.samples {
+		recordAdaptiveSample(hist, sample)
+	}
+	h.samples = nil
+	h.histogram.Store(hist)
+}
+
+// buckets selects the buckets from the buffered samples, must be called
+// with mu held.
+func (h *adaptiveHistogram) buckets() Buckets {
+	isValue := h.samples[0].isValue
+	values := make([]float64, len(h.samples))
+	for i, sample := range h.samples {
+		if sample.isValue {
+			values[i] = sample.value
+		} else {
+			values[i] = float64(sample.duration)
+		}
+	}
+	sort.Float64s(values)
+
+	bounds := adaptiveBounds(
+		quantileOfSorted(values, h.opts.LowerQuantile),
+		quantileOfSorted(values, h.opts.UpperQuantile),
+		h.opts.Count,
+	)
+	if isValue {
+		return ValueBuckets(bounds)
+	}
+
+	buckets := make(DurationBuckets, 0, len(bounds))
+	for _, b := range bounds {
+		d := time.Duration(math.Round(b))
+		if len(buckets) == 0 || d > buckets[len(buckets)-1] {
+			buckets = append(buckets, d)
+		}
+	}
+	return buckets
+}
+
+func recordAdaptiveSample(h Histogram, sample adaptiveSample) {
+	if sample.isValue {
+		h.RecordValue(sample.value)
+	} else {
+		h.RecordDuration(sample.duration)
+	}
+}
+
+// quantileOfSorted returns the q quantile of the sorted values using the
+// nearest rank.
+func quantileOfSorted(values []float64, q float64) float64 {
+	i := int(math.Ceil(q*float64(len(values)))) - 1
+	if i < 0 {
+		i = 0
+	}
+	if i >= len(values) {
+		i = len(values) - 1
+	}
+	return values[i]
+}
+
+// adaptiveBounds returns n increasing bucket bounds from lo to hi, spaced
+// logarithmically if lo is positive and linearly otherwise.
+func adaptiveBounds(lo, hi float64, n int) []float64 {
+	if hi <= lo || n == 1 {
+		return []float64{hi}
+	}
+
+	bounds := make([]float64, n)
+	if lo > 0 {
+		factor := math.Pow(hi/lo, 1/float64(n-1))
+		for i := range bounds {
+			bounds[i] = lo * math.Pow(factor, float64(i))
+		}
+	} else {
+		width := (hi - lo) / float64(n-1)
+		for i := range bounds {
+			bounds[i] = lo + float64(i)*width
+		}
+	}
+	// Avoid rounding errors on the upper bound.
+	bounds[n-1] = hi
+	return bounds
+}
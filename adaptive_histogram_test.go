@@ -0,0 +1,84 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tally
+
+import (
+	"math"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdaptiveHistogramValues(t *testing.T) {
+	s := NewTestScope("", nil)
+	h := NewAdaptiveHistogram(s, "size", AdaptiveBucketOptions{Samples: 100, Count: 5})
+
+	for i := 1; i < 100; i++ {
+		h.RecordValue(float64(i))
+	}
+	assert.Empty(t, s.Snapshot().Histograms())
+
+	h.RecordValue(100)
+	h.RecordValue(1000)
+
+	snap, ok := s.Snapshot().Histograms()["size+"]
+	require.True(t, ok)
+	values := snap.Values()
+
+	var (
+		bounds  []float64
+		samples []int64
+	)
+	for b := range values {
+		bounds = append(bounds, b)
+	}
+	sort.Float64s(bounds)
+	for _, b := range bounds {
+		samples = append(samples, values[b])
+	}
+	assert.InDeltaSlice(t, []float64{1, 3.1623, 10, 31.623, 100, math.MaxFloat64}, bounds, 1e-3)
+	assert.Equal(t, []int64{1, 2, 7, 21, 69, 1}, samples)
+}
+
+func TestAdaptiveHistogramDurations(t *testing.T) {
+	s := NewTestScope("", nil)
+	h := NewAdaptiveHistogram(s, "latency", AdaptiveBucketOptions{Samples: 3, Count: 3})
+
+	h.RecordDuration(time.Millisecond)
+	h.RecordDuration(10 * time.Millisecond)
+	h.RecordDuration(100 * time.Millisecond)
+
+	snap, ok := s.Snapshot().Histograms()["latency+"]
+	require.True(t, ok)
+	durations := snap.Durations()
+	assert.EqualValues(t, 1, durations[time.Millisecond])
+	assert.EqualValues(t, 1, durations[10*time.Millisecond])
+	assert.EqualValues(t, 1, durations[100*time.Millisecond])
+}
+
+func TestAdaptiveBounds(t *testing.T) {
+	assert.InDeltaSlice(t, []float64{1, 10, 100}, adaptiveBounds(1, 100, 3), 1e-9)
+	assert.Equal(t, []float64{-10, 0, 10}, adaptiveBounds(-10, 10, 3))
+	assert.Equal(t, []float64{5}, adaptiveBounds(5, 5, 3))
+}
@@ -0,0 +1,66 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tally
+
+// MetricAnnotator is implemented by reporters which export annotations of
+// metrics, such as the team owning a metric, its runbook URL or the
+// severity of its alerts.
+type MetricAnnotator interface {
+	// AnnotateMetric sets the annotations of the metric with the given
+	// fully qualified name.
+	AnnotateMetric(name string, annotations map[string]string)
+}
+
+// AnnotatedScope is a Scope which can attach annotations to its metrics.
+type AnnotatedScope interface {
+	Scope
+
+	// Annotate attaches annotations to the metric of the scope with the
+	// given name, they are passed to the scope's reporter if it is a
+	// MetricAnnotator. Reporters usually apply annotations when a metric
+	// is allocated, so metrics should be annotated before being created.
+	Annotate(name string, annotations map[string]string)
+}
+
+// Annotate calls s.Annotate(name, annotations) if s is an AnnotatedScope,
+// otherwise the annotations are discarded.
+func Annotate(s Scope, name string, annotations map[string]string) {
+	if as, ok := s.(AnnotatedScope); ok {
+		as.Annotate(name, annotations)
+	}
+}
+
+func (s *scope) Annotate(name string, annotations map[string]string) {
+	annotator, ok := s.baseReporter.(MetricAnnotator)
+	if !ok {
+		return
+	}
+
+	copied := make(map[string]string, len(annotations))
+	for k, v := range annotations {
+		copied[k] = v
+	}
+	annotator.AnnotateMetric(s.fullyQualifiedName(s.sanitizer.Name(name)), copied)
+}
+
+func (s *leveledScope) Annotate(name string, annotations map[string]string) {
+	s.scope.Annotate(name, annotations)
+}
@@ -0,0 +1,56 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tally
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type annotatingReporter struct {
+	nullStatsReporter
+	annotations map[string]map[string]string
+}
+
+func (r *annotatingReporter) AnnotateMetric(name string, annotations map[string]string) {
+	r.annotations[name] = annotations
+}
+
+func TestAnnotate(t *testing.T) {
+	r := &annotatingReporter{annotations: make(map[string]map[string]string)}
+	root, closer := NewRootScope(ScopeOptions{Prefix: "svc", Reporter: r}, 0)
+	defer closer.Close()
+
+	annotations := map[string]string{"team": "payments", "runbook": "https://runbooks/charges"}
+	Annotate(root.SubScope("charges"), "failed", annotations)
+	Annotate(AtLevel(root, DebugLevel), "retries", map[string]string{"severity": "low"})
+	annotations["team"] = "other"
+
+	assert.Equal(t, map[string]map[string]string{
+		"svc.charges.failed": {"team": "payments", "runbook": "https://runbooks/charges"},
+		"svc.retries":        {"severity": "low"},
+	}, r.annotations)
+
+	// Scopes and reporters without annotation support discard them.
+	Annotate(NoopScope, "failed", annotations)
+	Annotate(NewTestScope("", nil), "failed", annotations)
+}
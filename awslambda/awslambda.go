@@ -0,0 +1,256 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package awslambda reports metrics from AWS Lambda functions. The
+// execution environment of a function is frozen between invocations, which
+// pauses the reporting loop of a root scope and delays, or loses when the
+// environment is shut down, the metrics of an invocation. The root scope is
+// instead flushed at the end of each invocation, either by the handler
+// itself or by an extension before the environment is frozen.
+//
+// The package doesn't depend on the AWS Lambda Go SDK, Handler has the same
+// method set as its lambda.Handler so that either can be used in place of
+// the other.
+package awslambda
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+
+	tally "github.com/extrasalt/tally/v4"
+)
+
+const (
+	defaultExtensionName = "tally"
+
+	extensionNameHeader = "Lambda-Extension-Name"
+	extensionIDHeader   = "Lambda-Extension-Identifier"
+
+	invokeEvent   = "INVOKE"
+	shutdownEvent = "SHUTDOWN"
+)
+
+var errNoRuntimeAPI = errors.New("AWS_LAMBDA_RUNTIME_API is not set")
+
+// Handler handles an invocation of a function.
+type Handler interface {
+	Invoke(ctx context.Context, payload []byte) ([]byte, error)
+}
+
+// HandlerFunc is a function handling an invocation of a function.
+type HandlerFunc func(ctx context.Context, payload []byte) ([]byte, error)
+
+// Invoke calls f.
+func (f HandlerFunc) Invoke(ctx context.Context, payload []byte) ([]byte, error) {
+	return f(ctx, payload)
+}
+
+// Wrap returns a handler which flushes the root scope s once h returns,
+// before the response is returned to the runtime. The response is delayed
+// by the flush, an Extension flushes after the response is returned.
+func Wrap(s tally.Scope, h Handler) Handler {
+	return HandlerFunc(func(ctx context.Context, payload []byte) ([]byte, error) {
+		defer tally.Flush(s)
+		return h.Invoke(ctx, payload)
+	})
+}
+
+// ExtensionOptions is a set of options for an extension.
+type ExtensionOptions struct {
+	// Name is the name the extension registers with, it defaults to "tally".
+	Name string
+	// RuntimeAPI is the host and port of the Lambda runtime API, it
+	// defaults to the AWS_LAMBDA_RUNTIME_API environment variable.
+	RuntimeAPI string
+	// Client is the HTTP client of the extension, it defaults to
+	// http.DefaultClient.
+	Client *http.Client
+}
+
+// Extension is an internal extension, running in the process of the
+// function, which flushes a root scope after each invocation returns its
+// response and before the execution environment is frozen.
+type Extension struct {
+	scope  tally.Scope
+	name   string
+	api    string
+	client *http.Client
+	done   chan struct{}
+}
+
+// NewExtension returns an extension flushing the root scope s, its handler
+// must be wrapped with the extension's Wrap.
+func NewExtension(s tally.Scope, opts ExtensionOptions) *Extension {
+	if opts.Name == "" {
+		opts.Name = defaultExtensionName
+	}
+	if opts.RuntimeAPI == "" {
+		opts.RuntimeAPI = os.Getenv("AWS_LAMBDA_RUNTIME_API")
+	}
+	if opts.Client == nil {
+		opts.Client = http.DefaultClient
+	}
+
+	return &Extension{
+		scope:  s,
+		name:   opts.Name,
+		api:    opts.RuntimeAPI,
+		client: opts.Client,
+		// Invocations of an execution environment don't overlap, and the
+		// next one only starts once the extension is done with the
+		// current one.
+		done: make(chan struct{}, 1),
+	}
+}
+
+// Wrap returns a handler which notifies the extension once h returns.
+func (e *Extension) Wrap(h Handler) Handler {
+	return HandlerFunc(func(ctx context.Context, payload []byte) ([]byte, error) {
+		defer func() {
+			select {
+			case e.done <- struct{}{}:
+			default:
+			}
+		}()
+		return h.Invoke(ctx, payload)
+	})
+}
+
+// Run registers the extension then flushes the scope once each invocation
+// returns, until the execution environment shuts down or ctx is done. It
+// then closes the scope and returns the error from closing it.
+//
+// Run must be started before the function's runtime starts handling
+// invocations, since extensions are registered during the initialization
+// of the execution environment.
+func (e *Extension) Run(ctx context.Context) error {
+	if e.api == "" {
+		return errNoRuntimeAPI
+	}
+
+	id, err := e.register(ctx)
+	if err != nil {
+		return err
+	}
+
+	for {
+		event, err := e.next(ctx, id)
+		if err != nil {
+			if ctx.Err() != nil {
+				break
+			}
+			return err
+		}
+		if event != invokeEvent {
+			break
+		}
+
+		select {
+		case <-e.done:
+			if err := tally.Flush(e.scope); err != nil {
+				return err
+			}
+		case <-ctx.Done():
+		}
+	}
+
+	if closer, ok := e.scope.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+func (e *Extension) register(ctx context.Context) (string, error) {
+	body, err := json.Marshal(struct {
+		Events []string `json:"events"`
+	}{
+		// Internal extensions can't register for SHUTDOWN events.
+		Events: []string{invokeEvent},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost,
+		e.url("register"), bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set(extensionNameHeader, e.name)
+
+	resp, err := e.do(req)
+	if err != nil {
+		return "", fmt.Errorf("registering extension: %v", err)
+	}
+	resp.Body.Close()
+
+	id := resp.Header.Get(extensionIDHeader)
+	if id == "" {
+		return "", errors.New("registering extension: no extension identifier")
+	}
+	return id, nil
+}
+
+func (e *Extension) next(ctx context.Context, id string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet,
+		e.url("event/next"), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set(extensionIDHeader, id)
+
+	resp, err := e.do(req)
+	if err != nil {
+		return "", fmt.Errorf("waiting for next event: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var event struct {
+		EventType string `json:"eventType"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&event); err != nil {
+		return "", fmt.Errorf("decoding next event: %v", err)
+	}
+	return event.EventType, nil
+}
+
+func (e *Extension) do(req *http.Request) (*http.Response, error) {
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		resp.Body.Close()
+		return nil, fmt.Errorf("%s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	return resp, nil
+}
+
+func (e *Extension) url(path string) string {
+	return "http://" + e.api + "/2020-01-01/extension/" + path
+}
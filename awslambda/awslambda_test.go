@@ -0,0 +1,187 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package awslambda
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	tally "github.com/extrasalt/tally/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type flushCountingReporter struct {
+	counter int64
+	flushes int32
+	closes  int32
+}
+
+func (r *flushCountingReporter) ReportCounter(name string, tags map[string]string, value int64) {
+	atomic.AddInt64(&r.counter, value)
+}
+
+func (r *flushCountingReporter) ReportGauge(name string, tags map[string]string, value float64) {}
+
+func (r *flushCountingReporter) ReportTimer(name string, tags map[string]string, interval time.Duration) {
+}
+
+func (r *flushCountingReporter) ReportHistogramValueSamples(
+	name string,
+	tags map[string]string,
+	buckets tally.Buckets,
+	bucketLowerBound,
+	bucketUpperBound float64,
+	samples int64,
+) {
+}
+
+func (r *flushCountingReporter) ReportHistogramDurationSamples(
+	name string,
+	tags map[string]string,
+	buckets tally.Buckets,
+	bucketLowerBound,
+	bucketUpperBound time.Duration,
+	samples int64,
+) {
+}
+
+func (r *flushCountingReporter) Capabilities() tally.Capabilities { return r }
+func (r *flushCountingReporter) Reporting() bool                  { return true }
+func (r *flushCountingReporter) Tagging() bool                    { return true }
+func (r *flushCountingReporter) Flush()                           { atomic.AddInt32(&r.flushes, 1) }
+
+func (r *flushCountingReporter) Close() error {
+	atomic.AddInt32(&r.closes, 1)
+	return nil
+}
+
+func newTestScope(r *flushCountingReporter) tally.Scope {
+	s, _ := tally.NewRootScope(tally.ScopeOptions{
+		Reporter:      r,
+		MetricsOption: tally.OmitInternalMetrics,
+	}, time.Hour)
+	return s
+}
+
+func TestWrap(t *testing.T) {
+	r := &flushCountingReporter{}
+	s := newTestScope(r)
+	defer s.(interface{ Close() error }).Close()
+
+	h := Wrap(s, HandlerFunc(func(ctx context.Context, payload []byte) ([]byte, error) {
+		s.Counter("invocations").Inc(1)
+		return payload, nil
+	}))
+
+	resp, err := h.Invoke(context.Background(), []byte("ping"))
+	require.NoError(t, err)
+	assert.Equal(t, "ping", string(resp))
+	assert.EqualValues(t, 1, atomic.LoadInt64(&r.counter))
+	assert.EqualValues(t, 1, atomic.LoadInt32(&r.flushes))
+}
+
+func TestExtension(t *testing.T) {
+	r := &flushCountingReporter{}
+	s := newTestScope(r)
+
+	var (
+		events   = []string{invokeEvent, invokeEvent, shutdownEvent}
+		invoked  = make(chan struct{})
+		shutdown = make(chan struct{})
+		nexts    int32
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/2020-01-01/extension/register":
+			assert.Equal(t, "tally", req.Header.Get(extensionNameHeader))
+			w.Header().Set(extensionIDHeader, "id")
+		case "/2020-01-01/extension/event/next":
+			assert.Equal(t, "id", req.Header.Get(extensionIDHeader))
+			n := atomic.AddInt32(&nexts, 1)
+			event := events[n-1]
+			if event == shutdownEvent {
+				<-shutdown
+			}
+			w.Write([]byte(`{"eventType":"` + event + `"}`))
+			if event == invokeEvent {
+				invoked <- struct{}{}
+			}
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	e := NewExtension(s, ExtensionOptions{
+		RuntimeAPI: strings.TrimPrefix(srv.URL, "http://"),
+	})
+	h := e.Wrap(HandlerFunc(func(ctx context.Context, payload []byte) ([]byte, error) {
+		s.Counter("invocations").Inc(1)
+		return nil, nil
+	}))
+
+	done := make(chan error)
+	go func() {
+		done <- e.Run(context.Background())
+	}()
+
+	for i := 1; i <= 2; i++ {
+		<-invoked
+		_, err := h.Invoke(context.Background(), nil)
+		require.NoError(t, err)
+		require.Eventually(t, func() bool {
+			return atomic.LoadInt32(&r.flushes) == int32(i)
+		}, time.Second, time.Millisecond)
+		assert.EqualValues(t, i, atomic.LoadInt64(&r.counter))
+	}
+
+	close(shutdown)
+	require.NoError(t, <-done)
+	assert.EqualValues(t, 3, atomic.LoadInt32(&r.flushes))
+	assert.EqualValues(t, 1, atomic.LoadInt32(&r.closes))
+}
+
+func TestExtensionErrors(t *testing.T) {
+	s := newTestScope(&flushCountingReporter{})
+	defer s.(interface{ Close() error }).Close()
+
+	e := NewExtension(s, ExtensionOptions{})
+	e.api = ""
+	assert.Equal(t, errNoRuntimeAPI, e.Run(context.Background()))
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		http.Error(w, "bad name", http.StatusForbidden)
+	}))
+	defer srv.Close()
+
+	e = NewExtension(s, ExtensionOptions{
+		RuntimeAPI: strings.TrimPrefix(srv.URL, "http://"),
+	})
+	err := e.Run(context.Background())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "bad name")
+}
@@ -0,0 +1,102 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tally
+
+import (
+	"errors"
+	"time"
+)
+
+var errBackfillNotSupported = errors.New("scope has no timestamped reporter")
+
+// BackfillCounter reports a counter value observed at ts, for tools which
+// reconstruct metrics from logs or import them from other systems. The
+// counter is named and tagged like a counter of s with the given name and
+// tags would be.
+//
+// Backfilled values bypass the metrics of s: they are reported right away
+// and only to the reporters of s which are TimestampedStatsReporters, the
+// reporters of a multi reporter included. An error is returned if s has
+// none.
+func BackfillCounter(s Scope, name string, tags map[string]string, value int64, ts time.Time) error {
+	return backfill(s, name, tags, func(r TimestampedStatsReporter, name string, tags map[string]string) {
+		r.ReportCounterAt(name, tags, value, ts)
+	})
+}
+
+// BackfillGauge reports a gauge value observed at ts like BackfillCounter.
+func BackfillGauge(s Scope, name string, tags map[string]string, value float64, ts time.Time) error {
+	return backfill(s, name, tags, func(r TimestampedStatsReporter, name string, tags map[string]string) {
+		r.ReportGaugeAt(name, tags, value, ts)
+	})
+}
+
+// BackfillTimer reports a timer value observed at ts like BackfillCounter.
+func BackfillTimer(s Scope, name string, tags map[string]string, interval time.Duration, ts time.Time) error {
+	return backfill(s, name, tags, func(r TimestampedStatsReporter, name string, tags map[string]string) {
+		r.ReportTimerAt(name, tags, interval, ts)
+	})
+}
+
+func backfill(
+	s Scope,
+	name string,
+	tags map[string]string,
+	report func(r TimestampedStatsReporter, name string, tags map[string]string),
+) error {
+	var ts *scope
+	switch v := s.(type) {
+	case *scope:
+		ts = v
+	case *leveledScope:
+		ts = v.scope
+	default:
+		return errBackfillNotSupported
+	}
+
+	reporters := ts.registry.backfillReporters
+	if len(reporters) == 0 {
+		return errBackfillNotSupported
+	}
+
+	name = ts.fullyQualifiedName(ts.sanitizer.Name(name))
+	tags = mergeRightTags(ts.tags, ts.copyAndSanitizeMap(tags))
+	for _, r := range reporters {
+		report(r, name, tags)
+	}
+	return nil
+}
+
+// timestampedReporters returns the reporters of r which are
+// TimestampedStatsReporters, looking into multi reporters.
+func timestampedReporters(r StatsReporter) []TimestampedStatsReporter {
+	if m, ok := r.(*multiReporter); ok {
+		var reporters []TimestampedStatsReporter
+		for _, r := range m.reporters {
+			reporters = append(reporters, timestampedReporters(r)...)
+		}
+		return reporters
+	}
+	if tr, ok := r.(TimestampedStatsReporter); ok {
+		return []TimestampedStatsReporter{tr}
+	}
+	return nil
+}
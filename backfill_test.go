@@ -0,0 +1,65 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tally
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBackfill(t *testing.T) {
+	timestamped := newTimestampRecordingReporter()
+	plain := newTestStatsReporter()
+	root, closer := NewRootScope(ScopeOptions{
+		Reporter:      NewMultiReporter(timestamped, plain),
+		Prefix:        "svc",
+		Tags:          map[string]string{"env": "prod"},
+		MetricsOption: OmitInternalMetrics,
+	}, 0)
+	defer closer.Close()
+
+	ts := time.Unix(1e9, 0)
+	s := root.SubScope("imported")
+	require.NoError(t, BackfillCounter(s, "requests", map[string]string{"route": "/"}, 3, ts))
+	require.NoError(t, BackfillGauge(s, "queue", nil, 1, ts.Add(time.Second)))
+	require.NoError(t, BackfillTimer(s, "latency", nil, time.Millisecond, ts.Add(2*time.Second)))
+	assert.Equal(t, map[string]time.Time{
+		"svc.imported.requests+env=prod,route=/": ts,
+		"svc.imported.queue+env=prod":            ts.Add(time.Second),
+		"svc.imported.latency+env=prod":          ts.Add(2 * time.Second),
+	}, timestamped.timestamps)
+
+	// The backfilled values bypass the metrics of the scope and the
+	// reporters which don't accept timestamps.
+	assert.Empty(t, s.(*scope).Snapshot().Counters())
+	assert.Empty(t, plain.getCounters())
+}
+
+func TestBackfillNotSupported(t *testing.T) {
+	root, closer := NewRootScope(ScopeOptions{Reporter: NullStatsReporter}, 0)
+	defer closer.Close()
+
+	assert.Error(t, BackfillCounter(root, "requests", nil, 1, time.Now()))
+	assert.Error(t, BackfillGauge(NoopScope, "queue", nil, 1, time.Now()))
+}
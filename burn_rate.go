@@ -0,0 +1,255 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tally
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const defaultBurnRateResolution = 10 * time.Second
+
+var (
+	defaultBurnRateWindows = []time.Duration{
+		5 * time.Minute,
+		30 * time.Minute,
+		time.Hour,
+		6 * time.Hour,
+	}
+
+	errBurnRateObjective = errors.New("burn rate objective must be between 0 and 1")
+	errBurnRateWindow    = errors.New("burn rate windows must be positive")
+)
+
+// BurnRateOptions configures a BurnRate.
+type BurnRateOptions struct {
+	// Objective is the fraction of successful events targeted by the
+	// SLO, e.g. 0.999.
+	Objective float64
+
+	// Windows are the windows over which the burn rate is computed, they
+	// default to 5m, 30m, 1h and 6h.
+	Windows []time.Duration
+
+	// Resolution is the granularity at which events are bucketed and the
+	// interval at which the gauges are updated, it defaults to 10 seconds.
+	Resolution time.Duration
+}
+
+// BurnRate computes the error budget burn rate of an SLO client side from
+// the successful and total events recorded to it. The burn rate over a
+// window is the fraction of failed events in the window divided by the
+// error budget 1 - Objective, so a burn rate of 1 consumes exactly the
+// budget over the SLO period.
+//
+// The events are counted by the counters {name}_success and {name}_total,
+// and the burn rate of every window is emitted as the gauge
+// {name}_burn_rate tagged with window, e.g. window=5m, so that multi-window
+// alerts can be evaluated directly on the gauges.
+type BurnRate struct {
+	opts    BurnRateOptions
+	success Counter
+	total   Counter
+	gauges  []Gauge
+	stop    chan struct{}
+	stopped int32
+	wg      sync.WaitGroup
+
+	mu    sync.Mutex
+	slots []burnRateSlot
+}
+
+type burnRateSlot struct {
+	epoch   int64
+	success int64
+	total   int64
+}
+
+// NewBurnRate returns a BurnRate emitting its metrics on s. The gauges are
+// updated every opts.Resolution until the BurnRate or s is closed.
+func NewBurnRate(s Scope, name string, opts BurnRateOptions) (*BurnRate, error) {
+	if opts.Objective <= 0 || opts.Objective >= 1 {
+		return nil, errBurnRateObjective
+	}
+	if len(opts.Windows) == 0 {
+		opts.Windows = defaultBurnRateWindows
+	}
+	if opts.Resolution <= 0 {
+		opts.Resolution = defaultBurnRateResolution
+	}
+
+	var longest time.Duration
+	for _, window := range opts.Windows {
+		if window <= 0 {
+			return nil, errBurnRateWindow
+		}
+		if window > longest {
+			longest = window
+		}
+	}
+
+	b := &BurnRate{
+		opts:    opts,
+		success: s.Counter(name + "_success"),
+		total:   s.Counter(name + "_total"),
+		gauges:  make([]Gauge, 0, len(opts.Windows)),
+		stop:    make(chan struct{}),
+		slots:   make([]burnRateSlot, burnRateSlots(longest, opts.Resolution)),
+	}
+	for _, window := range opts.Windows {
+		tagged := s.Tagged(map[string]string{"window": formatBurnRateWindow(window)})
+		b.gauges = append(b.gauges, tagged.Gauge(name+"_burn_rate"))
+	}
+
+	var done <-chan struct{}
+	if sc, ok := s.(*scope); ok {
+		done = sc.done
+	}
+
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+		b.run(done)
+	}()
+
+	return b, nil
+}
+
+// Record records success successful events out of total events.
+func (b *BurnRate) Record(success, total int64) {
+	b.success.Inc(success)
+	b.total.Inc(total)
+	b.record(globalNow(), success, total)
+}
+
+// Close stops updating the gauges.
+func (b *BurnRate) Close() {
+	if atomic.CompareAndSwapInt32(&b.stopped, 0, 1) {
+		close(b.stop)
+	}
+	b.wg.Wait()
+}
+
+func (b *BurnRate) run(done <-chan struct{}) {
+	ticker := time.NewTicker(b.opts.Resolution)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			b.update(globalNow())
+		case <-b.stop:
+			return
+		case <-done:
+			return
+		}
+	}
+}
+
+func (b *BurnRate) record(now time.Time, success, total int64) {
+	epoch := b.epoch(now)
+	b.mu.Lock()
+	slot := b.slot(epoch)
+	slot.success += success
+	slot.total += total
+	b.mu.Unlock()
+}
+
+// update sets the gauges to the burn rates as of now.
+func (b *BurnRate) update(now time.Time) {
+	for i, window := range b.opts.Windows {
+		b.gauges[i].Update(b.burnRate(now, window))
+	}
+}
+
+func (b *BurnRate) burnRate(now time.Time, window time.Duration) float64 {
+	var (
+		epoch = b.epoch(now)
+		n     = burnRateSlots(window, b.opts.Resolution)
+
+		success, total int64
+	)
+
+	b.mu.Lock()
+	for e := epoch - int64(n) + 1; e <= epoch; e++ {
+		slot := &b.slots[b.index(e)]
+		if slot.epoch != e {
+			continue
+		}
+		success += slot.success
+		total += slot.total
+	}
+	b.mu.Unlock()
+
+	if total <= 0 {
+		return 0
+	}
+	errorRate := float64(total-success) / float64(total)
+	return errorRate / (1 - b.opts.Objective)
+}
+
+func (b *BurnRate) epoch(now time.Time) int64 {
+	return now.UnixNano() / int64(b.opts.Resolution)
+}
+
+func (b *BurnRate) index(epoch int64) int {
+	i := int(epoch % int64(len(b.slots)))
+	if i < 0 {
+		i += len(b.slots)
+	}
+	return i
+}
+
+// slot returns the slot of epoch, resetting it if it held an older epoch.
+// It must be called with mu held.
+func (b *BurnRate) slot(epoch int64) *burnRateSlot {
+	slot := &b.slots[b.index(epoch)]
+	if slot.epoch != epoch {
+		*slot = burnRateSlot{epoch: epoch}
+	}
+	return slot
+}
+
+// burnRateSlots returns the number of slots of the given resolution
+// covering window.
+func burnRateSlots(window, resolution time.Duration) int {
+	n := int((window + resolution - 1) / resolution)
+	if n < 1 {
+		n = 1
+	}
+	return n
+}
+
+// formatBurnRateWindow formats a window without its zero trailing units,
+// e.g. 5m rather than 5m0s.
+func formatBurnRateWindow(window time.Duration) string {
+	s := window.String()
+	if strings.HasSuffix(s, "m0s") {
+		s = strings.TrimSuffix(s, "0s")
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = strings.TrimSuffix(s, "0m")
+	}
+	return s
+}
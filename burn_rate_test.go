@@ -0,0 +1,87 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tally
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBurnRate(t *testing.T) {
+	s := NewTestScope("", nil)
+	b, err := NewBurnRate(s, "api", BurnRateOptions{
+		Objective:  0.99,
+		Windows:    []time.Duration{5 * time.Minute, time.Hour},
+		Resolution: time.Minute,
+	})
+	require.NoError(t, err)
+	defer b.Close()
+
+	start := time.Unix(0, 0)
+	// 10% errors 30 minutes ago, 2% errors in the last 5 minutes.
+	b.record(start, 90, 100)
+	now := start.Add(30 * time.Minute)
+	b.record(now.Add(-2*time.Minute), 98, 100)
+	b.update(now)
+
+	gauges := s.Snapshot().Gauges()
+	assert.InDelta(t, 2, gauges["api_burn_rate+window=5m"].Value(), 1e-9)
+	assert.InDelta(t, 6, gauges["api_burn_rate+window=1h"].Value(), 1e-9)
+
+	// The events age out of the windows.
+	b.update(now.Add(2 * time.Hour))
+	gauges = s.Snapshot().Gauges()
+	assert.Equal(t, float64(0), gauges["api_burn_rate+window=5m"].Value())
+	assert.Equal(t, float64(0), gauges["api_burn_rate+window=1h"].Value())
+
+	b.Record(9, 10)
+	counters := s.Snapshot().Counters()
+	assert.Equal(t, int64(9), counters["api_success+"].Value())
+	assert.Equal(t, int64(10), counters["api_total+"].Value())
+}
+
+func TestBurnRateOptions(t *testing.T) {
+	s := NewTestScope("", nil)
+
+	_, err := NewBurnRate(s, "api", BurnRateOptions{Objective: 1})
+	assert.Equal(t, errBurnRateObjective, err)
+
+	_, err = NewBurnRate(s, "api", BurnRateOptions{
+		Objective: 0.9,
+		Windows:   []time.Duration{-time.Minute},
+	})
+	assert.Equal(t, errBurnRateWindow, err)
+
+	b, err := NewBurnRate(s, "api", BurnRateOptions{Objective: 0.9})
+	require.NoError(t, err)
+	b.Close()
+	assert.Len(t, b.slots, 6*60*6)
+}
+
+func TestFormatBurnRateWindow(t *testing.T) {
+	assert.Equal(t, "5m", formatBurnRateWindow(5*time.Minute))
+	assert.Equal(t, "1h", formatBurnRateWindow(time.Hour))
+	assert.Equal(t, "1h30m", formatBurnRateWindow(90*time.Minute))
+	assert.Equal(t, "30s", formatBurnRateWindow(30*time.Second))
+}
@@ -0,0 +1,152 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+//go:build cgo
+// +build cgo
+
+package capi
+
+// #include <stdint.h>
+// #include <string.h>
+import "C"
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+	"unsafe"
+
+	tally "github.com/extrasalt/tally/v4"
+)
+
+var bound atomic.Value // *binding
+
+// binding is a scope bound to the C API and its metrics cached by name.
+type binding struct {
+	scope tally.Scope
+
+	mu       sync.RWMutex
+	counters map[string]tally.Counter
+	gauges   map[string]tally.Gauge
+	timers   map[string]tally.Timer
+}
+
+// Bind binds scope to the C API, replacing any previously bound scope.
+// Passing nil unbinds the current scope.
+func Bind(scope tally.Scope) {
+	if scope == nil {
+		scope = tally.NoopScope
+	}
+	bound.Store(&binding{
+		scope:    scope,
+		counters: make(map[string]tally.Counter),
+		gauges:   make(map[string]tally.Gauge),
+		timers:   make(map[string]tally.Timer),
+	})
+}
+
+func currentBinding() *binding {
+	b, _ := bound.Load().(*binding)
+	return b
+}
+
+//export tally_counter_inc
+func tally_counter_inc(name *C.char, delta C.int64_t) {
+	counterInc(cBytes(name), int64(delta))
+}
+
+//export tally_gauge_update
+func tally_gauge_update(name *C.char, value C.double) {
+	gaugeUpdate(cBytes(name), float64(value))
+}
+
+//export tally_timer_record
+func tally_timer_record(name *C.char, nanos C.int64_t) {
+	timerRecord(cBytes(name), time.Duration(nanos))
+}
+
+// cBytes returns the bytes of a NUL terminated C string without copying.
+func cBytes(s *C.char) []byte {
+	if s == nil {
+		return nil
+	}
+	n := int(C.strlen(s))
+	return (*[1 << 30]byte)(unsafe.Pointer(s))[:n:n]
+}
+
+func counterInc(name []byte, delta int64) {
+	b := currentBinding()
+	if b == nil {
+		return
+	}
+
+	b.mu.RLock()
+	c, ok := b.counters[string(name)]
+	b.mu.RUnlock()
+	if !ok {
+		b.mu.Lock()
+		if c, ok = b.counters[string(name)]; !ok {
+			c = b.scope.Counter(string(name))
+			b.counters[string(name)] = c
+		}
+		b.mu.Unlock()
+	}
+	c.Inc(delta)
+}
+
+func gaugeUpdate(name []byte, value float64) {
+	b := currentBinding()
+	if b == nil {
+		return
+	}
+
+	b.mu.RLock()
+	g, ok := b.gauges[string(name)]
+	b.mu.RUnlock()
+	if !ok {
+		b.mu.Lock()
+		if g, ok = b.gauges[string(name)]; !ok {
+			g = b.scope.Gauge(string(name))
+			b.gauges[string(name)] = g
+		}
+		b.mu.Unlock()
+	}
+	g.Update(value)
+}
+
+func timerRecord(name []byte, d time.Duration) {
+	b := currentBinding()
+	if b == nil {
+		return
+	}
+
+	b.mu.RLock()
+	t, ok := b.timers[string(name)]
+	b.mu.RUnlock()
+	if !ok {
+		b.mu.Lock()
+		if t, ok = b.timers[string(name)]; !ok {
+			t = b.scope.Timer(string(name))
+			b.timers[string(name)] = t
+		}
+		b.mu.Unlock()
+	}
+	t.Record(d)
+}
@@ -0,0 +1,66 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+//go:build cgo
+// +build cgo
+
+package capi
+
+import (
+	"testing"
+	"time"
+
+	tally "github.com/extrasalt/tally/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBind(t *testing.T) {
+	defer Bind(nil)
+
+	// Calls are discarded until a scope is bound.
+	counterInc([]byte("requests"), 1)
+
+	scope := tally.NewTestScope("native", nil)
+	Bind(scope)
+	counterInc([]byte("requests"), 1)
+	counterInc([]byte("requests"), 2)
+	gaugeUpdate([]byte("queue"), 3)
+	timerRecord([]byte("decode"), time.Millisecond)
+
+	snap := scope.Snapshot()
+	assert.EqualValues(t, 3, snap.Counters()["native.requests+"].Value())
+	assert.EqualValues(t, 3, snap.Gauges()["native.queue+"].Value())
+	assert.Equal(t, []time.Duration{time.Millisecond}, snap.Timers()["native.decode+"].Values())
+
+	Bind(nil)
+	counterInc([]byte("requests"), 1)
+	assert.EqualValues(t, 3, scope.Snapshot().Counters()["native.requests+"].Value())
+}
+
+func TestCounterIncDoesNotAllocate(t *testing.T) {
+	defer Bind(nil)
+	Bind(tally.NewTestScope("", nil))
+
+	name := []byte("requests")
+	counterInc(name, 1)
+	assert.Zero(t, testing.AllocsPerRun(100, func() {
+		counterInc(name, 1)
+	}))
+}
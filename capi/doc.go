@@ -0,0 +1,35 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package capi exports a minimal C API to emit metrics through a Go root
+// scope, so that C and C++ components embedded in a Go binary with cgo use
+// the same metrics pipeline as the Go code.
+//
+// The Go program binds a scope with Bind, C code then calls the functions
+// declared in the cgo generated header:
+//
+//	void tally_counter_inc(char* name, int64_t delta);
+//	void tally_gauge_update(char* name, double value);
+//	void tally_timer_record(char* name, int64_t nanos);
+//
+// Names are NUL terminated and copied on first use, metrics are cached by
+// name so that repeated calls don't allocate. Calls made while no scope is
+// bound are discarded.
+package capi
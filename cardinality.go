@@ -0,0 +1,233 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tally
+
+import (
+	"sync"
+
+	"go.uber.org/atomic"
+)
+
+// overflowTagKey is the tag of the series metrics overflowing the tag
+// cardinality limit are routed to.
+const overflowTagKey = "overflow"
+
+// seriesKind is the kind of metric of a series, the kinds of the metrics
+// sharing a name and tags are tracked so that the series is only released
+// once all of them are removed.
+type seriesKind uint16
+
+const (
+	counterSeries seriesKind = 1 << iota
+	floatCounterSeries
+	rateSeries
+	gaugeSeries
+	setSeries
+	timerSeries
+	histogramSeries
+	gaugeHistogramSeries
+	summarySeries
+)
+
+// tagCardinality caps the distinct sets of tags each metric name is
+// created with.
+type tagCardinality struct {
+	max int
+	// overflowKey is the key of the tags of the overflow scopes, which are
+	// always admitted.
+	overflowKey string
+
+	mu sync.Mutex
+	// series are the kinds of the metrics admitted by name and tags key.
+	series map[string]map[string]seriesKind
+	// rejected are the tags keys routed to overflow scopes by name, so
+	// that each is counted once.
+	rejected map[string]map[string]struct{}
+	// Series routed to overflow scopes since the last report.
+	overflows atomic.Int64
+	// releases is incremented whenever a series is released, so that
+	// scopes recheck the names they overflowed.
+	releases atomic.Int64
+}
+
+func newTagCardinality(max int, root *scope) *tagCardinality {
+	if max <= 0 {
+		return nil
+	}
+	overflowTags := root.copyAndSanitizeMap(map[string]string{overflowTagKey: "true"})
+	return &tagCardinality{
+		max:         max,
+		overflowKey: KeyForStringMap(mergeRightTags(root.tags, overflowTags)),
+		series:      make(map[string]map[string]seriesKind),
+		rejected:    make(map[string]map[string]struct{}),
+	}
+}
+
+// admit returns whether the metric of the given kind with the given fully
+// qualified name may be created with the tags of the given key.
+func (c *tagCardinality) admit(kind seriesKind, name, tagsKey string) bool {
+	if tagsKey == c.overflowKey {
+		return true
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	series, ok := c.series[name]
+	if !ok {
+		series = make(map[string]seriesKind)
+		c.series[name] = series
+	}
+	if kinds, ok := series[tagsKey]; ok {
+		series[tagsKey] = kinds | kind
+		return true
+	}
+	if len(series) >= c.max {
+		rejected, ok := c.rejected[name]
+		if !ok {
+			rejected = make(map[string]struct{})
+			c.rejected[name] = rejected
+		}
+		if _, ok := rejected[tagsKey]; !ok {
+			rejected[tagsKey] = struct{}{}
+			c.overflows.Inc()
+		}
+		return false
+	}
+	series[tagsKey] = kind
+	c.forgetLocked(name, tagsKey)
+	return true
+}
+
+// release releases the series of the metric of the given kind, once the
+// metrics of every kind of the series are released it no longer counts
+// towards the limit.
+func (c *tagCardinality) release(kind seriesKind, name, tagsKey string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	series := c.series[name]
+	kinds, ok := series[tagsKey]
+	if !ok {
+		return
+	}
+	if kinds &^= kind; kinds != 0 {
+		series[tagsKey] = kinds
+		return
+	}
+	delete(series, tagsKey)
+	if len(series) == 0 {
+		delete(c.series, name)
+	}
+	c.releases.Inc()
+}
+
+// forget forgets that the series was routed to an overflow scope.
+func (c *tagCardinality) forget(name, tagsKey string) {
+	c.mu.Lock()
+	c.forgetLocked(name, tagsKey)
+	c.mu.Unlock()
+}
+
+func (c *tagCardinality) forgetLocked(name, tagsKey string) {
+	rejected, ok := c.rejected[name]
+	if !ok {
+		return
+	}
+	delete(rejected, tagsKey)
+	if len(rejected) == 0 {
+		delete(c.rejected, name)
+	}
+}
+
+// overflowScope returns the scope the metric of the given kind with the
+// given sanitized name is created from rather than s, if creating it from
+// s would exceed the tag cardinality limit. The names routed to the
+// overflow scope are cached by s until a series is released.
+func (s *scope) overflowScope(kind seriesKind, name string) (*scope, bool) {
+	c := s.registry.cardinality
+	if c == nil {
+		return nil, false
+	}
+	releases := c.releases.Load()
+	if cached, ok := s.overflowed.Load(name); !ok || cached.(int64) != releases {
+		if c.admit(kind, s.fullyQualifiedName(name), KeyForStringMap(s.tags)) {
+			s.overflowed.Delete(name)
+			return nil, false
+		}
+		s.overflowed.Store(name, releases)
+	}
+	return s.registry.Subscope(s.registry.root, s.prefix, map[string]string{overflowTagKey: "true"}), true
+}
+
+// releaseSeries releases the series of the metric of the given kind with
+// the given sanitized name from the tag cardinality limit.
+func (s *scope) releaseSeries(kind seriesKind, name string) {
+	if c := s.registry.cardinality; c != nil {
+		c.release(kind, s.fullyQualifiedName(name), KeyForStringMap(s.tags))
+	}
+}
+
+// releaseAllSeries releases the series of every metric of the scope, and
+// forgets the names it routed to overflow scopes, as the scope is closed.
+// It must be called with the metric locks of the scope held.
+func (s *scope) releaseAllSeries() {
+	c := s.registry.cardinality
+	if c == nil {
+		return
+	}
+	tagsKey := KeyForStringMap(s.tags)
+	release := func(kind seriesKind, name string) {
+		c.release(kind, s.fullyQualifiedName(name), tagsKey)
+	}
+	for name := range s.counters {
+		release(counterSeries, name)
+	}
+	for name := range s.floatCounters {
+		release(floatCounterSeries, name)
+	}
+	for name := range s.rates {
+		release(rateSeries, name)
+	}
+	for name := range s.gauges {
+		release(gaugeSeries, name)
+	}
+	for name := range s.sets {
+		release(setSeries, name)
+	}
+	for name := range s.timers {
+		release(timerSeries, name)
+	}
+	for name := range s.histograms {
+		release(histogramSeries, name)
+	}
+	for name := range s.gaugeHistograms {
+		release(gaugeHistogramSeries, name)
+	}
+	for name := range s.summaries {
+		release(summarySeries, name)
+	}
+	s.overflowed.Range(func(name, _ interface{}) bool {
+		c.forget(s.fullyQualifiedName(name.(string)), tagsKey)
+		s.overflowed.Delete(name)
+		return true
+	})
+}
@@ -0,0 +1,117 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tally
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMaxTagCardinality(t *testing.T) {
+	r := &valueRecordingReporter{
+		counters:   make(map[string]int64),
+		histograms: make(map[string]int64),
+	}
+	root, closer := NewRootScope(ScopeOptions{
+		Prefix:            "svc",
+		Tags:              map[string]string{"service": "api"},
+		Reporter:          r,
+		MetricsOption:     SendInternalMetrics,
+		MaxTagCardinality: 2,
+	}, 0)
+	defer closer.Close()
+	s := root.(*scope)
+
+	for i := 0; i < 5; i++ {
+		root.Tagged(map[string]string{"user": strconv.Itoa(i)}).Counter("requests").Inc(1)
+	}
+	// Metrics already created and other names aren't capped.
+	root.Tagged(map[string]string{"user": "0"}).Counter("requests").Inc(1)
+	root.Tagged(map[string]string{"user": "4"}).Gauge("sessions").Update(1)
+	root.Tagged(map[string]string{"user": "4"}).Histogram("size", ValueBuckets{1}).RecordValue(1)
+	root.Tagged(map[string]string{"user": "4"}).Timer("latency").Record(1)
+
+	counters := s.Snapshot().Counters()
+	assert.EqualValues(t, 2, counters["svc.requests+service=api,user=0"].Value())
+	assert.EqualValues(t, 1, counters["svc.requests+service=api,user=1"].Value())
+	assert.NotContains(t, counters, "svc.requests+service=api,user=2")
+	assert.EqualValues(t, 3, counters["svc.requests+overflow=true,service=api"].Value())
+	assert.Contains(t, s.Snapshot().Gauges(), "svc.sessions+service=api,user=4")
+
+	s.reportRegistry()
+	assert.EqualValues(t, 3, r.counters[cardinalityOverflowsName])
+}
+
+func TestMaxTagCardinalityOverflowCountedOnce(t *testing.T) {
+	r := &valueRecordingReporter{
+		counters:   make(map[string]int64),
+		histograms: make(map[string]int64),
+	}
+	root, closer := NewRootScope(ScopeOptions{
+		Reporter:          r,
+		MetricsOption:     SendInternalMetrics,
+		MaxTagCardinality: 1,
+	}, 0)
+	defer closer.Close()
+
+	root.Tagged(map[string]string{"user": "0"}).Counter("requests").Inc(1)
+	overflowed := root.Tagged(map[string]string{"user": "1"})
+	for i := 0; i < 3; i++ {
+		overflowed.Counter("requests").Inc(1)
+	}
+
+	assert.EqualValues(t, 3, root.(TestScope).Snapshot().Counters()["requests+overflow=true"].Value())
+	root.(*scope).reportRegistry()
+	assert.EqualValues(t, 1, r.counters[cardinalityOverflowsName])
+}
+
+func TestMaxTagCardinalityRelease(t *testing.T) {
+	root, closer := NewRootScope(ScopeOptions{
+		Reporter:          NullStatsReporter,
+		MaxTagCardinality: 1,
+	}, 0)
+	defer closer.Close()
+	s := root.(*scope)
+	tagged := func(user string) *scope {
+		return root.Tagged(map[string]string{"user": user}).(*scope)
+	}
+
+	tagged("0").Counter("requests").Inc(1)
+	tagged("1").Counter("requests").Inc(1)
+	assert.NotContains(t, s.Snapshot().Counters(), "requests+user=1")
+
+	// A removed metric releases its series, the metrics which overflowed
+	// are then admitted.
+	assert.True(t, RemoveCounter(tagged("0"), "requests"))
+	tagged("1").Counter("requests").Inc(1)
+	assert.Contains(t, s.Snapshot().Counters(), "requests+user=1")
+
+	// So does a metric of a closed scope, once the scope is reported.
+	tagged("2").Gauge("requests").Update(1)
+	assert.NotContains(t, s.Snapshot().Gauges(), "requests+user=2")
+	assert.True(t, RemoveTagged(root, map[string]string{"user": "1"}))
+	s.reportRegistry()
+	tagged("2").Gauge("requests").Update(1)
+	assert.Contains(t, s.Snapshot().Gauges(), "requests+user=2")
+	assert.Empty(t, s.registry.cardinality.rejected)
+}
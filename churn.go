@@ -0,0 +1,158 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tally
+
+import (
+	"sort"
+	"sync"
+)
+
+const (
+	seriesCreatedName = "tally_internal_series_created"
+	seriesEvictedName = "tally_internal_series_evicted"
+
+	// seriesChurnTagKey is the tag of the series churn internal metrics
+	// holding the tag key the churn is attributed to.
+	seriesChurnTagKey = "tag_key"
+)
+
+// SeriesChurnOptions configures tracking the series created and evicted
+// by the registry per tag key, to find which tag churns the series of the
+// backend, e.g. a tag holding request IDs. A series is attributed to every
+// tag of its scope but the tags of the root scope, which all series share.
+//
+// With SendInternalMetrics the series created and evicted since the
+// previous report are reported by the counters
+// tally_internal_series_created and tally_internal_series_evicted, tagged
+// with the tag key as tag_key.
+type SeriesChurnOptions struct {
+	// Threshold is the number of series created and evicted for a tag key
+	// between two reports above which OnChurn is called.
+	Threshold int64
+
+	// OnChurn if set is called on report with the churn of every tag key
+	// above the threshold since the previous report, in tag key order. It
+	// is called by the reporting goroutine and must not block.
+	OnChurn func(SeriesChurn)
+}
+
+// SeriesChurn is the number of series created and evicted for a tag key
+// between two reports.
+type SeriesChurn struct {
+	TagKey  string
+	Created int64
+	Evicted int64
+}
+
+// seriesChurn counts the series created and evicted per tag key.
+type seriesChurn struct {
+	opts     SeriesChurnOptions
+	rootTags map[string]string
+
+	mu      sync.Mutex
+	created map[string]int64
+	evicted map[string]int64
+}
+
+func newSeriesChurn(opts *SeriesChurnOptions, root *scope) *seriesChurn {
+	if opts == nil {
+		return nil
+	}
+	return &seriesChurn{
+		opts:     *opts,
+		rootTags: root.tags,
+		created:  make(map[string]int64),
+		evicted:  make(map[string]int64),
+	}
+}
+
+// record records that n series with the given tags were created, or
+// evicted if evicted is set.
+func (c *seriesChurn) record(tags map[string]string, n int, evicted bool) {
+	if c == nil || n == 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	counts := c.created
+	if evicted {
+		counts = c.evicted
+	}
+	for k := range tags {
+		if _, ok := c.rootTags[k]; !ok {
+			counts[k] += int64(n)
+		}
+	}
+}
+
+// swap returns the churn of every tag key since the previous swap, in tag
+// key order.
+func (c *seriesChurn) swap() []SeriesChurn {
+	c.mu.Lock()
+	created, evicted := c.created, c.evicted
+	c.created, c.evicted = make(map[string]int64), make(map[string]int64)
+	c.mu.Unlock()
+
+	churn := make([]SeriesChurn, 0, len(created)+len(evicted))
+	for k, n := range created {
+		churn = append(churn, SeriesChurn{TagKey: k, Created: n, Evicted: evicted[k]})
+	}
+	for k, n := range evicted {
+		if _, ok := created[k]; !ok {
+			churn = append(churn, SeriesChurn{TagKey: k, Evicted: n})
+		}
+	}
+	sort.Slice(churn, func(i, j int) bool { return churn[i].TagKey < churn[j].TagKey })
+	return churn
+}
+
+// reportSeriesChurn reports the series churn since the previous report, and
+// calls the churn callback for the tag keys above the threshold.
+func (r *scopeRegistry) reportSeriesChurn() {
+	c := r.churn
+	if c == nil {
+		return
+	}
+
+	for _, churn := range c.swap() {
+		if c.opts.OnChurn != nil && churn.Created+churn.Evicted > c.opts.Threshold {
+			c.opts.OnChurn(churn)
+		}
+		if r.internalMetricsOption != SendInternalMetrics {
+			continue
+		}
+
+		tags := r.root.copyAndSanitizeMap(map[string]string{seriesChurnTagKey: churn.TagKey})
+		tags = mergeRightTags(internalTags, tags)
+		r.reportInternalCounterWithTags(seriesCreatedName, tags, churn.Created)
+		r.reportInternalCounterWithTags(seriesEvictedName, tags, churn.Evicted)
+	}
+}
+
+// seriesCount returns the number of series of the scope, it must be called
+// with every metric lock held.
+func (s *scope) seriesCount() int {
+	return len(s.counters) + len(s.floatCounters) + len(s.rates) + len(s.gauges) +
+		len(s.sets) + len(s.histograms) + len(s.gaugeHistograms) + len(s.summaries) +
+		len(s.timers)
+}
@@ -0,0 +1,76 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tally
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type churnRecordingReporter struct {
+	nullStatsReporter
+	counters map[string]int64
+}
+
+func (r *churnRecordingReporter) ReportCounter(name string, tags map[string]string, value int64) {
+	r.counters[KeyForPrefixedStringMap(name, tags)] += value
+}
+
+func TestSeriesChurn(t *testing.T) {
+	var churn []SeriesChurn
+	r := &churnRecordingReporter{counters: make(map[string]int64)}
+	root, closer := NewRootScope(ScopeOptions{
+		Tags:          map[string]string{"service": "api"},
+		Reporter:      r,
+		MetricsOption: SendInternalMetrics,
+		SeriesChurn: &SeriesChurnOptions{
+			Threshold: 3,
+			OnChurn:   func(c SeriesChurn) { churn = append(churn, c) },
+		},
+	}, 0)
+	defer closer.Close()
+	s := root.(*scope)
+
+	for i := 0; i < 3; i++ {
+		root.Tagged(map[string]string{"request": strconv.Itoa(i), "route": "/"}).Counter("requests").Inc(1)
+	}
+	root.Tagged(map[string]string{"route": "/"}).Gauge("in_flight").Update(1)
+	// Series without tags of their own aren't attributed to root tags.
+	root.Counter("total").Inc(1)
+	s.reportRegistry()
+
+	assert.Equal(t, []SeriesChurn{{TagKey: "route", Created: 4}}, churn)
+	created := KeyForPrefixedStringMap(seriesCreatedName, map[string]string{"version": Version, "tag_key": "route"})
+	assert.EqualValues(t, 4, r.counters[created])
+
+	// Churn is counted per report, the series of removed scopes are
+	// evicted once reported for the last time.
+	churn = nil
+	assert.True(t, RemoveTagged(root, map[string]string{"request": "0", "route": "/"}))
+	s.reportRegistry()
+	s.reportRegistry()
+	assert.Empty(t, churn)
+	assert.EqualValues(t, 4, r.counters[created])
+	evicted := KeyForPrefixedStringMap(seriesEvictedName, map[string]string{"version": Version, "tag_key": "request"})
+	assert.EqualValues(t, 1, r.counters[evicted])
+}
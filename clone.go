@@ -0,0 +1,96 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tally
+
+// CloneForTest returns a test scope reproducing the tree of scopes s
+// belongs to: the prefixes and tags of its root and subscopes, and the
+// names and buckets of their metrics, all without values. The clone has
+// its own registry, no reporter and no report loop, so that tests can
+// exercise instrumentation built around s without reporting to the
+// reporter s was created with. The scope returned is the clone of s.
+//
+// Scopes not created by this package are cloned as an empty test scope.
+func CloneForTest(s Scope) TestScope {
+	var src *scope
+	switch v := s.(type) {
+	case *scope:
+		src = v
+	case *leveledScope:
+		src = v.scope
+	default:
+		return NewTestScope("", nil)
+	}
+
+	root := src.registry.root
+	clone := newRootScope(ScopeOptions{
+		Prefix:         root.prefix,
+		Tags:           root.tags,
+		Separator:      root.separator,
+		DefaultBuckets: root.defaultBuckets,
+		MetricsOption:  OmitInternalMetrics,
+	}, 0)
+
+	var cloneOfSrc *scope
+	root.registry.forEachUniqueScope(func(ss *scope, tags map[string]string) bool {
+		cs := clone
+		if ss != root {
+			cs = clone.registry.Subscope(clone, ss.prefix, tags)
+		}
+		if ss == src {
+			cloneOfSrc = cs
+		}
+		cloneMetrics(ss, cs)
+		return true
+	})
+	if cloneOfSrc == nil {
+		// NB: s was closed and removed from its registry after it was
+		// obtained, clone it without its metrics.
+		cloneOfSrc = clone.registry.Subscope(clone, src.prefix, src.tags)
+	}
+	return cloneOfSrc
+}
+
+// cloneMetrics creates the metrics of src in dst.
+func cloneMetrics(src, dst *scope) {
+	src.cm.RLock()
+	for name := range src.counters {
+		dst.Counter(name)
+	}
+	src.cm.RUnlock()
+
+	src.gm.RLock()
+	for name := range src.gauges {
+		dst.Gauge(name)
+	}
+	src.gm.RUnlock()
+
+	src.tm.RLock()
+	for name := range src.timers {
+		dst.Timer(name)
+	}
+	src.tm.RUnlock()
+
+	src.hm.RLock()
+	for name, h := range src.histograms {
+		dst.Histogram(name, h.specification)
+	}
+	src.hm.RUnlock()
+}
@@ -0,0 +1,76 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tally
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCloneForTest(t *testing.T) {
+	r := newTestStatsReporter()
+	root, closer := NewRootScope(ScopeOptions{
+		Prefix:        "svc",
+		Tags:          map[string]string{"env": "prod"},
+		Reporter:      r,
+		MetricsOption: OmitInternalMetrics,
+	}, 0)
+	defer closer.Close()
+
+	root.Counter("requests")
+	db := root.SubScope("db").Tagged(map[string]string{"shard": "1"})
+	db.Gauge("connections")
+	db.Timer("query")
+	db.Histogram("rows", ValueBuckets{10, 100})
+
+	clone := CloneForTest(db)
+	clone.Counter("errors").Inc(1)
+	clone.Histogram("rows", nil).RecordValue(50)
+
+	snap := clone.Snapshot()
+	assert.Contains(t, snap.Counters(), "svc.requests+env=prod")
+	assert.Contains(t, snap.Counters(), "svc.db.errors+env=prod,shard=1")
+	assert.Contains(t, snap.Gauges(), "svc.db.connections+env=prod,shard=1")
+	assert.Contains(t, snap.Timers(), "svc.db.query+env=prod,shard=1")
+	rows, ok := snap.Histograms()["svc.db.rows+env=prod,shard=1"]
+	require.True(t, ok)
+	assert.EqualValues(t, 1, rows.Values()[100])
+
+	// The clone doesn't report to the reporter of the cloned scope.
+	root.(*scope).reportRegistry()
+	assert.Empty(t, r.getCounters())
+	assert.NotContains(t, root.(TestScope).Snapshot().Counters(), "svc.db.errors+env=prod,shard=1")
+}
+
+func TestCloneForTestLeveled(t *testing.T) {
+	root := NewTestScope("", nil)
+	leveled := AtLevel(root.SubScope("sub"), DebugLevel)
+	leveled.Timer("t")
+
+	clone := CloneForTest(leveled)
+	clone.Timer("t").Record(time.Second)
+	assert.Len(t, clone.Snapshot().Timers()["sub.t+"].Values(), 1)
+
+	assert.Empty(t, CloneForTest(struct{ Scope }{root}).Snapshot().Counters())
+}
@@ -0,0 +1,234 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tally
+
+import (
+	"context"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ContextStatsReporter is a backend for Scopes to report metrics to whose
+// methods take the context of the report, which allows exporters to honor
+// cancellation and deadlines and to propagate traces. It is used in place
+// of a StatsReporter when set as the ContextReporter of a root scope.
+//
+// The context of a report is the one given to FlushContext or
+// RunReportLoop, or one with the reporting interval as timeout for reports
+// of the root scope's own report loop. Timers are reported as they are
+// recorded, outside of any report, with a background context.
+type ContextStatsReporter interface {
+	// Capabilities returns the capabilities description of the reporter.
+	Capabilities() Capabilities
+
+	// ReportCounter reports a counter value.
+	ReportCounter(
+		ctx context.Context,
+		name string,
+		tags map[string]string,
+		value int64,
+	)
+
+	// ReportGauge reports a gauge value.
+	ReportGauge(
+		ctx context.Context,
+		name string,
+		tags map[string]string,
+		value float64,
+	)
+
+	// ReportTimer reports a timer value.
+	ReportTimer(
+		ctx context.Context,
+		name string,
+		tags map[string]string,
+		interval time.Duration,
+	)
+
+	// ReportHistogramValueSamples reports histogram samples for a bucket.
+	ReportHistogramValueSamples(
+		ctx context.Context,
+		name string,
+		tags map[string]string,
+		buckets Buckets,
+		bucketLowerBound,
+		bucketUpperBound float64,
+		samples int64,
+	)
+
+	// ReportHistogramDurationSamples reports histogram samples for a
+	// bucket.
+	ReportHistogramDurationSamples(
+		ctx context.Context,
+		name string,
+		tags map[string]string,
+		buckets Buckets,
+		bucketLowerBound,
+		bucketUpperBound time.Duration,
+		samples int64,
+	)
+
+	// Flush asks the reporter to flush all reported values, it returns
+	// the error flushing them if any.
+	Flush(ctx context.Context) error
+}
+
+// contextReporter adapts a ContextStatsReporter to a StatsReporter, passing
+// the context of the current report to its methods.
+type contextReporter struct {
+	reporter ContextStatsReporter
+
+	// mu serializes reports with a context.
+	mu sync.Mutex
+	// ctx holds the contextHolder of the current report.
+	ctx atomic.Value
+	// onError is called with the errors flushing the reporter, if set.
+	onError func(error)
+}
+
+type contextHolder struct {
+	ctx context.Context
+}
+
+func newContextReporter(r ContextStatsReporter) *contextReporter {
+	cr := &contextReporter{reporter: r}
+	cr.ctx.Store(contextHolder{context.Background()})
+	return cr
+}
+
+func (r *contextReporter) context() context.Context {
+	return r.ctx.Load().(contextHolder).ctx
+}
+
+// withContext calls report with ctx as the context of the reporter's
+// methods, then flushes the reporter, tracing the flush with tracer, and
+// returns the error flushing it.
+func (r *contextReporter) withContext(
+	ctx context.Context,
+	report func(),
+	tracer ReportTracer,
+) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.ctx.Store(contextHolder{ctx})
+	defer r.ctx.Store(contextHolder{context.Background()})
+
+	report()
+	defer StartReportPhase(tracer, FlushPhase)()
+	return r.flush(ctx)
+}
+
+// flush flushes the reporter with ctx, notifying the error flushing it.
+func (r *contextReporter) flush(ctx context.Context) error {
+	err := r.reporter.Flush(ctx)
+	if err != nil && r.onError != nil {
+		r.onError(err)
+	}
+	return err
+}
+
+func (r *contextReporter) ReportCounter(name string, tags map[string]string, value int64) {
+	r.reporter.ReportCounter(r.context(), name, tags, value)
+}
+
+func (r *contextReporter) ReportGauge(name string, tags map[string]string, value float64) {
+	r.reporter.ReportGauge(r.context(), name, tags, value)
+}
+
+func (r *contextReporter) ReportTimer(name string, tags map[string]string, interval time.Duration) {
+	r.reporter.ReportTimer(context.Background(), name, tags, interval)
+}
+
+func (r *contextReporter) ReportHistogramValueSamples(
+	name string,
+	tags map[string]string,
+	buckets Buckets,
+	bucketLowerBound,
+	bucketUpperBound float64,
+	samples int64,
+) {
+	r.reporter.ReportHistogramValueSamples(
+		r.context(), name, tags, buckets, bucketLowerBound, bucketUpperBound, samples,
+	)
+}
+
+func (r *contextReporter) ReportHistogramDurationSamples(
+	name string,
+	tags map[string]string,
+	buckets Buckets,
+	bucketLowerBound,
+	bucketUpperBound time.Duration,
+	samples int64,
+) {
+	r.reporter.ReportHistogramDurationSamples(
+		r.context(), name, tags, buckets, bucketLowerBound, bucketUpperBound, samples,
+	)
+}
+
+func (r *contextReporter) Capabilities() Capabilities {
+	return r.reporter.Capabilities()
+}
+
+// Flush flushes the reporter outside of a report with a context, e.g. when
+// closing the root scope, the error flushing it is only notified.
+func (r *contextReporter) Flush() {
+	_ = r.flush(r.context())
+}
+
+// NotifyReportErrors implements ReportErrorNotifier, the handler is called
+// with the errors flushing the reporter, and with the errors of the
+// reporter itself if it is a ReportErrorNotifier.
+func (r *contextReporter) NotifyReportErrors(onError func(error)) {
+	r.onError = onError
+	if n, ok := r.reporter.(ReportErrorNotifier); ok {
+		n.NotifyReportErrors(onError)
+	}
+}
+
+func (r *contextReporter) Close() error {
+	if closer, ok := r.reporter.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// reportContext reports the root scope and flushes its reporter, passing
+// ctx to its reporter if it is a ContextStatsReporter. It returns the error
+// flushing a ContextStatsReporter.
+func (s *scope) reportContext(ctx context.Context) error {
+	if s.closed.Load() {
+		return nil
+	}
+
+	if cr := s.contextReporter; cr != nil {
+		err := cr.withContext(ctx, s.reportRegistryWithoutFlush, s.registry.tracer)
+		if err == nil {
+			s.registry.markFlushed()
+		}
+		return err
+	}
+
+	s.reportRegistry()
+	return nil
+}
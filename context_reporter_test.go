@@ -0,0 +1,188 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tally
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type contextTestKey struct{}
+
+// contextRecordingReporter records the value of contextTestKey in the
+// context of each call by metric name.
+type contextRecordingReporter struct {
+	mu        sync.Mutex
+	values    map[string]interface{}
+	deadlines map[string]bool
+	flushErr  error
+	closed    bool
+}
+
+func newContextRecordingReporter() *contextRecordingReporter {
+	return &contextRecordingReporter{
+		values:    make(map[string]interface{}),
+		deadlines: make(map[string]bool),
+	}
+}
+
+func (r *contextRecordingReporter) record(ctx context.Context, name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.values[name] = ctx.Value(contextTestKey{})
+	_, r.deadlines[name] = ctx.Deadline()
+}
+
+func (r *contextRecordingReporter) get(name string) (interface{}, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	v, ok := r.values[name]
+	return v, ok
+}
+
+func (r *contextRecordingReporter) Capabilities() Capabilities {
+	return capabilitiesReportingTagging
+}
+
+func (r *contextRecordingReporter) ReportCounter(ctx context.Context, name string, _ map[string]string, _ int64) {
+	r.record(ctx, name)
+}
+
+func (r *contextRecordingReporter) ReportGauge(ctx context.Context, name string, _ map[string]string, _ float64) {
+	r.record(ctx, name)
+}
+
+func (r *contextRecordingReporter) ReportTimer(
+	ctx context.Context, name string, _ map[string]string, _ time.Duration,
+) {
+	r.record(ctx, name)
+}
+
+func (r *contextRecordingReporter) ReportHistogramValueSamples(
+	ctx context.Context, name string, _ map[string]string, _ Buckets, _, _ float64, _ int64,
+) {
+	r.record(ctx, name)
+}
+
+func (r *contextRecordingReporter) ReportHistogramDurationSamples(
+	ctx context.Context, name string, _ map[string]string, _ Buckets, _, _ time.Duration, _ int64,
+) {
+	r.record(ctx, name)
+}
+
+func (r *contextRecordingReporter) Flush(ctx context.Context) error {
+	r.record(ctx, "flush")
+	return r.flushErr
+}
+
+func (r *contextRecordingReporter) Close() error {
+	r.closed = true
+	return nil
+}
+
+func TestContextReporter(t *testing.T) {
+	r := newContextRecordingReporter()
+	root, closer := NewRootScope(ScopeOptions{
+		ContextReporter: r,
+		MetricsOption:   OmitInternalMetrics,
+	}, 0)
+
+	root.Counter("counter").Inc(1)
+	root.Gauge("gauge").Update(1)
+	root.Histogram("histogram", ValueBuckets{1}).RecordValue(1)
+	root.Timer("timer").Record(time.Second)
+
+	ctx := context.WithValue(context.Background(), contextTestKey{}, "report")
+	require.NoError(t, FlushContext(ctx, root))
+	for _, name := range []string{"counter", "gauge", "histogram", "flush"} {
+		v, _ := r.get(name)
+		assert.Equal(t, "report", v, name)
+	}
+
+	// Timers are reported as they are recorded.
+	v, ok := r.get("timer")
+	assert.True(t, ok)
+	assert.Nil(t, v)
+
+	r.flushErr = errors.New("flushing")
+	assert.Equal(t, r.flushErr, FlushContext(ctx, root))
+
+	require.NoError(t, closer.Close())
+	assert.True(t, r.closed)
+	assert.NoError(t, FlushContext(ctx, root))
+}
+
+func TestContextReporterReportLoop(t *testing.T) {
+	r := newContextRecordingReporter()
+	root, closer := NewRootScope(ScopeOptions{
+		ContextReporter: r,
+		MetricsOption:   OmitInternalMetrics,
+	}, time.Millisecond)
+	defer closer.Close()
+
+	root.Counter("counter").Inc(1)
+	require.Eventually(t, func() bool {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		return r.deadlines["counter"]
+	}, time.Second, time.Millisecond)
+}
+
+func TestContextReporterReportLoopErrors(t *testing.T) {
+	r := newContextRecordingReporter()
+	r.flushErr = errors.New("flushing")
+	errs := make(chan error, 1)
+	_, closer := NewRootScope(ScopeOptions{
+		ContextReporter: r,
+		MetricsOption:   OmitInternalMetrics,
+		OnReportError: func(err error) {
+			select {
+			case errs <- err:
+			default:
+			}
+		},
+	}, time.Millisecond)
+	defer closer.Close()
+
+	select {
+	case err := <-errs:
+		assert.Equal(t, r.flushErr, err)
+	case <-time.After(time.Second):
+		require.FailNow(t, "flush error not notified")
+	}
+}
+
+func TestContextReporterIgnoredWithReporter(t *testing.T) {
+	r := newContextRecordingReporter()
+	root := newRootScope(ScopeOptions{
+		Reporter:        NullStatsReporter,
+		ContextReporter: r,
+	}, 0)
+	defer root.Close()
+
+	assert.Nil(t, root.contextReporter)
+}
@@ -0,0 +1,250 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tally
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// FloatCounter is a counter accumulating fractional increments, e.g. of
+// amounts of money or fractions of work units, which would otherwise have
+// to be scaled into integers.
+type FloatCounter interface {
+	// Inc increments the counter by delta.
+	Inc(delta float64)
+}
+
+// FloatCounterScope is a Scope which can create float counters. As Scope
+// can't be extended, float counters are created by the function
+// CounterFloat rather than by a method of Scope.
+type FloatCounterScope interface {
+	Scope
+
+	// CounterFloat returns the float counter with the given name. It must
+	// not share its name with a counter of the scope, as they are both
+	// reported as counters.
+	CounterFloat(name string) FloatCounter
+}
+
+// FloatCounterReporter is implemented by StatsReporters which support
+// float counters natively. Other reporters receive the whole part of the
+// increments of float counters as counters, their fractional part being
+// carried over to the next report.
+type FloatCounterReporter interface {
+	// ReportFloatCounter reports a float counter value.
+	ReportFloatCounter(
+		name string,
+		tags map[string]string,
+		value float64,
+	)
+}
+
+// CachedFloatCounterReporter is implemented by CachedStatsReporters which
+// support float counters natively, other reporters allocate them as
+// counters like FloatCounterReporter.
+type CachedFloatCounterReporter interface {
+	// AllocateFloatCounter pre allocates a float counter data structure
+	// with name & tags.
+	AllocateFloatCounter(
+		name string,
+		tags map[string]string,
+	) CachedFloatCount
+}
+
+// CachedFloatCount interface for reporting an individual float counter
+type CachedFloatCount interface {
+	ReportFloatCount(value float64)
+}
+
+// CounterFloat returns s.CounterFloat(name) if s is a FloatCounterScope.
+// Otherwise it returns a float counter incrementing s.Counter(name) by the
+// whole part of its increments, carrying their fractional part over to
+// its next increment.
+func CounterFloat(s Scope, name string) FloatCounter {
+	if fs, ok := s.(FloatCounterScope); ok {
+		return fs.CounterFloat(name)
+	}
+	return &wholeFloatCounter{counter: s.Counter(name)}
+}
+
+func (s *scope) CounterFloat(name string) FloatCounter {
+	name = s.sanitizer.Name(name)
+	if c, ok := s.floatCounter(name); ok {
+		return c
+	}
+
+	s.registry.checkName(name)
+	if !s.metricEnabled(name) {
+		return noopFloatCounter{}
+	}
+	if o, ok := s.overflowScope(floatCounterSeries, name); ok {
+		return o.CounterFloat(name)
+	}
+
+	s.cm.Lock()
+	defer s.cm.Unlock()
+
+	if c, ok := s.floatCounters[name]; ok {
+		return c
+	}
+
+	c := &floatCounter{guard: s.guard}
+	if s.cachedReporter != nil {
+		fullName := s.fullyQualifiedName(name)
+		if fr, ok := s.cachedReporter.(CachedFloatCounterReporter); ok {
+			c.cachedCount = fr.AllocateFloatCounter(fullName, s.tags)
+		} else {
+			c.cachedWholeCount = s.cachedReporter.AllocateCounter(fullName, s.tags)
+		}
+	}
+	if s.floatCounters == nil {
+		s.floatCounters = make(map[string]*floatCounter)
+	}
+	s.floatCounters[name] = c
+	s.registry.churn.record(s.tags, 1, false)
+	s.floatCountersSlice = append(s.floatCountersSlice, c)
+
+	return c
+}
+
+func (s *scope) floatCounter(sanitizedName string) (FloatCounter, bool) {
+	s.cm.RLock()
+	defer s.cm.RUnlock()
+
+	c, ok := s.floatCounters[sanitizedName]
+	return c, ok
+}
+
+func (s *leveledScope) CounterFloat(name string) FloatCounter {
+	return leveledFloatCounter{s, s.scope.CounterFloat(name)}
+}
+
+type leveledFloatCounter struct {
+	scope   *leveledScope
+	counter FloatCounter
+}
+
+func (c leveledFloatCounter) Inc(delta float64) {
+	if c.scope.enabled() {
+		c.counter.Inc(delta)
+	}
+}
+
+type noopFloatCounter struct{}
+
+func (noopFloatCounter) Inc(float64) {}
+
+// floatCounter is the float counter of a scope. Its total and the part of
+// it already reported are stored as the bits of float64s.
+type floatCounter struct {
+	curr             uint64
+	prev             uint64
+	cachedCount      CachedFloatCount
+	cachedWholeCount CachedCount
+	guard            *closeGuard
+}
+
+func (c *floatCounter) Inc(delta float64) {
+	if !c.guard.begin() {
+		return
+	}
+	for {
+		curr := atomic.LoadUint64(&c.curr)
+		next := math.Float64bits(math.Float64frombits(curr) + delta)
+		if atomic.CompareAndSwapUint64(&c.curr, curr, next) {
+			break
+		}
+	}
+	c.guard.end()
+}
+
+// value returns the part of the counter not reported yet, or its whole
+// part if whole is set, and marks it reported.
+func (c *floatCounter) value(whole bool) float64 {
+	curr := math.Float64frombits(atomic.LoadUint64(&c.curr))
+	prev := math.Float64frombits(atomic.LoadUint64(&c.prev))
+	delta := curr - prev
+	if whole {
+		delta = math.Trunc(delta)
+	}
+	if delta == 0 {
+		return 0
+	}
+	atomic.StoreUint64(&c.prev, math.Float64bits(prev+delta))
+	return delta
+}
+
+func (c *floatCounter) report(name string, tags map[string]string, r StatsReporter) {
+	if fr, ok := r.(FloatCounterReporter); ok {
+		if delta := c.value(false); delta != 0 {
+			fr.ReportFloatCounter(name, tags, delta)
+		}
+		return
+	}
+	if delta := c.value(true); delta != 0 {
+		r.ReportCounter(name, tags, int64(delta))
+	}
+}
+
+func (c *floatCounter) cachedReport() {
+	if c.cachedCount != nil {
+		if delta := c.value(false); delta != 0 {
+			c.cachedCount.ReportFloatCount(delta)
+		}
+	} else if c.cachedWholeCount != nil {
+		if delta := c.value(true); delta != 0 {
+			c.cachedWholeCount.ReportCount(int64(delta))
+		}
+	}
+}
+
+func sortedFloatCounterNames(m map[string]*floatCounter) []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// wholeFloatCounter increments a counter by the whole part of its
+// increments, carrying their fractional part over to the next increment.
+type wholeFloatCounter struct {
+	counter Counter
+
+	mu       sync.Mutex
+	fraction float64
+}
+
+func (c *wholeFloatCounter) Inc(delta float64) {
+	c.mu.Lock()
+	total := c.fraction + delta
+	whole := math.Trunc(total)
+	c.fraction = total - whole
+	c.mu.Unlock()
+
+	if whole != 0 {
+		c.counter.Inc(int64(whole))
+	}
+}
@@ -0,0 +1,83 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tally
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type floatCounterRecordingReporter struct {
+	nullStatsReporter
+	floatCounters map[string]float64
+}
+
+func (r *floatCounterRecordingReporter) ReportFloatCounter(name string, _ map[string]string, value float64) {
+	r.floatCounters[name] = value
+}
+
+func TestCounterFloat(t *testing.T) {
+	r := &floatCounterRecordingReporter{floatCounters: make(map[string]float64)}
+	root, closer := NewRootScope(ScopeOptions{Reporter: r, MetricsOption: OmitInternalMetrics}, 0)
+	defer closer.Close()
+
+	cost := CounterFloat(root, "cost")
+	assert.Equal(t, cost, CounterFloat(root, "cost"))
+	cost.Inc(0.25)
+	cost.Inc(0.5)
+	root.(*scope).reportRegistry()
+	assert.Equal(t, 0.75, r.floatCounters["cost"])
+
+	cost.Inc(1)
+	root.(*scope).reportRegistry()
+	assert.Equal(t, 1.0, r.floatCounters["cost"])
+}
+
+func TestCounterFloatWholeFallback(t *testing.T) {
+	r := &valueRecordingReporter{counters: make(map[string]int64)}
+	root, closer := NewRootScope(ScopeOptions{Reporter: r, MetricsOption: OmitInternalMetrics}, 0)
+	defer closer.Close()
+
+	// The fractional part of the increments is carried over to the next
+	// report.
+	cost := CounterFloat(root, "cost")
+	cost.Inc(1.75)
+	root.(*scope).reportRegistry()
+	assert.Equal(t, int64(1), r.counters["cost"])
+
+	cost.Inc(0.5)
+	root.(*scope).reportRegistry()
+	assert.Equal(t, int64(1), r.counters["cost"])
+
+	delete(r.counters, "cost")
+	root.(*scope).reportRegistry()
+	assert.NotContains(t, r.counters, "cost")
+}
+
+func TestWholeFloatCounter(t *testing.T) {
+	s := NewTestScope("", nil)
+	c := &wholeFloatCounter{counter: s.Counter("cost")}
+	c.Inc(0.75)
+	c.Inc(0.75)
+	c.Inc(0.75)
+	assert.Equal(t, int64(2), s.Snapshot().Counters()["cost+"].Value())
+}
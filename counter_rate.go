@@ -0,0 +1,93 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tally
+
+import (
+	"sync"
+	"time"
+)
+
+// counterRate derives a per second rate gauge from the deltas reported
+// by a counter, for backends that can't compute rates themselves.
+type counterRate struct {
+	sync.Mutex
+
+	suffix      string
+	cachedGauge CachedGauge
+	now         func() time.Time
+	last        time.Time
+	lastRate    float64
+}
+
+func newCounterRate(suffix string, cachedGauge CachedGauge) *counterRate {
+	return &counterRate{
+		suffix:      suffix,
+		cachedGauge: cachedGauge,
+		now:         globalNow,
+		last:        globalNow(),
+	}
+}
+
+// update returns the rate of delta over the time elapsed since the
+// previous update, and whether it should be reported. A rate is reported
+// when the counter was incremented, and once more when it drops to zero
+// so that the gauge doesn't hold on to the last non zero rate.
+func (r *counterRate) update(delta int64) (float64, bool) {
+	r.Lock()
+	defer r.Unlock()
+
+	now := r.now()
+	elapsed := now.Sub(r.last)
+	if elapsed <= 0 {
+		return 0, false
+	}
+	r.last = now
+
+	rate := float64(delta) / elapsed.Seconds()
+	report := delta != 0 || r.lastRate != 0
+	r.lastRate = rate
+	return rate, report
+}
+
+// rate returns the last rate computed.
+func (r *counterRate) rate() float64 {
+	r.Lock()
+	defer r.Unlock()
+
+	return r.lastRate
+}
+
+func (r *counterRate) report(
+	name string,
+	tags map[string]string,
+	delta int64,
+	sr StatsReporter,
+) {
+	if rate, ok := r.update(delta); ok {
+		sr.ReportGauge(name+r.suffix, tags, rate)
+	}
+}
+
+func (r *counterRate) cachedReport(delta int64) {
+	if rate, ok := r.update(delta); ok {
+		r.cachedGauge.ReportGauge(rate)
+	}
+}
@@ -0,0 +1,126 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tally
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCounterRateUpdate(t *testing.T) {
+	now := time.Unix(0, 0)
+	r := newCounterRate("_rate", nil)
+	r.now = func() time.Time { return now }
+	r.last = now
+
+	now = now.Add(2 * time.Second)
+	rate, ok := r.update(10)
+	assert.True(t, ok)
+	assert.Equal(t, 5.0, rate)
+
+	now = now.Add(time.Second)
+	rate, ok = r.update(0)
+	assert.True(t, ok, "drop to zero must be reported")
+	assert.Equal(t, 0.0, rate)
+
+	now = now.Add(time.Second)
+	_, ok = r.update(0)
+	assert.False(t, ok)
+
+	_, ok = r.update(3)
+	assert.False(t, ok, "no time elapsed")
+}
+
+func TestCounterRateSuffix(t *testing.T) {
+	r := newTestStatsReporter()
+	root, closer := NewRootScope(ScopeOptions{
+		Prefix:            "svc",
+		Reporter:          r,
+		MetricsOption:     OmitInternalMetrics,
+		CounterRateSuffix: "_per_second",
+	}, 0)
+
+	root.Counter("requests").Inc(4)
+	time.Sleep(time.Millisecond)
+
+	r.cg.Add(1)
+	r.gg.Add(1)
+	root.(*scope).reportRegistry()
+	r.WaitAll()
+
+	assert.EqualValues(t, 4, r.getCounters()["svc.requests"].val)
+	require.Contains(t, r.getGauges(), "svc.requests_per_second")
+	assert.True(t, r.getGauges()["svc.requests_per_second"].val > 0)
+
+	// The final report drops the rate to zero.
+	r.gg.Add(1)
+	require.NoError(t, closer.Close())
+	assert.Equal(t, 0.0, r.getGauges()["svc.requests_per_second"].val)
+}
+
+func TestCounterRateSuffixCached(t *testing.T) {
+	r := newTestStatsReporter()
+	root, closer := NewRootScope(ScopeOptions{
+		CachedReporter:    r,
+		MetricsOption:     OmitInternalMetrics,
+		CounterRateSuffix: "_per_second",
+	}, 0)
+
+	c := root.Tagged(map[string]string{"a": "b"}).Counter("requests")
+	require.Contains(t, r.getGauges(), "requests_per_second")
+	assert.Equal(t, map[string]string{"a": "b"}, r.getGauges()["requests_per_second"].tags)
+
+	c.Inc(4)
+	time.Sleep(time.Millisecond)
+
+	r.cg.Add(1)
+	r.gg.Add(1)
+	root.(*scope).reportRegistry()
+	r.WaitAll()
+
+	assert.True(t, r.getGauges()["requests_per_second"].val > 0)
+
+	r.gg.Add(1)
+	require.NoError(t, closer.Close())
+	assert.Equal(t, 0.0, r.getGauges()["requests_per_second"].val)
+}
+
+func TestCounterRateSuffixShards(t *testing.T) {
+	r := &gaugeValueRecordingReporter{gauges: make(map[string][]float64)}
+	root, closer := NewRootScope(ScopeOptions{
+		Reporter:          r,
+		MetricsOption:     OmitInternalMetrics,
+		CounterRateSuffix: "_per_second",
+		// NB: the root scope is registered in every shard.
+		registryShardCount: 4,
+	}, 0)
+	defer closer.Close()
+
+	root.Counter("requests").Inc(4)
+	time.Sleep(time.Millisecond)
+	root.(*scope).reportRegistry()
+
+	require.Len(t, r.gauges["requests_per_second"], 1)
+	assert.True(t, r.gauges["requests_per_second"][0] > 0)
+}
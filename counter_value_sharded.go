@@ -0,0 +1,93 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+//go:build tally_sharded_counters
+// +build tally_sharded_counters
+
+package tally
+
+import (
+	"runtime"
+	"sync/atomic"
+	"unsafe"
+)
+
+// This file is an experiment for workloads incrementing the same counters
+// at more than 100M increments per second, where the cache line of an
+// atomic counter bounces between cores. Built with the
+// tally_sharded_counters tag, counters are sharded by P: each increment
+// goes to the shard of the P running the goroutine, and reading a counter
+// sums its shards. Increments are cheaper under contention, at the cost of
+// a cache line per P for each counter and of slower reads. Compare with
+//
+//	go test -run XXX -bench CounterInc -cpu 1,8,32
+//	go test -run XXX -bench CounterInc -cpu 1,8,32 -tags tally_sharded_counters
+
+// counterShard is padded to a cache line so that shards incremented by
+// different Ps don't share one.
+type counterShard struct {
+	v int64
+	_ [56]byte
+}
+
+// counterValue is the value of a counter sharded by P, its shards are
+// allocated on the first increment so that the zero value is usable.
+type counterValue struct {
+	shards unsafe.Pointer // *[]counterShard
+}
+
+func (c *counterValue) add(delta int64) {
+	shards := c.loadOrAllocShards()
+	// NB: the P only picks the shard, the goroutine may be rescheduled
+	// once unpinned as the shard is incremented atomically anyway.
+	p := procPin()
+	procUnpin()
+	atomic.AddInt64(&shards[p%len(shards)].v, delta)
+}
+
+func (c *counterValue) load() int64 {
+	ptr := atomic.LoadPointer(&c.shards)
+	if ptr == nil {
+		return 0
+	}
+	var v int64
+	for i := range *(*[]counterShard)(ptr) {
+		v += atomic.LoadInt64(&(*(*[]counterShard)(ptr))[i].v)
+	}
+	return v
+}
+
+func (c *counterValue) loadOrAllocShards() []counterShard {
+	if ptr := atomic.LoadPointer(&c.shards); ptr != nil {
+		return *(*[]counterShard)(ptr)
+	}
+	// NB: GOMAXPROCS may grow later, Ps beyond the shards then share them.
+	shards := make([]counterShard, runtime.GOMAXPROCS(0))
+	if atomic.CompareAndSwapPointer(&c.shards, nil, unsafe.Pointer(&shards)) {
+		return shards
+	}
+	return *(*[]counterShard)(atomic.LoadPointer(&c.shards))
+}
+
+//go:linkname procPin runtime.procPin
+func procPin() int
+
+//go:linkname procUnpin runtime.procUnpin
+func procUnpin()
@@ -0,0 +1,266 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tally
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// DeclaringScope is a Scope whose metrics can be declared upfront, so that
+// instrumentation can register its full set of metrics at startup rather
+// than on first use. Declared metrics are listed by Declared, which allows
+// discovering and validating them, and are reported from the first report
+// on: declared counters and histograms report zero values every interval
+// until they are recorded to, and declared gauges report their initial
+// zero value.
+type DeclaringScope interface {
+	Scope
+
+	// DeclareCounter returns the counter Counter(name) returns after
+	// declaring it.
+	DeclareCounter(name string) Counter
+
+	// DeclareGauge returns the gauge Gauge(name) returns after declaring
+	// it.
+	DeclareGauge(name string) Gauge
+
+	// DeclareTimer returns the timer Timer(name) returns after declaring
+	// it.
+	DeclareTimer(name string) Timer
+
+	// DeclareHistogram returns the histogram Histogram(name, buckets)
+	// returns after declaring it.
+	DeclareHistogram(name string, buckets Buckets) Histogram
+
+	// Declared returns the metrics declared in the scope's root scope and
+	// all its descendants, in the order they were first declared. Metrics
+	// that are removed or expire, and those of closed scopes once they are
+	// removed from the registry, are no longer listed.
+	Declared() []DeclaredMetric
+}
+
+// DeclaredMetric describes a declared metric.
+type DeclaredMetric struct {
+	Kind MetricKind
+	// Name is the fully qualified name of the metric.
+	Name string
+	Tags map[string]string
+	// Buckets are the buckets of a histogram.
+	Buckets Buckets
+}
+
+// DeclareCounter returns s.DeclareCounter(name) if s is a DeclaringScope,
+// otherwise s.Counter(name).
+func DeclareCounter(s Scope, name string) Counter {
+	if ds, ok := s.(DeclaringScope); ok {
+		return ds.DeclareCounter(name)
+	}
+	return s.Counter(name)
+}
+
+// DeclareGauge returns s.DeclareGauge(name) if s is a DeclaringScope,
+// otherwise s.Gauge(name).
+func DeclareGauge(s Scope, name string) Gauge {
+	if ds, ok := s.(DeclaringScope); ok {
+		return ds.DeclareGauge(name)
+	}
+	return s.Gauge(name)
+}
+
+// DeclareTimer returns s.DeclareTimer(name) if s is a DeclaringScope,
+// otherwise s.Timer(name).
+func DeclareTimer(s Scope, name string) Timer {
+	if ds, ok := s.(DeclaringScope); ok {
+		return ds.DeclareTimer(name)
+	}
+	return s.Timer(name)
+}
+
+// DeclareHistogram returns s.DeclareHistogram(name, buckets) if s is a
+// DeclaringScope, otherwise s.Histogram(name, buckets).
+func DeclareHistogram(s Scope, name string, buckets Buckets) Histogram {
+	if ds, ok := s.(DeclaringScope); ok {
+		return ds.DeclareHistogram(name, buckets)
+	}
+	return s.Histogram(name, buckets)
+}
+
+// Declared returns s.Declared() if s is a DeclaringScope, otherwise nil.
+func Declared(s Scope) []DeclaredMetric {
+	if ds, ok := s.(DeclaringScope); ok {
+		return ds.Declared()
+	}
+	return nil
+}
+
+// declarations holds the metrics declared in a registry.
+type declarations struct {
+	mu      sync.Mutex
+	keys    map[string]struct{}
+	metrics []declaration
+}
+
+// declaration is a declared metric and the scope it was declared in.
+type declaration struct {
+	key   string
+	scope *scope
+	DeclaredMetric
+}
+
+func declarationKey(kind MetricKind, name string, tags map[string]string) string {
+	return kind.String() + ":" + KeyForPrefixedStringMap(name, tags)
+}
+
+// add records m declared in s, it returns false if m was already declared.
+func (d *declarations) add(s *scope, m DeclaredMetric) bool {
+	key := declarationKey(m.Kind, m.Name, m.Tags)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if _, ok := d.keys[key]; ok {
+		return false
+	}
+	if d.keys == nil {
+		d.keys = make(map[string]struct{})
+	}
+	d.keys[key] = struct{}{}
+	d.metrics = append(d.metrics, declaration{key: key, scope: s, DeclaredMetric: m})
+	return true
+}
+
+// remove forgets the declarations matching f.
+func (d *declarations) remove(f func(declaration) bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	metrics := d.metrics[:0]
+	for _, m := range d.metrics {
+		if f(m) {
+			delete(d.keys, m.key)
+			continue
+		}
+		metrics = append(metrics, m)
+	}
+	for i := len(metrics); i < len(d.metrics); i++ {
+		d.metrics[i] = declaration{}
+	}
+	d.metrics = metrics
+}
+
+func (d *declarations) list() []DeclaredMetric {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	metrics := make([]DeclaredMetric, 0, len(d.metrics))
+	for _, m := range d.metrics {
+		metrics = append(metrics, m.DeclaredMetric)
+	}
+	return metrics
+}
+
+func (s *scope) declare(kind MetricKind, name string, buckets Buckets) bool {
+	return s.registry.declarations.add(s, DeclaredMetric{
+		Kind:    kind,
+		Name:    s.fullyQualifiedName(s.sanitizer.Name(name)),
+		Tags:    s.tags,
+		Buckets: buckets,
+	})
+}
+
+// undeclare forgets the declaration of the metric of the given kind with
+// the given sanitized name, once it is removed or expires.
+func (s *scope) undeclare(kind MetricKind, name string) {
+	key := declarationKey(kind, s.fullyQualifiedName(name), s.tags)
+	s.registry.declarations.remove(func(d declaration) bool {
+		return d.key == key
+	})
+}
+
+// undeclareAll forgets the declarations of every metric of the scope, once
+// it is closed and removed from the registry.
+func (s *scope) undeclareAll() {
+	s.registry.declarations.remove(func(d declaration) bool {
+		return d.scope == s
+	})
+}
+
+func (s *scope) DeclareCounter(name string) Counter {
+	c := s.Counter(name)
+	if cc, ok := c.(*counter); ok && s.declare(CounterKind, name, nil) {
+		atomic.StoreUint32(&cc.reportZero, 1)
+	}
+	return c
+}
+
+func (s *scope) DeclareGauge(name string) Gauge {
+	g := s.Gauge(name)
+	if gg, ok := g.(*gauge); ok && s.declare(GaugeKind, name, nil) {
+		// Report the current value, zero unless the gauge was already
+		// updated.
+		atomic.StoreUint64(&gg.updated, 1)
+	}
+	return g
+}
+
+func (s *scope) DeclareTimer(name string) Timer {
+	t := s.Timer(name)
+	if _, ok := t.(*timer); ok {
+		s.declare(TimerKind, name, nil)
+	}
+	return t
+}
+
+func (s *scope) DeclareHistogram(name string, buckets Buckets) Histogram {
+	if buckets == nil {
+		buckets = s.defaultBuckets
+	}
+	h := s.Histogram(name, buckets)
+	if hh, ok := h.(*histogram); ok && s.declare(HistogramKind, name, buckets) {
+		atomic.StoreUint32(&hh.reportZero, 1)
+	}
+	return h
+}
+
+func (s *scope) Declared() []DeclaredMetric {
+	return s.registry.declarations.list()
+}
+
+func (s *leveledScope) DeclareCounter(name string) Counter {
+	return leveledCounter{s, s.scope.DeclareCounter(name)}
+}
+
+func (s *leveledScope) DeclareGauge(name string) Gauge {
+	return leveledGauge{s, s.scope.DeclareGauge(name)}
+}
+
+func (s *leveledScope) DeclareTimer(name string) Timer {
+	return leveledTimer{s, s.scope.DeclareTimer(name)}
+}
+
+func (s *leveledScope) DeclareHistogram(name string, buckets Buckets) Histogram {
+	return leveledHistogram{s, s.scope.DeclareHistogram(name, buckets)}
+}
+
+func (s *leveledScope) Declared() []DeclaredMetric {
+	return s.scope.Declared()
+}
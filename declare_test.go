@@ -0,0 +1,105 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tally
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeclare(t *testing.T) {
+	r := &valueRecordingReporter{
+		counters:   make(map[string]int64),
+		histograms: make(map[string]int64),
+	}
+	root, closer := NewRootScope(ScopeOptions{
+		Reporter:      r,
+		MetricsOption: OmitInternalMetrics,
+	}, 0)
+	defer closer.Close()
+
+	sub := root.Tagged(map[string]string{"a": "b"})
+	c := DeclareCounter(sub, "requests")
+	assert.Same(t, sub.Counter("requests"), c)
+	DeclareGauge(sub, "queue")
+	DeclareTimer(sub, "latency")
+	DeclareHistogram(sub, "size", ValueBuckets{10})
+	DeclareCounter(sub, "requests")
+
+	assert.Equal(t, []DeclaredMetric{
+		{Kind: CounterKind, Name: "requests", Tags: map[string]string{"a": "b"}},
+		{Kind: GaugeKind, Name: "queue", Tags: map[string]string{"a": "b"}},
+		{Kind: TimerKind, Name: "latency", Tags: map[string]string{"a": "b"}},
+		{Kind: HistogramKind, Name: "size", Tags: map[string]string{"a": "b"}, Buckets: ValueBuckets{10}},
+	}, Declared(root))
+
+	root.(*scope).reportRegistry()
+	assert.Equal(t, map[string]int64{"requests": 0}, r.counters)
+	assert.Len(t, r.histograms, 2)
+	assert.Equal(t, []string{"queue+a=b"}, r.gauges)
+
+	// Declared gauges only report their initial value once.
+	root.(*scope).reportRegistry()
+	assert.Equal(t, []string{"queue+a=b"}, r.gauges)
+}
+
+func TestDeclareFallback(t *testing.T) {
+	s := NewTestScope("", nil)
+	ls := s.(LeveledScope).AtLevel(DebugLevel)
+	DeclareCounter(ls, "requests")
+	assert.Len(t, Declared(ls), 1)
+
+	assert.Equal(t, noopMetric{}, DeclareCounter(NoopScope, "requests"))
+	assert.Nil(t, Declared(NoopScope))
+}
+
+func TestDeclaredPruned(t *testing.T) {
+	root, closer := NewRootScope(ScopeOptions{
+		Reporter:      NullStatsReporter,
+		MetricsOption: OmitInternalMetrics,
+	}, 0)
+	defer closer.Close()
+
+	DeclareCounter(root, "requests")
+	DeclareGauge(root, "queue")
+	sub := root.Tagged(map[string]string{"a": "b"})
+	DeclareTimer(sub, "latency")
+	require.Len(t, Declared(root), 3)
+
+	// Removed metrics are no longer declared.
+	assert.True(t, RemoveCounter(root, "requests"))
+	assert.Equal(t, []DeclaredMetric{
+		{Kind: GaugeKind, Name: "queue", Tags: map[string]string{}},
+		{Kind: TimerKind, Name: "latency", Tags: map[string]string{"a": "b"}},
+	}, Declared(root))
+
+	// Neither are those of closed scopes once removed from the registry.
+	assert.True(t, RemoveTagged(root, map[string]string{"a": "b"}))
+	assert.Len(t, Declared(root), 2)
+	root.(*scope).reportRegistry()
+	assert.Equal(t, []DeclaredMetric{{Kind: GaugeKind, Name: "queue", Tags: map[string]string{}}}, Declared(root))
+
+	// A removed metric can be declared again.
+	DeclareCounter(root, "requests")
+	assert.Len(t, Declared(root), 2)
+}
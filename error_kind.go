@@ -0,0 +1,154 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tally
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"reflect"
+	"syscall"
+)
+
+// ErrorKindTag is the tag of the kind of an error, see ErrorKind.
+const ErrorKindTag = "error_kind"
+
+// The kinds of errors returned by ErrorKind. They are deliberately few so
+// that error rates are comparable across services and don't grow the
+// cardinality of the metrics they tag.
+const (
+	ErrorKindNone              = "none"
+	ErrorKindCanceled          = "canceled"
+	ErrorKindTimeout           = "timeout"
+	ErrorKindEOF               = "eof"
+	ErrorKindNetwork           = "network"
+	ErrorKindNotFound          = "not_found"
+	ErrorKindInvalidArgument   = "invalid_argument"
+	ErrorKindPermissionDenied  = "permission_denied"
+	ErrorKindResourceExhausted = "resource_exhausted"
+	ErrorKindConflict          = "conflict"
+	ErrorKindUnimplemented     = "unimplemented"
+	ErrorKindInternal          = "internal"
+	ErrorKindUnknown           = "unknown"
+)
+
+// grpcErrorKinds are the kinds of errors of the gRPC status codes, indexed
+// by code.
+var grpcErrorKinds = []string{
+	ErrorKindNone,              // OK
+	ErrorKindCanceled,          // Canceled
+	ErrorKindUnknown,           // Unknown
+	ErrorKindInvalidArgument,   // InvalidArgument
+	ErrorKindTimeout,           // DeadlineExceeded
+	ErrorKindNotFound,          // NotFound
+	ErrorKindConflict,          // AlreadyExists
+	ErrorKindPermissionDenied,  // PermissionDenied
+	ErrorKindResourceExhausted, // ResourceExhausted
+	ErrorKindInvalidArgument,   // FailedPrecondition
+	ErrorKindConflict,          // Aborted
+	ErrorKindInvalidArgument,   // OutOfRange
+	ErrorKindUnimplemented,     // Unimplemented
+	ErrorKindInternal,          // Internal
+	ErrorKindNetwork,           // Unavailable
+	ErrorKindInternal,          // DataLoss
+	ErrorKindPermissionDenied,  // Unauthenticated
+}
+
+// ErrorKind returns the kind of err, ErrorKindNone if it is nil and
+// ErrorKindUnknown if it isn't recognized. Wrapped errors are recognized,
+// as well as the errors of gRPC, by their status code.
+func ErrorKind(err error) string {
+	if err == nil {
+		return ErrorKindNone
+	}
+	if code, ok := grpcCode(err); ok {
+		if int(code) < len(grpcErrorKinds) {
+			return grpcErrorKinds[code]
+		}
+		return ErrorKindUnknown
+	}
+
+	switch {
+	case errors.Is(err, context.Canceled):
+		return ErrorKindCanceled
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, os.ErrDeadlineExceeded):
+		return ErrorKindTimeout
+	case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return ErrorKindEOF
+	case errors.Is(err, os.ErrNotExist):
+		return ErrorKindNotFound
+	case errors.Is(err, os.ErrPermission):
+		return ErrorKindPermissionDenied
+	}
+
+	var timeout interface{ Timeout() bool }
+	if errors.As(err, &timeout) && timeout.Timeout() {
+		return ErrorKindTimeout
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.EPIPE) {
+		return ErrorKindNetwork
+	}
+	return ErrorKindUnknown
+}
+
+// WithErrorKind returns s tagged with the kind of err as error_kind.
+func WithErrorKind(s Scope, err error) Scope {
+	return s.Tagged(map[string]string{ErrorKindTag: ErrorKind(err)})
+}
+
+// grpcCode returns the status code of the first error of the chain of err
+// with a gRPC status. gRPC isn't a dependency of tally, so the status is
+// found like status.FromError does, by its GRPCStatus method.
+func grpcCode(err error) (uint32, bool) {
+	for ; err != nil; err = errors.Unwrap(err) {
+		status, ok := callNoArgs(reflect.ValueOf(err), "GRPCStatus")
+		if !ok || (status.Kind() == reflect.Ptr && status.IsNil()) {
+			continue
+		}
+		code, ok := callNoArgs(status, "Code")
+		if !ok {
+			continue
+		}
+		switch code.Kind() {
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			return uint32(code.Uint()), true
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			return uint32(code.Int()), true
+		}
+	}
+	return 0, false
+}
+
+// callNoArgs calls the method of v with the given name if it takes no
+// argument and returns a single value.
+func callNoArgs(v reflect.Value, name string) (reflect.Value, bool) {
+	m := v.MethodByName(name)
+	if !m.IsValid() || m.Type().NumIn() != 0 || m.Type().NumOut() != 1 {
+		return reflect.Value{}, false
+	}
+	return m.Call(nil)[0], true
+}
@@ -0,0 +1,76 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tally
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// grpcCodeForTest and grpcStatusForTest mirror codes.Code and status.Status
+// of gRPC.
+type grpcCodeForTest uint32
+
+type grpcStatusForTest struct{ code grpcCodeForTest }
+
+func (s *grpcStatusForTest) Code() grpcCodeForTest { return s.code }
+
+type grpcErrorForTest struct{ status *grpcStatusForTest }
+
+func (e grpcErrorForTest) Error() string                  { return "rpc error" }
+func (e grpcErrorForTest) GRPCStatus() *grpcStatusForTest { return e.status }
+
+func TestErrorKind(t *testing.T) {
+	tests := []struct {
+		err  error
+		kind string
+	}{
+		{nil, ErrorKindNone},
+		{context.Canceled, ErrorKindCanceled},
+		{fmt.Errorf("query: %w", context.DeadlineExceeded), ErrorKindTimeout},
+		{&net.OpError{Op: "dial", Err: os.ErrDeadlineExceeded}, ErrorKindTimeout},
+		{&net.OpError{Op: "dial", Err: syscall.ECONNREFUSED}, ErrorKindNetwork},
+		{io.ErrUnexpectedEOF, ErrorKindEOF},
+		{&os.PathError{Op: "open", Err: os.ErrNotExist}, ErrorKindNotFound},
+		{grpcErrorForTest{&grpcStatusForTest{code: 4}}, ErrorKindTimeout},
+		{fmt.Errorf("call: %w", grpcErrorForTest{&grpcStatusForTest{code: 14}}), ErrorKindNetwork},
+		{grpcErrorForTest{&grpcStatusForTest{code: 99}}, ErrorKindUnknown},
+		{grpcErrorForTest{}, ErrorKindUnknown},
+		{errors.New("boom"), ErrorKindUnknown},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.kind, ErrorKind(tt.err), "%v", tt.err)
+	}
+}
+
+func TestWithErrorKind(t *testing.T) {
+	s := NewTestScope("", nil)
+	WithErrorKind(s, context.Canceled).Counter("errors").Inc(1)
+	assert.Contains(t, s.Snapshot().Counters(), "errors+error_kind=canceled")
+}
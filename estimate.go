@@ -0,0 +1,253 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tally
+
+import (
+	"errors"
+	"sort"
+)
+
+var errEstimateNotTallyScope = errors.New("scope was not created by tally")
+
+var (
+	// StatsdOutput estimates the size of statsd lines, which don't carry
+	// tags and report every histogram bucket as a separate counter.
+	StatsdOutput OutputFormat = statsdOutput{}
+
+	// PrometheusOutput estimates the size of the Prometheus text
+	// exposition format.
+	PrometheusOutput OutputFormat = prometheusOutput{}
+
+	// OTLPOutput estimates the size of an OTLP protobuf metrics payload.
+	OTLPOutput OutputFormat = otlpOutput{}
+)
+
+// OutputFormat estimates the size of the output of a reporter. The
+// estimates are meant for capacity planning rather than being exact.
+type OutputFormat interface {
+	// EstimateSeries returns the number of series a metric is reported
+	// as, and their size in bytes per report. Buckets is the number of
+	// buckets of a histogram, including the overflow bucket.
+	EstimateSeries(
+		kind MetricKind,
+		name string,
+		tags map[string]string,
+		buckets int,
+	) (series int, bytes int)
+
+	// EstimateFamily returns the size in bytes added once per report for
+	// all the metrics of a kind sharing a name.
+	EstimateFamily(kind MetricKind, name string) int
+}
+
+// OutputSize is the estimated output of a registry.
+type OutputSize struct {
+	// Series is the number of series.
+	Series int
+	// Families is the number of distinct metric names.
+	Families int
+	// Bytes is the size of a report.
+	Bytes int
+	// ByName is the number of series per metric name, which helps find
+	// the metrics responsible for a cardinality explosion.
+	ByName map[string]int
+}
+
+// TopNames returns up to n metric names with the most series, in
+// decreasing order of series.
+func (o OutputSize) TopNames(n int) []string {
+	names := make([]string, 0, len(o.ByName))
+	for name := range o.ByName {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if o.ByName[names[i]] != o.ByName[names[j]] {
+			return o.ByName[names[i]] > o.ByName[names[j]]
+		}
+		return names[i] < names[j]
+	})
+	if len(names) > n {
+		names = names[:n]
+	}
+	return names
+}
+
+// EstimateOutputSize estimates the size of a report of every metric of s's
+// registry in the given format, so that checks can catch series
+// explosions before they reach a backend. Timers are estimated as a single
+// value per report although reporters typically report every recorded
+// value.
+func EstimateOutputSize(s Scope, format OutputFormat) (OutputSize, error) {
+	var ts *scope
+	switch v := s.(type) {
+	case *scope:
+		ts = v
+	case *leveledScope:
+		ts = v.scope
+	default:
+		return OutputSize{}, errEstimateNotTallyScope
+	}
+
+	type family struct {
+		kind MetricKind
+		name string
+	}
+
+	var (
+		size     = OutputSize{ByName: make(map[string]int)}
+		families = make(map[family]struct{})
+		add      = func(kind MetricKind, name string, tags map[string]string, buckets int) {
+			series, bytes := format.EstimateSeries(kind, name, tags, buckets)
+			size.Series += series
+			size.Bytes += bytes
+			size.ByName[name] += series
+
+			f := family{kind: kind, name: name}
+			if _, ok := families[f]; !ok {
+				families[f] = struct{}{}
+				size.Bytes += format.EstimateFamily(kind, name)
+			}
+		}
+	)
+
+	ts.registry.forEachUniqueScope(func(ss *scope, tags map[string]string) bool {
+		ss.cm.RLock()
+		for key := range ss.counters {
+			add(CounterKind, ss.fullyQualifiedName(key), tags, 0)
+		}
+		ss.cm.RUnlock()
+
+		ss.gm.RLock()
+		for key := range ss.gauges {
+			add(GaugeKind, ss.fullyQualifiedName(key), tags, 0)
+		}
+		ss.gm.RUnlock()
+
+		ss.tm.RLock()
+		for key := range ss.timers {
+			add(TimerKind, ss.fullyQualifiedName(key), tags, 0)
+		}
+		ss.tm.RUnlock()
+
+		ss.hm.RLock()
+		for key, h := range ss.histograms {
+			add(HistogramKind, ss.fullyQualifiedName(key), tags, len(h.buckets))
+		}
+		ss.hm.RUnlock()
+		return true
+	})
+
+	size.Families = len(families)
+	return size, nil
+}
+
+const (
+	// estimatedValueBytes is the estimated size of a formatted value.
+	estimatedValueBytes = 8
+	// estimatedBucketBytes is the estimated size of formatted bucket
+	// bounds.
+	estimatedBucketBytes = 12
+)
+
+type statsdOutput struct{}
+
+func (statsdOutput) EstimateSeries(
+	kind MetricKind,
+	name string,
+	tags map[string]string,
+	buckets int,
+) (int, int) {
+	// name:value|c\n
+	line := len(name) + 1 + estimatedValueBytes + 3
+	if kind == TimerKind {
+		line++ // |ms
+	}
+	if kind != HistogramKind {
+		return 1, line
+	}
+	// name.lower-upper:count|c\n
+	return buckets, buckets * (line + 2*estimatedBucketBytes + 2)
+}
+
+func (statsdOutput) EstimateFamily(MetricKind, string) int {
+	return 0
+}
+
+type prometheusOutput struct{}
+
+func (prometheusOutput) EstimateSeries(
+	kind MetricKind,
+	name string,
+	tags map[string]string,
+	buckets int,
+) (int, int) {
+	// name{k="v",...} value\n
+	labels := 0
+	for k, v := range tags {
+		labels += len(k) + len(v) + 4
+	}
+	line := func(suffix string, extraLabels int) int {
+		n := len(name) + len(suffix) + labels + extraLabels
+		if labels+extraLabels > 0 {
+			n += 2
+		}
+		return n + 1 + estimatedValueBytes + 1
+	}
+
+	if kind != HistogramKind {
+		return 1, line("", 0)
+	}
+	// One _bucket series per bucket labeled with le, plus _sum and _count.
+	leLabel := len("le") + estimatedBucketBytes + 4
+	return buckets + 2,
+		buckets*line("_bucket", leLabel) + line("_sum", 0) + line("_count", 0)
+}
+
+func (prometheusOutput) EstimateFamily(kind MetricKind, name string) int {
+	// # HELP name name metric\n# TYPE name kind\n
+	return 3*len(name) + len(kind.String()) + 25
+}
+
+type otlpOutput struct{}
+
+func (otlpOutput) EstimateSeries(
+	kind MetricKind,
+	name string,
+	tags map[string]string,
+	buckets int,
+) (int, int) {
+	// A data point holds its attributes as key values, start and end
+	// timestamps as fixed64 and its value.
+	point := 2*9 + 9
+	for k, v := range tags {
+		point += len(k) + len(v) + 8
+	}
+	if kind == HistogramKind {
+		// Bucket counts and explicit bounds, plus count and sum.
+		point += buckets*9 + (buckets-1)*9 + 2*9
+	}
+	return 1, point + 2
+}
+
+func (otlpOutput) EstimateFamily(kind MetricKind, name string) int {
+	// The metric holds its name and the type of its data points.
+	return len(name) + 8
+}
@@ -0,0 +1,71 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tally
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEstimateOutputSize(t *testing.T) {
+	s := NewTestScope("", nil)
+	for _, v := range []string{"a", "b", "c"} {
+		s.Tagged(map[string]string{"k": v}).Counter("requests")
+	}
+	s.Gauge("queue")
+	s.Timer("latency")
+	s.Histogram("size", ValueBuckets{1, 10})
+
+	tests := []struct {
+		format OutputFormat
+		series int
+	}{
+		// 3 buckets including the overflow bucket.
+		{StatsdOutput, 3 + 1 + 1 + 3},
+		// 3 buckets plus _sum and _count.
+		{PrometheusOutput, 3 + 1 + 1 + 5},
+		{OTLPOutput, 3 + 1 + 1 + 1},
+	}
+	for _, tt := range tests {
+		size, err := EstimateOutputSize(s, tt.format)
+		require.NoError(t, err)
+		assert.Equal(t, tt.series, size.Series)
+		assert.Equal(t, 4, size.Families)
+		assert.True(t, size.Bytes > 0)
+		assert.Equal(t, 3, size.ByName["requests"])
+	}
+
+	size, err := EstimateOutputSize(s, PrometheusOutput)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"size", "requests"}, size.TopNames(2))
+
+	_, err = EstimateOutputSize(NoopScope, StatsdOutput)
+	assert.Equal(t, errEstimateNotTallyScope, err)
+}
+
+func TestEstimateStatsdSeries(t *testing.T) {
+	series, bytes := StatsdOutput.EstimateSeries(CounterKind, "c", nil, 0)
+	assert.Equal(t, 1, series)
+	// c:12345678|c\n
+	assert.Equal(t, 13, bytes)
+}
@@ -0,0 +1,97 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tally
+
+import (
+	"path"
+	"sync/atomic"
+)
+
+// MetricFilter enables or disables metrics by matching their fully
+// qualified names against sets of allow and deny glob patterns, using the
+// syntax of path.Match.
+//
+// A metric is enabled if it matches any allow pattern. Otherwise it is
+// disabled if it matches any deny pattern, and enabled if it does not.
+// Disabled metrics are handed out as noops when they are created; metrics
+// that already exist are not affected by later updates to the filter.
+//
+// A MetricFilter is safe for concurrent use and may be updated at runtime,
+// e.g. when configuration is reloaded.
+type MetricFilter struct {
+	rules atomic.Value // *metricFilterRules
+}
+
+type metricFilterRules struct {
+	allow []string
+	deny  []string
+}
+
+// NewMetricFilter returns a new MetricFilter with the given allow and
+// deny patterns.
+func NewMetricFilter(allow, deny []string) (*MetricFilter, error) {
+	f := &MetricFilter{}
+	if err := f.Update(allow, deny); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Update atomically replaces the allow and deny patterns of the filter.
+// If any of the patterns is malformed the filter is left unchanged.
+func (f *MetricFilter) Update(allow, deny []string) error {
+	for _, patterns := range [][]string{allow, deny} {
+		for _, pattern := range patterns {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return err
+			}
+		}
+	}
+
+	f.rules.Store(&metricFilterRules{
+		allow: append([]string(nil), allow...),
+		deny:  append([]string(nil), deny...),
+	})
+	return nil
+}
+
+// Enabled returns whether the metric with the given fully qualified name
+// is enabled.
+func (f *MetricFilter) Enabled(name string) bool {
+	rules, _ := f.rules.Load().(*metricFilterRules)
+	if rules == nil {
+		return true
+	}
+	if matchAny(rules.allow, name) {
+		return true
+	}
+	return !matchAny(rules.deny, name)
+}
+
+func matchAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		// NB: patterns are validated on update so the error can be ignored.
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
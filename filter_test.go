@@ -0,0 +1,76 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tally
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetricFilterEnabled(t *testing.T) {
+	f, err := NewMetricFilter([]string{"debug.keep"}, []string{"debug.*"})
+	require.NoError(t, err)
+
+	assert.True(t, f.Enabled("requests"))
+	assert.True(t, f.Enabled("debug.keep"))
+	assert.False(t, f.Enabled("debug.drop"))
+}
+
+func TestMetricFilterUpdate(t *testing.T) {
+	f, err := NewMetricFilter(nil, []string{"debug.*"})
+	require.NoError(t, err)
+	assert.False(t, f.Enabled("debug.foo"))
+
+	require.NoError(t, f.Update(nil, nil))
+	assert.True(t, f.Enabled("debug.foo"))
+
+	assert.Error(t, f.Update(nil, []string{"["}))
+	assert.True(t, f.Enabled("debug.foo"))
+
+	_, err = NewMetricFilter([]string{"["}, nil)
+	assert.Error(t, err)
+}
+
+func TestScopeMetricFilter(t *testing.T) {
+	f, err := NewMetricFilter(nil, []string{"svc.debug.*"})
+	require.NoError(t, err)
+
+	root, closer := NewRootScope(ScopeOptions{
+		Prefix:       "svc",
+		Reporter:     NullStatsReporter,
+		MetricFilter: f,
+	}, 0)
+	defer closer.Close()
+
+	debug := root.SubScope("debug")
+	assert.Equal(t, noopMetric{}, debug.Counter("c"))
+	assert.Equal(t, noopMetric{}, debug.Gauge("g"))
+	assert.Equal(t, noopMetric{}, debug.Timer("t"))
+	assert.Equal(t, noopMetric{}, debug.Histogram("h", nil))
+	assert.IsType(t, &counter{}, root.Counter("c"))
+
+	// Metrics are evaluated again once the filter is reloaded.
+	require.NoError(t, f.Update([]string{"svc.debug.c"}, []string{"svc.debug.*"}))
+	assert.IsType(t, &counter{}, debug.Counter("c"))
+	assert.Equal(t, noopMetric{}, debug.Gauge("g"))
+}
@@ -0,0 +1,42 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tally
+
+// Flushed returns a channel closed once the reporter of the root scope s
+// was first flushed successfully, so that readiness probes and smoke tests
+// can check that metrics are reported before taking traffic. A flush is
+// successful if the reporter, when it is a ReportErrorNotifier or a
+// ContextStatsReporter, didn't notify or return an error flushing.
+//
+// The channel is never closed for scopes without a reporter.
+func Flushed(s Scope) (<-chan struct{}, error) {
+	root, ok := s.(*scope)
+	if !ok || !root.root {
+		return nil, errNotRootScope
+	}
+	return root.registry.flushed, nil
+}
+
+// markFlushed records that the reporter of the registry was flushed
+// successfully.
+func (r *scopeRegistry) markFlushed() {
+	r.flushedOnce.Do(func() { close(r.flushed) })
+}
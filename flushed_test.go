@@ -0,0 +1,68 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tally
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFlushed(t *testing.T) {
+	root, closer := NewRootScope(ScopeOptions{Reporter: NullStatsReporter}, 0)
+	defer closer.Close()
+
+	flushed, err := Flushed(root)
+	require.NoError(t, err)
+	assert.False(t, isClosed(flushed))
+
+	root.(*scope).reportRegistry()
+	assert.True(t, isClosed(flushed))
+	root.(*scope).reportRegistry()
+
+	_, err = Flushed(root.SubScope("sub"))
+	assert.Equal(t, errNotRootScope, err)
+}
+
+func TestFlushedAfterError(t *testing.T) {
+	r := &failingReporter{counters: make(map[string]int64)}
+	root, closer := NewRootScope(ScopeOptions{Reporter: r}, 0)
+	defer closer.Close()
+
+	flushed, err := Flushed(root)
+	require.NoError(t, err)
+	root.(*scope).reportRegistry()
+	assert.False(t, isClosed(flushed))
+
+	r.onError = func(error) {}
+	root.(*scope).reportRegistry()
+	assert.True(t, isClosed(flushed))
+}
+
+func isClosed(ch <-chan struct{}) bool {
+	select {
+	case <-ch:
+		return true
+	default:
+		return false
+	}
+}
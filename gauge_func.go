@@ -0,0 +1,128 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tally
+
+// GaugeFuncScope is a Scope which can create gauges whose value is read
+// from a callback when the scope is reported, e.g. the length of a queue
+// or the size of a cache. As Scope can't be extended, they are created by
+// the function GaugeFunc rather than by a method of Scope.
+type GaugeFuncScope interface {
+	Scope
+
+	// GaugeFunc updates the gauge with the given name with the value
+	// returned by fn on each report, replacing any previous callback of
+	// the gauge.
+	GaugeFunc(name string, fn func() float64)
+}
+
+// GaugeFunc calls s.GaugeFunc(name, fn) if s is a GaugeFuncScope, and
+// returns whether it did.
+//
+// fn is called by the reporting goroutine before the metrics are reported
+// and must not block, it may create and update metrics of any scope. The
+// callback is released when the gauge is removed.
+func GaugeFunc(s Scope, name string, fn func() float64) bool {
+	if gs, ok := s.(GaugeFuncScope); ok {
+		gs.GaugeFunc(name, fn)
+		return true
+	}
+	return false
+}
+
+// gaugeFunc is the callback of a gauge, with the gauge it updates.
+type gaugeFunc struct {
+	gauge Gauge
+	fn    func() float64
+}
+
+func (s *scope) GaugeFunc(name string, fn func() float64) {
+	s.setGaugeFunc(name, s.Gauge(name), fn)
+}
+
+func (s *leveledScope) GaugeFunc(name string, fn func() float64) {
+	s.scope.setGaugeFunc(name, s.Gauge(name), fn)
+}
+
+func (s *scope) setGaugeFunc(name string, g Gauge, fn func() float64) {
+	if _, ok := g.(noopMetric); ok || fn == nil {
+		return
+	}
+	name = s.sanitizer.Name(name)
+
+	s.gm.Lock()
+	defer s.gm.Unlock()
+
+	if s.gaugeFuncs == nil {
+		s.gaugeFuncs = make(map[string]gaugeFunc)
+	}
+	s.gaugeFuncs[name] = gaugeFunc{gauge: g, fn: fn}
+}
+
+// appendGaugeFuncs appends the gauge callbacks of the scope to funcs.
+func (s *scope) appendGaugeFuncs(funcs []gaugeFunc) []gaugeFunc {
+	if s.closed.Load() {
+		return funcs
+	}
+
+	s.gm.RLock()
+	defer s.gm.RUnlock()
+
+	for _, f := range s.gaugeFuncs {
+		funcs = append(funcs, f)
+	}
+	return funcs
+}
+
+// updateGaugeFuncs updates the gauges with a callback of every scope of
+// the registry. It is called before the metric TTL is applied so that
+// these gauges are never idle, and calls the callbacks without any lock
+// held so that they can use the scopes.
+func (r *scopeRegistry) updateGaugeFuncs() {
+	var (
+		seen  map[*scope]struct{}
+		funcs []gaugeFunc
+	)
+	r.ForEachScope(func(s *scope) {
+		if s.gaugeFuncsLen() == 0 {
+			return
+		}
+		// NB: scopes can be registered under several keys.
+		if _, ok := seen[s]; ok {
+			return
+		}
+		if seen == nil {
+			seen = make(map[*scope]struct{})
+		}
+		seen[s] = struct{}{}
+		funcs = s.appendGaugeFuncs(funcs)
+	})
+
+	for _, f := range funcs {
+		f.gauge.Update(f.fn())
+	}
+}
+
+func (s *scope) gaugeFuncsLen() int {
+	s.gm.RLock()
+	defer s.gm.RUnlock()
+
+	return len(s.gaugeFuncs)
+}
@@ -0,0 +1,76 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tally
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGaugeFunc(t *testing.T) {
+	root, closer := NewRootScope(ScopeOptions{Reporter: NullStatsReporter, MetricsOption: OmitInternalMetrics}, 0)
+	defer closer.Close()
+	s := root.(*scope)
+	pod := root.Tagged(map[string]string{"pod": "a"})
+
+	depth := 0
+	assert.True(t, GaugeFunc(pod, "depth", func() float64 {
+		depth++
+		// Callbacks can use the scope they belong to.
+		pod.Counter("samples").Inc(1)
+		return float64(depth)
+	}))
+	assert.Equal(t, 0, depth, "callbacks are only called on report")
+
+	s.reportRegistry()
+	s.reportRegistry()
+	snapshot := s.Snapshot()
+	assert.Equal(t, 2.0, snapshot.Gauges()["depth+pod=a"].Value())
+	assert.Contains(t, snapshot.Counters(), "samples+pod=a")
+
+	assert.True(t, RemoveGauge(pod, "depth"))
+	s.reportRegistry()
+	assert.Equal(t, 2, depth)
+	assert.False(t, GaugeFunc(struct{ Scope }{root}, "depth", func() float64 { return 0 }))
+}
+
+func TestGaugeFuncNotExpired(t *testing.T) {
+	now := time.Unix(1e9, 0)
+	defer func(prev func() time.Time) { globalNow = prev }(globalNow)
+	globalNow = func() time.Time { return now }
+
+	root, closer := NewRootScope(ScopeOptions{
+		Reporter:      NullStatsReporter,
+		MetricsOption: OmitInternalMetrics,
+		MetricTTL:     time.Minute,
+	}, 0)
+	defer closer.Close()
+	s := root.(*scope)
+
+	GaugeFunc(root, "constant", func() float64 { return 1 })
+	for i := 0; i < 3; i++ {
+		s.reportRegistry()
+		now = now.Add(time.Minute)
+	}
+	assert.Len(t, s.Snapshot().Gauges(), 1)
+}
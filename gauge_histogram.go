@@ -0,0 +1,280 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tally
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"sync/atomic"
+)
+
+// gaugeHistogramBucketTag is the tag of the gauges a gauge histogram is
+// reported as to reporters which don't support gauge histograms.
+const gaugeHistogramBucketTag = "le"
+
+// GaugeHistogram is the distribution of a sampled level, e.g. the depth of
+// a queue sampled every second. Unlike a histogram, which counts events
+// since it was created, it reports the distribution of the samples
+// recorded since the previous report, and nothing if there was none.
+type GaugeHistogram interface {
+	// RecordValue records a sample.
+	RecordValue(value float64)
+}
+
+// GaugeHistogramScope is a Scope which can create gauge histograms. As
+// Scope can't be extended, gauge histograms are created by the function
+// GaugeHistogramOf rather than by a method of Scope.
+type GaugeHistogramScope interface {
+	Scope
+
+	// GaugeHistogram returns the gauge histogram with the given name and
+	// value buckets.
+	GaugeHistogram(name string, buckets Buckets) GaugeHistogram
+}
+
+// GaugeHistogramReporter is implemented by StatsReporters which support
+// gauge histograms natively, e.g. as the OpenMetrics gaugehistogram type.
+// Other reporters receive gauge histograms as a gauge per bucket, tagged
+// with the upper bound of the bucket as le.
+type GaugeHistogramReporter interface {
+	// ReportGaugeHistogram reports the distribution of the samples of a
+	// gauge histogram: samples[i] is the number of samples in the bucket
+	// with the upper bound upperBounds[i], the last of which is +Inf.
+	ReportGaugeHistogram(
+		name string,
+		tags map[string]string,
+		upperBounds []float64,
+		samples []int64,
+	)
+}
+
+// CachedGaugeHistogramReporter is implemented by CachedStatsReporters which
+// support gauge histograms natively, other reporters allocate them as a
+// gauge per bucket like GaugeHistogramReporter.
+type CachedGaugeHistogramReporter interface {
+	// AllocateGaugeHistogram pre allocates a gauge histogram data
+	// structure with name, tags and the upper bounds of its buckets.
+	AllocateGaugeHistogram(
+		name string,
+		tags map[string]string,
+		upperBounds []float64,
+	) CachedGaugeHistogram
+}
+
+// CachedGaugeHistogram interface for reporting an individual gauge
+// histogram
+type CachedGaugeHistogram interface {
+	ReportGaugeHistogram(samples []int64)
+}
+
+// GaugeHistogramOf returns s.GaugeHistogram(name, buckets) if s is a
+// GaugeHistogramScope, otherwise a gauge histogram discarding its samples.
+func GaugeHistogramOf(s Scope, name string, buckets Buckets) GaugeHistogram {
+	if gs, ok := s.(GaugeHistogramScope); ok {
+		return gs.GaugeHistogram(name, buckets)
+	}
+	return noopGaugeHistogram{}
+}
+
+func (s *scope) GaugeHistogram(name string, buckets Buckets) GaugeHistogram {
+	name = s.sanitizer.Name(name)
+	if h, ok := s.gaugeHistogram(name); ok {
+		return h
+	}
+
+	s.registry.checkName(name)
+	if !s.metricEnabled(name) {
+		return noopGaugeHistogram{}
+	}
+	if o, ok := s.overflowScope(gaugeHistogramSeries, name); ok {
+		return o.GaugeHistogram(name, buckets)
+	}
+
+	if buckets == nil {
+		buckets = s.defaultBuckets
+	}
+
+	s.hm.Lock()
+	defer s.hm.Unlock()
+
+	if h, ok := s.gaugeHistograms[name]; ok {
+		return h
+	}
+
+	h := newGaugeHistogram(buckets, s.guard)
+	if s.cachedReporter != nil {
+		fullName := s.fullyQualifiedName(name)
+		if hr, ok := s.cachedReporter.(CachedGaugeHistogramReporter); ok {
+			h.cached = hr.AllocateGaugeHistogram(fullName, s.tags, h.upperBounds)
+		} else {
+			h.cachedGauges = make([]CachedGauge, len(h.upperBounds))
+			for i, tags := range h.bucketTags(s.tags) {
+				h.cachedGauges[i] = s.cachedReporter.AllocateGauge(fullName, tags)
+			}
+		}
+	}
+	if s.gaugeHistograms == nil {
+		s.gaugeHistograms = make(map[string]*gaugeHistogram)
+	}
+	s.gaugeHistograms[name] = h
+	s.registry.churn.record(s.tags, 1, false)
+	s.gaugeHistogramsSlice = append(s.gaugeHistogramsSlice, h)
+
+	return h
+}
+
+func (s *scope) gaugeHistogram(sanitizedName string) (GaugeHistogram, bool) {
+	s.hm.RLock()
+	defer s.hm.RUnlock()
+
+	h, ok := s.gaugeHistograms[sanitizedName]
+	return h, ok
+}
+
+func (s *leveledScope) GaugeHistogram(name string, buckets Buckets) GaugeHistogram {
+	return leveledGaugeHistogram{s, s.scope.GaugeHistogram(name, buckets)}
+}
+
+type leveledGaugeHistogram struct {
+	scope     *leveledScope
+	histogram GaugeHistogram
+}
+
+func (h leveledGaugeHistogram) RecordValue(value float64) {
+	if h.scope.enabled() {
+		h.histogram.RecordValue(value)
+	}
+}
+
+type noopGaugeHistogram struct{}
+
+func (noopGaugeHistogram) RecordValue(float64) {}
+
+// gaugeHistogram is the gauge histogram of a scope.
+type gaugeHistogram struct {
+	upperBounds []float64
+	samples     []int64
+	updated     uint32
+	guard       *closeGuard
+
+	cached       CachedGaugeHistogram
+	cachedGauges []CachedGauge
+	// reportTags are the tags of the gauges of each bucket, allocated on
+	// the first report to a reporter not supporting gauge histograms.
+	reportTags []map[string]string
+}
+
+func newGaugeHistogram(buckets Buckets, guard *closeGuard) *gaugeHistogram {
+	var upperBounds []float64
+	if buckets != nil {
+		upperBounds = copyAndSortValues(buckets.AsValues())
+	}
+	upperBounds = append(upperBounds, math.Inf(1))
+	return &gaugeHistogram{
+		upperBounds: upperBounds,
+		samples:     make([]int64, len(upperBounds)),
+		guard:       guard,
+	}
+}
+
+func (h *gaugeHistogram) RecordValue(value float64) {
+	if !h.guard.begin() {
+		return
+	}
+	idx := sort.SearchFloat64s(h.upperBounds, value)
+	atomic.AddInt64(&h.samples[idx], 1)
+	atomic.StoreUint32(&h.updated, 1)
+	h.guard.end()
+}
+
+// take returns the samples recorded since it was last called and resets
+// them, or nil if there was none.
+func (h *gaugeHistogram) take() []int64 {
+	if atomic.SwapUint32(&h.updated, 0) == 0 {
+		return nil
+	}
+	samples := make([]int64, len(h.samples))
+	for i := range h.samples {
+		samples[i] = atomic.SwapInt64(&h.samples[i], 0)
+	}
+	return samples
+}
+
+// bucketTags returns tags with the upper bound of each bucket added.
+func (h *gaugeHistogram) bucketTags(tags map[string]string) []map[string]string {
+	bucketTags := make([]map[string]string, len(h.upperBounds))
+	for i, upperBound := range h.upperBounds {
+		bucketTags[i] = mergeRightTags(tags, map[string]string{
+			gaugeHistogramBucketTag: formatUpperBound(upperBound),
+		})
+	}
+	return bucketTags
+}
+
+func (h *gaugeHistogram) report(name string, tags map[string]string, r StatsReporter) {
+	samples := h.take()
+	if samples == nil {
+		return
+	}
+
+	if hr, ok := r.(GaugeHistogramReporter); ok {
+		hr.ReportGaugeHistogram(name, tags, h.upperBounds, samples)
+		return
+	}
+	if h.reportTags == nil {
+		h.reportTags = h.bucketTags(tags)
+	}
+	for i, n := range samples {
+		r.ReportGauge(name, h.reportTags[i], float64(n))
+	}
+}
+
+func (h *gaugeHistogram) cachedReport() {
+	samples := h.take()
+	if samples == nil {
+		return
+	}
+
+	if h.cached != nil {
+		h.cached.ReportGaugeHistogram(samples)
+		return
+	}
+	for i, g := range h.cachedGauges {
+		g.ReportGauge(float64(samples[i]))
+	}
+}
+
+func sortedGaugeHistogramNames(m map[string]*gaugeHistogram) []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func formatUpperBound(upperBound float64) string {
+	if math.IsInf(upperBound, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(upperBound, 'g', -1, 64)
+}
@@ -0,0 +1,72 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tally
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type gaugeHistogramRecordingReporter struct {
+	nullStatsReporter
+	upperBounds []float64
+	samples     [][]int64
+}
+
+func (r *gaugeHistogramRecordingReporter) ReportGaugeHistogram(
+	_ string, _ map[string]string, upperBounds []float64, samples []int64,
+) {
+	r.upperBounds = upperBounds
+	r.samples = append(r.samples, samples)
+}
+
+func TestGaugeHistogram(t *testing.T) {
+	r := &gaugeHistogramRecordingReporter{}
+	root, closer := NewRootScope(ScopeOptions{Reporter: r, MetricsOption: OmitInternalMetrics}, 0)
+	defer closer.Close()
+
+	h := GaugeHistogramOf(root, "depth", ValueBuckets{100, 10})
+	assert.Equal(t, h, GaugeHistogramOf(root, "depth", nil))
+	h.RecordValue(1)
+	h.RecordValue(10)
+	h.RecordValue(1000)
+	root.(*scope).reportRegistry()
+
+	// Nothing is reported without samples since the previous report.
+	root.(*scope).reportRegistry()
+	h.RecordValue(50)
+	root.(*scope).reportRegistry()
+
+	assert.Equal(t, []float64{10, 100, math.Inf(1)}, r.upperBounds)
+	assert.Equal(t, [][]int64{{2, 0, 1}, {0, 1, 0}}, r.samples)
+}
+
+func TestGaugeHistogramAsGauges(t *testing.T) {
+	r := &valueRecordingReporter{counters: make(map[string]int64)}
+	root, closer := NewRootScope(ScopeOptions{Reporter: r, MetricsOption: OmitInternalMetrics}, 0)
+	defer closer.Close()
+
+	GaugeHistogramOf(root.Tagged(map[string]string{"queue": "a"}), "depth", ValueBuckets{10}).RecordValue(1)
+	root.(*scope).reportRegistry()
+	assert.ElementsMatch(t, []string{"depth+le=10,queue=a", "depth+le=+Inf,queue=a"}, r.gauges)
+}
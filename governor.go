@@ -0,0 +1,150 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tally
+
+import (
+	"runtime"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultGovernorMaxIntervalFactor = 8
+
+	throttleFactorName = "tally_internal_report_throttle_factor"
+)
+
+// GovernorOptions configures a governor which stretches the reporting
+// interval of a root scope while the process is under memory pressure, so
+// that reporting doesn't add to it. The reporting interval is doubled for
+// each interval over budget, up to MaxIntervalFactor times the configured
+// interval, and restored as soon as the process is back within budget.
+//
+// The current factor is reported on every report by the gauge
+// tally_internal_report_throttle_factor, it is 1 while not throttled.
+type GovernorOptions struct {
+	// MaxAllocBytesPerSecond is the allocation rate of the process above
+	// which reporting is throttled, zero disables the check.
+	MaxAllocBytesPerSecond float64
+
+	// MaxGCPauseFraction is the fraction of time spent in stop the world
+	// garbage collection pauses above which reporting is throttled, zero
+	// disables the check.
+	MaxGCPauseFraction float64
+
+	// MaxIntervalFactor is the maximum factor the reporting interval is
+	// multiplied by while throttled, it defaults to 8.
+	MaxIntervalFactor int
+}
+
+// governor decides which reports of a report loop to skip.
+type governor struct {
+	opts         GovernorOptions
+	readMemStats func(*runtime.MemStats)
+
+	// Only accessed by the report loop.
+	last      time.Time
+	lastAlloc uint64
+	lastPause uint64
+	ticks     int
+
+	// factor is read by reports, which may run outside the report loop.
+	factor int64
+}
+
+func newGovernor(opts *GovernorOptions) *governor {
+	if opts == nil {
+		return nil
+	}
+	g := &governor{
+		opts:         *opts,
+		readMemStats: runtime.ReadMemStats,
+		factor:       1,
+	}
+	if g.opts.MaxIntervalFactor <= 0 {
+		g.opts.MaxIntervalFactor = defaultGovernorMaxIntervalFactor
+	}
+	return g
+}
+
+// skip samples the memory statistics of the process and returns whether
+// the report due at now should be skipped.
+func (g *governor) skip(now time.Time) bool {
+	if !g.overBudget(now) {
+		atomic.StoreInt64(&g.factor, 1)
+		g.ticks = 0
+		return false
+	}
+
+	// Stretch the interval further at the start of each throttled
+	// interval.
+	factor := atomic.LoadInt64(&g.factor)
+	if g.ticks == 0 {
+		factor *= 2
+		if max := int64(g.opts.MaxIntervalFactor); factor > max {
+			factor = max
+		}
+		atomic.StoreInt64(&g.factor, factor)
+	}
+
+	g.ticks++
+	if int64(g.ticks) < factor {
+		return true
+	}
+	g.ticks = 0
+	return false
+}
+
+func (g *governor) overBudget(now time.Time) bool {
+	var stats runtime.MemStats
+	g.readMemStats(&stats)
+
+	last, lastAlloc, lastPause := g.last, g.lastAlloc, g.lastPause
+	g.last, g.lastAlloc, g.lastPause = now, stats.TotalAlloc, stats.PauseTotalNs
+	if last.IsZero() {
+		return false
+	}
+
+	elapsed := now.Sub(last)
+	if elapsed <= 0 {
+		return false
+	}
+
+	if max := g.opts.MaxAllocBytesPerSecond; max > 0 {
+		rate := float64(stats.TotalAlloc-lastAlloc) / elapsed.Seconds()
+		if rate > max {
+			return true
+		}
+	}
+	if max := g.opts.MaxGCPauseFraction; max > 0 {
+		fraction := float64(stats.PauseTotalNs-lastPause) / float64(elapsed)
+		if fraction > max {
+			return true
+		}
+	}
+	return false
+}
+
+// throttleFactor returns the factor the reporting interval is currently
+// multiplied by.
+func (g *governor) throttleFactor() float64 {
+	return float64(atomic.LoadInt64(&g.factor))
+}
@@ -0,0 +1,103 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tally
+
+import (
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGovernorSkip(t *testing.T) {
+	var totalAlloc uint64
+	g := newGovernor(&GovernorOptions{
+		MaxAllocBytesPerSecond: 100,
+		MaxIntervalFactor:      4,
+	})
+	g.readMemStats = func(stats *runtime.MemStats) {
+		stats.TotalAlloc = totalAlloc
+	}
+
+	now := time.Unix(0, 0)
+	tick := func(alloc uint64) bool {
+		totalAlloc += alloc
+		now = now.Add(time.Second)
+		return g.skip(now)
+	}
+
+	// The first sample has no rate.
+	assert.False(t, tick(1000))
+	assert.False(t, tick(10))
+	assert.Equal(t, 1.0, g.throttleFactor())
+
+	// Over budget: the interval doubles at each tick up to 4 times.
+	assert.True(t, tick(1000))
+	assert.Equal(t, 2.0, g.throttleFactor())
+	assert.False(t, tick(1000))
+	assert.True(t, tick(1000))
+	assert.Equal(t, 4.0, g.throttleFactor())
+	assert.True(t, tick(1000))
+	assert.True(t, tick(1000))
+	assert.False(t, tick(1000))
+	assert.Equal(t, 4.0, g.throttleFactor())
+
+	// Back within budget: reports resume immediately.
+	assert.True(t, tick(1000))
+	assert.False(t, tick(10))
+	assert.Equal(t, 1.0, g.throttleFactor())
+	assert.False(t, tick(10))
+}
+
+func TestGovernorGCPause(t *testing.T) {
+	var pause uint64
+	g := newGovernor(&GovernorOptions{MaxGCPauseFraction: 0.01})
+	g.readMemStats = func(stats *runtime.MemStats) {
+		stats.PauseTotalNs = pause
+	}
+
+	now := time.Unix(0, 0)
+	assert.False(t, g.skip(now))
+
+	pause += uint64(100 * time.Millisecond)
+	now = now.Add(time.Second)
+	assert.True(t, g.skip(now))
+	assert.Equal(t, 2.0, g.throttleFactor())
+}
+
+func TestGovernorReportsThrottleFactor(t *testing.T) {
+	r := newTestStatsReporter()
+	root := newRootScope(ScopeOptions{
+		Reporter:      r,
+		Governor:      &GovernorOptions{},
+		MetricsOption: OmitInternalMetrics,
+	}, 0)
+
+	// Reported once by the report and once by closing the scope.
+	r.gg.Add(2)
+	root.reportRegistry()
+	assert.NoError(t, root.Close())
+	r.WaitAll()
+	assert.Equal(t, 1.0, r.getGauges()[throttleFactorName].val)
+
+	assert.Nil(t, newGovernor(nil))
+}
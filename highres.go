@@ -0,0 +1,303 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tally
+
+import (
+	"errors"
+	"path"
+	"sync/atomic"
+	"time"
+	"unsafe"
+)
+
+const (
+	defaultHighResolutionInterval = time.Second
+	defaultHighResolutionSuffix   = "_hires"
+)
+
+var (
+	errHighResolutionDuration   = errors.New("high resolution duration must be positive")
+	errHighResolutionNoReporter = errors.New("scope has no reporter")
+)
+
+// HighResolutionOptions configures a temporary high resolution mode for
+// some of the metrics of a root scope.
+type HighResolutionOptions struct {
+	// Patterns are path.Match patterns matched against fully qualified
+	// metric names, the metrics matching any of them are reported at
+	// the high resolution interval.
+	Patterns []string
+
+	// Interval is the reporting interval of the matching metrics, it
+	// defaults to one second.
+	Interval time.Duration
+
+	// Duration is how long the high resolution mode lasts before it is
+	// reverted.
+	Duration time.Duration
+
+	// Buckets if set are finer buckets which the matching histograms with
+	// the same type of buckets additionally record to, reported as
+	// separate histograms named with HistogramSuffix appended.
+	Buckets Buckets
+
+	// HistogramSuffix is appended to the name of the histograms with the
+	// finer buckets, it defaults to "_hires".
+	HistogramSuffix string
+}
+
+// EnableHighResolution reports the metrics of the root scope s matching
+// the given patterns at a higher resolution for a bounded duration, after
+// which the mode reverts automatically. This allows collecting detailed
+// data during an incident without a redeploy. The returned function
+// reverts the mode early, it is also reverted when s is closed.
+//
+// The values of matching counters, gauges and histograms are reported at
+// every high resolution interval in addition to the regular reports of s,
+// timers are always reported as they are recorded.
+func EnableHighResolution(s Scope, opts HighResolutionOptions) (func(), error) {
+	root, ok := s.(*scope)
+	if !ok || !root.root {
+		return nil, errNotRootScope
+	}
+	if opts.Duration <= 0 {
+		return nil, errHighResolutionDuration
+	}
+	if root.reporter == nil && root.cachedReporter == nil {
+		return nil, errHighResolutionNoReporter
+	}
+	if opts.Interval <= 0 {
+		opts.Interval = defaultHighResolutionInterval
+	}
+	if opts.HistogramSuffix == "" {
+		opts.HistogramSuffix = defaultHighResolutionSuffix
+	}
+
+	hr := &highResolution{
+		root: root,
+		opts: opts,
+		stop: make(chan struct{}),
+	}
+	if opts.Buckets != nil {
+		hr.htype = valueHistogramType
+		if _, ok := opts.Buckets.(DurationBuckets); ok {
+			hr.htype = durationHistogramType
+		}
+	}
+
+	root.wg.Add(1)
+	go func() {
+		defer root.wg.Done()
+		hr.run()
+	}()
+
+	var stopped int32
+	return func() {
+		if atomic.CompareAndSwapInt32(&stopped, 0, 1) {
+			close(hr.stop)
+		}
+	}, nil
+}
+
+type highResolution struct {
+	root    *scope
+	opts    HighResolutionOptions
+	htype   histogramType
+	stop    chan struct{}
+	shadows []*histogram
+}
+
+func (hr *highResolution) run() {
+	ticker := time.NewTicker(hr.opts.Interval)
+	defer ticker.Stop()
+	expired := time.NewTimer(hr.opts.Duration)
+	defer expired.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			hr.report()
+		case <-expired.C:
+			hr.revert()
+			return
+		case <-hr.stop:
+			hr.revert()
+			return
+		case <-hr.root.done:
+			hr.revert()
+			return
+		}
+	}
+}
+
+func (hr *highResolution) matches(name string) bool {
+	for _, pattern := range hr.opts.Patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// report reports the matching metrics of all scopes of the registry,
+// attaching the histograms with finer buckets as needed.
+func (hr *highResolution) report() {
+	var (
+		root     = hr.root
+		registry = root.registry
+		reporter = root.reporter
+	)
+
+	registry.reportMu.Lock()
+	defer registry.reportMu.Unlock()
+
+	// The final report of a closed root scope may have closed its reporter.
+	if root.closed.Load() {
+		return
+	}
+
+	if registry.rollups != nil {
+		if reporter != nil {
+			defer registry.rollups.report(reporter)
+			reporter = registry.rollups.wrapReporter(reporter)
+		} else {
+			defer registry.rollups.cachedReport(root.cachedReporter)
+		}
+	}
+
+	registry.forEachUniqueScope(func(s *scope, _ map[string]string) bool {
+		s.cm.RLock()
+		for name, c := range s.counters {
+			if name = s.fullyQualifiedName(name); hr.matches(name) {
+				if reporter != nil {
+					c.report(name, s.tags, reporter)
+				} else {
+					c.cachedReport()
+				}
+			}
+		}
+		s.cm.RUnlock()
+
+		s.gm.RLock()
+		for name, g := range s.gauges {
+			if name = s.fullyQualifiedName(name); hr.matches(name) {
+				if reporter != nil {
+					g.report(name, s.tags, reporter)
+				} else {
+					g.cachedReport()
+				}
+			}
+		}
+		s.gm.RUnlock()
+
+		s.hm.RLock()
+		for name, h := range s.histograms {
+			if name = s.fullyQualifiedName(name); !hr.matches(name) {
+				continue
+			}
+			hr.attach(s, h)
+			if reporter != nil {
+				h.report(name, s.tags, reporter)
+			} else {
+				h.cachedReport()
+			}
+			if shadow := h.loadShadow(); shadow != nil {
+				if reporter != nil {
+					shadow.report(shadow.name, shadow.tags, reporter)
+				} else {
+					shadow.cachedReport()
+				}
+			}
+		}
+		s.hm.RUnlock()
+		return true
+	})
+
+	if root.baseReporter != nil {
+		root.baseReporter.Flush()
+	}
+}
+
+// attach attaches a histogram with the finer buckets to h if it has the
+// same type of buckets and none is attached yet.
+func (hr *highResolution) attach(s *scope, h *histogram) {
+	if hr.opts.Buckets == nil || h.htype != hr.htype || h.loadShadow() != nil {
+		return
+	}
+
+	name := h.name + hr.opts.HistogramSuffix
+	var cachedHistogram CachedHistogram
+	if s.cachedReporter != nil {
+		cachedHistogram = s.cachedReporter.AllocateHistogram(name, s.tags, hr.opts.Buckets)
+	}
+	shadow := newHistogram(
+		h.htype,
+		name,
+		s.tags,
+		s.reporter,
+		s.bucketCache.Get(h.htype, hr.opts.Buckets),
+		cachedHistogram,
+		s.padHistogramBuckets,
+		s.registry.slabs,
+	)
+	if atomic.CompareAndSwapPointer(&h.shadow, nil, unsafe.Pointer(shadow)) {
+		hr.shadows = append(hr.shadows, shadow)
+	}
+}
+
+// revert detaches the histograms with finer buckets and reports their
+// remaining values.
+func (hr *highResolution) revert() {
+	var (
+		root     = hr.root
+		registry = root.registry
+	)
+
+	registry.reportMu.Lock()
+	defer registry.reportMu.Unlock()
+
+	registry.ForEachScope(func(s *scope) {
+		s.hm.RLock()
+		for _, h := range s.histograms {
+			for _, shadow := range hr.shadows {
+				atomic.CompareAndSwapPointer(&h.shadow, unsafe.Pointer(shadow), nil)
+			}
+		}
+		s.hm.RUnlock()
+	})
+
+	if root.closed.Load() {
+		hr.shadows = nil
+		return
+	}
+
+	for _, shadow := range hr.shadows {
+		if root.reporter != nil {
+			shadow.report(shadow.name, shadow.tags, root.reporter)
+		} else {
+			shadow.cachedReport()
+		}
+	}
+	if len(hr.shadows) > 0 && root.baseReporter != nil {
+		root.baseReporter.Flush()
+	}
+	hr.shadows = nil
+}
@@ -0,0 +1,163 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tally
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type highResolutionRecordingReporter struct {
+	nullStatsReporter
+
+	mu         sync.Mutex
+	counters   map[string]int64
+	histograms map[string]map[float64]int64
+}
+
+func newHighResolutionRecordingReporter() *highResolutionRecordingReporter {
+	return &highResolutionRecordingReporter{
+		counters:   make(map[string]int64),
+		histograms: make(map[string]map[float64]int64),
+	}
+}
+
+func (r *highResolutionRecordingReporter) ReportCounter(name string, tags map[string]string, value int64) {
+	r.mu.Lock()
+	r.counters[name] += value
+	r.mu.Unlock()
+}
+
+func (r *highResolutionRecordingReporter) ReportHistogramValueSamples(
+	name string,
+	tags map[string]string,
+	buckets Buckets,
+	bucketLowerBound,
+	bucketUpperBound float64,
+	samples int64,
+) {
+	r.mu.Lock()
+	if r.histograms[name] == nil {
+		r.histograms[name] = make(map[float64]int64)
+	}
+	r.histograms[name][bucketUpperBound] += samples
+	r.mu.Unlock()
+}
+
+func (r *highResolutionRecordingReporter) counter(name string) int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.counters[name]
+}
+
+func (r *highResolutionRecordingReporter) histogram(name string) map[float64]int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	result := make(map[float64]int64, len(r.histograms[name]))
+	for k, v := range r.histograms[name] {
+		result[k] = v
+	}
+	return result
+}
+
+func TestEnableHighResolution(t *testing.T) {
+	r := newHighResolutionRecordingReporter()
+	root, closer := NewRootScope(ScopeOptions{Reporter: r, MetricsOption: OmitInternalMetrics}, 0)
+	defer closer.Close()
+
+	sub := root.SubScope("db")
+	sub.Counter("queries").Inc(1)
+	sub.Counter("errors").Inc(1)
+	h := sub.Histogram("latency", ValueBuckets{10})
+
+	revert, err := EnableHighResolution(root, HighResolutionOptions{
+		Patterns: []string{"db.q*", "db.latency"},
+		Interval: time.Millisecond,
+		Duration: time.Hour,
+		Buckets:  ValueBuckets{1, 2, 5, 10},
+	})
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool {
+		return r.counter("db.queries") == 1
+	}, time.Second, time.Millisecond)
+	assert.Zero(t, r.counter("db.errors"))
+
+	// Wait for the histogram with the finer buckets to be attached.
+	require.Eventually(t, func() bool {
+		return h.(*histogram).loadShadow() != nil
+	}, time.Second, time.Millisecond)
+	h.RecordValue(1.5)
+	require.Eventually(t, func() bool {
+		return r.histogram("db.latency_hires")[2] == 1
+	}, time.Second, time.Millisecond)
+	assert.Equal(t, int64(1), r.histogram("db.latency")[10])
+
+	revert()
+	require.Eventually(t, func() bool {
+		return h.(*histogram).loadShadow() == nil
+	}, time.Second, time.Millisecond)
+	revert()
+
+	h.RecordValue(1.5)
+	root.(*scope).reportRegistry()
+	assert.Equal(t, int64(1), r.histogram("db.latency_hires")[2])
+	assert.Equal(t, int64(2), r.histogram("db.latency")[10])
+	assert.Equal(t, int64(1), r.counter("db.errors"))
+}
+
+func TestEnableHighResolutionExpires(t *testing.T) {
+	r := newHighResolutionRecordingReporter()
+	root, closer := NewRootScope(ScopeOptions{Reporter: r, MetricsOption: OmitInternalMetrics}, 0)
+	defer closer.Close()
+
+	h := root.Histogram("latency", ValueBuckets{10})
+	_, err := EnableHighResolution(root, HighResolutionOptions{
+		Patterns: []string{"latency"},
+		Interval: time.Millisecond,
+		Duration: 50 * time.Millisecond,
+		Buckets:  ValueBuckets{1, 2, 5, 10},
+	})
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool {
+		return h.(*histogram).loadShadow() != nil
+	}, time.Second, time.Millisecond)
+	require.Eventually(t, func() bool {
+		return h.(*histogram).loadShadow() == nil
+	}, time.Second, time.Millisecond)
+}
+
+func TestEnableHighResolutionErrors(t *testing.T) {
+	root, closer := NewRootScope(ScopeOptions{Reporter: NullStatsReporter}, 0)
+	defer closer.Close()
+
+	_, err := EnableHighResolution(root.SubScope("a"), HighResolutionOptions{Duration: time.Second})
+	assert.Error(t, err)
+	_, err = EnableHighResolution(root, HighResolutionOptions{})
+	assert.Error(t, err)
+	_, err = EnableHighResolution(NewTestScope("", nil), HighResolutionOptions{Duration: time.Second})
+	assert.Error(t, err)
+}
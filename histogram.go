@@ -126,6 +126,26 @@ func (v DurationBuckets) AsDurations() []time.Duration {
 	return v
 }
 
+// BucketedHistogram is a Histogram exposing its buckets, e.g. for tests to
+// assert that configured buckets took effect.
+type BucketedHistogram interface {
+	Histogram
+
+	// Buckets returns the buckets of the histogram, the default buckets
+	// of its scope if it was created without buckets, or nil if it has
+	// no buckets yet.
+	Buckets() Buckets
+}
+
+// HistogramBuckets returns h.Buckets() if h is a BucketedHistogram,
+// otherwise nil.
+func HistogramBuckets(h Histogram) Buckets {
+	if bh, ok := h.(BucketedHistogram); ok {
+		return bh.Buckets()
+	}
+	return nil
+}
+
 func bucketsEqual(x Buckets, y Buckets) bool {
 	switch b1 := x.(type) {
 	case DurationBuckets:
@@ -323,7 +343,8 @@ func MustMakeLinearDurationBuckets(start, width time.Duration, n int) DurationBu
 	return buckets
 }
 
-// ExponentialValueBuckets creates a set of exponential value buckets.
+// ExponentialValueBuckets creates a set of n exponential value buckets,
+// start multiplied by factor to the power of 0 through n-1.
 func ExponentialValueBuckets(start, factor float64, n int) (ValueBuckets, error) {
 	if n <= 0 {
 		return nil, errBucketsCountNeedsGreaterThanZero
@@ -353,7 +374,10 @@ func MustMakeExponentialValueBuckets(start, factor float64, n int) ValueBuckets
 	return buckets
 }
 
-// ExponentialDurationBuckets creates a set of exponential duration buckets.
+// ExponentialDurationBuckets creates a set of n exponential duration
+// buckets, start multiplied by factor to the power of 0 through n-1. For
+// example a start of 1ms, a factor of 2 and 17 buckets cover latencies
+// from 1ms to about 65s.
 func ExponentialDurationBuckets(start time.Duration, factor float64, n int) (DurationBuckets, error) {
 	if n <= 0 {
 		return nil, errBucketsCountNeedsGreaterThanZero
@@ -373,8 +397,8 @@ func ExponentialDurationBuckets(start time.Duration, factor float64, n int) (Dur
 	return buckets, nil
 }
 
-// MustMakeExponentialDurationBuckets creates a set of exponential value buckets
-// or panics.
+// MustMakeExponentialDurationBuckets creates a set of exponential duration
+// buckets or panics.
 func MustMakeExponentialDurationBuckets(start time.Duration, factor float64, n int) DurationBuckets {
 	buckets, err := ExponentialDurationBuckets(start, factor, n)
 	if err != nil {
@@ -0,0 +1,58 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tally
+
+// HistogramOptions are the options of a histogram created by
+// HistogramWithOptions. Unlike the positional buckets of Scope.Histogram,
+// options can be added without breaking callers, so new histogram options
+// are added here.
+type HistogramOptions struct {
+	// Buckets are the buckets of the histogram, the default buckets of the
+	// scope if nil. Buckets made by MustMakeLinearValueBuckets and friends
+	// can be used as is.
+	Buckets Buckets
+
+	// Unit is the unit of the values of the histogram, see
+	// MetricMetadata.Unit.
+	Unit string
+
+	// Description describes the histogram, see MetricMetadata.Help.
+	Description string
+}
+
+// HistogramWithOptions returns the histogram with the given name and
+// options. Its metadata is exported by the scope's reporter if s is a
+// DescribedScope, like HistogramWith.
+func HistogramWithOptions(s Scope, name string, opts HistogramOptions) Histogram {
+	return HistogramWith(s, name, opts.Buckets, opts.metricOptions()...)
+}
+
+// metricOptions returns the metadata options of the histogram.
+func (o HistogramOptions) metricOptions() []MetricOption {
+	var opts []MetricOption
+	if o.Description != "" {
+		opts = append(opts, WithHelp(o.Description))
+	}
+	if o.Unit != "" {
+		opts = append(opts, WithUnit(o.Unit))
+	}
+	return opts
+}
@@ -0,0 +1,137 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tally
+
+import (
+	"path"
+	"time"
+)
+
+// DefaultHistogramOutlierSuffix is the default suffix of the counters of
+// values dropped by histogram outlier rules.
+const DefaultHistogramOutlierSuffix = "_outliers"
+
+// OutlierPolicy is the handling of the values recorded to a histogram
+// outside of the bounds of its outlier rule.
+type OutlierPolicy int
+
+const (
+	// OutlierRecord records outliers as is, e.g. a value above the last
+	// bucket of a histogram in its overflow bucket.
+	OutlierRecord OutlierPolicy = iota
+	// OutlierClamp records outliers at the nearest bound.
+	OutlierClamp
+	// OutlierDrop doesn't record outliers, but counts them in a counter of
+	// the same scope named after the histogram with the rule's Suffix
+	// appended.
+	OutlierDrop
+)
+
+// HistogramOutlierRule configures the handling of absurd values recorded to
+// the histograms whose name matches the rule, such as negative durations
+// or values above a sanity cap, which would otherwise be recorded in the
+// first or overflow bucket and skew percentiles.
+type HistogramOutlierRule struct {
+	// Name is a path.Match pattern matched against fully qualified
+	// histogram names.
+	Name string
+
+	// Policy is the handling of outliers.
+	Policy OutlierPolicy
+
+	// Min and Max bound the values recorded to value histograms, a zero
+	// Max means values have no upper bound. Values below zero are outliers
+	// unless Min is set to a negative bound.
+	Min float64
+	Max float64
+
+	// MinDuration and MaxDuration bound the durations recorded to duration
+	// histograms, a zero MaxDuration means durations have no upper bound.
+	// Negative durations are outliers unless MinDuration is negative.
+	MinDuration time.Duration
+	MaxDuration time.Duration
+
+	// Suffix is appended to the name of the histogram to name the counter
+	// of dropped outliers. Use the empty string to specify
+	// DefaultHistogramOutlierSuffix.
+	Suffix string
+}
+
+// histogramOutliers applies an outlier rule to the values recorded to a
+// histogram.
+type histogramOutliers struct {
+	rule    HistogramOutlierRule
+	dropped Counter
+}
+
+// histogramOutlierRule returns the first rule matching the fully qualified
+// name of a histogram, unless it records outliers as is.
+func histogramOutlierRule(
+	rules []HistogramOutlierRule,
+	name string,
+) (HistogramOutlierRule, bool) {
+	for _, rule := range rules {
+		if ok, _ := path.Match(rule.Name, name); !ok {
+			continue
+		}
+		if rule.Policy == OutlierRecord {
+			return HistogramOutlierRule{}, false
+		}
+		if rule.Suffix == "" {
+			rule.Suffix = DefaultHistogramOutlierSuffix
+		}
+		return rule, true
+	}
+	return HistogramOutlierRule{}, false
+}
+
+// value returns the value to record in place of v, and false if v must
+// not be recorded.
+func (o *histogramOutliers) value(v float64) (float64, bool) {
+	switch {
+	case v < o.rule.Min:
+		return o.rule.Min, o.keep()
+	case o.rule.Max != 0 && v > o.rule.Max:
+		return o.rule.Max, o.keep()
+	}
+	return v, true
+}
+
+// duration returns the duration to record in place of d, and false if d
+// must not be recorded.
+func (o *histogramOutliers) duration(d time.Duration) (time.Duration, bool) {
+	switch {
+	case d < o.rule.MinDuration:
+		return o.rule.MinDuration, o.keep()
+	case o.rule.MaxDuration != 0 && d > o.rule.MaxDuration:
+		return o.rule.MaxDuration, o.keep()
+	}
+	return d, true
+}
+
+// keep returns whether an outlier is recorded, counting it if dropped.
+func (o *histogramOutliers) keep() bool {
+	if o.rule.Policy == OutlierDrop {
+		o.dropped.Inc(1)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,69 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tally
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHistogramOutliers(t *testing.T) {
+	root, closer := NewRootScope(ScopeOptions{
+		MetricsOption: OmitInternalMetrics,
+		HistogramOutliers: []HistogramOutlierRule{
+			{Name: "clamped", Policy: OutlierClamp, Max: 100},
+			{Name: "dropped", Policy: OutlierDrop, MaxDuration: time.Minute},
+			{Name: "recorded", Policy: OutlierRecord, Max: 1},
+		},
+	}, 0)
+	defer closer.Close()
+
+	clamped := root.Histogram("clamped", ValueBuckets{0, 50, 100})
+	clamped.RecordValue(-5)
+	clamped.RecordValue(1000)
+	clamped.RecordValue(math.Inf(1))
+
+	dropped := root.Histogram("dropped", DurationBuckets{time.Second, time.Minute})
+	dropped.RecordDuration(-time.Second)
+	dropped.RecordDuration(time.Hour)
+	dropped.RecordDuration(time.Millisecond)
+
+	recorded := root.Histogram("recorded", ValueBuckets{1})
+	recorded.RecordValue(10)
+
+	snap := root.(TestScope).Snapshot()
+	values := snap.Histograms()["clamped+"].Values()
+	assert.EqualValues(t, 1, values[0])
+	assert.EqualValues(t, 2, values[100])
+	assert.EqualValues(t, 0, values[math.MaxFloat64])
+
+	durations := snap.Histograms()["dropped+"].Durations()
+	assert.EqualValues(t, 1, durations[time.Second])
+	assert.EqualValues(t, 0, durations[time.Minute])
+	assert.EqualValues(t, 0, durations[time.Duration(math.MaxInt64)])
+	assert.EqualValues(t, 2, snap.Counters()["dropped_outliers+"].Value())
+
+	assert.EqualValues(t, 1, snap.Histograms()["recorded+"].Values()[math.MaxFloat64])
+	assert.NotContains(t, snap.Counters(), "recorded_outliers+")
+}
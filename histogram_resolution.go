@@ -0,0 +1,157 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tally
+
+import (
+	"math"
+	"math/rand"
+	"path"
+	"sync/atomic"
+)
+
+// DefaultHistogramResolutionSuffix is the default suffix of the fine
+// histograms of histogram resolution rules.
+const DefaultHistogramResolutionSuffix = "_fine"
+
+// HistogramResolutionRule configures the histograms whose name matches the
+// rule to additionally record to a histogram with finer buckets, so that
+// the coarse histograms are stored cheaply at all times while detailed
+// data is available when needed. The fine histogram is a histogram of the
+// same scope named after the histogram with Suffix appended.
+//
+// A fraction SampleRate of the values recorded to the histogram is
+// recorded to the fine histogram, its samples are those of the sampled
+// values. SetFineResolution changes the sample rate at runtime, e.g. to
+// turn fine histograms on only while investigating an issue.
+type HistogramResolutionRule struct {
+	// Name is a path.Match pattern matched against fully qualified
+	// histogram names.
+	Name string
+
+	// Buckets are the buckets of the fine histogram, the rule applies to
+	// the histograms with the same type of buckets only.
+	Buckets Buckets
+
+	// Suffix is appended to the name of the histogram to name the fine
+	// histogram. Use the empty string to specify
+	// DefaultHistogramResolutionSuffix.
+	Suffix string
+
+	// SampleRate is the fraction of the values recorded to the fine
+	// histogram, from 0 where fine histograms are created but record
+	// nothing until SetFineResolution is called, to 1 where every value is
+	// recorded.
+	SampleRate float64
+}
+
+// histogramResolutionRule returns the first rule matching the fully
+// qualified name of a histogram of the given type.
+func histogramResolutionRule(
+	rules []HistogramResolutionRule,
+	name string,
+	htype histogramType,
+) (HistogramResolutionRule, bool) {
+	for _, rule := range rules {
+		if ok, _ := path.Match(rule.Name, name); !ok || rule.Buckets == nil {
+			continue
+		}
+		if _, ok := rule.Buckets.(DurationBuckets); ok != (htype == durationHistogramType) {
+			continue
+		}
+		if rule.Suffix == "" {
+			rule.Suffix = DefaultHistogramResolutionSuffix
+		}
+		return rule, true
+	}
+	return HistogramResolutionRule{}, false
+}
+
+// fineResolution is the fine histogram of a histogram.
+type fineResolution struct {
+	histogram *histogram
+	// sampleRate holds the bits of the float64 sample rate.
+	sampleRate uint64
+}
+
+func (f *fineResolution) setSampleRate(rate float64) {
+	atomic.StoreUint64(&f.sampleRate, math.Float64bits(rate))
+}
+
+// sampled returns whether a value is recorded to the fine histogram.
+func (f *fineResolution) sampled() bool {
+	rate := math.Float64frombits(atomic.LoadUint64(&f.sampleRate))
+	return rate >= 1 || (rate > 0 && rand.Float64() < rate)
+}
+
+// addFineResolution adds a fine histogram to the histogram h with the
+// given sanitized name if a resolution rule matches it, it must be called
+// with hm held.
+func (s *scope) addFineResolution(name string, h *histogram) {
+	rule, ok := histogramResolutionRule(s.registry.histogramResolutions, h.name, h.htype)
+	if !ok {
+		return
+	}
+
+	fineName := derivedName(name, rule.Suffix)
+	fine, ok := s.histograms[fineName]
+	if !ok {
+		fine = s.newHistogram(fineName, rule.Buckets, h.htype)
+	} else if fine.htype != h.htype {
+		return
+	}
+	h.fine = &fineResolution{histogram: fine}
+	h.fine.setSampleRate(rule.SampleRate)
+}
+
+// SetFineResolution sets the sample rate of the fine histograms of the
+// histograms of the root scope s whose fully qualified name matches the
+// path.Match pattern name, see HistogramResolutionRule, and returns the
+// number of histograms matched.
+func SetFineResolution(s Scope, name string, sampleRate float64) (int, error) {
+	root, ok := s.(*scope)
+	if !ok || !root.root {
+		return 0, errNotRootScope
+	}
+	if _, err := path.Match(name, ""); err != nil {
+		return 0, err
+	}
+
+	var (
+		seen    = make(map[*histogram]struct{})
+		matched int
+	)
+	root.registry.ForEachScope(func(ss *scope) {
+		ss.hm.RLock()
+		defer ss.hm.RUnlock()
+
+		for _, h := range ss.histograms {
+			if _, ok := seen[h]; ok || h.fine == nil {
+				continue
+			}
+			seen[h] = struct{}{}
+			if ok, _ := path.Match(name, h.name); ok {
+				h.fine.setSampleRate(sampleRate)
+				matched++
+			}
+		}
+	})
+	return matched, nil
+}
@@ -0,0 +1,89 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tally
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHistogramResolution(t *testing.T) {
+	root, closer := NewRootScope(ScopeOptions{
+		Prefix:        "svc",
+		Reporter:      NullStatsReporter,
+		MetricsOption: OmitInternalMetrics,
+		HistogramResolutions: []HistogramResolutionRule{{
+			Name:       "svc.*latency",
+			Buckets:    DurationBuckets{time.Millisecond, 10 * time.Millisecond, 100 * time.Millisecond},
+			SampleRate: 1,
+		}},
+	}, 0)
+	defer closer.Close()
+	s := root.(*scope)
+
+	latency := root.Histogram("latency", DurationBuckets{time.Second})
+	latency.RecordDuration(5 * time.Millisecond)
+	// Histograms with other types of buckets or names aren't matched.
+	root.Histogram("db_latency", ValueBuckets{1}).RecordValue(1)
+	root.Histogram("size", DurationBuckets{time.Second}).RecordDuration(1)
+
+	histograms := s.Snapshot().Histograms()
+	assert.Len(t, histograms, 4)
+	require.Contains(t, histograms, "svc.latency_fine+")
+	fine := histograms["svc.latency_fine+"]
+	assert.EqualValues(t, 1, fine.Durations()[10*time.Millisecond])
+	assert.EqualValues(t, 1, histograms["svc.latency+"].Durations()[time.Second])
+
+	// Fine histograms can be turned off and on at runtime.
+	n, err := SetFineResolution(root, "svc.*", 0)
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	latency.RecordDuration(5 * time.Millisecond)
+	assert.EqualValues(t, 1, s.Snapshot().Histograms()["svc.latency_fine+"].Durations()[10*time.Millisecond])
+	assert.EqualValues(t, 2, s.Snapshot().Histograms()["svc.latency+"].Durations()[time.Second])
+
+	_, err = SetFineResolution(root.SubScope("sub"), "*", 1)
+	assert.Equal(t, errNotRootScope, err)
+}
+
+func TestHistogramResolutionSampled(t *testing.T) {
+	root, closer := NewRootScope(ScopeOptions{
+		Reporter:      NullStatsReporter,
+		MetricsOption: OmitInternalMetrics,
+		HistogramResolutions: []HistogramResolutionRule{{
+			Name:       "*",
+			Buckets:    ValueBuckets{1, 2},
+			Suffix:     "_detailed",
+			SampleRate: 0.1,
+		}},
+	}, 0)
+	defer closer.Close()
+
+	h := root.Histogram("size", ValueBuckets{10})
+	for i := 0; i < 10000; i++ {
+		h.RecordValue(1)
+	}
+	samples := root.(*scope).Snapshot().Histograms()["size_detailed+"].Values()[1]
+	assert.InDelta(t, 1000, samples, 200)
+}
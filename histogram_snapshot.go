@@ -0,0 +1,118 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tally
+
+import (
+	"math"
+	"sort"
+)
+
+// snapshotBucket is the bucket of a histogram snapshot.
+type snapshotBucket struct {
+	upperBound float64
+	samples    int64
+	overflow   bool
+}
+
+// sortedBuckets returns the buckets of the snapshot sorted by upper bound,
+// with durations in nanoseconds.
+func (s *histogramSnapshot) sortedBuckets() []snapshotBucket {
+	buckets := make([]snapshotBucket, 0, len(s.values)+len(s.durations))
+	for upper, samples := range s.values {
+		buckets = append(buckets, snapshotBucket{
+			upperBound: upper,
+			samples:    samples,
+			overflow:   upper == math.MaxFloat64,
+		})
+	}
+	for upper, samples := range s.durations {
+		buckets = append(buckets, snapshotBucket{
+			upperBound: float64(upper),
+			samples:    samples,
+			overflow:   upper == math.MaxInt64,
+		})
+	}
+	sort.Slice(buckets, func(i, j int) bool {
+		return buckets[i].upperBound < buckets[j].upperBound
+	})
+	return buckets
+}
+
+func (s *histogramSnapshot) Count() int64 {
+	var count int64
+	for _, samples := range s.values {
+		count += samples
+	}
+	for _, samples := range s.durations {
+		count += samples
+	}
+	return count
+}
+
+func (s *histogramSnapshot) Sum() float64 {
+	var (
+		sum     float64
+		buckets = s.sortedBuckets()
+	)
+	for i, b := range buckets {
+		approximation := b.upperBound
+		if b.overflow {
+			approximation = 0
+			if i > 0 {
+				approximation = buckets[i-1].upperBound
+			}
+		}
+		sum += approximation * float64(b.samples)
+	}
+	return sum
+}
+
+func (s *histogramSnapshot) Quantile(q float64) float64 {
+	count := s.Count()
+	if q < 0 || q > 1 || math.IsNaN(q) || count == 0 {
+		return math.NaN()
+	}
+
+	var (
+		rank       = q * float64(count)
+		cumulative int64
+		buckets    = s.sortedBuckets()
+	)
+	for i, b := range buckets {
+		if b.samples == 0 || float64(cumulative+b.samples) < rank {
+			cumulative += b.samples
+			continue
+		}
+
+		var lower float64
+		switch {
+		case i > 0:
+			lower = buckets[i-1].upperBound
+		case b.upperBound <= 0:
+			return b.upperBound
+		}
+		if b.overflow {
+			return lower
+		}
+		return lower + (b.upperBound-lower)*(rank-float64(cumulative))/float64(b.samples)
+	}
+	return buckets[len(buckets)-1].upperBound
+}
@@ -0,0 +1,79 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tally
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHistogramSnapshotQuantile(t *testing.T) {
+	s := NewTestScope("", nil)
+	h := s.Histogram("h", ValueBuckets{10, 20, 40})
+	for i := 0; i < 10; i++ {
+		h.RecordValue(5)
+	}
+	for i := 0; i < 10; i++ {
+		h.RecordValue(15)
+	}
+
+	snap, ok := s.Snapshot().Histograms()["h+"]
+	require.True(t, ok)
+	assert.EqualValues(t, 20, snap.Count())
+	assert.Equal(t, 300.0, snap.Sum())
+	assert.Equal(t, 0.0, snap.Quantile(0))
+	assert.Equal(t, 5.0, snap.Quantile(0.25))
+	assert.Equal(t, 10.0, snap.Quantile(0.5))
+	assert.Equal(t, 15.0, snap.Quantile(0.75))
+	assert.Equal(t, 20.0, snap.Quantile(1))
+	assert.True(t, math.IsNaN(snap.Quantile(-0.1)))
+	assert.True(t, math.IsNaN(snap.Quantile(1.1)))
+}
+
+func TestHistogramSnapshotQuantileOverflow(t *testing.T) {
+	s := NewTestScope("", nil)
+	h := s.Histogram("h", DurationBuckets{time.Millisecond, time.Second})
+	h.RecordDuration(time.Minute)
+	h.RecordDuration(time.Hour)
+
+	snap := s.Snapshot().Histograms()["h+"]
+	assert.EqualValues(t, 2, snap.Count())
+	assert.Equal(t, float64(2*time.Second), snap.Sum())
+	assert.Equal(t, time.Second, time.Duration(snap.Quantile(0.99)))
+}
+
+func TestHistogramSnapshotQuantileEmpty(t *testing.T) {
+	s := NewTestScope("", nil)
+	s.Histogram("h", ValueBuckets{-10, 10})
+
+	snap := s.Snapshot().Histograms()["h+"]
+	assert.EqualValues(t, 0, snap.Count())
+	assert.Equal(t, 0.0, snap.Sum())
+	assert.True(t, math.IsNaN(snap.Quantile(0.5)))
+
+	s.Histogram("h", nil).RecordValue(-20)
+	snap = s.Snapshot().Histograms()["h+"]
+	assert.Equal(t, -10.0, snap.Quantile(0.5))
+}
@@ -213,3 +213,22 @@ func BenchmarkBucketsEqual(b *testing.B) {
 		bench(b, buckets, buckets)
 	})
 }
+
+func TestHistogramBuckets(t *testing.T) {
+	s := NewTestScope("", nil)
+	buckets := MustMakeLinearValueBuckets(0, 10, 3)
+
+	assert.Equal(t, buckets, HistogramBuckets(s.Histogram("size", buckets)))
+	assert.Equal(t, defaultScopeBuckets, HistogramBuckets(AtLevel(s, DebugLevel).Histogram("latency", nil)))
+	assert.Nil(t, HistogramBuckets(NoopScope.Histogram("size", buckets)))
+
+	adaptive := NewAdaptiveHistogram(s, "adaptive", AdaptiveBucketOptions{Samples: 2, Count: 2})
+	assert.Nil(t, HistogramBuckets(adaptive))
+	adaptive.RecordValue(1)
+	adaptive.RecordValue(2)
+	assert.Len(t, HistogramBuckets(adaptive).AsValues(), 2)
+
+	snapshot := s.Snapshot().Histograms()
+	assert.Equal(t, buckets, snapshot["size+"].Buckets())
+	assert.Equal(t, defaultScopeBuckets, snapshot["latency+"].Buckets())
+}
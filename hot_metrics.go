@@ -0,0 +1,105 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tally
+
+// HotMetricsOptions lists the metrics resolved by a HotMetrics. The
+// position of a name in its slice is the index used to access the metric.
+type HotMetricsOptions struct {
+	Counters   []string
+	Gauges     []string
+	Timers     []string
+	Histograms []HotHistogram
+}
+
+// HotHistogram describes a histogram resolved by a HotMetrics.
+type HotHistogram struct {
+	Name string
+	// Buckets of the histogram, nil uses the scope's default buckets.
+	Buckets Buckets
+}
+
+// HotMetrics holds metric handles resolved once from a scope for a fixed
+// set of metrics. Accessing a metric is a bounds checked slice index, which
+// avoids the name sanitization and registry lookup performed by Scope on
+// every call. It is intended for paths measured in nanoseconds where the
+// metrics can be enumerated upfront, typically with iota constants:
+//
+//	const (
+//		requestsCounter = iota
+//		errorsCounter
+//	)
+//
+//	metrics := tally.NewHotMetrics(scope, tally.HotMetricsOptions{
+//		Counters: []string{"requests", "errors"},
+//	})
+//	metrics.Counter(requestsCounter).Inc(1)
+//
+// The handles are safe for concurrent use, so a single HotMetrics can be
+// shared by all goroutines.
+type HotMetrics struct {
+	counters   []Counter
+	gauges     []Gauge
+	timers     []Timer
+	histograms []Histogram
+}
+
+// NewHotMetrics resolves the metrics described by opts from the scope.
+func NewHotMetrics(s Scope, opts HotMetricsOptions) *HotMetrics {
+	m := &HotMetrics{
+		counters:   make([]Counter, 0, len(opts.Counters)),
+		gauges:     make([]Gauge, 0, len(opts.Gauges)),
+		timers:     make([]Timer, 0, len(opts.Timers)),
+		histograms: make([]Histogram, 0, len(opts.Histograms)),
+	}
+	for _, name := range opts.Counters {
+		m.counters = append(m.counters, s.Counter(name))
+	}
+	for _, name := range opts.Gauges {
+		m.gauges = append(m.gauges, s.Gauge(name))
+	}
+	for _, name := range opts.Timers {
+		m.timers = append(m.timers, s.Timer(name))
+	}
+	for _, h := range opts.Histograms {
+		m.histograms = append(m.histograms, s.Histogram(h.Name, h.Buckets))
+	}
+	return m
+}
+
+// Counter returns the i-th counter of HotMetricsOptions.Counters.
+func (m *HotMetrics) Counter(i int) Counter {
+	return m.counters[i]
+}
+
+// Gauge returns the i-th gauge of HotMetricsOptions.Gauges.
+func (m *HotMetrics) Gauge(i int) Gauge {
+	return m.gauges[i]
+}
+
+// Timer returns the i-th timer of HotMetricsOptions.Timers.
+func (m *HotMetrics) Timer(i int) Timer {
+	return m.timers[i]
+}
+
+// Histogram returns the i-th histogram of HotMetricsOptions.Histograms.
+func (m *HotMetrics) Histogram(i int) Histogram {
+	return m.histograms[i]
+}
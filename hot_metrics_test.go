@@ -0,0 +1,53 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tally
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHotMetrics(t *testing.T) {
+	s := NewTestScope("", nil)
+	m := NewHotMetrics(s, HotMetricsOptions{
+		Counters:   []string{"requests", "errors"},
+		Gauges:     []string{"inflight"},
+		Timers:     []string{"latency"},
+		Histograms: []HotHistogram{{Name: "size", Buckets: ValueBuckets{10}}},
+	})
+
+	m.Counter(0).Inc(1)
+	m.Counter(1).Inc(2)
+	m.Gauge(0).Update(3)
+	m.Timer(0).Record(time.Second)
+	m.Histogram(0).RecordValue(5)
+
+	snap := s.Snapshot()
+	assert.EqualValues(t, 1, snap.Counters()["requests+"].Value())
+	assert.EqualValues(t, 2, snap.Counters()["errors+"].Value())
+	assert.EqualValues(t, 3, snap.Gauges()["inflight+"].Value())
+	assert.Equal(t, []time.Duration{time.Second}, snap.Timers()["latency+"].Values())
+	assert.EqualValues(t, 1, snap.Histograms()["size+"].Values()[10])
+
+	assert.Equal(t, s.Counter("requests"), m.Counter(0))
+}
@@ -0,0 +1,224 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package http instruments HTTP servers. It wraps handlers to record the
+// requests they serve, their latency and response size, and the requests
+// in flight, tagged by method, route and status code.
+package http
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	nethttp "net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	tally "github.com/extrasalt/tally/v4"
+)
+
+const (
+	requestsName     = "requests"
+	latencyName      = "request_latency"
+	inFlightName     = "requests_in_flight"
+	responseSizeName = "response_size"
+
+	methodTag = "method"
+	routeTag  = "route"
+	statusTag = "status"
+
+	// otherMethod tags requests with a non-standard method, which would
+	// otherwise let clients create arbitrarily many series.
+	otherMethod = "other"
+)
+
+var (
+	// DefaultLatencyBuckets are the default buckets of the request latency
+	// histogram, from 1ms to about 16s.
+	DefaultLatencyBuckets = tally.MustMakeExponentialDurationBuckets(time.Millisecond, 2, 15)
+	// DefaultSizeBuckets are the default buckets of the response size
+	// histogram in bytes, from 64B to 16MiB.
+	DefaultSizeBuckets = tally.MustMakeExponentialValueBuckets(64, 4, 10)
+
+	errNotHijacker = errors.New("response writer does not implement http.Hijacker")
+)
+
+// Options are the options of an instrumented handler.
+type Options struct {
+	// LatencyBuckets are the buckets of the request latency histogram,
+	// they default to DefaultLatencyBuckets.
+	LatencyBuckets tally.Buckets
+	// SizeBuckets are the buckets of the response size histogram, they
+	// default to DefaultSizeBuckets.
+	SizeBuckets tally.Buckets
+}
+
+// NewHandler wraps next to record the requests it serves into scope, the
+// route should be the pattern next is registered with rather than the
+// request path to keep the number of series bounded. The following metrics
+// are created:
+// requests+method,route,status
+// request_latency+method,route,status
+// response_size+method,route,status
+// requests_in_flight+route
+// The requests in flight are summed across the handlers of a route.
+// A request whose handler panics is recorded with status 500 before the
+// panic is propagated.
+func NewHandler(
+	scope tally.Scope,
+	route string,
+	next nethttp.Handler,
+	opts Options,
+) nethttp.Handler {
+	if opts.LatencyBuckets == nil {
+		opts.LatencyBuckets = DefaultLatencyBuckets
+	}
+	if opts.SizeBuckets == nil {
+		opts.SizeBuckets = DefaultSizeBuckets
+	}
+
+	return &handler{
+		scope:    scope,
+		route:    route,
+		next:     next,
+		opts:     opts,
+		inFlight: scope.Tagged(map[string]string{routeTag: route}).Gauge(inFlightName),
+	}
+}
+
+type handler struct {
+	scope tally.Scope
+	route string
+	next  nethttp.Handler
+	opts  Options
+
+	inFlight tally.Gauge
+	// inFlightCount counts the requests in flight if inFlight isn't an
+	// additive gauge, mu serializes updating inFlight with it.
+	mu            sync.Mutex
+	inFlightCount int64
+}
+
+func (h *handler) ServeHTTP(w nethttp.ResponseWriter, r *nethttp.Request) {
+	h.addInFlight(1)
+	start := time.Now()
+	rw := &responseWriter{ResponseWriter: w}
+
+	defer func() {
+		p := recover()
+		if p != nil {
+			rw.status = nethttp.StatusInternalServerError
+		}
+		h.record(r.Method, rw, time.Since(start))
+		h.addInFlight(-1)
+		if p != nil {
+			panic(p)
+		}
+	}()
+
+	h.next.ServeHTTP(rw, r)
+}
+
+// addInFlight adds delta to the requests in flight. The gauge of a route
+// is shared by its handlers, which add to it rather than set it so that
+// they sum their requests.
+func (h *handler) addInFlight(delta int64) {
+	if tally.AddGauge(h.inFlight, float64(delta)) {
+		return
+	}
+	h.mu.Lock()
+	h.inFlightCount += delta
+	h.inFlight.Update(float64(h.inFlightCount))
+	h.mu.Unlock()
+}
+
+func (h *handler) record(method string, rw *responseWriter, latency time.Duration) {
+	status := rw.status
+	if status == 0 {
+		// Nothing was written, the server replies with 200.
+		status = nethttp.StatusOK
+	}
+
+	scope := h.scope.Tagged(map[string]string{
+		methodTag: normalizeMethod(method),
+		routeTag:  h.route,
+		statusTag: strconv.Itoa(status),
+	})
+	scope.Counter(requestsName).Inc(1)
+	scope.Histogram(latencyName, h.opts.LatencyBuckets).RecordDuration(latency)
+	scope.Histogram(responseSizeName, h.opts.SizeBuckets).RecordValue(float64(rw.size))
+}
+
+func normalizeMethod(method string) string {
+	switch method {
+	case nethttp.MethodGet, nethttp.MethodHead, nethttp.MethodPost,
+		nethttp.MethodPut, nethttp.MethodPatch, nethttp.MethodDelete,
+		nethttp.MethodConnect, nethttp.MethodOptions, nethttp.MethodTrace:
+		return method
+	default:
+		return otherMethod
+	}
+}
+
+// responseWriter records the status code and the size of a response.
+type responseWriter struct {
+	nethttp.ResponseWriter
+
+	status int
+	size   int64
+}
+
+func (w *responseWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = nethttp.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.size += int64(n)
+	return n, err
+}
+
+// Flush implements http.Flusher if the wrapped writer does.
+func (w *responseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(nethttp.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack implements http.Hijacker if the wrapped writer does.
+func (w *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(nethttp.Hijacker)
+	if !ok {
+		return nil, nil, errNotHijacker
+	}
+	return h.Hijack()
+}
+
+// Unwrap returns the wrapped writer, for http.ResponseController.
+func (w *responseWriter) Unwrap() nethttp.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,106 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package http
+
+import (
+	nethttp "net/http"
+	"net/http/httptest"
+	"testing"
+
+	tally "github.com/extrasalt/tally/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandler(t *testing.T) {
+	s := tally.NewTestScope("http", nil)
+	var inFlight float64
+	h := NewHandler(s, "/users/{id}", nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		inFlight = s.Snapshot().Gauges()["http.requests_in_flight+route=/users/{id}"].Value()
+		if r.Method == nethttp.MethodPost {
+			w.WriteHeader(nethttp.StatusCreated)
+		}
+		_, _ = w.Write([]byte("hello"))
+	}), Options{})
+
+	for _, method := range []string{nethttp.MethodGet, nethttp.MethodGet, nethttp.MethodPost, "PURGE"} {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(method, "/users/1", nil))
+	}
+	assert.Equal(t, 1.0, inFlight)
+
+	snap := s.Snapshot()
+	counters := snap.Counters()
+	assert.EqualValues(t, 2, counters["http.requests+method=GET,route=/users/{id},status=200"].Value())
+	assert.EqualValues(t, 1, counters["http.requests+method=POST,route=/users/{id},status=201"].Value())
+	assert.EqualValues(t, 1, counters["http.requests+method=other,route=/users/{id},status=200"].Value())
+	assert.Equal(t, 0.0, snap.Gauges()["http.requests_in_flight+route=/users/{id}"].Value())
+
+	sizes := snap.Histograms()["http.response_size+method=GET,route=/users/{id},status=200"].Values()
+	assert.EqualValues(t, 2, sizes[64])
+
+	var latencies int64
+	for _, n := range snap.Histograms()["http.request_latency+method=GET,route=/users/{id},status=200"].Durations() {
+		latencies += n
+	}
+	assert.EqualValues(t, 2, latencies)
+}
+
+func TestHandlerInFlightShared(t *testing.T) {
+	s := tally.NewTestScope("", nil)
+	inFlight := func() float64 {
+		return s.Snapshot().Gauges()["requests_in_flight+route=/"].Value()
+	}
+
+	// Handlers of the same route sum their requests in flight.
+	var observed []float64
+	inner := NewHandler(s, "/", nethttp.HandlerFunc(func(nethttp.ResponseWriter, *nethttp.Request) {
+		observed = append(observed, inFlight())
+	}), Options{})
+	outer := NewHandler(s, "/", inner, Options{})
+
+	outer.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(nethttp.MethodGet, "/", nil))
+	assert.Equal(t, []float64{2}, observed)
+	assert.Equal(t, 0.0, inFlight())
+}
+
+func TestHandlerPanic(t *testing.T) {
+	s := tally.NewTestScope("", nil)
+	h := NewHandler(s, "/", nethttp.HandlerFunc(func(nethttp.ResponseWriter, *nethttp.Request) {
+		panic("boom")
+	}), Options{})
+
+	require.Panics(t, func() {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(nethttp.MethodGet, "/", nil))
+	})
+	assert.EqualValues(t, 1, s.Snapshot().Counters()["requests+method=GET,route=/,status=500"].Value())
+}
+
+func TestResponseWriterFlush(t *testing.T) {
+	rec := httptest.NewRecorder()
+	h := NewHandler(tally.NoopScope, "/", nethttp.HandlerFunc(func(w nethttp.ResponseWriter, _ *nethttp.Request) {
+		w.(nethttp.Flusher).Flush()
+		_, _, err := w.(nethttp.Hijacker).Hijack()
+		assert.Error(t, err)
+	}), Options{})
+
+	h.ServeHTTP(rec, httptest.NewRequest(nethttp.MethodGet, "/", nil))
+	assert.True(t, rec.Flushed)
+}
@@ -0,0 +1,96 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package instrument
+
+import (
+	"time"
+
+	tally "github.com/extrasalt/tally/v4"
+)
+
+const (
+	cacheHits         = "hits"
+	cacheMisses       = "misses"
+	cacheEvictions    = "evictions"
+	cacheSize         = "size"
+	cacheLoadDuration = "load_duration"
+	cacheLoadErrors   = "load_errors"
+)
+
+// NewCache returns a Cache that instruments a cache using a given scope
+// and a label to name the metrics.
+// The following metrics are created excluding {{ and }} and replacing .
+// with the scope's separator:
+// {{name}}.hits
+// {{name}}.misses
+// {{name}}.evictions
+// {{name}}.size
+// {{name}}.load_duration
+// {{name}}.load_errors
+func NewCache(scope tally.Scope, name string) Cache {
+	sub := scope.SubScope(name)
+	return &cache{
+		hits:         sub.Counter(cacheHits),
+		misses:       sub.Counter(cacheMisses),
+		evictions:    sub.Counter(cacheEvictions),
+		size:         sub.Gauge(cacheSize),
+		loadDuration: sub.Histogram(cacheLoadDuration, defaultCacheLoadBuckets),
+		loadErrors:   sub.Counter(cacheLoadErrors),
+	}
+}
+
+var defaultCacheLoadBuckets = tally.MustMakeExponentialDurationBuckets(time.Millisecond, 2, 16)
+
+type cache struct {
+	hits         tally.Counter
+	misses       tally.Counter
+	evictions    tally.Counter
+	size         tally.Gauge
+	loadDuration tally.Histogram
+	loadErrors   tally.Counter
+}
+
+func (c *cache) Hit() {
+	c.hits.Inc(1)
+}
+
+func (c *cache) Miss() {
+	c.misses.Inc(1)
+}
+
+func (c *cache) Evicted(n int) {
+	c.evictions.Inc(int64(n))
+}
+
+func (c *cache) Size(n int) {
+	c.size.Update(float64(n))
+}
+
+func (c *cache) Load(f ExecFn) error {
+	sw := c.loadDuration.Start()
+	err := f()
+	sw.Stop()
+
+	if err != nil {
+		c.loadErrors.Inc(1)
+	}
+	return err
+}
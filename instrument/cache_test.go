@@ -0,0 +1,57 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package instrument
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/extrasalt/tally/v4"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCache(t *testing.T) {
+	s := tally.NewTestScope("", nil)
+	c := NewCache(s, "users")
+
+	c.Hit()
+	c.Hit()
+	c.Miss()
+	c.Evicted(3)
+	c.Size(10)
+	assert.NoError(t, c.Load(func() error { return nil }))
+	assert.Error(t, c.Load(func() error { return errors.New("an error") }))
+
+	snapshot := s.Snapshot()
+	counters := snapshot.Counters()
+	assert.Equal(t, int64(2), counters["users.hits+"].Value())
+	assert.Equal(t, int64(1), counters["users.misses+"].Value())
+	assert.Equal(t, int64(3), counters["users.evictions+"].Value())
+	assert.Equal(t, int64(1), counters["users.load_errors+"].Value())
+	assert.Equal(t, 10.0, snapshot.Gauges()["users.size+"].Value())
+
+	var loads int64
+	for _, v := range snapshot.Histograms()["users.load_duration+"].Durations() {
+		loads += v
+	}
+	assert.Equal(t, int64(2), loads)
+}
@@ -0,0 +1,139 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package instrument
+
+import (
+	"io"
+	"strconv"
+	"sync"
+	"time"
+
+	tally "github.com/extrasalt/tally/v4"
+)
+
+const (
+	consumerTopic             = "topic"
+	consumerPartition         = "partition"
+	consumerLag               = "lag"
+	consumerProcessed         = "processed"
+	consumerProcessingLatency = "processing_latency"
+)
+
+// NewConsumer returns a Consumer that instruments a stream consumer using
+// a given scope and a label to name the metrics, keeping the metrics of
+// revoked partitions for ttl in case they are assigned again.
+// The following metrics are created per partition excluding {{ and }} and
+// replacing . with the scope's separator, tagged with the topic and the
+// partition:
+// {{name}}.lag
+// {{name}}.processed
+// {{name}}.processing_latency
+func NewConsumer(scope tally.Scope, name string, ttl time.Duration) Consumer {
+	return &consumer{
+		scope:      scope.SubScope(name),
+		ttl:        ttl,
+		partitions: make(map[topicPartition]*partition),
+	}
+}
+
+var defaultConsumerLatencyBuckets = tally.MustMakeExponentialDurationBuckets(time.Millisecond, 2, 16)
+
+type topicPartition struct {
+	topic     string
+	partition int32
+}
+
+type consumer struct {
+	scope tally.Scope
+	ttl   time.Duration
+
+	mu         sync.Mutex
+	partitions map[topicPartition]*partition
+}
+
+type partition struct {
+	scope     tally.Scope
+	revokedAt time.Time
+	lag       tally.Gauge
+	processed tally.Counter
+	latency   tally.Histogram
+}
+
+func (c *consumer) Partition(topic string, p int32) ConsumerPartition {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	c.sweep(now)
+
+	key := topicPartition{topic: topic, partition: p}
+	if existing, ok := c.partitions[key]; ok {
+		existing.revokedAt = time.Time{}
+		return existing
+	}
+
+	scope := c.scope.Tagged(map[string]string{
+		consumerTopic:     topic,
+		consumerPartition: strconv.Itoa(int(p)),
+	})
+	created := &partition{
+		scope:     scope,
+		lag:       scope.Gauge(consumerLag),
+		processed: scope.Counter(consumerProcessed),
+		latency:   scope.Histogram(consumerProcessingLatency, defaultConsumerLatencyBuckets),
+	}
+	c.partitions[key] = created
+	return created
+}
+
+func (c *consumer) Revoke(topic string, p int32) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	if existing, ok := c.partitions[topicPartition{topic: topic, partition: p}]; ok {
+		existing.revokedAt = now
+	}
+	c.sweep(now)
+}
+
+// sweep releases the metrics of the partitions revoked for longer than the
+// ttl, must be called with mu held.
+func (c *consumer) sweep(now time.Time) {
+	for key, p := range c.partitions {
+		if p.revokedAt.IsZero() || now.Sub(p.revokedAt) < c.ttl {
+			continue
+		}
+		if closer, ok := p.scope.(io.Closer); ok {
+			_ = closer.Close()
+		}
+		delete(c.partitions, key)
+	}
+}
+
+func (p *partition) Lag(lag int64) {
+	p.lag.Update(float64(lag))
+}
+
+func (p *partition) Processed(n int, latency time.Duration) {
+	p.processed.Inc(int64(n))
+	p.latency.RecordDuration(latency)
+}
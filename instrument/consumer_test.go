@@ -0,0 +1,62 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package instrument
+
+import (
+	"testing"
+	"time"
+
+	"github.com/extrasalt/tally/v4"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConsumer(t *testing.T) {
+	s := tally.NewTestScope("", nil)
+
+	c := NewConsumer(s, "orders", time.Hour)
+	p := c.Partition("orders", 3)
+	p.Lag(42)
+	p.Processed(10, time.Millisecond)
+	assert.Same(t, p, c.Partition("orders", 3))
+
+	snapshot := s.Snapshot()
+	assert.Equal(t, 42.0, snapshot.Gauges()["orders.lag+partition=3,topic=orders"].Value())
+	assert.Equal(t, int64(10), snapshot.Counters()["orders.processed+partition=3,topic=orders"].Value())
+	assert.Equal(t, int64(1),
+		snapshot.Histograms()["orders.processing_latency+partition=3,topic=orders"].Durations()[time.Millisecond])
+
+	// Partitions assigned again within the ttl keep their metrics.
+	c.Revoke("orders", 3)
+	assert.Same(t, p, c.Partition("orders", 3))
+}
+
+func TestConsumerRevokedPartitionsExpire(t *testing.T) {
+	s := tally.NewTestScope("", nil)
+
+	c := NewConsumer(s, "orders", 0)
+	p := c.Partition("orders", 1)
+	c.Revoke("orders", 1)
+
+	require.Empty(t, c.(*consumer).partitions)
+	assert.NotSame(t, p, c.Partition("orders", 1))
+}
@@ -0,0 +1,83 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package instrument
+
+import (
+	"sync/atomic"
+	"time"
+
+	tally "github.com/extrasalt/tally/v4"
+)
+
+const (
+	poolActive       = "active"
+	poolQueued       = "queued"
+	poolTaskDuration = "task_duration"
+	poolPanics       = "panics"
+)
+
+// NewPool returns a Pool that instruments a worker pool using a given
+// scope and a label to name the metrics.
+// The following metrics are created excluding {{ and }} and replacing .
+// with the scope's separator:
+// {{name}}.active
+// {{name}}.queued
+// {{name}}.task_duration
+// {{name}}.panics
+func NewPool(scope tally.Scope, name string) Pool {
+	sub := scope.SubScope(name)
+	return &pool{
+		active:       sub.Gauge(poolActive),
+		queued:       sub.Gauge(poolQueued),
+		taskDuration: sub.Histogram(poolTaskDuration, defaultPoolTaskBuckets),
+		panics:       sub.Counter(poolPanics),
+	}
+}
+
+var defaultPoolTaskBuckets = tally.MustMakeExponentialDurationBuckets(time.Millisecond, 2, 16)
+
+type pool struct {
+	numActive    int64
+	numQueued    int64
+	active       tally.Gauge
+	queued       tally.Gauge
+	taskDuration tally.Histogram
+	panics       tally.Counter
+}
+
+func (p *pool) Task(f func()) func() {
+	p.queued.Update(float64(atomic.AddInt64(&p.numQueued, 1)))
+	return func() {
+		p.queued.Update(float64(atomic.AddInt64(&p.numQueued, -1)))
+		p.active.Update(float64(atomic.AddInt64(&p.numActive, 1)))
+		sw := p.taskDuration.Start()
+		defer func() {
+			sw.Stop()
+			p.active.Update(float64(atomic.AddInt64(&p.numActive, -1)))
+			if r := recover(); r != nil {
+				p.panics.Inc(1)
+				panic(r)
+			}
+		}()
+
+		f()
+	}
+}
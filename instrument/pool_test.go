@@ -0,0 +1,55 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package instrument
+
+import (
+	"testing"
+
+	"github.com/extrasalt/tally/v4"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPool(t *testing.T) {
+	s := tally.NewTestScope("", nil)
+	p := NewPool(s, "workers")
+
+	first := p.Task(func() {
+		assert.Equal(t, 1.0, s.Snapshot().Gauges()["workers.active+"].Value())
+		assert.Equal(t, 1.0, s.Snapshot().Gauges()["workers.queued+"].Value())
+	})
+	second := p.Task(func() { panic("task failed") })
+	assert.Equal(t, 2.0, s.Snapshot().Gauges()["workers.queued+"].Value())
+
+	first()
+	assert.PanicsWithValue(t, "task failed", second)
+
+	snapshot := s.Snapshot()
+	assert.Equal(t, 0.0, snapshot.Gauges()["workers.active+"].Value())
+	assert.Equal(t, 0.0, snapshot.Gauges()["workers.queued+"].Value())
+	assert.Equal(t, int64(1), snapshot.Counters()["workers.panics+"].Value())
+
+	var tasks int64
+	for _, v := range snapshot.Histograms()["workers.task_duration+"].Durations() {
+		tasks += v
+	}
+	assert.Equal(t, int64(2), tasks)
+}
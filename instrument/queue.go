@@ -0,0 +1,75 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package instrument
+
+import (
+	"sync/atomic"
+	"time"
+
+	tally "github.com/extrasalt/tally/v4"
+)
+
+const (
+	queueDepth       = "depth"
+	queueEnqueued    = "enqueued"
+	queueDequeued    = "dequeued"
+	queueTimeInQueue = "time_in_queue"
+)
+
+// NewQueue returns a Queue that instruments a queue like structure using
+// a given scope and a label to name the metrics.
+// The following metrics are created excluding {{ and }} and replacing .
+// with the scope's separator:
+// {{name}}.depth
+// {{name}}.enqueued
+// {{name}}.dequeued
+// {{name}}.time_in_queue
+func NewQueue(scope tally.Scope, name string) Queue {
+	sub := scope.SubScope(name)
+	return &queue{
+		depth:       sub.Gauge(queueDepth),
+		enqueued:    sub.Counter(queueEnqueued),
+		dequeued:    sub.Counter(queueDequeued),
+		timeInQueue: sub.Histogram(queueTimeInQueue, defaultQueueBuckets),
+	}
+}
+
+var defaultQueueBuckets = tally.MustMakeExponentialDurationBuckets(time.Millisecond, 2, 16)
+
+type queue struct {
+	size        int64
+	depth       tally.Gauge
+	enqueued    tally.Counter
+	dequeued    tally.Counter
+	timeInQueue tally.Histogram
+}
+
+func (q *queue) Enqueued() time.Time {
+	q.depth.Update(float64(atomic.AddInt64(&q.size, 1)))
+	q.enqueued.Inc(1)
+	return time.Now()
+}
+
+func (q *queue) Dequeued(enqueuedAt time.Time) {
+	q.depth.Update(float64(atomic.AddInt64(&q.size, -1)))
+	q.dequeued.Inc(1)
+	q.timeInQueue.RecordDuration(time.Since(enqueuedAt))
+}
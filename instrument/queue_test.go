@@ -0,0 +1,51 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package instrument
+
+import (
+	"testing"
+	"time"
+
+	"github.com/extrasalt/tally/v4"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQueue(t *testing.T) {
+	s := tally.NewTestScope("", nil)
+	q := NewQueue(s, "jobs")
+
+	first := q.Enqueued()
+	second := q.Enqueued()
+	assert.Equal(t, 2.0, s.Snapshot().Gauges()["jobs.depth+"].Value())
+
+	q.Dequeued(first)
+	q.Dequeued(second.Add(-time.Second))
+
+	snapshot := s.Snapshot()
+	assert.Equal(t, 0.0, snapshot.Gauges()["jobs.depth+"].Value())
+	assert.Equal(t, int64(2), snapshot.Counters()["jobs.enqueued+"].Value())
+	assert.Equal(t, int64(2), snapshot.Counters()["jobs.dequeued+"].Value())
+
+	durations := snapshot.Histograms()["jobs.time_in_queue+"].Durations()
+	assert.Equal(t, int64(1), durations[time.Millisecond])
+	assert.Equal(t, int64(1), durations[1024*time.Millisecond])
+}
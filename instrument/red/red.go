@@ -0,0 +1,92 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package red provides the RED metrics of a request driven component:
+// the rate of requests, the rate of errors and the duration of requests,
+// with consistent names and buckets so that dashboards are uniform across
+// services.
+package red
+
+import (
+	"time"
+
+	tally "github.com/extrasalt/tally/v4"
+)
+
+const (
+	requestsName = "requests"
+	errorsName   = "errors"
+	durationName = "duration"
+)
+
+// DefaultDurationBuckets returns the default buckets of the duration
+// histogram, exponential from 1ms to about 33s.
+func DefaultDurationBuckets() tally.DurationBuckets {
+	return tally.MustMakeExponentialDurationBuckets(time.Millisecond, 2, 16)
+}
+
+// Metrics are the RED metrics of a component.
+type Metrics struct {
+	// Requests counts every request.
+	Requests tally.Counter
+
+	// Errors counts the requests which failed.
+	Errors tally.Counter
+
+	// Duration is the duration of every request.
+	Duration tally.Histogram
+}
+
+// New returns the RED metrics of the component with the given name,
+// creating the following metrics excluding {{ and }} and replacing . with
+// the scope's separator:
+// {{name}}.requests
+// {{name}}.errors
+// {{name}}.duration
+func New(scope tally.Scope, name string) *Metrics {
+	return NewWithBuckets(scope, name, DefaultDurationBuckets())
+}
+
+// NewWithBuckets is New with the given buckets for the duration histogram.
+func NewWithBuckets(scope tally.Scope, name string, buckets tally.Buckets) *Metrics {
+	sub := scope.SubScope(name)
+	return &Metrics{
+		Requests: sub.Counter(requestsName),
+		Errors:   sub.Counter(errorsName),
+		Duration: sub.Histogram(durationName, buckets),
+	}
+}
+
+// Observe records a request which took d and failed if err is not nil.
+func (m *Metrics) Observe(d time.Duration, err error) {
+	m.Requests.Inc(1)
+	if err != nil {
+		m.Errors.Inc(1)
+	}
+	m.Duration.RecordDuration(d)
+}
+
+// Exec executes f and records it as a request.
+func (m *Metrics) Exec(f func() error) error {
+	start := time.Now()
+	err := f()
+	m.Observe(time.Since(start), err)
+	return err
+}
@@ -0,0 +1,52 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package red
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/extrasalt/tally/v4"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetrics(t *testing.T) {
+	s := tally.NewTestScope("", nil)
+	m := New(s, "handler")
+
+	assert.NoError(t, m.Exec(func() error { return nil }))
+	assert.Error(t, m.Exec(func() error { return errors.New("failed") }))
+	m.Observe(3*time.Millisecond, nil)
+
+	snapshot := s.Snapshot()
+	counters := snapshot.Counters()
+	assert.Equal(t, int64(3), counters["handler.requests+"].Value())
+	assert.Equal(t, int64(1), counters["handler.errors+"].Value())
+
+	var samples int64
+	for _, v := range snapshot.Histograms()["handler.duration+"].Durations() {
+		samples += v
+	}
+	assert.Equal(t, int64(3), samples)
+	assert.Equal(t, int64(1), snapshot.Histograms()["handler.duration+"].Durations()[4*time.Millisecond])
+}
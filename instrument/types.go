@@ -20,6 +20,8 @@
 
 package instrument
 
+import "time"
+
 // ExecFn is an executable function that can be instrumented with a Call.
 type ExecFn func() error
 
@@ -29,3 +31,69 @@ type Call interface {
 	// failed, and the amount of time that it took.
 	Exec(f ExecFn) error
 }
+
+// Queue tracks the depth of a queue, the items entering and leaving it and
+// the time they spend in it.
+type Queue interface {
+	// Enqueued records an item being added to the queue and returns the
+	// time it was added at, to be kept with the item.
+	Enqueued() time.Time
+
+	// Dequeued records an item added to the queue at enqueuedAt being
+	// removed from the queue.
+	Dequeued(enqueuedAt time.Time)
+}
+
+// Cache tracks the hits, misses, evictions and size of a cache, and the
+// time it takes to load the values it caches. Cache libraries can accept a
+// tally.Scope and create a Cache to emit standard cache metrics.
+type Cache interface {
+	// Hit records a lookup which found its value in the cache.
+	Hit()
+
+	// Miss records a lookup which did not find its value in the cache.
+	Miss()
+
+	// Evicted records n values being evicted from the cache.
+	Evicted(n int)
+
+	// Size records the number of values in the cache.
+	Size(n int)
+
+	// Load executes a function loading a value into the cache and records
+	// the amount of time it took and whether it failed.
+	Load(f ExecFn) error
+}
+
+// Pool tracks the tasks queued and running in a worker pool, the time
+// they take to run and how many of them panic.
+type Pool interface {
+	// Task wraps a task before it's submitted to the pool. The task is
+	// counted as queued until the returned function is executed by a
+	// worker, then as active while it runs. Panics of the task are
+	// counted and propagated.
+	Task(f func()) func()
+}
+
+// Consumer tracks the lag and processing of the partitions assigned to a
+// stream consumer, such as a Kafka consumer.
+type Consumer interface {
+	// Partition returns the metrics of a partition assigned to the
+	// consumer.
+	Partition(topic string, partition int32) ConsumerPartition
+
+	// Revoke records a partition being revoked from the consumer, its
+	// metrics are released once it has been revoked for the consumer's
+	// ttl unless it's assigned again in the meantime.
+	Revoke(topic string, partition int32)
+}
+
+// ConsumerPartition tracks the lag and processing of a partition.
+type ConsumerPartition interface {
+	// Lag records the lag of the consumer on the partition.
+	Lag(lag int64)
+
+	// Processed records n messages of the partition being processed in
+	// the given amount of time.
+	Processed(n int, latency time.Duration)
+}
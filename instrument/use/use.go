@@ -0,0 +1,79 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package use provides the USE metrics of a resource: its utilization, its
+// saturation and its errors, with consistent names so that dashboards are
+// uniform across services.
+package use
+
+import (
+	tally "github.com/extrasalt/tally/v4"
+)
+
+const (
+	utilizationName = "utilization"
+	saturationName  = "saturation"
+	errorsName      = "errors"
+)
+
+// Metrics are the USE metrics of a resource.
+type Metrics struct {
+	// Utilization is the fraction of the resource in use, from 0 to 1.
+	Utilization tally.Gauge
+
+	// Saturation is the amount of work waiting for the resource, e.g.
+	// the length of the queue in front of a pool.
+	Saturation tally.Gauge
+
+	// Errors counts the errors of the resource.
+	Errors tally.Counter
+}
+
+// New returns the USE metrics of the resource with the given name,
+// creating the following metrics excluding {{ and }} and replacing . with
+// the scope's separator:
+// {{name}}.utilization
+// {{name}}.saturation
+// {{name}}.errors
+func New(scope tally.Scope, name string) *Metrics {
+	sub := scope.SubScope(name)
+	return &Metrics{
+		Utilization: sub.Gauge(utilizationName),
+		Saturation:  sub.Gauge(saturationName),
+		Errors:      sub.Counter(errorsName),
+	}
+}
+
+// Update records the utilization of a resource of which used out of
+// capacity units are in use, and its saturation. A resource without
+// capacity is fully utilized.
+func (m *Metrics) Update(used, capacity, saturation float64) {
+	utilization := 1.0
+	if capacity > 0 {
+		utilization = used / capacity
+	}
+	m.Utilization.Update(utilization)
+	m.Saturation.Update(saturation)
+}
+
+// Error records an error of the resource.
+func (m *Metrics) Error() {
+	m.Errors.Inc(1)
+}
@@ -0,0 +1,116 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package jobrun instruments batch jobs, such as cron jobs or Kubernetes
+// Jobs, whose process exits as soon as the job is done. The job's metrics
+// are reported by an ephemeral root scope which is flushed and closed when
+// the job returns, so that they are not lost when the process exits.
+package jobrun
+
+import (
+	"fmt"
+	"time"
+
+	tally "github.com/extrasalt/tally/v4"
+)
+
+const (
+	startedName   = "started"
+	finishedName  = "finished"
+	durationName  = "duration"
+	processedName = "items_processed"
+	failedName    = "items_failed"
+
+	resultTag     = "result"
+	resultSuccess = "success"
+	resultFailure = "failure"
+)
+
+// Job is a running job.
+type Job struct {
+	scope     tally.Scope
+	processed tally.Counter
+	failed    tally.Counter
+}
+
+// Scope returns the scope of the job, for metrics specific to the job.
+func (j *Job) Scope() tally.Scope {
+	return j.scope
+}
+
+// Processed records n items processed by the job.
+func (j *Job) Processed(n int) {
+	j.processed.Inc(int64(n))
+}
+
+// Failed records n items the job failed to process.
+func (j *Job) Failed(n int) {
+	j.failed.Inc(int64(n))
+}
+
+// Run runs a job with a root scope created with the given options and
+// reporting interval, a zero interval only reports when the job is done.
+// The following metrics are created excluding {{ and }} and replacing .
+// with the scope's separator:
+// {{name}}.started
+// {{name}}.finished+result=success|failure
+// {{name}}.duration
+// {{name}}.items_processed
+// {{name}}.items_failed
+// The job fails if it returns an error or panics, panics are propagated
+// once the scope is closed. Run returns the error of the job, or else the
+// error closing the scope.
+func Run(
+	name string,
+	opts tally.ScopeOptions,
+	interval time.Duration,
+	job func(*Job) error,
+) (err error) {
+	root, closer := tally.NewRootScope(opts, interval)
+	scope := root.SubScope(name)
+	j := &Job{
+		scope:     scope,
+		processed: scope.Counter(processedName),
+		failed:    scope.Counter(failedName),
+	}
+
+	scope.Counter(startedName).Inc(1)
+	sw := scope.Timer(durationName).Start()
+
+	defer func() {
+		sw.Stop()
+
+		r := recover()
+		result := resultSuccess
+		if err != nil || r != nil {
+			result = resultFailure
+		}
+		scope.Tagged(map[string]string{resultTag: result}).Counter(finishedName).Inc(1)
+
+		if closeErr := closer.Close(); err == nil && r == nil && closeErr != nil {
+			err = fmt.Errorf("closing scope: %v", closeErr)
+		}
+		if r != nil {
+			panic(r)
+		}
+	}()
+
+	return job(j)
+}
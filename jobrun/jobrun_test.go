@@ -0,0 +1,128 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package jobrun
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	tally "github.com/extrasalt/tally/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+type capturingReporter struct {
+	counters map[string]int64
+	timers   map[string]int
+	flushed  int
+	closed   int
+}
+
+func newCapturingReporter() *capturingReporter {
+	return &capturingReporter{
+		counters: make(map[string]int64),
+		timers:   make(map[string]int),
+	}
+}
+
+func (r *capturingReporter) ReportCounter(name string, tags map[string]string, value int64) {
+	r.counters[tally.KeyForPrefixedStringMap(name, tags)] += value
+}
+
+func (r *capturingReporter) ReportGauge(name string, tags map[string]string, value float64) {}
+
+func (r *capturingReporter) ReportTimer(name string, tags map[string]string, interval time.Duration) {
+	r.timers[tally.KeyForPrefixedStringMap(name, tags)]++
+}
+
+func (r *capturingReporter) ReportHistogramValueSamples(
+	name string,
+	tags map[string]string,
+	buckets tally.Buckets,
+	bucketLowerBound,
+	bucketUpperBound float64,
+	samples int64,
+) {
+}
+
+func (r *capturingReporter) ReportHistogramDurationSamples(
+	name string,
+	tags map[string]string,
+	buckets tally.Buckets,
+	bucketLowerBound,
+	bucketUpperBound time.Duration,
+	samples int64,
+) {
+}
+
+func (r *capturingReporter) Capabilities() tally.Capabilities { return r }
+func (r *capturingReporter) Reporting() bool                  { return true }
+func (r *capturingReporter) Tagging() bool                    { return true }
+func (r *capturingReporter) Flush()                           { r.flushed++ }
+
+func (r *capturingReporter) Close() error {
+	r.closed++
+	return nil
+}
+
+func TestRun(t *testing.T) {
+	r := newCapturingReporter()
+	err := Run("backfill", tally.ScopeOptions{Reporter: r}, 0, func(j *Job) error {
+		j.Processed(10)
+		j.Failed(2)
+		j.Scope().Counter("batches").Inc(1)
+		return nil
+	})
+	assert.NoError(t, err)
+
+	assert.Equal(t, map[string]int64{
+		"backfill.started+":                1,
+		"backfill.finished+result=success": 1,
+		"backfill.items_processed+":        10,
+		"backfill.items_failed+":           2,
+		"backfill.batches+":                1,
+	}, r.counters)
+	assert.Equal(t, map[string]int{"backfill.duration+": 1}, r.timers)
+	assert.Equal(t, 1, r.flushed)
+	assert.Equal(t, 1, r.closed)
+}
+
+func TestRunFailure(t *testing.T) {
+	r := newCapturingReporter()
+	failed := errors.New("failed")
+	err := Run("backfill", tally.ScopeOptions{Reporter: r}, 0, func(j *Job) error {
+		return failed
+	})
+	assert.Equal(t, failed, err)
+	assert.Equal(t, int64(1), r.counters["backfill.finished+result=failure"])
+	assert.Equal(t, 1, r.closed)
+}
+
+func TestRunPanic(t *testing.T) {
+	r := newCapturingReporter()
+	assert.PanicsWithValue(t, "boom", func() {
+		_ = Run("backfill", tally.ScopeOptions{Reporter: r}, 0, func(j *Job) error {
+			panic("boom")
+		})
+	})
+	assert.Equal(t, int64(1), r.counters["backfill.finished+result=failure"])
+	assert.Equal(t, 1, r.closed)
+}
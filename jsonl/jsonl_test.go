@@ -0,0 +1,102 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package jsonl
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	tally "github.com/extrasalt/tally/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReporter(t *testing.T) {
+	var out bytes.Buffer
+	r := NewReporter(&out, Options{})
+	ts := time.Unix(1e9, 0)
+	r.ReportCounterAt("requests", map[string]string{"env": "test"}, 2, ts)
+	r.ReportGaugeAt("load", nil, 1.5, ts)
+	r.ReportTimerAt("latency", nil, time.Millisecond, ts)
+	r.ReportHistogramValueSamplesAt("size", nil, tally.ValueBuckets{10, 100}, 10, 100, 3, ts)
+	r.ReportHistogramDurationSamplesAt("wait", nil, tally.DurationBuckets{time.Second}, 0, time.Second, 1, ts)
+	assert.Empty(t, out.String())
+	r.Flush()
+
+	assert.Equal(t, strings.Join([]string{
+		`{"name":"requests","type":"counter","tags":{"env":"test"},"value":2,"timestamp":"2001-09-09T01:46:40Z"}`,
+		`{"name":"load","type":"gauge","value":1.5,"timestamp":"2001-09-09T01:46:40Z"}`,
+		`{"name":"latency","type":"timer","value":1000000,"timestamp":"2001-09-09T01:46:40Z"}`,
+		`{"name":"size","type":"histogram","value":3,"bucket":{"lower":10,"upper":100},"timestamp":"2001-09-09T01:46:40Z"}`,
+		`{"name":"wait","type":"histogram","value":1,"bucket":{"lower":0,"upper":1000000000},"timestamp":"2001-09-09T01:46:40Z"}`,
+	}, "\n")+"\n", out.String())
+}
+
+func TestReporterScope(t *testing.T) {
+	var out bytes.Buffer
+	s, closer := tally.NewRootScope(tally.ScopeOptions{
+		Prefix:   "svc",
+		Reporter: NewReporter(&out, Options{}),
+	}, 0)
+	s.Tagged(map[string]string{"env": "test"}).Counter("requests").Inc(1)
+	require.NoError(t, closer.Close())
+
+	var l struct {
+		Name      string
+		Type      string
+		Tags      map[string]string
+		Value     int64
+		Timestamp time.Time
+	}
+	require.NoError(t, json.Unmarshal(out.Bytes(), &l))
+	assert.Equal(t, "svc.requests", l.Name)
+	assert.Equal(t, "counter", l.Type)
+	assert.Equal(t, map[string]string{"env": "test"}, l.Tags)
+	assert.EqualValues(t, 1, l.Value)
+	assert.False(t, l.Timestamp.IsZero())
+}
+
+type errWriter struct{}
+
+func (errWriter) Write([]byte) (int, error) {
+	return 0, errors.New("closed")
+}
+
+func TestReporterOnError(t *testing.T) {
+	var errs []error
+	r := NewReporter(errWriter{}, Options{OnError: func(err error) {
+		errs = append(errs, err)
+	}})
+	var notified []error
+	r.(tally.ReportErrorNotifier).NotifyReportErrors(func(err error) {
+		notified = append(notified, err)
+	})
+
+	r.ReportGauge("load", nil, 1)
+	r.Flush()
+	require.Len(t, errs, 1)
+	assert.EqualError(t, errs[0], "closed")
+	assert.Equal(t, errs, notified)
+}
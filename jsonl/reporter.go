@@ -0,0 +1,233 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package jsonl provides a reporter writing each reported value as a JSON
+// object on its own line, for local debugging, CI and log aggregators:
+//
+//	{"name":"requests","type":"counter","tags":{"env":"test"},"value":1,"timestamp":"2023-01-02T03:04:05Z"}
+//	{"name":"latency","type":"timer","value":1500000,"timestamp":"2023-01-02T03:04:05Z"}
+//	{"name":"size","type":"histogram","value":3,"bucket":{"lower":10,"upper":100},"timestamp":"2023-01-02T03:04:05Z"}
+//
+// Timers and the buckets of duration histograms are in nanoseconds, the
+// value of a histogram line is the number of samples in its bucket.
+package jsonl
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+
+	tally "github.com/extrasalt/tally/v4"
+)
+
+const (
+	counterType   = "counter"
+	gaugeType     = "gauge"
+	timerType     = "timer"
+	histogramType = "histogram"
+)
+
+// Options is a set of options for the reporter.
+type Options struct {
+	// OnError if set is called with the errors encoding or writing values,
+	// which are dropped otherwise as reporters can't return errors.
+	OnError func(error)
+}
+
+// line is a reported value.
+type line struct {
+	Name      string            `json:"name"`
+	Type      string            `json:"type"`
+	Tags      map[string]string `json:"tags,omitempty"`
+	Value     interface{}       `json:"value"`
+	Bucket    *bucket           `json:"bucket,omitempty"`
+	Timestamp time.Time         `json:"timestamp"`
+}
+
+// bucket are the bounds of the bucket of a histogram line.
+type bucket struct {
+	Lower interface{} `json:"lower"`
+	Upper interface{} `json:"upper"`
+}
+
+type reporter struct {
+	now     func() time.Time
+	onError func(error)
+
+	mu  sync.Mutex
+	w   *bufio.Writer
+	enc *json.Encoder
+}
+
+// NewReporter returns a reporter which writes the reported values to w, it
+// is flushed to w when the reporter is flushed. Values are stamped with the
+// time of the report they are part of, or the time timers are recorded at.
+func NewReporter(w io.Writer, opts Options) tally.TimestampedStatsReporter {
+	bw := bufio.NewWriter(w)
+	return &reporter{
+		now:     time.Now,
+		onError: opts.OnError,
+		w:       bw,
+		enc:     json.NewEncoder(bw),
+	}
+}
+
+func (r *reporter) ReportCounter(name string, tags map[string]string, value int64) {
+	r.ReportCounterAt(name, tags, value, r.now())
+}
+
+func (r *reporter) ReportCounterAt(name string, tags map[string]string, value int64, ts time.Time) {
+	r.write(line{Name: name, Type: counterType, Tags: tags, Value: value, Timestamp: ts})
+}
+
+func (r *reporter) ReportGauge(name string, tags map[string]string, value float64) {
+	r.ReportGaugeAt(name, tags, value, r.now())
+}
+
+func (r *reporter) ReportGaugeAt(name string, tags map[string]string, value float64, ts time.Time) {
+	r.write(line{Name: name, Type: gaugeType, Tags: tags, Value: value, Timestamp: ts})
+}
+
+func (r *reporter) ReportTimer(name string, tags map[string]string, interval time.Duration) {
+	r.ReportTimerAt(name, tags, interval, r.now())
+}
+
+func (r *reporter) ReportTimerAt(name string, tags map[string]string, interval time.Duration, ts time.Time) {
+	r.write(line{Name: name, Type: timerType, Tags: tags, Value: int64(interval), Timestamp: ts})
+}
+
+func (r *reporter) ReportHistogramValueSamples(
+	name string,
+	tags map[string]string,
+	buckets tally.Buckets,
+	bucketLowerBound,
+	bucketUpperBound float64,
+	samples int64,
+) {
+	r.ReportHistogramValueSamplesAt(
+		name, tags, buckets, bucketLowerBound, bucketUpperBound, samples, r.now(),
+	)
+}
+
+func (r *reporter) ReportHistogramValueSamplesAt(
+	name string,
+	tags map[string]string,
+	buckets tally.Buckets,
+	bucketLowerBound,
+	bucketUpperBound float64,
+	samples int64,
+	ts time.Time,
+) {
+	r.write(line{
+		Name:      name,
+		Type:      histogramType,
+		Tags:      tags,
+		Value:     samples,
+		Bucket:    &bucket{Lower: bucketLowerBound, Upper: bucketUpperBound},
+		Timestamp: ts,
+	})
+}
+
+func (r *reporter) ReportHistogramDurationSamples(
+	name string,
+	tags map[string]string,
+	buckets tally.Buckets,
+	bucketLowerBound,
+	bucketUpperBound time.Duration,
+	samples int64,
+) {
+	r.ReportHistogramDurationSamplesAt(
+		name, tags, buckets, bucketLowerBound, bucketUpperBound, samples, r.now(),
+	)
+}
+
+func (r *reporter) ReportHistogramDurationSamplesAt(
+	name string,
+	tags map[string]string,
+	buckets tally.Buckets,
+	bucketLowerBound,
+	bucketUpperBound time.Duration,
+	samples int64,
+	ts time.Time,
+) {
+	r.write(line{
+		Name:      name,
+		Type:      histogramType,
+		Tags:      tags,
+		Value:     samples,
+		Bucket:    &bucket{Lower: int64(bucketLowerBound), Upper: int64(bucketUpperBound)},
+		Timestamp: ts,
+	})
+}
+
+func (r *reporter) write(l line) {
+	l.Timestamp = l.Timestamp.UTC()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// NB: the encoder terminates each value with a newline.
+	if err := r.enc.Encode(l); err != nil {
+		r.handleError(err)
+	}
+}
+
+// handleError calls the error handler of the reporter with err, it must be
+// called with mu held.
+func (r *reporter) handleError(err error) {
+	if r.onError != nil {
+		r.onError(err)
+	}
+}
+
+// NotifyReportErrors implements tally.ReportErrorNotifier.
+func (r *reporter) NotifyReportErrors(onError func(error)) {
+	if prev := r.onError; prev != nil {
+		r.onError = func(err error) {
+			prev(err)
+			onError(err)
+		}
+		return
+	}
+	r.onError = onError
+}
+
+func (r *reporter) Capabilities() tally.Capabilities {
+	return r
+}
+
+func (r *reporter) Reporting() bool {
+	return true
+}
+
+func (r *reporter) Tagging() bool {
+	return true
+}
+
+func (r *reporter) Flush() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.w.Flush(); err != nil {
+		r.handleError(err)
+	}
+}
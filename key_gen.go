@@ -89,6 +89,63 @@ func keyForPrefixedStringMapsAsKey(buf []byte, prefix string, maps ...map[string
 	return buf
 }
 
+// keyForPrefixedStringMapAndPairsAsKey writes the same key as
+// keyForPrefixedStringMapsAsKey for the prefix, the tags in stringMap and
+// the tags given as key/value pairs. Pairs take precedence over the map,
+// and later pairs over earlier ones. A trailing key without a value is
+// ignored.
+func keyForPrefixedStringMapAndPairsAsKey(
+	buf []byte,
+	prefix string,
+	stringMap map[string]string,
+	pairs []string,
+) []byte {
+	// stack allocated
+	keys := make([]string, 0, 32)
+	for k := range stringMap {
+		keys = append(keys, k)
+	}
+	for i := 0; i+1 < len(pairs); i += 2 {
+		keys = append(keys, pairs[i])
+	}
+
+	insertionSort(keys)
+
+	if prefix != nilString {
+		buf = append(buf, prefix...)
+		buf = append(buf, prefixSplitter)
+	}
+
+	var lastKey string // last key written to the buffer
+	for _, k := range keys {
+		if len(lastKey) > 0 {
+			if k == lastKey {
+				// Already wrote this key.
+				continue
+			}
+			buf = append(buf, keyPairSplitter)
+		}
+		lastKey = k
+
+		buf = append(buf, k...)
+		buf = append(buf, keyNameSplitter)
+		buf = append(buf, pairValue(stringMap, pairs, k)...)
+	}
+
+	return buf
+}
+
+// pairValue returns the value of key k from the last pair defining it, or
+// from the map if no pair does.
+func pairValue(stringMap map[string]string, pairs []string, k string) string {
+	for i := (len(pairs) &^ 1) - 2; i >= 0; i -= 2 {
+		if pairs[i] == k {
+			return pairs[i+1]
+		}
+	}
+	return stringMap[k]
+}
+
 // keyForPrefixedStringMaps generates a unique key for a prefix and a series
 // of maps containing tags.
 //
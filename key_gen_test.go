@@ -99,3 +99,41 @@ func TestInsertionSort(t *testing.T) {
 	insertionSort(actual)
 	assert.Equal(t, expected, actual)
 }
+
+func TestKeyForPrefixedStringMapAndPairs(t *testing.T) {
+	tests := []struct {
+		desc  string
+		m     map[string]string
+		pairs []string
+		want  string
+	}{
+		{
+			desc: "no pairs",
+			m:    map[string]string{"a": "1"},
+			want: "foo+a=1",
+		},
+		{
+			desc:  "pairs override map",
+			m:     map[string]string{"a": "1", "b": "1"},
+			pairs: []string{"c", "3", "b", "2"},
+			want:  "foo+a=1,b=2,c=3",
+		},
+		{
+			desc:  "later pairs win",
+			pairs: []string{"a", "1", "a", "2"},
+			want:  "foo+a=2",
+		},
+		{
+			desc:  "dangling key ignored",
+			pairs: []string{"a", "1", "b"},
+			want:  "foo+a=1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			got := keyForPrefixedStringMapAndPairsAsKey(nil, "foo", tt.m, tt.pairs)
+			assert.Equal(t, tt.want, string(got))
+		})
+	}
+}
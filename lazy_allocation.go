@@ -0,0 +1,331 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tally
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// BatchCachedStatsReporter is implemented by CachedStatsReporters which can
+// allocate many metrics at once more cheaply than one at a time. With lazy
+// allocation, the metrics first reported during a report are allocated in
+// a single batch.
+type BatchCachedStatsReporter interface {
+	CachedStatsReporter
+
+	// AllocateBatch allocates the metric of each request, setting the
+	// field of the request matching its kind.
+	AllocateBatch(requests []AllocationRequest)
+}
+
+// AllocationRequest is a request to allocate a metric in a batch.
+type AllocationRequest struct {
+	Kind MetricKind
+	Name string
+	Tags map[string]string
+	// Buckets are the buckets of a histogram.
+	Buckets Buckets
+
+	// The allocated metric, set by AllocateBatch.
+	Counter   CachedCount
+	Gauge     CachedGauge
+	Timer     CachedTimer
+	Histogram CachedHistogram
+}
+
+// lazyCachedReporter defers the allocation of metrics by a cached reporter
+// until they are first reported, so that metrics which are never reported
+// are never allocated. Metrics first reported during a registry report are
+// allocated together at the end of the report.
+type lazyCachedReporter struct {
+	CachedStatsReporter
+
+	mu       sync.Mutex
+	batching bool
+	pending  []lazyReport
+}
+
+// lazyReport is a report of a metric deferred until it is allocated.
+type lazyReport struct {
+	metric *lazyMetric
+	report func(interface{})
+}
+
+func newLazyCachedReporter(r CachedStatsReporter) *lazyCachedReporter {
+	return &lazyCachedReporter{CachedStatsReporter: r}
+}
+
+func (r *lazyCachedReporter) AllocateCounter(name string, tags map[string]string) CachedCount {
+	return lazyCount{r.newMetric(AllocationRequest{Kind: CounterKind, Name: name, Tags: tags})}
+}
+
+func (r *lazyCachedReporter) AllocateGauge(name string, tags map[string]string) CachedGauge {
+	return lazyGauge{r.newMetric(AllocationRequest{Kind: GaugeKind, Name: name, Tags: tags})}
+}
+
+func (r *lazyCachedReporter) AllocateTimer(name string, tags map[string]string) CachedTimer {
+	return lazyTimer{r.newMetric(AllocationRequest{Kind: TimerKind, Name: name, Tags: tags})}
+}
+
+func (r *lazyCachedReporter) AllocateHistogram(
+	name string,
+	tags map[string]string,
+	buckets Buckets,
+) CachedHistogram {
+	return lazyHistogram{r.newMetric(AllocationRequest{
+		Kind:    HistogramKind,
+		Name:    name,
+		Tags:    tags,
+		Buckets: buckets,
+	})}
+}
+
+// AllocateSummary allocates summaries right away, they aren't deferred.
+func (r *lazyCachedReporter) AllocateSummary(
+	name string,
+	tags map[string]string,
+	quantiles []float64,
+) CachedSummary {
+	return allocateSummary(r.CachedStatsReporter, name, tags, quantiles)
+}
+
+func (r *lazyCachedReporter) newMetric(request AllocationRequest) *lazyMetric {
+	return &lazyMetric{reporter: r, request: request}
+}
+
+// beginBatch defers the allocation of metrics first reported until
+// endBatch is called.
+func (r *lazyCachedReporter) beginBatch() {
+	r.mu.Lock()
+	r.batching = true
+	r.mu.Unlock()
+}
+
+// endBatch allocates the metrics first reported since beginBatch, in a
+// single batch if the reporter supports it, then performs their reports.
+func (r *lazyCachedReporter) endBatch() {
+	r.mu.Lock()
+	pending := r.pending
+	r.pending = nil
+	r.batching = false
+	r.mu.Unlock()
+
+	if br, ok := r.CachedStatsReporter.(BatchCachedStatsReporter); ok {
+		var (
+			metrics  []*lazyMetric
+			requests []AllocationRequest
+			seen     = make(map[*lazyMetric]struct{}, len(pending))
+		)
+		for _, p := range pending {
+			if _, ok := seen[p.metric]; ok || p.metric.allocated.Load() != nil {
+				continue
+			}
+			seen[p.metric] = struct{}{}
+			metrics = append(metrics, p.metric)
+			requests = append(requests, p.metric.request)
+		}
+		if len(requests) > 0 {
+			br.AllocateBatch(requests)
+		}
+		for i, m := range metrics {
+			m.set(allocatedMetric(requests[i]))
+		}
+	}
+
+	for _, p := range pending {
+		p.report(p.metric.get())
+	}
+}
+
+// defer queues the report of m if a batch is in progress.
+func (r *lazyCachedReporter) deferReport(m *lazyMetric, report func(interface{})) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !r.batching {
+		return false
+	}
+	r.pending = append(r.pending, lazyReport{metric: m, report: report})
+	return true
+}
+
+// allocatedMetric returns the metric allocated for request.
+func allocatedMetric(request AllocationRequest) interface{} {
+	switch request.Kind {
+	case CounterKind:
+		return request.Counter
+	case GaugeKind:
+		return request.Gauge
+	case TimerKind:
+		return request.Timer
+	default:
+		return request.Histogram
+	}
+}
+
+// lazyMetric is a metric allocated when it is first reported.
+type lazyMetric struct {
+	reporter  *lazyCachedReporter
+	request   AllocationRequest
+	allocated atomic.Value
+
+	// mu serializes allocations.
+	mu sync.Mutex
+}
+
+// do calls report with the allocated metric, allocating it first if
+// needed, or defers the call to the end of the current batch.
+func (m *lazyMetric) do(report func(interface{})) {
+	if metric := m.allocated.Load(); metric != nil {
+		report(metric)
+		return
+	}
+	if m.reporter.deferReport(m, report) {
+		return
+	}
+	report(m.get())
+}
+
+// get returns the allocated metric, allocating it if needed.
+func (m *lazyMetric) get() interface{} {
+	if metric := m.allocated.Load(); metric != nil {
+		return metric
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if metric := m.allocated.Load(); metric != nil {
+		return metric
+	}
+
+	var (
+		r      = m.reporter.CachedStatsReporter
+		req    = m.request
+		metric interface{}
+	)
+	switch req.Kind {
+	case CounterKind:
+		metric = r.AllocateCounter(req.Name, req.Tags)
+	case GaugeKind:
+		metric = r.AllocateGauge(req.Name, req.Tags)
+	case TimerKind:
+		metric = r.AllocateTimer(req.Name, req.Tags)
+	default:
+		metric = r.AllocateHistogram(req.Name, req.Tags, req.Buckets)
+	}
+	m.allocated.Store(metric)
+	return metric
+}
+
+// set sets the allocated metric unless it was already allocated.
+func (m *lazyMetric) set(metric interface{}) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.allocated.Load() == nil {
+		m.allocated.Store(metric)
+	}
+}
+
+type lazyCount struct {
+	m *lazyMetric
+}
+
+func (c lazyCount) ReportCount(value int64) {
+	c.m.do(func(metric interface{}) {
+		metric.(CachedCount).ReportCount(value)
+	})
+}
+
+func (c lazyCount) ReportTotal(total int64) {
+	c.m.do(func(metric interface{}) {
+		if cc, ok := metric.(CachedCumulativeCount); ok {
+			cc.ReportTotal(total)
+		}
+	})
+}
+
+type lazyGauge struct {
+	m *lazyMetric
+}
+
+func (g lazyGauge) ReportGauge(value float64) {
+	g.m.do(func(metric interface{}) {
+		metric.(CachedGauge).ReportGauge(value)
+	})
+}
+
+type lazyTimer struct {
+	m *lazyMetric
+}
+
+func (t lazyTimer) ReportTimer(interval time.Duration) {
+	t.m.do(func(metric interface{}) {
+		metric.(CachedTimer).ReportTimer(interval)
+	})
+}
+
+type lazyHistogram struct {
+	m *lazyMetric
+}
+
+func (h lazyHistogram) ValueBucket(
+	bucketLowerBound, bucketUpperBound float64,
+) CachedHistogramBucket {
+	return &lazyHistogramBucket{
+		histogram: h.m,
+		allocate: func(hist CachedHistogram) CachedHistogramBucket {
+			return hist.ValueBucket(bucketLowerBound, bucketUpperBound)
+		},
+	}
+}
+
+func (h lazyHistogram) DurationBucket(
+	bucketLowerBound, bucketUpperBound time.Duration,
+) CachedHistogramBucket {
+	return &lazyHistogramBucket{
+		histogram: h.m,
+		allocate: func(hist CachedHistogram) CachedHistogramBucket {
+			return hist.DurationBucket(bucketLowerBound, bucketUpperBound)
+		},
+	}
+}
+
+// lazyHistogramBucket is a bucket of a lazy histogram, allocated from the
+// histogram when it is first reported.
+type lazyHistogramBucket struct {
+	histogram *lazyMetric
+	allocate  func(CachedHistogram) CachedHistogramBucket
+	once      sync.Once
+	bucket    CachedHistogramBucket
+}
+
+func (b *lazyHistogramBucket) ReportSamples(value int64) {
+	b.histogram.do(func(metric interface{}) {
+		b.once.Do(func() {
+			b.bucket = b.allocate(metric.(CachedHistogram))
+		})
+		b.bucket.ReportSamples(value)
+	})
+}
@@ -0,0 +1,123 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tally
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// batchAllocatingReporter is an allocatingReporter which also allocates
+// counters in batches.
+type batchAllocatingReporter struct {
+	allocatingReporter
+	batches [][]AllocationRequest
+}
+
+func (r *batchAllocatingReporter) AllocateBatch(requests []AllocationRequest) {
+	r.batches = append(r.batches, requests)
+	for i := range requests {
+		requests[i].Counter = &r.allocatingReporter
+	}
+}
+
+func TestLazyCachedAllocation(t *testing.T) {
+	r := &allocatingReporter{}
+	root, closer := NewRootScope(ScopeOptions{
+		CachedReporter:       r,
+		MetricsOption:        OmitInternalMetrics,
+		LazyCachedAllocation: true,
+	}, 0)
+
+	for _, name := range []string{"a", "b", "c"} {
+		root.Counter(name)
+	}
+	root.(*scope).reportRegistry()
+	assert.Equal(t, 0, r.allocations)
+
+	root.Counter("a").Inc(1)
+	root.(*scope).reportRegistry()
+	assert.Equal(t, 1, r.allocations)
+	assert.Equal(t, 1, r.reports)
+
+	root.Counter("a").Inc(1)
+	root.(*scope).reportRegistry()
+	assert.Equal(t, 1, r.allocations)
+	assert.Equal(t, 2, r.reports)
+
+	require.NoError(t, closer.Close())
+}
+
+func TestLazyCachedAllocationBatch(t *testing.T) {
+	r := &batchAllocatingReporter{}
+	root, closer := NewRootScope(ScopeOptions{
+		CachedReporter:       r,
+		MetricsOption:        OmitInternalMetrics,
+		LazyCachedAllocation: true,
+	}, 0)
+	defer closer.Close()
+
+	for _, name := range []string{"a", "b", "c"} {
+		root.Counter(name).Inc(1)
+	}
+	root.Counter("unreported")
+	root.(*scope).reportRegistry()
+
+	require.Len(t, r.batches, 1)
+	assert.Len(t, r.batches[0], 3)
+	assert.Equal(t, CounterKind, r.batches[0][0].Kind)
+	assert.Equal(t, 0, r.allocations)
+	assert.Equal(t, 3, r.reports)
+}
+
+func TestLazyCachedAllocationTimersAndHistograms(t *testing.T) {
+	r := newTestStatsReporter()
+	root, closer := NewRootScope(ScopeOptions{
+		CachedReporter:       r,
+		MetricsOption:        OmitInternalMetrics,
+		LazyCachedAllocation: true,
+	}, 0)
+
+	root.Timer("unused")
+	root.Histogram("unused_histogram", ValueBuckets{1})
+	assert.Empty(t, r.timers)
+
+	// Timers are reported outside of reports, they are allocated
+	// immediately.
+	r.tg.Add(1)
+	root.Timer("t").Record(time.Second)
+	r.tg.Wait()
+	assert.Equal(t, int64(time.Second), r.getTimers()["t"].val)
+	assert.Len(t, r.timers, 1)
+
+	r.hg.Add(1)
+	root.Histogram("h", ValueBuckets{1}).RecordValue(2)
+	root.(*scope).reportRegistry()
+	r.hg.Wait()
+	assert.Equal(t, 1, r.getHistograms()["h"].valueSamples[math.MaxFloat64])
+	assert.NotContains(t, r.getHistograms(), "unused_histogram")
+
+	require.NoError(t, closer.Close())
+}
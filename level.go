@@ -0,0 +1,271 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tally
+
+import (
+	"fmt"
+	"time"
+)
+
+// Level is the verbosity level of a metric. Metrics at a level above the
+// level threshold of their root scope are created but discard all values
+// until the threshold is raised.
+type Level int32
+
+const (
+	// InfoLevel is the default level, metrics at this level are always
+	// emitted.
+	InfoLevel Level = iota
+	// DebugLevel is for metrics only needed during investigations.
+	DebugLevel
+	// TraceLevel is for very high volume or high cardinality metrics.
+	TraceLevel
+)
+
+// String returns the name of the level.
+func (l Level) String() string {
+	switch l {
+	case InfoLevel:
+		return "info"
+	case DebugLevel:
+		return "debug"
+	case TraceLevel:
+		return "trace"
+	default:
+		return fmt.Sprintf("Level(%d)", int32(l))
+	}
+}
+
+// LeveledScope is a Scope which supports metric verbosity levels.
+type LeveledScope interface {
+	Scope
+
+	// AtLevel returns a view of the scope whose metrics are only
+	// emitted while the scope's level threshold is at least level.
+	AtLevel(level Level) Scope
+
+	// SetLevel sets the level threshold of the scope's root, it applies
+	// to every scope sharing the root, including existing metrics.
+	SetLevel(level Level)
+
+	// Level returns the level threshold of the scope's root.
+	Level() Level
+}
+
+// AtLevel returns s.AtLevel(level) if s is a LeveledScope. Otherwise it
+// returns s for InfoLevel and a noop scope for any more verbose level.
+func AtLevel(s Scope, level Level) Scope {
+	if ls, ok := s.(LeveledScope); ok {
+		return ls.AtLevel(level)
+	}
+	if level <= InfoLevel {
+		return s
+	}
+	return NoopScope
+}
+
+func (s *scope) AtLevel(level Level) Scope {
+	if level <= InfoLevel {
+		return s
+	}
+	return &leveledScope{scope: s, level: level}
+}
+
+func (s *scope) SetLevel(level Level) {
+	s.registry.level.Store(int32(level))
+}
+
+func (s *scope) Level() Level {
+	return Level(s.registry.level.Load())
+}
+
+// leveledScope wraps the metrics of a scope so that they only record
+// values while the level threshold permits.
+type leveledScope struct {
+	scope *scope
+	level Level
+}
+
+var (
+	_ LeveledScope        = (*leveledScope)(nil)
+	_ PairTaggedScope     = (*leveledScope)(nil)
+	_ ForkableScope       = (*leveledScope)(nil)
+	_ AnnotatedScope      = (*leveledScope)(nil)
+	_ InspectableScope    = (*leveledScope)(nil)
+	_ DeclaringScope      = (*leveledScope)(nil)
+	_ TagSetScope         = (*leveledScope)(nil)
+	_ DescribedScope      = (*leveledScope)(nil)
+	_ RemovableScope      = (*leveledScope)(nil)
+	_ FloatCounterScope   = (*leveledScope)(nil)
+	_ GaugeHistogramScope = (*leveledScope)(nil)
+	_ GaugeFuncScope      = (*leveledScope)(nil)
+	_ SummaryScope        = (*leveledScope)(nil)
+	_ SetScope            = (*leveledScope)(nil)
+	_ RateScope           = (*leveledScope)(nil)
+)
+
+func (s *leveledScope) enabled() bool {
+	return s.level <= s.scope.Level()
+}
+
+func (s *leveledScope) Counter(name string) Counter {
+	return leveledCounter{s, s.scope.Counter(name)}
+}
+
+func (s *leveledScope) Gauge(name string) Gauge {
+	return leveledGauge{s, s.scope.Gauge(name)}
+}
+
+func (s *leveledScope) Timer(name string) Timer {
+	return leveledTimer{s, s.scope.Timer(name)}
+}
+
+func (s *leveledScope) Histogram(name string, buckets Buckets) Histogram {
+	return leveledHistogram{s, s.scope.Histogram(name, buckets)}
+}
+
+func (s *leveledScope) Tagged(tags map[string]string) Scope {
+	return s.wrap(s.scope.Tagged(tags))
+}
+
+func (s *leveledScope) TaggedPairs(pairs ...string) Scope {
+	return s.wrap(s.scope.TaggedPairs(pairs...))
+}
+
+func (s *leveledScope) TaggedKV(key, value string) Scope {
+	return s.wrap(s.scope.TaggedKV(key, value))
+}
+
+func (s *leveledScope) WithTagSet(ts TagSet) Scope {
+	return s.wrap(s.scope.WithTagSet(ts))
+}
+
+func (s *leveledScope) Fork(prefix string, tags map[string]string) Scope {
+	return s.wrap(s.scope.Fork(prefix, tags))
+}
+
+func (s *leveledScope) SubScope(name string) Scope {
+	return s.wrap(s.scope.SubScope(name))
+}
+
+func (s *leveledScope) wrap(child Scope) Scope {
+	cs, ok := child.(*scope)
+	if !ok {
+		return child
+	}
+	return &leveledScope{scope: cs, level: s.level}
+}
+
+func (s *leveledScope) Capabilities() Capabilities {
+	return s.scope.Capabilities()
+}
+
+func (s *leveledScope) AtLevel(level Level) Scope {
+	return s.scope.AtLevel(level)
+}
+
+func (s *leveledScope) SetLevel(level Level) {
+	s.scope.SetLevel(level)
+}
+
+func (s *leveledScope) Level() Level {
+	return s.scope.Level()
+}
+
+type leveledCounter struct {
+	scope   *leveledScope
+	counter Counter
+}
+
+func (c leveledCounter) Inc(delta int64) {
+	if c.scope.enabled() {
+		c.counter.Inc(delta)
+	}
+}
+
+type leveledGauge struct {
+	scope *leveledScope
+	gauge Gauge
+}
+
+func (g leveledGauge) Update(value float64) {
+	if g.scope.enabled() {
+		g.gauge.Update(value)
+	}
+}
+
+func (g leveledGauge) Add(delta float64) {
+	if g.scope.enabled() {
+		AddGauge(g.gauge, delta)
+	}
+}
+
+func (g leveledGauge) Sub(delta float64) {
+	g.Add(-delta)
+}
+
+type leveledTimer struct {
+	scope *leveledScope
+	timer Timer
+}
+
+func (t leveledTimer) Record(value time.Duration) {
+	if t.scope.enabled() {
+		t.timer.Record(value)
+	}
+}
+
+func (t leveledTimer) Start() Stopwatch {
+	return NewStopwatch(globalNow(), t)
+}
+
+func (t leveledTimer) RecordStopwatch(stopwatchStart time.Time) {
+	t.Record(globalNow().Sub(stopwatchStart))
+}
+
+type leveledHistogram struct {
+	scope     *leveledScope
+	histogram Histogram
+}
+
+func (h leveledHistogram) RecordValue(value float64) {
+	if h.scope.enabled() {
+		h.histogram.RecordValue(value)
+	}
+}
+
+func (h leveledHistogram) RecordDuration(value time.Duration) {
+	if h.scope.enabled() {
+		h.histogram.RecordDuration(value)
+	}
+}
+
+func (h leveledHistogram) Buckets() Buckets {
+	return HistogramBuckets(h.histogram)
+}
+
+func (h leveledHistogram) Start() Stopwatch {
+	return NewStopwatch(globalNow(), h)
+}
+
+func (h leveledHistogram) RecordStopwatch(stopwatchStart time.Time) {
+	h.RecordDuration(globalNow().Sub(stopwatchStart))
+}
@@ -0,0 +1,77 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tally
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAtLevel(t *testing.T) {
+	root := NewTestScope("", nil)
+
+	debug := AtLevel(root, DebugLevel)
+	debug.Counter("c").Inc(1)
+	debug.Gauge("g").Update(1)
+	debug.Timer("t").Record(time.Second)
+	debug.Histogram("h", ValueBuckets{1}).RecordValue(1)
+	debug.Tagged(map[string]string{"a": "b"}).Counter("tc").Inc(1)
+
+	snap := root.Snapshot()
+	assert.EqualValues(t, 0, snap.Counters()["c+"].Value())
+	assert.EqualValues(t, 0, snap.Counters()["tc+a=b"].Value())
+	assert.EqualValues(t, 0, snap.Gauges()["g+"].Value())
+	assert.Len(t, snap.Timers()["t+"].Values(), 0)
+	assert.EqualValues(t, 0, snap.Histograms()["h+"].Values()[1])
+
+	root.(LeveledScope).SetLevel(DebugLevel)
+	assert.Equal(t, DebugLevel, debug.(LeveledScope).Level())
+
+	debug.Counter("c").Inc(1)
+	debug.Gauge("g").Update(1)
+	debug.Timer("t").Record(time.Second)
+	debug.Histogram("h", ValueBuckets{1}).RecordValue(1)
+	debug.Tagged(map[string]string{"a": "b"}).Counter("tc").Inc(1)
+	AtLevel(debug, TraceLevel).Counter("trace").Inc(1)
+
+	snap = root.Snapshot()
+	assert.EqualValues(t, 1, snap.Counters()["c+"].Value())
+	assert.EqualValues(t, 1, snap.Counters()["tc+a=b"].Value())
+	assert.EqualValues(t, 1, snap.Gauges()["g+"].Value())
+	assert.Len(t, snap.Timers()["t+"].Values(), 1)
+	assert.EqualValues(t, 1, snap.Histograms()["h+"].Values()[1])
+	assert.EqualValues(t, 0, snap.Counters()["trace+"].Value())
+}
+
+func TestAtLevelInfoReturnsScope(t *testing.T) {
+	root := NewTestScope("", nil)
+	assert.Equal(t, root, AtLevel(root, InfoLevel))
+}
+
+func TestLevelOption(t *testing.T) {
+	root, closer := NewRootScope(ScopeOptions{Level: DebugLevel}, 0)
+	defer closer.Close()
+
+	assert.Equal(t, DebugLevel, root.(LeveledScope).Level())
+	assert.Equal(t, "debug", DebugLevel.String())
+}
@@ -0,0 +1,171 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tally
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var (
+	errNotRootScope = errors.New("scope is not a root scope")
+	errNotDrainable = errors.New("scope is not drainable")
+)
+
+// RunReportLoop reports the root scope s every interval until ctx is done,
+// then closes s which reports and flushes it a final time and closes its
+// reporter. It blocks until then and returns the error from closing s,
+// which makes it suitable as an actor of a run group or as the body of an
+// application lifecycle hook.
+//
+// The scope should be created with a zero reporting interval so that it is
+// only reported by RunReportLoop. If interval is not positive, s is assumed
+// to be reported by its own loop and RunReportLoop only closes it once ctx
+// is done.
+func RunReportLoop(ctx context.Context, s Scope, interval time.Duration) error {
+	root, ok := s.(*scope)
+	if !ok || !root.root {
+		return errNotRootScope
+	}
+
+	if interval > 0 {
+		runReportLoop(interval, ctx.Done(), func() {
+			if root.registry.suppressed(globalNow()) {
+				return
+			}
+			_ = root.reportContext(ctx)
+		})
+	} else {
+		<-ctx.Done()
+	}
+
+	return root.Close()
+}
+
+// CloseAndSnapshot closes the root scope s like Close and returns the
+// snapshot of its metrics taken right before its final report, i.e. the
+// values that report flushes. It lets batch tools log or persist their
+// final metric state without racing the last flush. The snapshot is empty
+// if s was already closed.
+func CloseAndSnapshot(s Scope) (Snapshot, error) {
+	root, ok := s.(*scope)
+	if !ok || !root.root {
+		return nil, errNotRootScope
+	}
+
+	snap, err := root.close(true)
+	if snap == nil {
+		snap = newSnapshot()
+	}
+	return snap, err
+}
+
+// Flush reports the root scope s and flushes its reporter immediately,
+// without waiting for the next reporting interval. It is meant for
+// environments where the reporting loop can't be relied upon, such as
+// serverless runtimes frozen between invocations, and is a no-op once s is
+// closed.
+func Flush(s Scope) error {
+	return FlushContext(context.Background(), s)
+}
+
+// FlushContext is Flush with ctx passed to the reporter of s if it is a
+// ContextStatsReporter, it then returns the error flushing the reporter.
+func FlushContext(ctx context.Context, s Scope) error {
+	root, ok := s.(*scope)
+	if !ok || !root.root {
+		return errNotRootScope
+	}
+
+	return root.reportContext(ctx)
+}
+
+// PauseReporting pauses the periodic reports of the root scope s, e.g.
+// during maintenance windows or the warm-up phase of a test. Metrics keep
+// accumulating their values, which are reported once reporting resumes.
+// Flush and Close still report s.
+func PauseReporting(s Scope) error {
+	root, ok := s.(*scope)
+	if !ok || !root.root {
+		return errNotRootScope
+	}
+
+	root.registry.paused.Store(true)
+	return nil
+}
+
+// ResumeReporting resumes the periodic reports of the root scope s paused
+// by PauseReporting.
+func ResumeReporting(s Scope) error {
+	root, ok := s.(*scope)
+	if !ok || !root.root {
+		return errNotRootScope
+	}
+
+	root.registry.paused.Store(false)
+	return nil
+}
+
+// Drain starts shutting down the root scope s: metrics created from then
+// on are no-ops and writes to existing metrics are dropped. It waits for
+// the writes in flight to finish, or for ctx to be done, then reports and
+// flushes s a final time so that no write races the last flush, and
+// returns ctx's error if it expired. s should then be closed.
+//
+// s must have been created with ScopeOptions.Drainable set.
+func Drain(ctx context.Context, s Scope) error {
+	root, ok := s.(*scope)
+	if !ok || !root.root {
+		return errNotRootScope
+	}
+	if root.registry.drained == nil {
+		return errNotDrainable
+	}
+
+	root.registry.draining.Store(true)
+
+	err := waitWritesInFlight(ctx, root.registry)
+	flushCtx := ctx
+	if err != nil {
+		// The final report can't be skipped, it's flushed regardless of
+		// ctx which already expired.
+		flushCtx = context.Background()
+	}
+	if flushErr := root.reportContext(flushCtx); err == nil {
+		err = flushErr
+	}
+	return err
+}
+
+// waitWritesInFlight waits for the writes in flight to the metrics of r
+// to finish, or for ctx to be done. r must be draining, so that writes
+// signal r.drained as they end.
+func waitWritesInFlight(ctx context.Context, r *scopeRegistry) error {
+	for r.writesInFlight() > 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-r.drained:
+		}
+	}
+	return nil
+}
@@ -0,0 +1,205 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tally
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunReportLoop(t *testing.T) {
+	r := newTestStatsReporter()
+	root, _ := NewRootScope(ScopeOptions{Reporter: r, MetricsOption: OmitInternalMetrics}, 0)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- RunReportLoop(ctx, root, time.Millisecond)
+	}()
+
+	r.cg.Add(1)
+	root.Counter("foo").Inc(1)
+	r.WaitAll()
+
+	cancel()
+	require.NoError(t, <-done)
+	assert.True(t, root.(*scope).closed.Load())
+	assert.True(t, atomic.LoadInt32(&r.flushes) >= 2)
+}
+
+func TestRunReportLoopOwnLoop(t *testing.T) {
+	r := newTestStatsReporter()
+	root, _ := NewRootScope(ScopeOptions{Reporter: r, MetricsOption: OmitInternalMetrics}, time.Hour)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	r.cg.Add(1)
+	root.Counter("foo").Inc(1)
+	require.NoError(t, RunReportLoop(ctx, root, 0))
+	r.WaitAll()
+	assert.EqualValues(t, 1, r.getCounters()["foo"].val)
+}
+
+func TestRunReportLoopNotRoot(t *testing.T) {
+	root := NewTestScope("", nil)
+	assert.Error(t, RunReportLoop(context.Background(), root.SubScope("foo"), time.Second))
+	assert.Error(t, RunReportLoop(context.Background(), NoopScope, time.Second))
+}
+
+func TestFlush(t *testing.T) {
+	r := newTestStatsReporter()
+	root, closer := NewRootScope(ScopeOptions{Reporter: r, MetricsOption: OmitInternalMetrics}, time.Hour)
+
+	r.cg.Add(1)
+	root.Counter("foo").Inc(1)
+	require.NoError(t, Flush(root))
+	r.WaitAll()
+	assert.EqualValues(t, 1, r.getCounters()["foo"].val)
+	assert.EqualValues(t, 1, atomic.LoadInt32(&r.flushes))
+
+	require.NoError(t, closer.Close())
+	require.NoError(t, Flush(root))
+	assert.EqualValues(t, 2, atomic.LoadInt32(&r.flushes))
+
+	assert.Error(t, Flush(root.SubScope("foo")))
+	assert.Error(t, Flush(NoopScope))
+}
+
+func TestPauseReporting(t *testing.T) {
+	r := newTestStatsReporter()
+	root, closer := NewRootScope(ScopeOptions{Reporter: r, MetricsOption: OmitInternalMetrics}, time.Millisecond)
+	defer closer.Close()
+
+	require.NoError(t, PauseReporting(root))
+	root.Counter("foo").Inc(1)
+	time.Sleep(20 * time.Millisecond)
+	root.Counter("foo").Inc(2)
+	assert.Zero(t, atomic.LoadInt32(&r.flushes))
+
+	// The values accumulated while paused are reported once resumed.
+	r.cg.Add(1)
+	require.NoError(t, ResumeReporting(root))
+	r.WaitAll()
+	assert.EqualValues(t, 3, r.getCounters()["foo"].val)
+
+	assert.Error(t, PauseReporting(root.SubScope("foo")))
+	assert.Error(t, ResumeReporting(NoopScope))
+}
+
+func TestCloseAndSnapshot(t *testing.T) {
+	r := newTestStatsReporter()
+	root, _ := NewRootScope(ScopeOptions{Reporter: r, MetricsOption: OmitInternalMetrics}, 0)
+
+	r.cg.Add(1)
+	r.gg.Add(1)
+	root.Counter("foo").Inc(3)
+	root.Tagged(map[string]string{"a": "b"}).Gauge("bar").Update(2)
+
+	snap, err := CloseAndSnapshot(root)
+	require.NoError(t, err)
+	r.WaitAll()
+	assert.EqualValues(t, 3, snap.Counters()["foo+"].Value())
+	assert.EqualValues(t, 2, snap.Gauges()["bar+a=b"].Value())
+	assert.EqualValues(t, 3, r.getCounters()["foo"].val)
+	assert.EqualValues(t, 1, atomic.LoadInt32(&r.flushes))
+
+	snap, err = CloseAndSnapshot(root)
+	require.NoError(t, err)
+	assert.Empty(t, snap.Counters())
+
+	_, err = CloseAndSnapshot(root.SubScope("foo"))
+	assert.Error(t, err)
+}
+
+func TestDrain(t *testing.T) {
+	r := newTestStatsReporter()
+	root, closer := NewRootScope(ScopeOptions{
+		Reporter:      r,
+		MetricsOption: OmitInternalMetrics,
+		Drainable:     true,
+	}, 0)
+	defer closer.Close()
+
+	foo := root.Counter("foo")
+	foo.Inc(1)
+
+	// A write in flight delays the final flush until it's done.
+	guard := root.(*scope).guard
+	require.True(t, guard.begin())
+	done := make(chan error)
+	go func() {
+		done <- Drain(context.Background(), root)
+	}()
+	require.Eventually(t, root.(*scope).registry.draining.Load, time.Second, time.Millisecond)
+
+	foo.Inc(2)
+	assert.Equal(t, noopMetric{}, root.Counter("bar"))
+	select {
+	case <-done:
+		t.Fatal("drained with a write in flight")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	r.cg.Add(1)
+	guard.end()
+	require.NoError(t, <-done)
+	r.WaitAll()
+	assert.EqualValues(t, 1, r.getCounters()["foo"].val)
+	assert.EqualValues(t, 1, atomic.LoadInt32(&r.flushes))
+
+	assert.Error(t, Drain(context.Background(), root.SubScope("foo")))
+}
+
+func TestDrainContextDone(t *testing.T) {
+	r := newTestStatsReporter()
+	root, closer := NewRootScope(ScopeOptions{
+		Reporter:      r,
+		MetricsOption: OmitInternalMetrics,
+		Drainable:     true,
+	}, 0)
+	defer closer.Close()
+
+	require.True(t, root.(*scope).guard.begin())
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, context.DeadlineExceeded, Drain(ctx, root))
+	assert.EqualValues(t, 1, atomic.LoadInt32(&r.flushes))
+}
+
+func TestDrainNotDrainable(t *testing.T) {
+	root, closer := NewRootScope(ScopeOptions{Reporter: NullStatsReporter}, 0)
+	defer closer.Close()
+
+	// Writes aren't tracked unless the scope is drainable.
+	root.Counter("foo").Inc(1)
+	guard := root.(*scope).guard
+	require.True(t, guard.begin())
+	assert.Zero(t, guard.writesInFlight())
+	guard.end()
+
+	assert.Equal(t, errNotDrainable, Drain(context.Background(), root))
+}
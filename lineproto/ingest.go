@@ -0,0 +1,164 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package lineproto
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	tally "github.com/extrasalt/tally/v4"
+)
+
+var errMalformedLine = errors.New("malformed metric line")
+
+// IngestOptions is a set of options for ingesting metric lines.
+type IngestOptions struct {
+	// Passthrough if set receives the lines which are not metric lines,
+	// e.g. the regular output of a child process whose stdout is read.
+	Passthrough io.Writer
+
+	// HistogramBuckets are the buckets of histograms by name, histograms
+	// which are not listed use the default buckets of the scope. Samples
+	// are recorded at the upper bound of the bucket reported by the child,
+	// so buckets should match those of the child's histograms.
+	HistogramBuckets map[string]tally.Buckets
+}
+
+// Ingest reads metric lines written by a Reporter from r until EOF and
+// applies them to scope. Malformed metric lines are skipped, the error
+// returned then reports the first of them.
+func Ingest(r io.Reader, scope tally.Scope, opts IngestOptions) error {
+	var (
+		scanner  = bufio.NewScanner(r)
+		lineErr  error
+		lineNum  int
+		prefixed = marker + " "
+	)
+	for scanner.Scan() {
+		lineNum++
+		line := scanner.Text()
+		if !strings.HasPrefix(line, prefixed) {
+			if opts.Passthrough != nil {
+				if _, err := io.WriteString(opts.Passthrough, line+"\n"); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		if err := ingestLine(line[len(prefixed):], scope, opts); err != nil && lineErr == nil {
+			lineErr = fmt.Errorf("line %d: %v", lineNum, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return lineErr
+}
+
+func ingestLine(line string, scope tally.Scope, opts IngestOptions) error {
+	fields := strings.Split(line, " ")
+	if len(fields) < 3 {
+		return errMalformedLine
+	}
+
+	kind := fields[0]
+	name, err := url.QueryUnescape(fields[1])
+	if err != nil {
+		return err
+	}
+
+	values := 1
+	if kind == valueHistogramKind || kind == durationHistogramKind {
+		values = 2
+	}
+	if len(fields) < 2+values || len(fields) > 3+values {
+		return errMalformedLine
+	}
+
+	if len(fields) == 3+values {
+		query, err := url.ParseQuery(fields[2+values])
+		if err != nil {
+			return err
+		}
+		tags := make(map[string]string, len(query))
+		for k, v := range query {
+			tags[k] = v[0]
+		}
+		scope = scope.Tagged(tags)
+	}
+
+	switch kind {
+	case counterKind:
+		v, err := strconv.ParseInt(fields[2], 10, 64)
+		if err != nil {
+			return err
+		}
+		scope.Counter(name).Inc(v)
+	case gaugeKind:
+		v, err := strconv.ParseFloat(fields[2], 64)
+		if err != nil {
+			return err
+		}
+		scope.Gauge(name).Update(v)
+	case timerKind:
+		v, err := strconv.ParseInt(fields[2], 10, 64)
+		if err != nil {
+			return err
+		}
+		scope.Timer(name).Record(time.Duration(v))
+	case valueHistogramKind:
+		upper, err := strconv.ParseFloat(fields[2], 64)
+		if err != nil {
+			return err
+		}
+		samples, err := strconv.ParseInt(fields[3], 10, 64)
+		if err != nil {
+			return err
+		}
+		h := scope.Histogram(name, opts.HistogramBuckets[name])
+		for i := int64(0); i < samples; i++ {
+			h.RecordValue(upper)
+		}
+	case durationHistogramKind:
+		upper, err := strconv.ParseInt(fields[2], 10, 64)
+		if err != nil {
+			return err
+		}
+		samples, err := strconv.ParseInt(fields[3], 10, 64)
+		if err != nil {
+			return err
+		}
+		h := scope.Histogram(name, opts.HistogramBuckets[name])
+		for i := int64(0); i < samples; i++ {
+			h.RecordDuration(time.Duration(upper))
+		}
+	default:
+		return errMalformedLine
+	}
+	return nil
+}
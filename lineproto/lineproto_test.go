@@ -0,0 +1,96 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package lineproto
+
+import (
+	"bytes"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	tally "github.com/extrasalt/tally/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRoundTrip(t *testing.T) {
+	var out bytes.Buffer
+	child, closer := tally.NewRootScope(tally.ScopeOptions{
+		Prefix:   "child",
+		Tags:     map[string]string{"cmd": "a b&c"},
+		Reporter: NewReporter(&out),
+	}, 0)
+	child.Counter("runs").Inc(2)
+	child.Gauge("files").Update(1.5)
+	child.Tagged(map[string]string{"step": "x"}).Timer("step time").Record(time.Millisecond)
+	h := child.Histogram("size", tally.ValueBuckets{10, 100})
+	h.RecordValue(5)
+	h.RecordValue(50)
+	h.RecordValue(500)
+	child.Histogram("wait", tally.DurationBuckets{time.Second}).RecordDuration(time.Millisecond)
+	require.NoError(t, closer.Close())
+
+	input := "regular output\n" + out.String() + "more output\n"
+
+	var passthrough bytes.Buffer
+	parent := tally.NewTestScope("", nil)
+	require.NoError(t, Ingest(strings.NewReader(input), parent, IngestOptions{
+		Passthrough: &passthrough,
+		HistogramBuckets: map[string]tally.Buckets{
+			"child.size": tally.ValueBuckets{10, 100},
+			"child.wait": tally.DurationBuckets{time.Second},
+		},
+	}))
+	assert.Equal(t, "regular output\nmore output\n", passthrough.String())
+
+	snap := parent.Snapshot()
+	assert.EqualValues(t, 2, snap.Counters()["child.runs+cmd=a b&c"].Value())
+	assert.EqualValues(t, 1.5, snap.Gauges()["child.files+cmd=a b&c"].Value())
+	assert.Equal(t,
+		[]time.Duration{time.Millisecond},
+		snap.Timers()["child.step time+cmd=a b&c,step=x"].Values(),
+	)
+	assert.Equal(t,
+		map[float64]int64{10: 1, 100: 1, math.MaxFloat64: 1},
+		snap.Histograms()["child.size+cmd=a b&c"].Values(),
+	)
+	assert.Equal(t,
+		map[time.Duration]int64{time.Second: 1, math.MaxInt64: 0},
+		snap.Histograms()["child.wait+cmd=a b&c"].Durations(),
+	)
+}
+
+func TestIngestMalformedLines(t *testing.T) {
+	parent := tally.NewTestScope("", nil)
+	err := Ingest(strings.NewReader(strings.Join([]string{
+		"#tally c a 1",
+		"#tally c b",
+		"#tally x c 1",
+		"#tally c d one",
+		"#tally c e 1",
+	}, "\n")), parent, IngestOptions{})
+	assert.EqualError(t, err, "line 2: malformed metric line")
+
+	counters := parent.Snapshot().Counters()
+	assert.Len(t, counters, 2)
+	assert.EqualValues(t, 1, counters["e+"].Value())
+}
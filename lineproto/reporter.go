@@ -0,0 +1,168 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package lineproto forwards metrics from short lived child processes to
+// their parent over a pipe, typically the child's stdout. The child reports
+// to a Reporter writing one line per reported value, the parent ingests the
+// lines into its own scope with Ingest.
+//
+// Each line is "#tally", the kind of value, the escaped metric name, its
+// value and its tags encoded as a URL query, separated by spaces:
+//
+//	#tally c requests 1 env=test
+//	#tally g workers 4
+//	#tally t db.query 1500000 env=test
+//	#tally hv latency 2 3
+//	#tally hd latency 10000000 3 env=test
+//
+// Timers are in nanoseconds, histogram lines hold the upper bound of the
+// bucket and the number of samples in it.
+package lineproto
+
+import (
+	"bufio"
+	"io"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	tally "github.com/extrasalt/tally/v4"
+)
+
+const marker = "#tally"
+
+const (
+	counterKind           = "c"
+	gaugeKind             = "g"
+	timerKind             = "t"
+	valueHistogramKind    = "hv"
+	durationHistogramKind = "hd"
+)
+
+type reporter struct {
+	mu  sync.Mutex
+	w   *bufio.Writer
+	buf []byte
+}
+
+// NewReporter returns a reporter which writes the reported values to w, it
+// is flushed to w when the reporter is flushed. The reporter of a child
+// process' root scope should be closed before the process exits so that
+// its final values are written.
+func NewReporter(w io.Writer) tally.StatsReporter {
+	return &reporter{w: bufio.NewWriter(w)}
+}
+
+func (r *reporter) ReportCounter(name string, tags map[string]string, value int64) {
+	r.write(counterKind, name, tags, func(b []byte) []byte {
+		return strconv.AppendInt(b, value, 10)
+	})
+}
+
+func (r *reporter) ReportGauge(name string, tags map[string]string, value float64) {
+	r.write(gaugeKind, name, tags, func(b []byte) []byte {
+		return strconv.AppendFloat(b, value, 'g', -1, 64)
+	})
+}
+
+func (r *reporter) ReportTimer(name string, tags map[string]string, interval time.Duration) {
+	r.write(timerKind, name, tags, func(b []byte) []byte {
+		return strconv.AppendInt(b, int64(interval), 10)
+	})
+}
+
+func (r *reporter) ReportHistogramValueSamples(
+	name string,
+	tags map[string]string,
+	buckets tally.Buckets,
+	bucketLowerBound,
+	bucketUpperBound float64,
+	samples int64,
+) {
+	r.write(valueHistogramKind, name, tags, func(b []byte) []byte {
+		b = strconv.AppendFloat(b, bucketUpperBound, 'g', -1, 64)
+		b = append(b, ' ')
+		return strconv.AppendInt(b, samples, 10)
+	})
+}
+
+func (r *reporter) ReportHistogramDurationSamples(
+	name string,
+	tags map[string]string,
+	buckets tally.Buckets,
+	bucketLowerBound,
+	bucketUpperBound time.Duration,
+	samples int64,
+) {
+	r.write(durationHistogramKind, name, tags, func(b []byte) []byte {
+		b = strconv.AppendInt(b, int64(bucketUpperBound), 10)
+		b = append(b, ' ')
+		return strconv.AppendInt(b, samples, 10)
+	})
+}
+
+func (r *reporter) write(
+	kind string,
+	name string,
+	tags map[string]string,
+	appendValue func([]byte) []byte,
+) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	b := append(r.buf[:0], marker...)
+	b = append(b, ' ')
+	b = append(b, kind...)
+	b = append(b, ' ')
+	b = append(b, url.QueryEscape(name)...)
+	b = append(b, ' ')
+	b = appendValue(b)
+	if len(tags) > 0 {
+		values := make(url.Values, len(tags))
+		for k, v := range tags {
+			values.Set(k, v)
+		}
+		b = append(b, ' ')
+		b = append(b, values.Encode()...)
+	}
+	b = append(b, '\n')
+	r.buf = b
+
+	_, _ = r.w.Write(b)
+}
+
+func (r *reporter) Capabilities() tally.Capabilities {
+	return r
+}
+
+func (r *reporter) Reporting() bool {
+	return true
+}
+
+func (r *reporter) Tagging() bool {
+	return true
+}
+
+func (r *reporter) Flush() {
+	r.mu.Lock()
+	_ = r.w.Flush()
+	r.mu.Unlock()
+}
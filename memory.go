@@ -0,0 +1,117 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tally
+
+import "unsafe"
+
+// MemoryStats is the approximate memory used by a registry, in bytes. The
+// stats are meant for capacity planning rather than being exact, they
+// don't account for the memory of reporters or for the overhead of Go
+// maps beyond their entries.
+type MemoryStats struct {
+	// Counters is the memory used by counters.
+	Counters int
+	// Gauges is the memory used by gauges.
+	Gauges int
+	// Timers is the memory used by timers.
+	Timers int
+	// Histograms is the memory used by histograms and their buckets.
+	Histograms int
+	// Scopes is the memory used by the scopes themselves, their prefix
+	// and tags.
+	Scopes int
+	// BySubscope is the memory used by each scope and its metrics, by
+	// scope key.
+	BySubscope map[string]int
+}
+
+// Total returns the memory used by the registry.
+func (m MemoryStats) Total() int {
+	return m.Counters + m.Gauges + m.Timers + m.Histograms + m.Scopes
+}
+
+const (
+	// metricEntryBytes is the size of the entry of a metric in the map of
+	// its scope, its key excluded, and in the slice reported from.
+	metricEntryBytes = int(unsafe.Sizeof("")) + 2*int(unsafe.Sizeof(uintptr(0)))
+	// histogramBucketBytes is the size of a histogram bucket with its
+	// sample counter.
+	histogramBucketBytes = int(unsafe.Sizeof(histogramBucket{})) +
+		int(unsafe.Sizeof(sampleCounter{})) + int(unsafe.Sizeof(counter{}))
+)
+
+// ReadMemoryStats returns the approximate memory used by the registry of
+// s, broken down by metric type and scope.
+func ReadMemoryStats(s Scope) (MemoryStats, error) {
+	var ts *scope
+	switch v := s.(type) {
+	case *scope:
+		ts = v
+	case *leveledScope:
+		ts = v.scope
+	default:
+		return MemoryStats{}, errEstimateNotTallyScope
+	}
+
+	stats := MemoryStats{BySubscope: make(map[string]int)}
+	ts.registry.forEachUniqueScope(func(ss *scope, tags map[string]string) bool {
+		var counters, gauges, timers, histograms int
+
+		ss.cm.RLock()
+		for key := range ss.counters {
+			counters += len(key) + metricEntryBytes + int(unsafe.Sizeof(counter{}))
+		}
+		ss.cm.RUnlock()
+
+		ss.gm.RLock()
+		for key := range ss.gauges {
+			gauges += len(key) + metricEntryBytes + int(unsafe.Sizeof(gauge{}))
+		}
+		ss.gm.RUnlock()
+
+		ss.tm.RLock()
+		for key, t := range ss.timers {
+			timers += len(key) + metricEntryBytes + int(unsafe.Sizeof(timer{})) + len(t.name)
+		}
+		ss.tm.RUnlock()
+
+		ss.hm.RLock()
+		for key, h := range ss.histograms {
+			histograms += len(key) + metricEntryBytes + int(unsafe.Sizeof(histogram{})) +
+				len(h.name) + len(h.buckets)*histogramBucketBytes
+		}
+		ss.hm.RUnlock()
+
+		scopeBytes := int(unsafe.Sizeof(scope{})) + len(ss.prefix)
+		for k, v := range tags {
+			scopeBytes += len(k) + len(v) + 2*int(unsafe.Sizeof(""))
+		}
+
+		stats.Counters += counters
+		stats.Gauges += gauges
+		stats.Timers += timers
+		stats.Histograms += histograms
+		stats.Scopes += scopeBytes
+		stats.BySubscope[ss.Key()] += counters + gauges + timers + histograms + scopeBytes
+		return true
+	})
+	return stats, nil
+}
@@ -0,0 +1,64 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tally
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadMemoryStats(t *testing.T) {
+	root, closer := NewRootScope(ScopeOptions{Prefix: "svc", MetricsOption: OmitInternalMetrics}, 0)
+	defer closer.Close()
+
+	sub := root.Tagged(map[string]string{"region": "us"})
+	root.Counter("requests")
+	sub.Gauge("workers")
+	sub.Timer("latency")
+	sub.Histogram("size", MustMakeLinearValueBuckets(0, 1, 10))
+
+	stats, err := ReadMemoryStats(root)
+	require.NoError(t, err)
+	assert.True(t, stats.Counters > 0)
+	assert.True(t, stats.Gauges > 0)
+	assert.True(t, stats.Timers > 0)
+	assert.True(t, stats.Histograms > stats.Gauges)
+	assert.True(t, stats.Scopes > 0)
+
+	var total int
+	for _, bytes := range stats.BySubscope {
+		total += bytes
+	}
+	assert.Equal(t, stats.Total(), total)
+	require.Contains(t, stats.BySubscope, sub.(*scope).Key())
+	assert.True(t, stats.BySubscope[sub.(*scope).Key()] > stats.BySubscope[root.(*scope).Key()])
+
+	// Every counter adds its name and entry.
+	root.Counter("errors")
+	more, err := ReadMemoryStats(AtLevel(root, DebugLevel))
+	require.NoError(t, err)
+	assert.Equal(t, 2*stats.Counters+len("errors")-len("requests"), more.Counters)
+
+	_, err = ReadMemoryStats(NoopScope)
+	assert.Error(t, err)
+}
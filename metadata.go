@@ -0,0 +1,179 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tally
+
+// MetricMetadata is the metadata of a metric, exported by reporters which
+// support it, e.g. as the HELP line of a Prometheus metric or the unit of
+// an OpenTelemetry metric.
+type MetricMetadata struct {
+	// Help describes the metric.
+	Help string
+	// Unit is the unit of the values of the metric, preferably in the
+	// Unified Code for Units of Measure, e.g. "By" or "1".
+	Unit string
+}
+
+// MetricOption sets the metadata of a metric.
+type MetricOption func(*MetricMetadata)
+
+// WithHelp sets the help text of a metric.
+func WithHelp(help string) MetricOption {
+	return func(m *MetricMetadata) {
+		m.Help = help
+	}
+}
+
+// WithUnit sets the unit of a metric.
+func WithUnit(unit string) MetricOption {
+	return func(m *MetricMetadata) {
+		m.Unit = unit
+	}
+}
+
+// MetricDescriber is implemented by reporters which export the metadata of
+// metrics.
+type MetricDescriber interface {
+	// DescribeMetric sets the metadata of the metric with the given kind
+	// and fully qualified name. It is called before the metric is first
+	// allocated or reported.
+	DescribeMetric(kind MetricKind, name string, metadata MetricMetadata)
+}
+
+// DescribedScope is a Scope which can create metrics with metadata. As
+// Scope can't be extended, metrics with metadata are created by the
+// functions CounterWith, GaugeWith, TimerWith and HistogramWith rather
+// than by options of Scope.Counter and friends.
+type DescribedScope interface {
+	Scope
+
+	// CounterWith returns the counter with the given name, describing it
+	// to the scope's reporter if it is a MetricDescriber when it is
+	// created.
+	CounterWith(name string, opts ...MetricOption) Counter
+
+	// GaugeWith is CounterWith for gauges.
+	GaugeWith(name string, opts ...MetricOption) Gauge
+
+	// TimerWith is CounterWith for timers.
+	TimerWith(name string, opts ...MetricOption) Timer
+
+	// HistogramWith is CounterWith for histograms.
+	HistogramWith(name string, buckets Buckets, opts ...MetricOption) Histogram
+}
+
+// CounterWith returns s.CounterWith(name, opts...) if s is a
+// DescribedScope, otherwise s.Counter(name).
+func CounterWith(s Scope, name string, opts ...MetricOption) Counter {
+	if ds, ok := s.(DescribedScope); ok {
+		return ds.CounterWith(name, opts...)
+	}
+	return s.Counter(name)
+}
+
+// GaugeWith returns s.GaugeWith(name, opts...) if s is a DescribedScope,
+// otherwise s.Gauge(name).
+func GaugeWith(s Scope, name string, opts ...MetricOption) Gauge {
+	if ds, ok := s.(DescribedScope); ok {
+		return ds.GaugeWith(name, opts...)
+	}
+	return s.Gauge(name)
+}
+
+// TimerWith returns s.TimerWith(name, opts...) if s is a DescribedScope,
+// otherwise s.Timer(name).
+func TimerWith(s Scope, name string, opts ...MetricOption) Timer {
+	if ds, ok := s.(DescribedScope); ok {
+		return ds.TimerWith(name, opts...)
+	}
+	return s.Timer(name)
+}
+
+// HistogramWith returns s.HistogramWith(name, buckets, opts...) if s is a
+// DescribedScope, otherwise s.Histogram(name, buckets).
+func HistogramWith(s Scope, name string, buckets Buckets, opts ...MetricOption) Histogram {
+	if ds, ok := s.(DescribedScope); ok {
+		return ds.HistogramWith(name, buckets, opts...)
+	}
+	return s.Histogram(name, buckets)
+}
+
+// describe describes the metric with the given name to the scope's
+// reporter if it is a MetricDescriber.
+func (s *scope) describe(kind MetricKind, name string, opts []MetricOption) {
+	describer, ok := s.baseReporter.(MetricDescriber)
+	if !ok || len(opts) == 0 {
+		return
+	}
+
+	var metadata MetricMetadata
+	for _, opt := range opts {
+		opt(&metadata)
+	}
+	describer.DescribeMetric(kind, s.fullyQualifiedName(s.sanitizer.Name(name)), metadata)
+}
+
+func (s *scope) CounterWith(name string, opts ...MetricOption) Counter {
+	if c, ok := s.counter(s.sanitizer.Name(name)); ok {
+		return c
+	}
+	s.describe(CounterKind, name, opts)
+	return s.Counter(name)
+}
+
+func (s *scope) GaugeWith(name string, opts ...MetricOption) Gauge {
+	if g, ok := s.gauge(s.sanitizer.Name(name)); ok {
+		return g
+	}
+	s.describe(GaugeKind, name, opts)
+	return s.Gauge(name)
+}
+
+func (s *scope) TimerWith(name string, opts ...MetricOption) Timer {
+	if t, ok := s.timer(s.sanitizer.Name(name)); ok {
+		return t
+	}
+	s.describe(TimerKind, name, opts)
+	return s.Timer(name)
+}
+
+func (s *scope) HistogramWith(name string, buckets Buckets, opts ...MetricOption) Histogram {
+	if h, ok := s.histogram(s.sanitizer.Name(name)); ok {
+		return h
+	}
+	s.describe(HistogramKind, name, opts)
+	return s.Histogram(name, buckets)
+}
+
+func (s *leveledScope) CounterWith(name string, opts ...MetricOption) Counter {
+	return leveledCounter{s, s.scope.CounterWith(name, opts...)}
+}
+
+func (s *leveledScope) GaugeWith(name string, opts ...MetricOption) Gauge {
+	return leveledGauge{s, s.scope.GaugeWith(name, opts...)}
+}
+
+func (s *leveledScope) TimerWith(name string, opts ...MetricOption) Timer {
+	return leveledTimer{s, s.scope.TimerWith(name, opts...)}
+}
+
+func (s *leveledScope) HistogramWith(name string, buckets Buckets, opts ...MetricOption) Histogram {
+	return leveledHistogram{s, s.scope.HistogramWith(name, buckets, opts...)}
+}
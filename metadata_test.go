@@ -0,0 +1,96 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tally
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type describedMetric struct {
+	kind     MetricKind
+	name     string
+	metadata MetricMetadata
+}
+
+type describingReporter struct {
+	nullStatsReporter
+	described []describedMetric
+}
+
+func (r *describingReporter) DescribeMetric(kind MetricKind, name string, metadata MetricMetadata) {
+	r.described = append(r.described, describedMetric{kind, name, metadata})
+}
+
+func TestDescribeMetric(t *testing.T) {
+	r := &describingReporter{}
+	root, closer := NewRootScope(ScopeOptions{Prefix: "svc", Reporter: r}, 0)
+	defer closer.Close()
+
+	s := root.SubScope("http")
+	c := CounterWith(s, "requests", WithHelp("Total requests."), WithUnit("1"))
+	assert.Equal(t, c, CounterWith(s, "requests", WithHelp("Ignored.")))
+	assert.Equal(t, c, s.Counter("requests"))
+	GaugeWith(AtLevel(root, DebugLevel), "queue", WithUnit("1"))
+	TimerWith(root, "latency", WithHelp("Latency."))
+	HistogramWith(root, "size", MustMakeLinearValueBuckets(0, 1, 2), WithUnit("By"))
+	CounterWith(root, "plain")
+
+	assert.Equal(t, []describedMetric{
+		{CounterKind, "svc.http.requests", MetricMetadata{Help: "Total requests.", Unit: "1"}},
+		{GaugeKind, "svc.queue", MetricMetadata{Unit: "1"}},
+		{TimerKind, "svc.latency", MetricMetadata{Help: "Latency."}},
+		{HistogramKind, "svc.size", MetricMetadata{Unit: "By"}},
+	}, r.described)
+}
+
+func TestDescribeMetricFallback(t *testing.T) {
+	type wrapped struct{ Scope }
+
+	s := NewTestScope("", nil)
+	CounterWith(wrapped{s}, "requests", WithHelp("Total requests.")).Inc(1)
+	GaugeWith(NoopScope, "queue", WithUnit("1")).Update(1)
+
+	counters := s.Snapshot().Counters()
+	require.Contains(t, counters, "requests+")
+	assert.Equal(t, int64(1), counters["requests+"].Value())
+}
+
+func TestHistogramWithOptions(t *testing.T) {
+	r := &describingReporter{}
+	root, closer := NewRootScope(ScopeOptions{Reporter: r}, 0)
+	defer closer.Close()
+
+	buckets := MustMakeLinearValueBuckets(0, 1, 2)
+	h := HistogramWithOptions(root, "size", HistogramOptions{
+		Buckets:     buckets,
+		Unit:        "By",
+		Description: "Size of the requests.",
+	})
+	assert.Equal(t, h, root.Histogram("size", buckets))
+	HistogramWithOptions(root, "plain", HistogramOptions{})
+
+	assert.Equal(t, []describedMetric{
+		{HistogramKind, "size", MetricMetadata{Help: "Size of the requests.", Unit: "By"}},
+	}, r.described)
+}
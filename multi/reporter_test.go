@@ -173,6 +173,34 @@ func TestMultiCachedReporter(t *testing.T) {
 	}
 }
 
+func TestMultiReporterAnnotateMetric(t *testing.T) {
+	a, b := newCapturingStatsReporter(), newCapturingStatsReporter()
+	annotations := map[string]string{"team": "payments"}
+
+	r := NewMultiReporter(a, tally.NullStatsReporter, b)
+	r.(tally.MetricAnnotator).AnnotateMetric("foo", annotations)
+	assert.Equal(t, annotations, a.annotations["foo"])
+	assert.Equal(t, annotations, b.annotations["foo"])
+
+	cached := NewMultiCachedReporter(a)
+	cached.(tally.MetricAnnotator).AnnotateMetric("bar", annotations)
+	assert.Equal(t, annotations, a.annotations["bar"])
+}
+
+func TestMultiReporterCounterTotal(t *testing.T) {
+	a, b := newCapturingStatsReporter(), newCapturingStatsReporter()
+	tags := map[string]string{"foo": "bar"}
+
+	r := NewMultiReporter(a, tally.NullStatsReporter, b)
+	r.(tally.CumulativeCounterReporter).ReportCounterTotal("foo", tags, 42)
+	assert.Equal(t, []capturedCount{{"foo", tags, 42}}, a.totals)
+	assert.Equal(t, []capturedCount{{"foo", tags, 42}}, b.totals)
+
+	cached := NewMultiCachedReporter(a)
+	cached.AllocateCounter("bar", tags).(tally.CachedCumulativeCount).ReportTotal(84)
+	assert.Equal(t, capturedCount{"bar", tags, 84}, a.totals[1])
+}
+
 type capturingStatsReporter struct {
 	counts                   []capturedCount
 	gauges                   []capturedGauge
@@ -181,6 +209,8 @@ type capturingStatsReporter struct {
 	histogramDurationSamples []capturedHistogramDurationSamples
 	capabilities             int
 	flush                    int
+	annotations              map[string]map[string]string
+	totals                   []capturedCount
 }
 
 type capturedCount struct {
@@ -218,7 +248,13 @@ type capturedHistogramDurationSamples struct {
 }
 
 func newCapturingStatsReporter() *capturingStatsReporter {
-	return &capturingStatsReporter{}
+	return &capturingStatsReporter{
+		annotations: make(map[string]map[string]string),
+	}
+}
+
+func (r *capturingStatsReporter) AnnotateMetric(name string, annotations map[string]string) {
+	r.annotations[name] = annotations
 }
 
 func (r *capturingStatsReporter) ReportCounter(
@@ -229,6 +265,14 @@ func (r *capturingStatsReporter) ReportCounter(
 	r.counts = append(r.counts, capturedCount{name, tags, value})
 }
 
+func (r *capturingStatsReporter) ReportCounterTotal(
+	name string,
+	tags map[string]string,
+	total int64,
+) {
+	r.totals = append(r.totals, capturedCount{name, tags, total})
+}
+
 func (r *capturingStatsReporter) ReportGauge(
 	name string,
 	tags map[string]string,
@@ -279,9 +323,14 @@ func (r *capturingStatsReporter) AllocateCounter(
 	name string,
 	tags map[string]string,
 ) tally.CachedCount {
-	return cachedCount{fn: func(value int64) {
-		r.counts = append(r.counts, capturedCount{name, tags, value})
-	}}
+	return cachedCount{
+		fn: func(value int64) {
+			r.counts = append(r.counts, capturedCount{name, tags, value})
+		},
+		totalFn: func(total int64) {
+			r.totals = append(r.totals, capturedCount{name, tags, total})
+		},
+	}
 }
 
 func (r *capturingStatsReporter) AllocateGauge(
@@ -341,13 +390,18 @@ func (r *capturingStatsReporter) Flush() {
 }
 
 type cachedCount struct {
-	fn func(value int64)
+	fn      func(value int64)
+	totalFn func(total int64)
 }
 
 func (c cachedCount) ReportCount(value int64) {
 	c.fn(value)
 }
 
+func (c cachedCount) ReportTotal(total int64) {
+	c.totalFn(total)
+}
+
 type cachedGauge struct {
 	fn func(value float64)
 }
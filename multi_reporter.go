@@ -0,0 +1,378 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tally
+
+import "time"
+
+// NewMultiReporter returns a reporter fanning every report out to
+// reporters, e.g. to dual-write to two backends during a migration. It is
+// reporting and tagging if all of reporters are.
+//
+// Backends are isolated from each other: a panic of a reporter is
+// recovered and drops that report for it only.
+func NewMultiReporter(reporters ...StatsReporter) StatsReporter {
+	base := make(multiBaseReporters, 0, len(reporters))
+	for _, r := range reporters {
+		base = append(base, r)
+	}
+	return &multiReporter{
+		multiBaseReporters: base,
+		reporters:          reporters,
+	}
+}
+
+// NewMultiCachedReporter returns a cached reporter allocating its metrics
+// from all of reporters, which are isolated from each other like with
+// NewMultiReporter. A reporter which panics allocating a metric doesn't
+// receive the values of that metric.
+func NewMultiCachedReporter(reporters ...CachedStatsReporter) CachedStatsReporter {
+	base := make(multiBaseReporters, 0, len(reporters))
+	for _, r := range reporters {
+		base = append(base, r)
+	}
+	return &multiCachedReporter{
+		multiBaseReporters: base,
+		reporters:          reporters,
+	}
+}
+
+// isolated calls f, recovering any panic so that a failing backend
+// doesn't prevent reporting to the others.
+func isolated(f func()) {
+	defer func() {
+		_ = recover()
+	}()
+	f()
+}
+
+type multiReporter struct {
+	multiBaseReporters
+	reporters []StatsReporter
+}
+
+func (m *multiReporter) ReportCounter(name string, tags map[string]string, value int64) {
+	for _, r := range m.reporters {
+		r := r
+		isolated(func() { r.ReportCounter(name, tags, value) })
+	}
+}
+
+func (m *multiReporter) ReportCounterTotal(name string, tags map[string]string, total int64) {
+	for _, r := range m.reporters {
+		if cr, ok := r.(CumulativeCounterReporter); ok {
+			isolated(func() { cr.ReportCounterTotal(name, tags, total) })
+		}
+	}
+}
+
+func (m *multiReporter) ReportGauge(name string, tags map[string]string, value float64) {
+	for _, r := range m.reporters {
+		r := r
+		isolated(func() { r.ReportGauge(name, tags, value) })
+	}
+}
+
+func (m *multiReporter) ReportTimer(name string, tags map[string]string, interval time.Duration) {
+	for _, r := range m.reporters {
+		r := r
+		isolated(func() { r.ReportTimer(name, tags, interval) })
+	}
+}
+
+func (m *multiReporter) ReportHistogramValueSamples(
+	name string,
+	tags map[string]string,
+	buckets Buckets,
+	bucketLowerBound,
+	bucketUpperBound float64,
+	samples int64,
+) {
+	for _, r := range m.reporters {
+		r := r
+		isolated(func() {
+			r.ReportHistogramValueSamples(name, tags, buckets,
+				bucketLowerBound, bucketUpperBound, samples)
+		})
+	}
+}
+
+func (m *multiReporter) ReportHistogramDurationSamples(
+	name string,
+	tags map[string]string,
+	buckets Buckets,
+	bucketLowerBound,
+	bucketUpperBound time.Duration,
+	samples int64,
+) {
+	for _, r := range m.reporters {
+		r := r
+		isolated(func() {
+			r.ReportHistogramDurationSamples(name, tags, buckets,
+				bucketLowerBound, bucketUpperBound, samples)
+		})
+	}
+}
+
+// ReportCounterAt implements TimestampedStatsReporter, reporters which
+// don't accept timestamps receive the value without it, like the other At
+// methods.
+func (m *multiReporter) ReportCounterAt(name string, tags map[string]string, value int64, ts time.Time) {
+	for _, r := range m.reporters {
+		r := r
+		isolated(func() {
+			if tr, ok := r.(TimestampedStatsReporter); ok {
+				tr.ReportCounterAt(name, tags, value, ts)
+			} else {
+				r.ReportCounter(name, tags, value)
+			}
+		})
+	}
+}
+
+func (m *multiReporter) ReportGaugeAt(name string, tags map[string]string, value float64, ts time.Time) {
+	for _, r := range m.reporters {
+		r := r
+		isolated(func() {
+			if tr, ok := r.(TimestampedStatsReporter); ok {
+				tr.ReportGaugeAt(name, tags, value, ts)
+			} else {
+				r.ReportGauge(name, tags, value)
+			}
+		})
+	}
+}
+
+func (m *multiReporter) ReportTimerAt(name string, tags map[string]string, interval time.Duration, ts time.Time) {
+	for _, r := range m.reporters {
+		r := r
+		isolated(func() {
+			if tr, ok := r.(TimestampedStatsReporter); ok {
+				tr.ReportTimerAt(name, tags, interval, ts)
+			} else {
+				r.ReportTimer(name, tags, interval)
+			}
+		})
+	}
+}
+
+func (m *multiReporter) ReportHistogramValueSamplesAt(
+	name string,
+	tags map[string]string,
+	buckets Buckets,
+	bucketLowerBound,
+	bucketUpperBound float64,
+	samples int64,
+	ts time.Time,
+) {
+	for _, r := range m.reporters {
+		r := r
+		isolated(func() {
+			if tr, ok := r.(TimestampedStatsReporter); ok {
+				tr.ReportHistogramValueSamplesAt(name, tags, buckets,
+					bucketLowerBound, bucketUpperBound, samples, ts)
+			} else {
+				r.ReportHistogramValueSamples(name, tags, buckets,
+					bucketLowerBound, bucketUpperBound, samples)
+			}
+		})
+	}
+}
+
+func (m *multiReporter) ReportHistogramDurationSamplesAt(
+	name string,
+	tags map[string]string,
+	buckets Buckets,
+	bucketLowerBound,
+	bucketUpperBound time.Duration,
+	samples int64,
+	ts time.Time,
+) {
+	for _, r := range m.reporters {
+		r := r
+		isolated(func() {
+			if tr, ok := r.(TimestampedStatsReporter); ok {
+				tr.ReportHistogramDurationSamplesAt(name, tags, buckets,
+					bucketLowerBound, bucketUpperBound, samples, ts)
+			} else {
+				r.ReportHistogramDurationSamples(name, tags, buckets,
+					bucketLowerBound, bucketUpperBound, samples)
+			}
+		})
+	}
+}
+
+type multiCachedReporter struct {
+	multiBaseReporters
+	reporters []CachedStatsReporter
+}
+
+func (m *multiCachedReporter) AllocateCounter(name string, tags map[string]string) CachedCount {
+	metrics := make([]CachedCount, 0, len(m.reporters))
+	for _, r := range m.reporters {
+		r := r
+		isolated(func() { metrics = append(metrics, r.AllocateCounter(name, tags)) })
+	}
+	return multiCachedMetric{counters: metrics}
+}
+
+func (m *multiCachedReporter) AllocateGauge(name string, tags map[string]string) CachedGauge {
+	metrics := make([]CachedGauge, 0, len(m.reporters))
+	for _, r := range m.reporters {
+		r := r
+		isolated(func() { metrics = append(metrics, r.AllocateGauge(name, tags)) })
+	}
+	return multiCachedMetric{gauges: metrics}
+}
+
+func (m *multiCachedReporter) AllocateTimer(name string, tags map[string]string) CachedTimer {
+	metrics := make([]CachedTimer, 0, len(m.reporters))
+	for _, r := range m.reporters {
+		r := r
+		isolated(func() { metrics = append(metrics, r.AllocateTimer(name, tags)) })
+	}
+	return multiCachedMetric{timers: metrics}
+}
+
+func (m *multiCachedReporter) AllocateHistogram(
+	name string,
+	tags map[string]string,
+	buckets Buckets,
+) CachedHistogram {
+	metrics := make([]CachedHistogram, 0, len(m.reporters))
+	for _, r := range m.reporters {
+		r := r
+		isolated(func() { metrics = append(metrics, r.AllocateHistogram(name, tags, buckets)) })
+	}
+	return multiCachedMetric{histograms: metrics}
+}
+
+type multiCachedMetric struct {
+	counters   []CachedCount
+	gauges     []CachedGauge
+	timers     []CachedTimer
+	histograms []CachedHistogram
+}
+
+func (m multiCachedMetric) ReportCount(value int64) {
+	for _, c := range m.counters {
+		c := c
+		isolated(func() { c.ReportCount(value) })
+	}
+}
+
+func (m multiCachedMetric) ReportTotal(total int64) {
+	for _, c := range m.counters {
+		if cc, ok := c.(CachedCumulativeCount); ok {
+			isolated(func() { cc.ReportTotal(total) })
+		}
+	}
+}
+
+func (m multiCachedMetric) ReportGauge(value float64) {
+	for _, g := range m.gauges {
+		g := g
+		isolated(func() { g.ReportGauge(value) })
+	}
+}
+
+func (m multiCachedMetric) ReportTimer(interval time.Duration) {
+	for _, t := range m.timers {
+		t := t
+		isolated(func() { t.ReportTimer(interval) })
+	}
+}
+
+func (m multiCachedMetric) ValueBucket(
+	bucketLowerBound, bucketUpperBound float64,
+) CachedHistogramBucket {
+	buckets := make(multiCachedHistogramBucket, 0, len(m.histograms))
+	for _, h := range m.histograms {
+		h := h
+		isolated(func() {
+			buckets = append(buckets, h.ValueBucket(bucketLowerBound, bucketUpperBound))
+		})
+	}
+	return buckets
+}
+
+func (m multiCachedMetric) DurationBucket(
+	bucketLowerBound, bucketUpperBound time.Duration,
+) CachedHistogramBucket {
+	buckets := make(multiCachedHistogramBucket, 0, len(m.histograms))
+	for _, h := range m.histograms {
+		h := h
+		isolated(func() {
+			buckets = append(buckets, h.DurationBucket(bucketLowerBound, bucketUpperBound))
+		})
+	}
+	return buckets
+}
+
+type multiCachedHistogramBucket []CachedHistogramBucket
+
+func (m multiCachedHistogramBucket) ReportSamples(value int64) {
+	for _, b := range m {
+		b := b
+		isolated(func() { b.ReportSamples(value) })
+	}
+}
+
+type multiBaseReporters []BaseStatsReporter
+
+func (m multiBaseReporters) Capabilities() Capabilities {
+	c := &capabilities{reporting: true, tagging: true}
+	for _, r := range m {
+		r := r
+		isolated(func() {
+			caps := r.Capabilities()
+			c.reporting = c.reporting && caps.Reporting()
+			c.tagging = c.tagging && caps.Tagging()
+		})
+	}
+	return c
+}
+
+func (m multiBaseReporters) AnnotateMetric(name string, annotations map[string]string) {
+	for _, r := range m {
+		if a, ok := r.(MetricAnnotator); ok {
+			isolated(func() { a.AnnotateMetric(name, annotations) })
+		}
+	}
+}
+
+func (m multiBaseReporters) DescribeMetric(
+	kind MetricKind,
+	name string,
+	metadata MetricMetadata,
+) {
+	for _, r := range m {
+		if d, ok := r.(MetricDescriber); ok {
+			isolated(func() { d.DescribeMetric(kind, name, metadata) })
+		}
+	}
+}
+
+func (m multiBaseReporters) Flush() {
+	for _, r := range m {
+		r := r
+		isolated(func() { r.Flush() })
+	}
+}
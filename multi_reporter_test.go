@@ -0,0 +1,114 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tally
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// panickingReporter panics on every call.
+type panickingReporter struct{}
+
+func (panickingReporter) ReportCounter(string, map[string]string, int64)       { panic("counter") }
+func (panickingReporter) ReportGauge(string, map[string]string, float64)       { panic("gauge") }
+func (panickingReporter) ReportTimer(string, map[string]string, time.Duration) { panic("timer") }
+func (panickingReporter) ReportHistogramValueSamples(
+	string, map[string]string, Buckets, float64, float64, int64,
+) {
+	panic("histogram")
+}
+func (panickingReporter) ReportHistogramDurationSamples(
+	string, map[string]string, Buckets, time.Duration, time.Duration, int64,
+) {
+	panic("histogram")
+}
+func (panickingReporter) AllocateCounter(string, map[string]string) CachedCount { panic("counter") }
+func (panickingReporter) AllocateGauge(string, map[string]string) CachedGauge   { panic("gauge") }
+func (panickingReporter) AllocateTimer(string, map[string]string) CachedTimer   { panic("timer") }
+func (panickingReporter) AllocateHistogram(string, map[string]string, Buckets) CachedHistogram {
+	panic("histogram")
+}
+func (panickingReporter) Capabilities() Capabilities { return capabilitiesReportingTagging }
+func (panickingReporter) Flush()                     { panic("flush") }
+
+func TestMultiReporterIsolation(t *testing.T) {
+	r := newTestStatsReporter()
+	root, closer := NewRootScope(ScopeOptions{
+		Reporter:      NewMultiReporter(panickingReporter{}, r),
+		MetricsOption: OmitInternalMetrics,
+	}, 0)
+	defer closer.Close()
+
+	root.Counter("c").Inc(1)
+	root.Gauge("g").Update(2)
+	root.Histogram("h", ValueBuckets{1}).RecordValue(1)
+
+	r.tg.Add(1)
+	root.Timer("t").Record(time.Second)
+	r.cg.Add(1)
+	r.gg.Add(1)
+	r.hg.Add(1)
+	root.(*scope).reportRegistry()
+	r.WaitAll()
+
+	assert.EqualValues(t, 1, r.getCounters()["c"].val)
+	assert.EqualValues(t, 2, r.getGauges()["g"].val)
+	assert.EqualValues(t, time.Second, r.getTimers()["t"].val)
+	assert.Equal(t, 1, r.getHistograms()["h"].valueSamples[1])
+}
+
+func TestMultiCachedReporterIsolation(t *testing.T) {
+	r := newTestStatsReporter()
+	root, closer := NewRootScope(ScopeOptions{
+		CachedReporter: NewMultiCachedReporter(panickingReporter{}, r),
+		MetricsOption:  OmitInternalMetrics,
+	}, 0)
+	defer closer.Close()
+
+	root.Counter("c").Inc(1)
+	root.Gauge("g").Update(2)
+	root.Histogram("h", ValueBuckets{1}).RecordValue(1)
+
+	r.tg.Add(1)
+	root.Timer("t").Record(time.Second)
+	r.cg.Add(1)
+	r.gg.Add(1)
+	r.hg.Add(1)
+	root.(*scope).reportRegistry()
+	r.WaitAll()
+
+	assert.EqualValues(t, 1, r.getCounters()["c"].val)
+	assert.EqualValues(t, 2, r.getGauges()["g"].val)
+	assert.EqualValues(t, time.Second, r.getTimers()["t"].val)
+	assert.Equal(t, 1, r.getHistograms()["h"].valueSamples[1])
+}
+
+func TestMultiReporterCapabilities(t *testing.T) {
+	caps := NewMultiReporter(newTestStatsReporter(), panickingReporter{}).Capabilities()
+	assert.True(t, caps.Reporting())
+	assert.False(t, caps.Tagging())
+
+	caps = NewMultiReporter(NullStatsReporter, panickingReporter{}).Capabilities()
+	assert.False(t, caps.Reporting())
+}
@@ -0,0 +1,98 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tally
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// nameSeparator joins the parts of a Name.
+const nameSeparator = "_"
+
+// builtNames are the names built by N, used to detect metric names built
+// otherwise when StrictNames is set.
+var builtNames sync.Map
+
+// Name is a metric name built from constant parts by N. Building metric
+// names with N rather than by formatting strings makes the names of a
+// program a fixed set, which static analysis and StrictNames can verify,
+// and avoids the unbounded cardinality of names built from variables.
+type Name string
+
+// N returns the name joining parts with underscores, e.g.
+// N("requests", "total") is "requests_total". Parts must be non empty and
+// only contain ASCII letters, digits and underscores, N panics otherwise
+// since parts are meant to be constants.
+func N(parts ...string) Name {
+	if len(parts) == 0 {
+		panic("tally: name has no parts")
+	}
+	for _, part := range parts {
+		if err := validateNamePart(part); err != nil {
+			panic(err)
+		}
+	}
+
+	name := strings.Join(parts, nameSeparator)
+	builtNames.Store(name, struct{}{})
+	return Name(name)
+}
+
+// String returns the name.
+func (n Name) String() string {
+	return string(n)
+}
+
+func validateNamePart(part string) error {
+	if part == "" {
+		return fmt.Errorf("tally: empty name part")
+	}
+	for _, c := range part {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '_':
+		default:
+			return fmt.Errorf("tally: invalid character %q in name part %q", c, part)
+		}
+	}
+	return nil
+}
+
+// checkName panics if strict names are required and the metric name wasn't
+// built by N.
+func (r *scopeRegistry) checkName(name string) {
+	if !r.strictNames {
+		return
+	}
+	if _, ok := builtNames.Load(name); !ok {
+		panic(fmt.Sprintf("tally: metric name %q was not built with tally.N", name))
+	}
+}
+
+// derivedName returns name with suffix appended, for metrics derived from
+// a metric whose name was already checked, and records it as built so
+// that strict names accept it.
+func derivedName(name, suffix string) string {
+	derived := name + suffix
+	builtNames.Store(derived, struct{}{})
+	return derived
+}
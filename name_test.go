@@ -0,0 +1,62 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tally
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestN(t *testing.T) {
+	assert.Equal(t, Name("requests_total"), N("requests", "total"))
+	assert.Equal(t, "http_5xx", N("http", "5xx").String())
+
+	assert.Panics(t, func() { N() })
+	assert.Panics(t, func() { N("requests", "") })
+	assert.Panics(t, func() { N("requests.total") })
+	assert.Panics(t, func() { N("requests", "tötal") })
+}
+
+func TestStrictNames(t *testing.T) {
+	root := newRootScope(ScopeOptions{
+		Reporter:    NullStatsReporter,
+		StrictNames: true,
+	}, 0)
+	defer root.Close()
+
+	name := N("strict", "requests")
+	assert.NotPanics(t, func() {
+		root.Counter(string(name)).Inc(1)
+		root.Gauge(string(name))
+		root.Timer(string(name))
+		root.Histogram(string(name), nil)
+	})
+
+	for i := 0; i < 2; i++ {
+		dynamic := fmt.Sprintf("strict_requests_%d", i)
+		assert.Panics(t, func() { root.Counter(dynamic) })
+	}
+
+	lax := NewTestScope("", nil)
+	assert.NotPanics(t, func() { lax.Counter("strict_requests_0") })
+}
@@ -0,0 +1,160 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tally
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// NamespaceOptions are the options the metrics of a namespace are created
+// with. Options left unset are compatible with any.
+type NamespaceOptions struct {
+	// Buckets are the buckets of the histograms of the namespace.
+	Buckets Buckets
+	// TagKeys are the keys of the tags of the metrics of the namespace.
+	TagKeys []string
+}
+
+// NamespaceConflict is a namespace reserved with options incompatible with
+// the options it was reserved with before.
+type NamespaceConflict struct {
+	// Namespace is the prefix of the namespace.
+	Namespace string
+	// Owner is the owner reserving the namespace.
+	Owner string
+	// PreviousOwner is the owner who reserved the namespace first.
+	PreviousOwner string
+	// Reason describes the incompatibility of the options.
+	Reason string
+}
+
+func (c NamespaceConflict) Error() string {
+	return fmt.Sprintf("namespace %q reserved by %s conflicts with its reservation by %s: %s",
+		c.Namespace, c.Owner, c.PreviousOwner, c.Reason)
+}
+
+// ReserveNamespace reserves the namespace of s, its prefix, for owner,
+// typically the import path of the package creating metrics from s.
+//
+// Packages may share a namespace, but a reservation with options
+// incompatible with the first reservation of the namespace, e.g. other
+// buckets, is a conflict: rather than letting the packages silently
+// interleave their metrics, the conflict is counted by an internal metric,
+// passed to ScopeOptions.OnNamespaceConflict and returned. Scopes not
+// created by NewRootScope don't detect conflicts.
+func ReserveNamespace(s Scope, owner string, opts NamespaceOptions) error {
+	var ts *scope
+	switch v := s.(type) {
+	case *scope:
+		ts = v
+	case *leveledScope:
+		ts = v.scope
+	default:
+		return nil
+	}
+
+	c, ok := ts.registry.namespaces.reserve(ts.prefix, owner, opts)
+	if !ok {
+		return nil
+	}
+
+	ts.registry.namespaceConflicts.Inc()
+	if ts.registry.onNamespaceConflict != nil {
+		ts.registry.onNamespaceConflict(c)
+	}
+	return c
+}
+
+// namespaceReservation is the first reservation of a namespace.
+type namespaceReservation struct {
+	owner   string
+	buckets Buckets
+	tagKeys []string
+}
+
+// namespaces are the namespaces reserved in a registry.
+type namespaces struct {
+	mu       sync.Mutex
+	reserved map[string]namespaceReservation
+}
+
+// reserve reserves namespace for owner, returning the conflict with its
+// first reservation if any.
+func (n *namespaces) reserve(
+	namespace string,
+	owner string,
+	opts NamespaceOptions,
+) (NamespaceConflict, bool) {
+	var tagKeys []string
+	if opts.TagKeys != nil {
+		tagKeys = append(make([]string, 0, len(opts.TagKeys)), opts.TagKeys...)
+		sort.Strings(tagKeys)
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	first, ok := n.reserved[namespace]
+	if !ok {
+		if n.reserved == nil {
+			n.reserved = make(map[string]namespaceReservation)
+		}
+		n.reserved[namespace] = namespaceReservation{
+			owner:   owner,
+			buckets: opts.Buckets,
+			tagKeys: tagKeys,
+		}
+		return NamespaceConflict{}, false
+	}
+
+	var reason string
+	switch {
+	case first.buckets != nil && opts.Buckets != nil && !bucketsEqual(first.buckets, opts.Buckets):
+		reason = fmt.Sprintf("buckets %v != %v", opts.Buckets, first.buckets)
+	case first.tagKeys != nil && tagKeys != nil && !stringsEqual(first.tagKeys, tagKeys):
+		reason = fmt.Sprintf("tag keys [%s] != [%s]",
+			strings.Join(tagKeys, " "), strings.Join(first.tagKeys, " "))
+	default:
+		return NamespaceConflict{}, false
+	}
+
+	return NamespaceConflict{
+		Namespace:     namespace,
+		Owner:         owner,
+		PreviousOwner: first.owner,
+		Reason:        reason,
+	}, true
+}
+
+func stringsEqual(x, y []string) bool {
+	if len(x) != len(y) {
+		return false
+	}
+	for i := range x {
+		if x[i] != y[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,80 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tally
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReserveNamespace(t *testing.T) {
+	r := &valueRecordingReporter{
+		counters:   make(map[string]int64),
+		histograms: make(map[string]int64),
+	}
+	var conflicts []NamespaceConflict
+	root, closer := NewRootScope(ScopeOptions{
+		Prefix:              "svc",
+		Reporter:            r,
+		MetricsOption:       SendInternalMetrics,
+		OnNamespaceConflict: func(c NamespaceConflict) { conflicts = append(conflicts, c) },
+	}, 0)
+	defer closer.Close()
+
+	http := root.SubScope("http")
+	buckets := ValueBuckets{1, 10}
+	require.NoError(t, ReserveNamespace(http, "pkg/server", NamespaceOptions{
+		Buckets: buckets,
+		TagKeys: []string{"method", "status"},
+	}))
+
+	// Compatible reservations share the namespace.
+	assert.NoError(t, ReserveNamespace(http, "pkg/middleware", NamespaceOptions{
+		Buckets: ValueBuckets{1, 10},
+		TagKeys: []string{"status", "method"},
+	}))
+	assert.NoError(t, ReserveNamespace(AtLevel(http, DebugLevel), "pkg/debug", NamespaceOptions{}))
+	assert.NoError(t, ReserveNamespace(root, "pkg/other", NamespaceOptions{Buckets: ValueBuckets{5}}))
+
+	err := ReserveNamespace(root.SubScope("http"), "pkg/client", NamespaceOptions{
+		Buckets: DurationBuckets{1, 10},
+	})
+	require.Error(t, err)
+	assert.Equal(t, NamespaceConflict{
+		Namespace:     "svc.http",
+		Owner:         "pkg/client",
+		PreviousOwner: "pkg/server",
+		Reason:        "buckets [1ns 10ns] != [1.000000 10.000000]",
+	}, err)
+
+	err = ReserveNamespace(http, "pkg/client", NamespaceOptions{TagKeys: []string{"method"}})
+	assert.Equal(t, "namespace \"svc.http\" reserved by pkg/client conflicts with its "+
+		"reservation by pkg/server: tag keys [method] != [method status]", err.Error())
+	assert.Len(t, conflicts, 2)
+
+	root.(*scope).reportRegistry()
+	assert.EqualValues(t, 2, r.counters[namespaceConflictsName])
+
+	// Scopes not created by NewRootScope don't detect conflicts.
+	assert.NoError(t, ReserveNamespace(NoopScope, "pkg/client", NamespaceOptions{}))
+}
@@ -0,0 +1,321 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tally
+
+import (
+	"math"
+	"math/rand"
+	"path"
+	"sync"
+	"time"
+)
+
+// NoiseRule configures noise injection for the metrics whose name matches
+// the rule, for metrics derived from user behavior which must only be
+// exported as privacy preserving aggregates. Laplace noise with a scale
+// of Sensitivity divided by Epsilon is added to every value reported: the
+// deltas of counters, the values of gauges and the sample counts of
+// histogram buckets, so that each report is Epsilon-differentially
+// private with respect to changes of up to Sensitivity to the value.
+//
+// Noised counts are rounded and floored at zero. The cumulative totals of
+// noised counters are not reported, as they would reveal the values
+// without noise. Timers are not noised.
+type NoiseRule struct {
+	// Name is a path.Match pattern matched against fully qualified metric
+	// names.
+	Name string
+
+	// Epsilon is the privacy budget of each report, lower values add more
+	// noise. Rules with a non positive Epsilon are ignored.
+	Epsilon float64
+
+	// Sensitivity is the largest change a single user can make to a value
+	// reported. Use zero to specify 1.
+	Sensitivity float64
+}
+
+// noise adds Laplace noise to the values of the metrics matching a set of
+// noise rules.
+type noise struct {
+	rules []NoiseRule
+
+	mu     sync.Mutex
+	rand   *rand.Rand
+	scales map[string]float64
+}
+
+func newNoise(rules []NoiseRule, src rand.Source) *noise {
+	if len(rules) == 0 {
+		return nil
+	}
+	return &noise{
+		rules:  rules,
+		rand:   rand.New(src),
+		scales: make(map[string]float64),
+	}
+}
+
+// scale returns the scale of the noise added to the values of the metric
+// with the given fully qualified name, zero if none is.
+func (n *noise) scale(name string) float64 {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if scale, ok := n.scales[name]; ok {
+		return scale
+	}
+	var scale float64
+	for _, rule := range n.rules {
+		if ok, _ := path.Match(rule.Name, name); !ok || rule.Epsilon <= 0 {
+			continue
+		}
+		sensitivity := rule.Sensitivity
+		if sensitivity <= 0 {
+			sensitivity = 1
+		}
+		scale = sensitivity / rule.Epsilon
+		break
+	}
+	n.scales[name] = scale
+	return scale
+}
+
+// laplace returns a sample of the Laplace distribution centered on zero
+// with the given scale.
+func (n *noise) laplace(scale float64) float64 {
+	n.mu.Lock()
+	u := n.rand.Float64() - 0.5
+	n.mu.Unlock()
+
+	if u < 0 {
+		return scale * math.Log(1+2*u)
+	}
+	return -scale * math.Log(1-2*u)
+}
+
+func (n *noise) value(scale, v float64) float64 {
+	return v + n.laplace(scale)
+}
+
+// summary returns the values, count and sum of a summary noised, values
+// is copied rather than modified.
+func (n *noise) summary(scale float64, values []float64, count int64, sum float64) ([]float64, int64, float64) {
+	noised := make([]float64, len(values))
+	for i, v := range values {
+		noised[i] = n.value(scale, v)
+	}
+	return noised, n.count(scale, count), n.value(scale, sum)
+}
+
+func (n *noise) count(scale float64, v int64) int64 {
+	noised := math.Round(float64(v) + n.laplace(scale))
+	if noised < 0 {
+		return 0
+	}
+	return int64(noised)
+}
+
+type noiseReporter struct {
+	StatsReporter
+	noise *noise
+}
+
+func (r noiseReporter) ReportCounter(name string, tags map[string]string, value int64) {
+	if scale := r.noise.scale(name); scale > 0 {
+		value = r.noise.count(scale, value)
+	}
+	r.StatsReporter.ReportCounter(name, tags, value)
+}
+
+func (r noiseReporter) ReportCounterTotal(name string, tags map[string]string, total int64) {
+	if r.noise.scale(name) > 0 {
+		return
+	}
+	if cr, ok := r.StatsReporter.(CumulativeCounterReporter); ok {
+		cr.ReportCounterTotal(name, tags, total)
+	}
+}
+
+func (r noiseReporter) ReportGauge(name string, tags map[string]string, value float64) {
+	if scale := r.noise.scale(name); scale > 0 {
+		value = r.noise.value(scale, value)
+	}
+	r.StatsReporter.ReportGauge(name, tags, value)
+}
+
+func (r noiseReporter) ReportHistogramValueSamples(
+	name string,
+	tags map[string]string,
+	buckets Buckets,
+	bucketLowerBound,
+	bucketUpperBound float64,
+	samples int64,
+) {
+	if scale := r.noise.scale(name); scale > 0 {
+		samples = r.noise.count(scale, samples)
+	}
+	r.StatsReporter.ReportHistogramValueSamples(
+		name, tags, buckets, bucketLowerBound, bucketUpperBound, samples,
+	)
+}
+
+func (r noiseReporter) ReportHistogramDurationSamples(
+	name string,
+	tags map[string]string,
+	buckets Buckets,
+	bucketLowerBound,
+	bucketUpperBound time.Duration,
+	samples int64,
+) {
+	if scale := r.noise.scale(name); scale > 0 {
+		samples = r.noise.count(scale, samples)
+	}
+	r.StatsReporter.ReportHistogramDurationSamples(
+		name, tags, buckets, bucketLowerBound, bucketUpperBound, samples,
+	)
+}
+
+func (r noiseReporter) ReportSummary(
+	name string,
+	tags map[string]string,
+	quantiles []float64,
+	values []float64,
+	count int64,
+	sum float64,
+) {
+	sr, ok := r.StatsReporter.(SummaryReporter)
+	if !ok {
+		return
+	}
+	if scale := r.noise.scale(name); scale > 0 {
+		values, count, sum = r.noise.summary(scale, values, count, sum)
+	}
+	sr.ReportSummary(name, tags, quantiles, values, count, sum)
+}
+
+type noiseCachedReporter struct {
+	CachedStatsReporter
+	noise *noise
+}
+
+func (r noiseCachedReporter) AllocateCounter(name string, tags map[string]string) CachedCount {
+	c := r.CachedStatsReporter.AllocateCounter(name, tags)
+	if scale := r.noise.scale(name); scale > 0 {
+		return noiseCachedMetric{count: c, noise: r.noise, scale: scale}
+	}
+	return c
+}
+
+func (r noiseCachedReporter) AllocateGauge(name string, tags map[string]string) CachedGauge {
+	g := r.CachedStatsReporter.AllocateGauge(name, tags)
+	if scale := r.noise.scale(name); scale > 0 {
+		return noiseCachedMetric{gauge: g, noise: r.noise, scale: scale}
+	}
+	return g
+}
+
+func (r noiseCachedReporter) AllocateHistogram(
+	name string,
+	tags map[string]string,
+	buckets Buckets,
+) CachedHistogram {
+	h := r.CachedStatsReporter.AllocateHistogram(name, tags, buckets)
+	if scale := r.noise.scale(name); scale > 0 {
+		return noiseCachedHistogram{CachedHistogram: h, noise: r.noise, scale: scale}
+	}
+	return h
+}
+
+func (r noiseCachedReporter) AllocateSummary(
+	name string,
+	tags map[string]string,
+	quantiles []float64,
+) CachedSummary {
+	s := allocateSummary(r.CachedStatsReporter, name, tags, quantiles)
+	if scale := r.noise.scale(name); scale > 0 {
+		return noiseCachedSummary{CachedSummary: s, noise: r.noise, scale: scale}
+	}
+	return s
+}
+
+// noiseCachedMetric is a noised counter or gauge, noised counters don't
+// implement CachedCumulativeCount so that their totals are not reported.
+type noiseCachedMetric struct {
+	count CachedCount
+	gauge CachedGauge
+	noise *noise
+	scale float64
+}
+
+func (m noiseCachedMetric) ReportCount(value int64) {
+	m.count.ReportCount(m.noise.count(m.scale, value))
+}
+
+func (m noiseCachedMetric) ReportGauge(value float64) {
+	m.gauge.ReportGauge(m.noise.value(m.scale, value))
+}
+
+type noiseCachedHistogram struct {
+	CachedHistogram
+	noise *noise
+	scale float64
+}
+
+func (h noiseCachedHistogram) ValueBucket(
+	bucketLowerBound, bucketUpperBound float64,
+) CachedHistogramBucket {
+	return noiseCachedHistogramBucket{
+		CachedHistogramBucket: h.CachedHistogram.ValueBucket(bucketLowerBound, bucketUpperBound),
+		noise:                 h.noise,
+		scale:                 h.scale,
+	}
+}
+
+func (h noiseCachedHistogram) DurationBucket(
+	bucketLowerBound, bucketUpperBound time.Duration,
+) CachedHistogramBucket {
+	return noiseCachedHistogramBucket{
+		CachedHistogramBucket: h.CachedHistogram.DurationBucket(bucketLowerBound, bucketUpperBound),
+		noise:                 h.noise,
+		scale:                 h.scale,
+	}
+}
+
+type noiseCachedHistogramBucket struct {
+	CachedHistogramBucket
+	noise *noise
+	scale float64
+}
+
+func (b noiseCachedHistogramBucket) ReportSamples(value int64) {
+	b.CachedHistogramBucket.ReportSamples(b.noise.count(b.scale, value))
+}
+
+type noiseCachedSummary struct {
+	CachedSummary
+	noise *noise
+	scale float64
+}
+
+func (s noiseCachedSummary) ReportSummary(values []float64, count int64, sum float64) {
+	s.CachedSummary.ReportSummary(s.noise.summary(s.scale, values, count, sum))
+}
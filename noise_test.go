@@ -0,0 +1,105 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tally
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNoiseScale(t *testing.T) {
+	n := newNoise([]NoiseRule{
+		{Name: "ignored", Epsilon: 0},
+		{Name: "users.*", Epsilon: 0.5},
+		{Name: "sessions", Epsilon: 2, Sensitivity: 4},
+	}, rand.NewSource(1))
+
+	assert.Equal(t, 2.0, n.scale("users.active"))
+	assert.Equal(t, 2.0, n.scale("sessions"))
+	assert.Equal(t, 0.0, n.scale("ignored"))
+	assert.Equal(t, 0.0, n.scale("requests"))
+	assert.Nil(t, newNoise(nil, rand.NewSource(1)))
+}
+
+func TestNoiseLaplace(t *testing.T) {
+	n := newNoise([]NoiseRule{{Name: "*", Epsilon: 1}}, rand.NewSource(1))
+
+	const samples = 100000
+	var sum, sumAbs float64
+	for i := 0; i < samples; i++ {
+		x := n.laplace(2)
+		sum += x
+		sumAbs += math.Abs(x)
+	}
+	// The Laplace distribution has a mean of zero and a mean absolute
+	// deviation of its scale.
+	assert.InDelta(t, 0, sum/samples, 0.05)
+	assert.InDelta(t, 2, sumAbs/samples, 0.05)
+
+	for i := 0; i < 100; i++ {
+		assert.True(t, n.count(10, 0) >= 0)
+	}
+}
+
+func TestNoiseRules(t *testing.T) {
+	r := &valueRecordingReporter{
+		counters:   make(map[string]int64),
+		histograms: make(map[string]int64),
+	}
+	root, closer := NewRootScope(ScopeOptions{
+		Reporter:      r,
+		MetricsOption: OmitInternalMetrics,
+		NoiseRules:    []NoiseRule{{Name: "users", Epsilon: 1e-5}},
+	}, 0)
+	defer closer.Close()
+
+	// With an epsilon this low, noised values are almost never unchanged.
+	root.Counter("users").Inc(1000)
+	root.Counter("requests").Inc(1000)
+	root.(*scope).reportRegistry()
+
+	require.Contains(t, r.counters, "users")
+	assert.NotEqual(t, int64(1000), r.counters["users"])
+	assert.Equal(t, int64(1000), r.counters["requests"])
+}
+
+func TestNoiseRulesCached(t *testing.T) {
+	r := newTestStatsReporter()
+	root, closer := NewRootScope(ScopeOptions{
+		CachedReporter: r,
+		MetricsOption:  OmitInternalMetrics,
+		NoiseRules:     []NoiseRule{{Name: "users", Epsilon: 1e-5}},
+	}, 0)
+	defer closer.Close()
+
+	root.Counter("users").Inc(1000)
+	root.Counter("requests").Inc(1000)
+	r.cg.Add(2)
+	root.(*scope).reportRegistry()
+	r.WaitAll()
+
+	assert.NotEqual(t, int64(1000), r.getCounters()["users"].val)
+	assert.Equal(t, int64(1000), r.getCounters()["requests"].val)
+}
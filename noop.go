@@ -0,0 +1,122 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tally
+
+import "time"
+
+// noopMetric is a metric which discards all values, it is handed out in
+// place of metrics that are disabled for a scope.
+type noopMetric struct{}
+
+var (
+	_ Counter       = noopMetric{}
+	_ Gauge         = noopMetric{}
+	_ Timer         = noopMetric{}
+	_ Histogram     = noopMetric{}
+	_ AdditiveGauge = noopMetric{}
+)
+
+func (noopMetric) Inc(int64)                    {}
+func (noopMetric) Update(float64)               {}
+func (noopMetric) Add(float64)                  {}
+func (noopMetric) Sub(float64)                  {}
+func (noopMetric) Record(time.Duration)         {}
+func (noopMetric) RecordValue(float64)          {}
+func (noopMetric) RecordDuration(time.Duration) {}
+func (noopMetric) RecordStopwatch(time.Time)    {}
+func (m noopMetric) Start() Stopwatch           { return NewStopwatch(time.Time{}, m) }
+
+// noopScope is the implementation of NoopScope.
+type noopScope struct{}
+
+var (
+	_ TestScope           = noopScope{}
+	_ IterableScope       = noopScope{}
+	_ AnnotatedScope      = noopScope{}
+	_ InspectableScope    = noopScope{}
+	_ DeclaringScope      = noopScope{}
+	_ LeveledScope        = noopScope{}
+	_ PairTaggedScope     = noopScope{}
+	_ ForkableScope       = noopScope{}
+	_ TagSetScope         = noopScope{}
+	_ DescribedScope      = noopScope{}
+	_ RemovableScope      = noopScope{}
+	_ FloatCounterScope   = noopScope{}
+	_ GaugeHistogramScope = noopScope{}
+	_ GaugeFuncScope      = noopScope{}
+	_ SummaryScope        = noopScope{}
+	_ SetScope            = noopScope{}
+	_ RateScope           = noopScope{}
+)
+
+func (noopScope) Counter(string) Counter                        { return noopMetric{} }
+func (noopScope) Gauge(string) Gauge                            { return noopMetric{} }
+func (noopScope) Timer(string) Timer                            { return noopMetric{} }
+func (noopScope) Histogram(string, Buckets) Histogram           { return noopMetric{} }
+func (s noopScope) Tagged(map[string]string) Scope              { return s }
+func (s noopScope) TaggedPairs(...string) Scope                 { return s }
+func (s noopScope) TaggedKV(string, string) Scope               { return s }
+func (s noopScope) WithTagSet(TagSet) Scope                     { return s }
+func (s noopScope) SubScope(string) Scope                       { return s }
+func (s noopScope) Fork(string, map[string]string) Scope        { return s }
+func (noopScope) Capabilities() Capabilities                    { return capabilitiesNone }
+func (s noopScope) AtLevel(Level) Scope                         { return s }
+func (noopScope) SetLevel(Level)                                {}
+func (noopScope) Level() Level                                  { return InfoLevel }
+func (noopScope) Snapshot() Snapshot                            { return newSnapshot() }
+func (noopScope) ForEachCounter(func(CounterSnapshot) bool)     {}
+func (noopScope) ForEachGauge(func(GaugeSnapshot) bool)         {}
+func (noopScope) ForEachTimer(func(TimerSnapshot) bool)         {}
+func (noopScope) ForEachHistogram(func(HistogramSnapshot) bool) {}
+func (noopScope) Annotate(string, map[string]string)            {}
+func (noopScope) Tags() map[string]string                       { return nil }
+func (noopScope) Key() string                                   { return "" }
+func (noopScope) LookupSubScope(string) (Scope, bool)           { return nil, false }
+func (noopScope) LookupTagged(map[string]string) (Scope, bool)  { return nil, false }
+func (noopScope) DeclareCounter(string) Counter                 { return noopMetric{} }
+func (noopScope) DeclareGauge(string) Gauge                     { return noopMetric{} }
+func (noopScope) DeclareTimer(string) Timer                     { return noopMetric{} }
+func (noopScope) DeclareHistogram(string, Buckets) Histogram    { return noopMetric{} }
+func (noopScope) Declared() []DeclaredMetric                    { return nil }
+func (noopScope) CounterWith(string, ...MetricOption) Counter   { return noopMetric{} }
+func (noopScope) GaugeWith(string, ...MetricOption) Gauge       { return noopMetric{} }
+func (noopScope) TimerWith(string, ...MetricOption) Timer       { return noopMetric{} }
+func (noopScope) RemoveCounter(string) bool                     { return false }
+func (noopScope) RemoveGauge(string) bool                       { return false }
+func (noopScope) RemoveTimer(string) bool                       { return false }
+func (noopScope) RemoveHistogram(string) bool                   { return false }
+func (noopScope) RemoveTagged(map[string]string) bool           { return false }
+func (noopScope) CounterFloat(string) FloatCounter              { return noopFloatCounter{} }
+func (noopScope) GaugeFunc(string, func() float64)              {}
+func (noopScope) Set(string, SetOptions) Set                    { return noopSet{} }
+func (noopScope) Rate(string) Rate                              { return noopRate{} }
+
+func (noopScope) HistogramWith(string, Buckets, ...MetricOption) Histogram {
+	return noopMetric{}
+}
+
+func (noopScope) Summary(string, SummaryOptions) Summary {
+	return noopSummary{}
+}
+
+func (noopScope) GaugeHistogram(string, Buckets) GaugeHistogram {
+	return noopGaugeHistogram{}
+}
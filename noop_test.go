@@ -0,0 +1,52 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tally
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNoopScopeZeroAllocations(t *testing.T) {
+	tags := map[string]string{"foo": "bar"}
+	allocs := testing.AllocsPerRun(1000, func() {
+		s := NoopScope.Tagged(tags).SubScope("sub")
+		s.Counter("counter").Inc(1)
+		s.Gauge("gauge").Update(1)
+		s.Timer("timer").Record(time.Second)
+		s.Timer("timer").Start().Stop()
+		s.Histogram("histogram", DefaultBuckets).RecordValue(1)
+		s.Histogram("histogram", DefaultBuckets).RecordDuration(time.Second)
+		s.Capabilities().Reporting()
+		AtLevel(s, DebugLevel).Counter("debug").Inc(1)
+	})
+	assert.Zero(t, allocs)
+}
+
+func TestNoopScope(t *testing.T) {
+	assert.Equal(t, NoopScope, NoopScope.Tagged(map[string]string{"a": "b"}))
+	assert.Equal(t, NoopScope, NoopScope.SubScope("foo"))
+	assert.False(t, NoopScope.Capabilities().Reporting())
+	assert.False(t, NoopScope.Capabilities().Tagging())
+	assert.Empty(t, NoopScope.(TestScope).Snapshot().Counters())
+}
@@ -0,0 +1,157 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package otlp
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+const (
+	grpcExportPath   = "/opentelemetry.proto.collector.metrics.v1.MetricsService/Export"
+	httpExportPath   = "/v1/metrics"
+	protoContentType = "application/x-protobuf"
+	grpcContentType  = "application/grpc"
+)
+
+// Exporter sends serialized OTLP ExportMetricsServiceRequest messages to
+// a collector.
+type Exporter interface {
+	Export(ctx context.Context, request []byte) error
+}
+
+// ExporterFunc is an Exporter function, e.g. to export requests with an
+// existing gRPC client connection.
+type ExporterFunc func(ctx context.Context, request []byte) error
+
+// Export calls f.
+func (f ExporterFunc) Export(ctx context.Context, request []byte) error {
+	return f(ctx, request)
+}
+
+type grpcExporter struct {
+	url    string
+	client *http.Client
+}
+
+// NewGRPCExporter returns an exporter calling the OTLP gRPC metrics
+// service at endpoint, e.g. "https://collector:4317", with client or
+// http.DefaultClient if nil. gRPC requires HTTP/2, which net/http only
+// negotiates over TLS, so endpoint must be an https URL; use
+// NewHTTPExporter for collectors listening without TLS.
+func NewGRPCExporter(endpoint string, client *http.Client) Exporter {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &grpcExporter{
+		url:    strings.TrimSuffix(endpoint, "/") + grpcExportPath,
+		client: client,
+	}
+}
+
+func (e *grpcExporter) Export(ctx context.Context, request []byte) error {
+	// gRPC messages are prefixed with a compression flag and their length.
+	body := make([]byte, 5, 5+len(request))
+	binary.BigEndian.PutUint32(body[1:], uint32(len(request)))
+	body = append(body, request...)
+
+	req, err := http.NewRequest(http.MethodPost, e.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", grpcContentType)
+	req.Header.Set("TE", "trailers")
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// The status is in the trailers, which are read with the body.
+	if _, err := io.Copy(ioutil.Discard, resp.Body); err != nil {
+		return err
+	}
+	if resp.ProtoMajor != 2 {
+		return fmt.Errorf("otlp: gRPC export requires HTTP/2, got %s", resp.Proto)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("otlp: gRPC export failed: %s", resp.Status)
+	}
+
+	status := resp.Trailer.Get("Grpc-Status")
+	message := resp.Trailer.Get("Grpc-Message")
+	if status == "" {
+		// Responses without a message carry the status in their headers.
+		status = resp.Header.Get("Grpc-Status")
+		message = resp.Header.Get("Grpc-Message")
+	}
+	if status != "0" {
+		return fmt.Errorf("otlp: gRPC export failed with status %s: %s", status, message)
+	}
+	return nil
+}
+
+type httpExporter struct {
+	url    string
+	client *http.Client
+}
+
+// NewHTTPExporter returns an exporter posting requests to the OTLP/HTTP
+// metrics endpoint of the collector at endpoint, e.g.
+// "http://collector:4318", with client or http.DefaultClient if nil.
+func NewHTTPExporter(endpoint string, client *http.Client) Exporter {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &httpExporter{
+		url:    strings.TrimSuffix(endpoint, "/") + httpExportPath,
+		client: client,
+	}
+}
+
+func (e *httpExporter) Export(ctx context.Context, request []byte) error {
+	req, err := http.NewRequest(http.MethodPost, e.url, bytes.NewReader(request))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", protoContentType)
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(ioutil.Discard, resp.Body)
+
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("otlp: HTTP export failed: %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,330 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package otlp
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	tally "github.com/extrasalt/tally/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fields are the decoded fields of a protobuf message by number, values
+// are uint64 for varint and fixed64 fields and []byte otherwise.
+type fields map[int][]interface{}
+
+func decode(t *testing.T, b []byte) fields {
+	f := make(fields)
+	for len(b) > 0 {
+		key, n := binary.Uvarint(b)
+		require.True(t, n > 0)
+		b = b[n:]
+
+		field, wireType := int(key>>3), int(key&7)
+		switch wireType {
+		case wireVarint:
+			v, n := binary.Uvarint(b)
+			require.True(t, n > 0)
+			f[field] = append(f[field], v)
+			b = b[n:]
+		case wireFixed64:
+			f[field] = append(f[field], binary.LittleEndian.Uint64(b))
+			b = b[8:]
+		case wireBytes:
+			l, n := binary.Uvarint(b)
+			require.True(t, n > 0)
+			f[field] = append(f[field], b[n:n+int(l)])
+			b = b[n+int(l):]
+		default:
+			t.Fatalf("unexpected wire type %d", wireType)
+		}
+	}
+	return f
+}
+
+func (f fields) message(t *testing.T, field int) fields {
+	return decode(t, f[field][0].([]byte))
+}
+
+func (f fields) messages(t *testing.T, field int) []fields {
+	var ms []fields
+	for _, v := range f[field] {
+		ms = append(ms, decode(t, v.([]byte)))
+	}
+	return ms
+}
+
+func (f fields) string(field int) string {
+	return string(f[field][0].([]byte))
+}
+
+func (f fields) attributes(t *testing.T, field int) map[string]string {
+	attrs := make(map[string]string)
+	for _, kv := range f.messages(t, field) {
+		attrs[kv.string(keyValueKey)] = kv.message(t, keyValueValue).string(anyValueString)
+	}
+	return attrs
+}
+
+func packedFixed64(t *testing.T, b []byte) []uint64 {
+	require.Equal(t, 0, len(b)%8)
+	var vs []uint64
+	for ; len(b) > 0; b = b[8:] {
+		vs = append(vs, binary.LittleEndian.Uint64(b))
+	}
+	return vs
+}
+
+// decodeMetrics returns the metrics of an export request by name.
+func decodeMetrics(t *testing.T, request []byte) map[string]fields {
+	rm := decode(t, request).message(t, requestResourceMetrics)
+	sm := rm.message(t, resourceMetricsScopeMetrics)
+	metrics := make(map[string]fields)
+	for _, m := range sm.messages(t, scopeMetricsMetrics) {
+		metrics[m.string(metricName)] = m
+	}
+	return metrics
+}
+
+func TestReporter(t *testing.T) {
+	var requests [][]byte
+	r, err := NewReporter(Options{
+		Exporter: ExporterFunc(func(_ context.Context, request []byte) error {
+			requests = append(requests, request)
+			return nil
+		}),
+		Resource:     map[string]string{"service.name": "svc"},
+		TimerBuckets: []float64{0.1, 1},
+	})
+	require.NoError(t, err)
+
+	scope, closer := tally.NewRootScope(tally.ScopeOptions{
+		CachedReporter: r,
+		MetricsOption:  tally.OmitInternalMetrics,
+	}, 0)
+	tagged := scope.Tagged(map[string]string{"region": "us"})
+	tagged.Counter("requests").Inc(2)
+	tagged.Gauge("workers").Update(3)
+	tagged.Timer("latency").Record(500 * time.Millisecond)
+	h := tagged.Histogram("size", tally.ValueBuckets{10, 100})
+	h.RecordValue(5)
+	h.RecordValue(50)
+	h.RecordValue(500)
+	require.NoError(t, closer.Close())
+
+	require.Len(t, requests, 1)
+	rm := decode(t, requests[0]).message(t, requestResourceMetrics)
+	assert.Equal(t, map[string]string{"service.name": "svc"},
+		rm.message(t, resourceMetricsResource).attributes(t, resourceAttributes))
+	assert.Equal(t, DefaultScopeName, rm.message(t, resourceMetricsScopeMetrics).
+		message(t, scopeMetricsScope).string(instrumentationScopeName))
+
+	metrics := decodeMetrics(t, requests[0])
+	require.Len(t, metrics, 4)
+
+	sum := metrics["requests"].message(t, metricSum)
+	assert.Equal(t, uint64(temporalityCumulative), sum[aggregationTemporality][0])
+	assert.Equal(t, uint64(1), sum[sumIsMonotonic][0])
+	dp := sum.message(t, dataPoints)
+	assert.Equal(t, uint64(2), dp[numberDataPointInt][0])
+	assert.Equal(t, map[string]string{"region": "us"}, dp.attributes(t, numberDataPointAttributes))
+
+	dp = metrics["workers"].message(t, metricGauge).message(t, dataPoints)
+	assert.Equal(t, 3.0, math.Float64frombits(dp[numberDataPointDouble][0].(uint64)))
+
+	assert.Equal(t, secondsUnit, metrics["latency"].string(metricUnit))
+	dp = metrics["latency"].message(t, metricHistogram).message(t, dataPoints)
+	assert.Equal(t, []uint64{0, 1, 0}, packedFixed64(t, dp[histogramDataPointBucketCounts][0].([]byte)))
+	assert.Equal(t, 0.5, math.Float64frombits(dp[histogramDataPointSum][0].(uint64)))
+
+	dp = metrics["size"].message(t, metricHistogram).message(t, dataPoints)
+	assert.Equal(t, uint64(3), dp[histogramDataPointCount][0])
+	assert.Equal(t, []uint64{1, 1, 1}, packedFixed64(t, dp[histogramDataPointBucketCounts][0].([]byte)))
+	bounds := packedFixed64(t, dp[histogramDataPointExplicitBounds][0].([]byte))
+	require.Len(t, bounds, 2)
+	assert.Equal(t, 100.0, math.Float64frombits(bounds[1]))
+}
+
+func TestReporterCumulative(t *testing.T) {
+	var last []byte
+	r, err := NewReporter(Options{
+		Exporter: ExporterFunc(func(_ context.Context, request []byte) error {
+			last = request
+			return nil
+		}),
+	})
+	require.NoError(t, err)
+
+	c := r.AllocateCounter("c", nil)
+	c.ReportCount(2)
+	r.Flush()
+	c.ReportCount(3)
+	r.Flush()
+
+	dp := decodeMetrics(t, last)["c"].message(t, metricSum).message(t, dataPoints)
+	assert.Equal(t, uint64(5), dp[numberDataPointInt][0])
+}
+
+func TestReporterFloatCounter(t *testing.T) {
+	var last []byte
+	r, err := NewReporter(Options{
+		Exporter: ExporterFunc(func(_ context.Context, request []byte) error {
+			last = request
+			return nil
+		}),
+	})
+	require.NoError(t, err)
+
+	scope, closer := tally.NewRootScope(tally.ScopeOptions{
+		CachedReporter: r,
+		MetricsOption:  tally.OmitInternalMetrics,
+	}, 0)
+	cost := tally.CounterFloat(scope, "cost")
+	cost.Inc(0.25)
+	require.NoError(t, tally.Flush(scope))
+	cost.Inc(0.5)
+	require.NoError(t, closer.Close())
+
+	sum := decodeMetrics(t, last)["cost"].message(t, metricSum)
+	assert.Equal(t, uint64(1), sum[sumIsMonotonic][0])
+	dp := sum.message(t, dataPoints)
+	assert.Equal(t, 0.75, math.Float64frombits(dp[numberDataPointDouble][0].(uint64)))
+}
+
+func TestReporterMetadata(t *testing.T) {
+	var last []byte
+	r, err := NewReporter(Options{
+		Exporter: ExporterFunc(func(_ context.Context, request []byte) error {
+			last = request
+			return nil
+		}),
+	})
+	require.NoError(t, err)
+
+	scope, closer := tally.NewRootScope(tally.ScopeOptions{
+		CachedReporter: r,
+		MetricsOption:  tally.OmitInternalMetrics,
+	}, 0)
+	tally.CounterWith(scope, "bytes", tally.WithHelp("Bytes sent."), tally.WithUnit("By")).Inc(1)
+	tally.TimerWith(scope, "latency", tally.WithHelp("Latency."), tally.WithUnit("ms")).Record(time.Millisecond)
+	scope.Gauge("workers").Update(1)
+	require.NoError(t, closer.Close())
+
+	metrics := decodeMetrics(t, last)
+	assert.Equal(t, "Bytes sent.", metrics["bytes"].string(metricDescription))
+	assert.Equal(t, "By", metrics["bytes"].string(metricUnit))
+	assert.Equal(t, "Latency.", metrics["latency"].string(metricDescription))
+	assert.Equal(t, secondsUnit, metrics["latency"].string(metricUnit))
+	assert.NotContains(t, metrics["workers"], metricDescription)
+	assert.NotContains(t, metrics["workers"], metricUnit)
+}
+
+func TestReporterTracer(t *testing.T) {
+	var phases []tally.ReportPhase
+	r, err := NewReporter(Options{
+		Exporter: ExporterFunc(func(context.Context, []byte) error { return nil }),
+		Tracer: tally.ReportTimingFunc(func(phase tally.ReportPhase, _ time.Duration) {
+			phases = append(phases, phase)
+		}),
+	})
+	require.NoError(t, err)
+
+	r.AllocateCounter("c", nil).ReportCount(1)
+	r.Flush()
+	assert.Equal(t, []tally.ReportPhase{tally.SerializePhase, tally.SendPhase}, phases)
+}
+
+func TestReporterErrors(t *testing.T) {
+	_, err := NewReporter(Options{})
+	assert.Equal(t, errNoExporter, err)
+
+	exportErr := errors.New("unavailable")
+	var got error
+	r, err := NewReporter(Options{
+		Exporter: ExporterFunc(func(context.Context, []byte) error {
+			return exportErr
+		}),
+		OnError: func(err error) { got = err },
+	})
+	require.NoError(t, err)
+	r.Flush()
+	assert.Equal(t, exportErr, got)
+}
+
+func TestHTTPExporter(t *testing.T) {
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, httpExportPath, req.URL.Path)
+		assert.Equal(t, protoContentType, req.Header.Get("Content-Type"))
+		body, _ = ioutil.ReadAll(req.Body)
+	}))
+	defer server.Close()
+
+	e := NewHTTPExporter(server.URL, server.Client())
+	require.NoError(t, e.Export(context.Background(), []byte("request")))
+	assert.Equal(t, "request", string(body))
+
+	missing := httptest.NewServer(http.NotFoundHandler())
+	defer missing.Close()
+	e = NewHTTPExporter(missing.URL, missing.Client())
+	assert.EqualError(t, e.Export(context.Background(), []byte("request")),
+		"otlp: HTTP export failed: 404 Not Found")
+}
+
+func TestGRPCExporter(t *testing.T) {
+	var (
+		body   []byte
+		status = "0"
+	)
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, grpcExportPath, req.URL.Path)
+		assert.Equal(t, grpcContentType, req.Header.Get("Content-Type"))
+		body, _ = ioutil.ReadAll(req.Body)
+
+		w.Header().Set("Trailer", "Grpc-Status, Grpc-Message")
+		w.Header().Set("Content-Type", grpcContentType)
+		w.WriteHeader(http.StatusOK)
+		w.Header().Set("Grpc-Status", status)
+		w.Header().Set("Grpc-Message", "message")
+	}))
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	defer server.Close()
+
+	e := NewGRPCExporter(server.URL, server.Client())
+	require.NoError(t, e.Export(context.Background(), []byte("request")))
+	require.Len(t, body, 5+len("request"))
+	assert.Equal(t, uint32(len("request")), binary.BigEndian.Uint32(body[1:5]))
+	assert.Equal(t, "request", string(body[5:]))
+
+	status = "14"
+	assert.EqualError(t, e.Export(context.Background(), []byte("request")),
+		"otlp: gRPC export failed with status 14: message")
+}
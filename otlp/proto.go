@@ -0,0 +1,179 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package otlp
+
+import (
+	"encoding/binary"
+	"math"
+	"sort"
+)
+
+// Field numbers and enum values of the OTLP metrics protocol, see
+// opentelemetry/proto/metrics/v1/metrics.proto and
+// opentelemetry/proto/collector/metrics/v1/metrics_service.proto.
+const (
+	requestResourceMetrics = 1
+
+	resourceMetricsResource     = 1
+	resourceMetricsScopeMetrics = 2
+
+	resourceAttributes = 1
+
+	scopeMetricsScope   = 1
+	scopeMetricsMetrics = 2
+
+	instrumentationScopeName    = 1
+	instrumentationScopeVersion = 2
+
+	keyValueKey   = 1
+	keyValueValue = 2
+
+	anyValueString = 1
+
+	metricName        = 1
+	metricDescription = 2
+	metricUnit        = 3
+	metricGauge       = 5
+	metricSum         = 7
+	metricHistogram   = 9
+
+	dataPoints             = 1
+	aggregationTemporality = 2
+	sumIsMonotonic         = 3
+
+	numberDataPointStartTime  = 2
+	numberDataPointTime       = 3
+	numberDataPointDouble     = 4
+	numberDataPointInt        = 6
+	numberDataPointAttributes = 7
+
+	histogramDataPointStartTime      = 2
+	histogramDataPointTime           = 3
+	histogramDataPointCount          = 4
+	histogramDataPointSum            = 5
+	histogramDataPointBucketCounts   = 6
+	histogramDataPointExplicitBounds = 7
+	histogramDataPointAttributes     = 9
+
+	temporalityCumulative = 2
+
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+)
+
+// encoder appends the protobuf wire format of messages to a buffer, which
+// avoids depending on generated code for the few messages of OTLP.
+type encoder struct {
+	b []byte
+}
+
+func (e *encoder) tag(field, wireType int) {
+	e.varint(uint64(field<<3 | wireType))
+}
+
+func (e *encoder) varint(v uint64) {
+	var buf [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(buf[:], v)
+	e.b = append(e.b, buf[:n]...)
+}
+
+func (e *encoder) uint64(field int, v uint64) {
+	e.tag(field, wireVarint)
+	e.varint(v)
+}
+
+func (e *encoder) bool(field int, v bool) {
+	if v {
+		e.uint64(field, 1)
+	}
+}
+
+func (e *encoder) fixed64(field int, v uint64) {
+	e.tag(field, wireFixed64)
+	e.appendFixed64(v)
+}
+
+func (e *encoder) appendFixed64(v uint64) {
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], v)
+	e.b = append(e.b, buf[:]...)
+}
+
+func (e *encoder) double(field int, v float64) {
+	e.fixed64(field, math.Float64bits(v))
+}
+
+func (e *encoder) string(field int, s string) {
+	e.tag(field, wireBytes)
+	e.varint(uint64(len(s)))
+	e.b = append(e.b, s...)
+}
+
+// message encodes the fields written by fn as an embedded message.
+func (e *encoder) message(field int, fn func(*encoder)) {
+	var m encoder
+	fn(&m)
+	e.tag(field, wireBytes)
+	e.varint(uint64(len(m.b)))
+	e.b = append(e.b, m.b...)
+}
+
+func (e *encoder) packedFixed64(field int, vs []uint64) {
+	if len(vs) == 0 {
+		return
+	}
+	e.tag(field, wireBytes)
+	e.varint(uint64(8 * len(vs)))
+	for _, v := range vs {
+		e.appendFixed64(v)
+	}
+}
+
+func (e *encoder) packedDoubles(field int, vs []float64) {
+	if len(vs) == 0 {
+		return
+	}
+	e.tag(field, wireBytes)
+	e.varint(uint64(8 * len(vs)))
+	for _, v := range vs {
+		e.appendFixed64(math.Float64bits(v))
+	}
+}
+
+// attributes encodes tags as string attributes sorted by key.
+func (e *encoder) attributes(field int, tags map[string]string) {
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		v := tags[k]
+		e.message(field, func(kv *encoder) {
+			kv.string(keyValueKey, k)
+			kv.message(keyValueValue, func(av *encoder) {
+				av.string(anyValueString, v)
+			})
+		})
+	}
+}
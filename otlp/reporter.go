@@ -0,0 +1,521 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package otlp
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	tally "github.com/extrasalt/tally/v4"
+)
+
+const (
+	// DefaultScopeName is the default name of the instrumentation scope
+	// metrics are exported with.
+	DefaultScopeName = "github.com/extrasalt/tally"
+
+	// DefaultTimeout is the default timeout of exports.
+	DefaultTimeout = 10 * time.Second
+
+	secondsUnit = "s"
+)
+
+var errNoExporter = errors.New("otlp: no exporter")
+
+// DefaultTimerBuckets returns the default upper bounds, in seconds, of the
+// histograms timers are exported as.
+func DefaultTimerBuckets() []float64 {
+	return []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+}
+
+// Options is a set of options for an OTLP reporter.
+type Options struct {
+	// Exporter sends the metrics to a collector, e.g. NewGRPCExporter.
+	Exporter Exporter
+
+	// Resource are the attributes of the resource the metrics are
+	// exported for, e.g. "service.name".
+	Resource map[string]string
+
+	// ScopeName is the name of the instrumentation scope metrics are
+	// exported with. Use the empty string to specify DefaultScopeName.
+	ScopeName string
+
+	// TimerBuckets are the upper bounds, in seconds, of the histograms
+	// timers are exported as. Use nil to specify DefaultTimerBuckets.
+	TimerBuckets []float64
+
+	// Timeout is the timeout of exports. Use zero to specify
+	// DefaultTimeout.
+	Timeout time.Duration
+
+	// OnError if set is called with the errors of exports, which are
+	// dropped otherwise as reporters can't return errors.
+	OnError func(error)
+
+	// Tracer if set traces the serialization of the metrics and their
+	// export to the collector.
+	Tracer tally.ReportTracer
+}
+
+type reporter struct {
+	exporter  Exporter
+	resource  map[string]string
+	scopeName string
+	timers    []float64
+	timeout   time.Duration
+	onError   func(error)
+	tracer    tally.ReportTracer
+	now       func() time.Time
+
+	mu        sync.Mutex
+	series    []series
+	described map[string]tally.MetricMetadata
+	// exportMu serializes exports so that cumulative values are received
+	// in order.
+	exportMu sync.Mutex
+}
+
+// series is a metric allocated by the reporter.
+type series interface {
+	encode(e *encoder, now uint64)
+}
+
+// NewReporter returns a cached reporter exporting the metrics allocated
+// from it to an OpenTelemetry collector every time it is flushed, mapping
+// tags to attributes.
+//
+// Counters are exported as monotonic sums, gauges as gauges, and timers
+// and histograms as explicit bucket histograms with durations in seconds,
+// all with cumulative temporality starting when they were allocated. The
+// sum of a histogram is approximated by counting its samples at the upper
+// bound of their bucket, or the lower bound for the overflow bucket, as
+// scopes don't report the values recorded to histograms.
+func NewReporter(opts Options) (tally.CachedStatsReporter, error) {
+	if opts.Exporter == nil {
+		return nil, errNoExporter
+	}
+	if opts.ScopeName == "" {
+		opts.ScopeName = DefaultScopeName
+	}
+	if opts.TimerBuckets == nil {
+		opts.TimerBuckets = DefaultTimerBuckets()
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultTimeout
+	}
+	timers := append([]float64(nil), opts.TimerBuckets...)
+	sort.Float64s(timers)
+
+	return &reporter{
+		exporter:  opts.Exporter,
+		resource:  opts.Resource,
+		scopeName: opts.ScopeName,
+		timers:    timers,
+		timeout:   opts.Timeout,
+		onError:   opts.OnError,
+		tracer:    opts.Tracer,
+		now:       time.Now,
+		described: make(map[string]tally.MetricMetadata),
+	}, nil
+}
+
+func (r *reporter) add(s series) {
+	r.mu.Lock()
+	r.series = append(r.series, s)
+	r.mu.Unlock()
+}
+
+// DescribeMetric implements tally.MetricDescriber, the help text and unit
+// of a metric are exported as its description and unit.
+func (r *reporter) DescribeMetric(_ tally.MetricKind, name string, metadata tally.MetricMetadata) {
+	r.mu.Lock()
+	r.described[name] = metadata
+	r.mu.Unlock()
+}
+
+// metadata returns the metadata of the metric with the given name. The
+// unit of metrics exported in seconds is always unit, whatever the unit
+// they were described with.
+func (r *reporter) metadata(name, unit string) tally.MetricMetadata {
+	r.mu.Lock()
+	metadata := r.described[name]
+	r.mu.Unlock()
+
+	if unit != "" {
+		metadata.Unit = unit
+	}
+	return metadata
+}
+
+func (r *reporter) start() uint64 {
+	return uint64(r.now().UnixNano())
+}
+
+func (r *reporter) AllocateCounter(name string, tags map[string]string) tally.CachedCount {
+	c := &counter{name: name, tags: tags, metadata: r.metadata(name, ""), start: r.start()}
+	r.add(c)
+	return c
+}
+
+// AllocateFloatCounter implements tally.CachedFloatCounterReporter, float
+// counters are exported as monotonic sums of doubles.
+func (r *reporter) AllocateFloatCounter(name string, tags map[string]string) tally.CachedFloatCount {
+	c := &floatCounter{name: name, tags: tags, metadata: r.metadata(name, ""), start: r.start()}
+	r.add(c)
+	return c
+}
+
+func (r *reporter) AllocateGauge(name string, tags map[string]string) tally.CachedGauge {
+	g := &gauge{name: name, tags: tags, metadata: r.metadata(name, "")}
+	r.add(g)
+	return g
+}
+
+func (r *reporter) AllocateTimer(name string, tags map[string]string) tally.CachedTimer {
+	h := newHistogram(name, tags, r.metadata(name, secondsUnit), r.timers, r.start())
+	r.add(h)
+	return timer{h}
+}
+
+func (r *reporter) AllocateHistogram(
+	name string,
+	tags map[string]string,
+	buckets tally.Buckets,
+) tally.CachedHistogram {
+	var (
+		bounds []float64
+		unit   string
+	)
+	if _, ok := buckets.(tally.DurationBuckets); ok {
+		for _, d := range buckets.AsDurations() {
+			bounds = append(bounds, d.Seconds())
+		}
+		unit = secondsUnit
+	} else {
+		bounds = buckets.AsValues()
+	}
+	bounds = withoutOverflow(bounds)
+
+	h := newHistogram(name, tags, r.metadata(name, unit), bounds, r.start())
+	r.add(h)
+	return h
+}
+
+func (r *reporter) Capabilities() tally.Capabilities {
+	return r
+}
+
+func (r *reporter) Reporting() bool {
+	return true
+}
+
+func (r *reporter) Tagging() bool {
+	return true
+}
+
+// Flush exports the metrics allocated so far.
+func (r *reporter) Flush() {
+	r.exportMu.Lock()
+	defer r.exportMu.Unlock()
+
+	endSerialize := tally.StartReportPhase(r.tracer, tally.SerializePhase)
+	request := r.encode()
+	endSerialize()
+
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	endSend := tally.StartReportPhase(r.tracer, tally.SendPhase)
+	err := r.exporter.Export(ctx, request)
+	endSend()
+	if err != nil && r.onError != nil {
+		r.onError(err)
+	}
+}
+
+// NotifyReportErrors implements tally.ReportErrorNotifier.
+func (r *reporter) NotifyReportErrors(onError func(error)) {
+	if prev := r.onError; prev != nil {
+		r.onError = func(err error) {
+			prev(err)
+			onError(err)
+		}
+		return
+	}
+	r.onError = onError
+}
+
+// encode returns the ExportMetricsServiceRequest of the metrics.
+func (r *reporter) encode() []byte {
+	r.mu.Lock()
+	all := append([]series(nil), r.series...)
+	r.mu.Unlock()
+
+	now := uint64(r.now().UnixNano())
+	var e encoder
+	e.message(requestResourceMetrics, func(rm *encoder) {
+		rm.message(resourceMetricsResource, func(res *encoder) {
+			res.attributes(resourceAttributes, r.resource)
+		})
+		rm.message(resourceMetricsScopeMetrics, func(sm *encoder) {
+			sm.message(scopeMetricsScope, func(is *encoder) {
+				is.string(instrumentationScopeName, r.scopeName)
+				is.string(instrumentationScopeVersion, tally.Version)
+			})
+			for _, s := range all {
+				s.encode(sm, now)
+			}
+		})
+	})
+	return e.b
+}
+
+type counter struct {
+	name     string
+	tags     map[string]string
+	metadata tally.MetricMetadata
+	start    uint64
+	total    int64
+}
+
+func (c *counter) ReportCount(value int64) {
+	atomic.AddInt64(&c.total, value)
+}
+
+func (c *counter) encode(e *encoder, now uint64) {
+	total := atomic.LoadInt64(&c.total)
+	e.message(scopeMetricsMetrics, func(m *encoder) {
+		m.string(metricName, c.name)
+		encodeMetadata(m, c.metadata)
+		m.message(metricSum, func(sum *encoder) {
+			sum.message(dataPoints, func(dp *encoder) {
+				dp.fixed64(numberDataPointStartTime, c.start)
+				dp.fixed64(numberDataPointTime, now)
+				dp.fixed64(numberDataPointInt, uint64(total))
+				dp.attributes(numberDataPointAttributes, c.tags)
+			})
+			sum.uint64(aggregationTemporality, temporalityCumulative)
+			sum.bool(sumIsMonotonic, true)
+		})
+	})
+}
+
+type floatCounter struct {
+	name     string
+	tags     map[string]string
+	metadata tally.MetricMetadata
+	start    uint64
+
+	mu    sync.Mutex
+	total float64
+}
+
+func (c *floatCounter) ReportFloatCount(value float64) {
+	c.mu.Lock()
+	c.total += value
+	c.mu.Unlock()
+}
+
+func (c *floatCounter) encode(e *encoder, now uint64) {
+	c.mu.Lock()
+	total := c.total
+	c.mu.Unlock()
+
+	e.message(scopeMetricsMetrics, func(m *encoder) {
+		m.string(metricName, c.name)
+		encodeMetadata(m, c.metadata)
+		m.message(metricSum, func(sum *encoder) {
+			sum.message(dataPoints, func(dp *encoder) {
+				dp.fixed64(numberDataPointStartTime, c.start)
+				dp.fixed64(numberDataPointTime, now)
+				dp.double(numberDataPointDouble, total)
+				dp.attributes(numberDataPointAttributes, c.tags)
+			})
+			sum.uint64(aggregationTemporality, temporalityCumulative)
+			sum.bool(sumIsMonotonic, true)
+		})
+	})
+}
+
+type gauge struct {
+	name     string
+	tags     map[string]string
+	metadata tally.MetricMetadata
+	updated  uint32
+	value    uint64
+}
+
+func (g *gauge) ReportGauge(value float64) {
+	atomic.StoreUint64(&g.value, math.Float64bits(value))
+	atomic.StoreUint32(&g.updated, 1)
+}
+
+func (g *gauge) encode(e *encoder, now uint64) {
+	// Gauges are only exported once they have a value.
+	if atomic.LoadUint32(&g.updated) == 0 {
+		return
+	}
+	value := math.Float64frombits(atomic.LoadUint64(&g.value))
+	e.message(scopeMetricsMetrics, func(m *encoder) {
+		m.string(metricName, g.name)
+		encodeMetadata(m, g.metadata)
+		m.message(metricGauge, func(gauge *encoder) {
+			gauge.message(dataPoints, func(dp *encoder) {
+				dp.fixed64(numberDataPointTime, now)
+				dp.double(numberDataPointDouble, value)
+				dp.attributes(numberDataPointAttributes, g.tags)
+			})
+		})
+	})
+}
+
+type histogram struct {
+	name     string
+	tags     map[string]string
+	metadata tally.MetricMetadata
+	start    uint64
+	bounds   []float64
+
+	mu     sync.Mutex
+	counts []uint64
+	sum    float64
+}
+
+func newHistogram(
+	name string,
+	tags map[string]string,
+	metadata tally.MetricMetadata,
+	bounds []float64,
+	start uint64,
+) *histogram {
+	return &histogram{
+		name:     name,
+		tags:     tags,
+		metadata: metadata,
+		start:    start,
+		bounds:   bounds,
+		counts:   make([]uint64, len(bounds)+1),
+	}
+}
+
+// record records samples in the bucket of upper bound, or the overflow
+// bucket if upper is past the last bound, with approximation as their
+// value.
+func (h *histogram) record(upper, approximation float64, samples int64) {
+	i := sort.SearchFloat64s(h.bounds, upper)
+	h.mu.Lock()
+	h.counts[i] += uint64(samples)
+	h.sum += approximation * float64(samples)
+	h.mu.Unlock()
+}
+
+func (h *histogram) ValueBucket(lower, upper float64) tally.CachedHistogramBucket {
+	if upper == math.MaxFloat64 {
+		return histogramBucket{h: h, upper: math.Inf(1), approximation: lower}
+	}
+	return histogramBucket{h: h, upper: upper, approximation: upper}
+}
+
+func (h *histogram) DurationBucket(lower, upper time.Duration) tally.CachedHistogramBucket {
+	if upper == time.Duration(math.MaxInt64) {
+		return histogramBucket{h: h, upper: math.Inf(1), approximation: lower.Seconds()}
+	}
+	return histogramBucket{h: h, upper: upper.Seconds(), approximation: upper.Seconds()}
+}
+
+func (h *histogram) encode(e *encoder, now uint64) {
+	h.mu.Lock()
+	counts := append([]uint64(nil), h.counts...)
+	sum := h.sum
+	h.mu.Unlock()
+
+	var count uint64
+	for _, c := range counts {
+		count += c
+	}
+
+	e.message(scopeMetricsMetrics, func(m *encoder) {
+		m.string(metricName, h.name)
+		encodeMetadata(m, h.metadata)
+		m.message(metricHistogram, func(hist *encoder) {
+			hist.message(dataPoints, func(dp *encoder) {
+				dp.fixed64(histogramDataPointStartTime, h.start)
+				dp.fixed64(histogramDataPointTime, now)
+				dp.fixed64(histogramDataPointCount, count)
+				dp.double(histogramDataPointSum, sum)
+				dp.packedFixed64(histogramDataPointBucketCounts, counts)
+				dp.packedDoubles(histogramDataPointExplicitBounds, h.bounds)
+				dp.attributes(histogramDataPointAttributes, h.tags)
+			})
+			hist.uint64(aggregationTemporality, temporalityCumulative)
+		})
+	})
+}
+
+type histogramBucket struct {
+	h             *histogram
+	upper         float64
+	approximation float64
+}
+
+func (b histogramBucket) ReportSamples(value int64) {
+	b.h.record(b.upper, b.approximation, value)
+}
+
+// timer records the durations reported to it in a histogram.
+type timer struct {
+	h *histogram
+}
+
+func (t timer) ReportTimer(interval time.Duration) {
+	seconds := interval.Seconds()
+	t.h.record(seconds, seconds, 1)
+}
+
+// encodeMetadata encodes the description and unit of a metric.
+func encodeMetadata(m *encoder, metadata tally.MetricMetadata) {
+	if metadata.Help != "" {
+		m.string(metricDescription, metadata.Help)
+	}
+	if metadata.Unit != "" {
+		m.string(metricUnit, metadata.Unit)
+	}
+}
+
+// withoutOverflow returns bounds without the trailing bounds of overflow
+// buckets.
+func withoutOverflow(bounds []float64) []float64 {
+	for len(bounds) > 0 {
+		last := bounds[len(bounds)-1]
+		if last != math.MaxFloat64 && last != time.Duration(math.MaxInt64).Seconds() &&
+			!math.IsInf(last, 1) {
+			break
+		}
+		bounds = bounds[:len(bounds)-1]
+	}
+	return bounds
+}
@@ -0,0 +1,59 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tally
+
+import "fmt"
+
+// PanicTypeTag is the tag of the panics counted by RecoverAndCount and
+// CountAndRepanic holding the type of the value the goroutine panicked
+// with, e.g. "runtime.boundsError" or "string".
+const PanicTypeTag = "panic_type"
+
+// RecoverAndCount recovers the panic of the goroutine, if any, and counts
+// it by the counter of s with the given name tagged with its type. It must
+// be deferred directly, e.g.
+//
+//	go func() {
+//		defer tally.RecoverAndCount(scope, "worker_panics")
+//		...
+//	}()
+//
+// as panics can only be recovered by deferred functions.
+func RecoverAndCount(s Scope, name string) {
+	if r := recover(); r != nil {
+		countPanic(s, name, r)
+	}
+}
+
+// CountAndRepanic is RecoverAndCount for goroutines which must still crash
+// the process on panic: the panic is counted, then the goroutine panics
+// again with the same value. The stack trace of the crash is the one of
+// the repanic, which includes the frames of the original panic.
+func CountAndRepanic(s Scope, name string) {
+	if r := recover(); r != nil {
+		countPanic(s, name, r)
+		panic(r)
+	}
+}
+
+func countPanic(s Scope, name string, r interface{}) {
+	s.Tagged(map[string]string{PanicTypeTag: fmt.Sprintf("%T", r)}).Counter(name).Inc(1)
+}
@@ -0,0 +1,445 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package prometheus
+
+import (
+	"bufio"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	tally "github.com/extrasalt/tally/v4"
+)
+
+const (
+	counterType        = "counter"
+	gaugeType          = "gauge"
+	histogramType      = "histogram"
+	gaugeHistogramType = "gaugehistogram"
+
+	// textContentType is the content type of the text exposition format.
+	textContentType = "text/plain; version=0.0.4; charset=utf-8"
+	// openMetricsContentType is the content type of the OpenMetrics text
+	// format.
+	openMetricsContentType = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+)
+
+// PullReporter is a tally reporter keeping the values reported to it in
+// memory and serving them in the Prometheus text exposition format, which
+// doesn't depend on the Prometheus client library.
+//
+// Counters are exposed as counters of their cumulative value, gauges as
+// gauges, and timers and histograms as histograms with cumulative buckets
+// in seconds for durations. The sum of a histogram reported by a scope is
+// approximated by counting its samples at the upper bound of their bucket,
+// or the lower bound for the overflow bucket, as scopes don't report the
+// values recorded to histograms.
+//
+// Gauge histograms are exposed as OpenMetrics gauge histograms, which the
+// text exposition format doesn't support: they are written there as
+// untyped _bucket, _gcount and _gsum samples.
+//
+// Names and tags must already be valid Prometheus names and labels, e.g.
+// by creating the scope with DefaultSanitizerOpts. A name reported as
+// metrics of different types is only exposed with the first type.
+type PullReporter interface {
+	tally.StatsReporter
+
+	// HTTPHandler returns a handler serving the metrics, typically on
+	// /metrics, in the OpenMetrics text format if the request accepts it
+	// and in the text exposition format otherwise.
+	HTTPHandler() http.Handler
+
+	// WriteText writes the metrics in the text exposition format to w.
+	WriteText(w io.Writer) error
+
+	// WriteOpenMetrics writes the metrics in the OpenMetrics text format
+	// to w.
+	WriteOpenMetrics(w io.Writer) error
+}
+
+// PullOptions is a set of options for a pull reporter.
+type PullOptions struct {
+	// TimerBuckets are the upper bounds, in seconds, of the buckets of the
+	// histograms timers are exposed as. Use nil to specify the default
+	// histogram buckets.
+	TimerBuckets []float64
+}
+
+type pullReporter struct {
+	timerBuckets []float64
+
+	mu       sync.Mutex
+	families map[string]*pullFamily
+	help     map[string]string
+}
+
+type pullFamily struct {
+	typ    string
+	series map[string]*pullSeries
+}
+
+type pullSeries struct {
+	labels string
+	value  float64
+	// The number of samples per bucket upper bound, and the sum of the
+	// samples of a histogram.
+	buckets map[float64]int64
+	sum     float64
+}
+
+// NewPullReporter returns a new pull reporter.
+func NewPullReporter(opts PullOptions) PullReporter {
+	if opts.TimerBuckets == nil {
+		opts.TimerBuckets = DefaultHistogramBuckets()
+	}
+	buckets := append([]float64(nil), opts.TimerBuckets...)
+	sort.Float64s(buckets)
+
+	return &pullReporter{
+		timerBuckets: buckets,
+		families:     make(map[string]*pullFamily),
+		help:         make(map[string]string),
+	}
+}
+
+func (r *pullReporter) ReportCounter(name string, tags map[string]string, value int64) {
+	r.update(name, counterType, tags, func(s *pullSeries) {
+		s.value += float64(value)
+	})
+}
+
+func (r *pullReporter) ReportGauge(name string, tags map[string]string, value float64) {
+	r.update(name, gaugeType, tags, func(s *pullSeries) {
+		s.value = value
+	})
+}
+
+func (r *pullReporter) ReportTimer(name string, tags map[string]string, interval time.Duration) {
+	seconds := interval.Seconds()
+	upperBound := math.Inf(1)
+	if i := sort.SearchFloat64s(r.timerBuckets, seconds); i < len(r.timerBuckets) {
+		upperBound = r.timerBuckets[i]
+	}
+	r.update(name, histogramType, tags, func(s *pullSeries) {
+		seedBuckets(s, r.timerBuckets)
+		s.buckets[upperBound]++
+		s.sum += seconds
+	})
+}
+
+func (r *pullReporter) ReportHistogramValueSamples(
+	name string,
+	tags map[string]string,
+	buckets tally.Buckets,
+	bucketLowerBound,
+	bucketUpperBound float64,
+	samples int64,
+) {
+	r.reportHistogramSamples(name, tags, buckets.AsValues(), bucketLowerBound, bucketUpperBound,
+		bucketUpperBound == math.MaxFloat64, samples)
+}
+
+func (r *pullReporter) ReportHistogramDurationSamples(
+	name string,
+	tags map[string]string,
+	buckets tally.Buckets,
+	bucketLowerBound,
+	bucketUpperBound time.Duration,
+	samples int64,
+) {
+	durations := buckets.AsDurations()
+	bounds := make([]float64, len(durations))
+	for i, d := range durations {
+		bounds[i] = d.Seconds()
+	}
+	r.reportHistogramSamples(name, tags, bounds, bucketLowerBound.Seconds(), bucketUpperBound.Seconds(),
+		bucketUpperBound == time.Duration(math.MaxInt64), samples)
+}
+
+func (r *pullReporter) reportHistogramSamples(
+	name string,
+	tags map[string]string,
+	bounds []float64,
+	lowerBound float64,
+	upperBound float64,
+	overflow bool,
+	samples int64,
+) {
+	approximation := upperBound
+	if overflow {
+		upperBound = math.Inf(1)
+		approximation = lowerBound
+	}
+	r.update(name, histogramType, tags, func(s *pullSeries) {
+		seedBuckets(s, bounds)
+		s.buckets[upperBound] += samples
+		s.sum += approximation * float64(samples)
+	})
+}
+
+// seedBuckets adds the buckets with the given upper bounds to a histogram
+// series first reported, so that every bucket is exposed, including those
+// without samples. The +Inf bucket is always exposed.
+func seedBuckets(s *pullSeries, bounds []float64) {
+	if len(s.buckets) > 0 {
+		return
+	}
+	for _, bound := range bounds {
+		if bound < math.MaxFloat64 {
+			s.buckets[bound] = 0
+		}
+	}
+}
+
+// ReportGaugeHistogram implements tally.GaugeHistogramReporter, the
+// buckets of a gauge histogram are replaced by each report.
+func (r *pullReporter) ReportGaugeHistogram(
+	name string,
+	tags map[string]string,
+	upperBounds []float64,
+	samples []int64,
+) {
+	r.update(name, gaugeHistogramType, tags, func(s *pullSeries) {
+		s.buckets = make(map[float64]int64, len(upperBounds))
+		s.sum = 0
+		for i, upperBound := range upperBounds {
+			s.buckets[upperBound] = samples[i]
+			approximation := upperBound
+			if math.IsInf(upperBound, 1) && i > 0 {
+				approximation = upperBounds[i-1]
+			}
+			if samples[i] > 0 {
+				s.sum += approximation * float64(samples[i])
+			}
+		}
+	})
+}
+
+// update calls fn with the series of name and tags, unless name was
+// reported with another type.
+func (r *pullReporter) update(name, typ string, tags map[string]string, fn func(*pullSeries)) {
+	labels := formatLabels(tags)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	f, ok := r.families[name]
+	if !ok {
+		f = &pullFamily{typ: typ, series: make(map[string]*pullSeries)}
+		r.families[name] = f
+	}
+	if f.typ != typ {
+		return
+	}
+
+	s, ok := f.series[labels]
+	if !ok {
+		s = &pullSeries{labels: labels}
+		if typ == histogramType || typ == gaugeHistogramType {
+			s.buckets = make(map[float64]int64)
+		}
+		f.series[labels] = s
+	}
+	fn(s)
+}
+
+// DescribeMetric implements tally.MetricDescriber, the help text of a
+// metric is written as its HELP line.
+func (r *pullReporter) DescribeMetric(_ tally.MetricKind, name string, metadata tally.MetricMetadata) {
+	if metadata.Help == "" {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.help[name] = metadata.Help
+}
+
+func (r *pullReporter) Capabilities() tally.Capabilities {
+	return r
+}
+
+func (r *pullReporter) Reporting() bool {
+	return true
+}
+
+func (r *pullReporter) Tagging() bool {
+	return true
+}
+
+func (r *pullReporter) Flush() {}
+
+func (r *pullReporter) HTTPHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if strings.Contains(req.Header.Get("Accept"), "application/openmetrics-text") {
+			w.Header().Set("Content-Type", openMetricsContentType)
+			_ = r.WriteOpenMetrics(w)
+			return
+		}
+		w.Header().Set("Content-Type", textContentType)
+		_ = r.WriteText(w)
+	})
+}
+
+func (r *pullReporter) WriteText(w io.Writer) error {
+	return r.write(w, false)
+}
+
+func (r *pullReporter) WriteOpenMetrics(w io.Writer) error {
+	return r.write(w, true)
+}
+
+// write writes the metrics in the OpenMetrics text format if openMetrics
+// is set, otherwise in the text exposition format.
+func (r *pullReporter) write(w io.Writer, openMetrics bool) error {
+	bw := bufio.NewWriter(w)
+
+	r.mu.Lock()
+	names := make([]string, 0, len(r.families))
+	for name := range r.families {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		f := r.families[name]
+		family, suffix := name, ""
+		if openMetrics && f.typ == counterType {
+			// NB: OpenMetrics counter samples have a _total suffix which
+			// their family name lacks.
+			family, suffix = strings.TrimSuffix(name, "_total"), "_total"
+		}
+		if openMetrics || f.typ != gaugeHistogramType {
+			if help, ok := r.help[name]; ok {
+				bw.WriteString("# HELP " + family + " " + helpEscaper.Replace(help) + "\n")
+			}
+			bw.WriteString("# TYPE " + family + " " + f.typ + "\n")
+		}
+
+		keys := make([]string, 0, len(f.series))
+		for key := range f.series {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			s := f.series[key]
+			if f.typ != histogramType && f.typ != gaugeHistogramType {
+				writeSample(bw, family+suffix, s.labels, "", s.value)
+				continue
+			}
+
+			bounds := make([]float64, 0, len(s.buckets)+1)
+			for bound := range s.buckets {
+				bounds = append(bounds, bound)
+			}
+			if _, ok := s.buckets[math.Inf(1)]; !ok {
+				bounds = append(bounds, math.Inf(1))
+			}
+			sort.Float64s(bounds)
+
+			var count int64
+			for _, bound := range bounds {
+				count += s.buckets[bound]
+				writeSample(bw, name+"_bucket", s.labels, formatFloat(bound), float64(count))
+			}
+			if f.typ == gaugeHistogramType {
+				writeSample(bw, name+"_gcount", s.labels, "", float64(count))
+				writeSample(bw, name+"_gsum", s.labels, "", s.sum)
+				continue
+			}
+			writeSample(bw, name+"_sum", s.labels, "", s.sum)
+			writeSample(bw, name+"_count", s.labels, "", float64(count))
+		}
+	}
+	r.mu.Unlock()
+	if openMetrics {
+		bw.WriteString("# EOF\n")
+	}
+
+	return bw.Flush()
+}
+
+// writeSample writes a sample line, adding an le label to labels if set.
+func writeSample(w *bufio.Writer, name, labels, le string, value float64) {
+	w.WriteString(name)
+	if le != "" {
+		if labels != "" {
+			labels += ","
+		}
+		labels += `le="` + le + `"`
+	}
+	if labels != "" {
+		w.WriteString("{" + labels + "}")
+	}
+	w.WriteString(" " + formatFloat(value) + "\n")
+}
+
+// formatLabels returns the labels of tags sorted by key, in the text
+// exposition format.
+func formatLabels(tags map[string]string) string {
+	if len(tags) == 0 {
+		return ""
+	}
+
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	for i, k := range keys {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(k)
+		b.WriteString(`="`)
+		b.WriteString(labelValueEscaper.Replace(tags[k]))
+		b.WriteByte('"')
+	}
+	return b.String()
+}
+
+var (
+	labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	helpEscaper       = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+)
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	default:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+}
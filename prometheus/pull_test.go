@@ -0,0 +1,203 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package prometheus
+
+import (
+	"io/ioutil"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	tally "github.com/extrasalt/tally/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPullReporter(t *testing.T) {
+	r := NewPullReporter(PullOptions{TimerBuckets: []float64{0.1, 1}})
+	scope, closer := tally.NewRootScope(tally.ScopeOptions{
+		Reporter:        r,
+		SanitizeOptions: &DefaultSanitizerOpts,
+		MetricsOption:   tally.OmitInternalMetrics,
+	}, 0)
+
+	s := scope.Tagged(map[string]string{"method": "get"})
+	s.Counter("requests").Inc(2)
+	scope.Tagged(map[string]string{"method": `a"b`}).Counter("requests").Inc(1)
+	scope.Gauge("workers").Update(4)
+	s.Timer("latency").Record(50 * time.Millisecond)
+	s.Timer("latency").Record(2 * time.Second)
+	h := scope.Histogram("size", tally.ValueBuckets{1, 10})
+	h.RecordValue(0.5)
+	h.RecordValue(5)
+	h.RecordValue(50)
+	require.NoError(t, tally.Flush(scope))
+	s.Counter("requests").Inc(3)
+	require.NoError(t, closer.Close())
+
+	server := httptest.NewServer(r.HTTPHandler())
+	defer server.Close()
+
+	resp, err := server.Client().Get(server.URL + "/metrics")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	assert.Equal(t, textContentType, resp.Header.Get("Content-Type"))
+	assert.Equal(t, `# TYPE latency histogram
+latency_bucket{method="get",le="0.1"} 1
+latency_bucket{method="get",le="1"} 1
+latency_bucket{method="get",le="+Inf"} 2
+latency_sum{method="get"} 2.05
+latency_count{method="get"} 2
+# TYPE requests counter
+requests{method="a_b"} 1
+requests{method="get"} 5
+# TYPE size histogram
+size_bucket{le="1"} 1
+size_bucket{le="10"} 2
+size_bucket{le="+Inf"} 3
+size_sum 21
+size_count 3
+# TYPE workers gauge
+workers 4
+`, string(body))
+}
+
+func TestPullReporterTypeConflict(t *testing.T) {
+	r := NewPullReporter(PullOptions{})
+	r.ReportCounter("m", nil, 1)
+	r.ReportGauge("m", nil, 5)
+	r.ReportGauge("escaped", map[string]string{"k": "a\"b\\c\n"}, 1)
+
+	var b strings.Builder
+	require.NoError(t, r.WriteText(&b))
+	assert.Equal(t, `# TYPE escaped gauge
+escaped{k="a\"b\\c\n"} 1
+# TYPE m counter
+m 1
+`, b.String())
+}
+
+func TestPullReporterHelp(t *testing.T) {
+	r := NewPullReporter(PullOptions{})
+	scope, closer := tally.NewRootScope(tally.ScopeOptions{
+		Reporter:      r,
+		MetricsOption: tally.OmitInternalMetrics,
+	}, 0)
+
+	tally.CounterWith(scope, "requests", tally.WithHelp("Total requests.\nBy method \\ path.")).Inc(1)
+	scope.Gauge("workers").Update(2)
+	require.NoError(t, closer.Close())
+
+	var b strings.Builder
+	require.NoError(t, r.WriteText(&b))
+	assert.Equal(t, `# HELP requests Total requests.\nBy method \\ path.
+# TYPE requests counter
+requests 1
+# TYPE workers gauge
+workers 2
+`, b.String())
+}
+
+func TestPullReporterGaugeHistogram(t *testing.T) {
+	r := NewPullReporter(PullOptions{})
+	scope, closer := tally.NewRootScope(tally.ScopeOptions{
+		Reporter:      r,
+		MetricsOption: tally.OmitInternalMetrics,
+	}, 0)
+
+	depth := tally.GaugeHistogramOf(scope, "queue_depth", tally.ValueBuckets{10, 100})
+	depth.RecordValue(500)
+	require.NoError(t, tally.Flush(scope))
+
+	// Only the samples since the previous report are exposed.
+	depth.RecordValue(5)
+	depth.RecordValue(50)
+	depth.RecordValue(50)
+	scope.Counter("requests_total").Inc(1)
+	require.NoError(t, closer.Close())
+
+	server := httptest.NewServer(r.HTTPHandler())
+	defer server.Close()
+
+	req := httptest.NewRequest("GET", server.URL+"/metrics", nil)
+	req.RequestURI = ""
+	req.Header.Set("Accept", "application/openmetrics-text; version=1.0.0")
+	resp, err := server.Client().Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	assert.Equal(t, openMetricsContentType, resp.Header.Get("Content-Type"))
+	assert.Equal(t, `# TYPE queue_depth gaugehistogram
+queue_depth_bucket{le="10"} 1
+queue_depth_bucket{le="100"} 3
+queue_depth_bucket{le="+Inf"} 3
+queue_depth_gcount 3
+queue_depth_gsum 210
+# TYPE requests counter
+requests_total 1
+# EOF
+`, string(body))
+
+	var b strings.Builder
+	require.NoError(t, r.WriteText(&b))
+	assert.Equal(t, `queue_depth_bucket{le="10"} 1
+queue_depth_bucket{le="100"} 3
+queue_depth_bucket{le="+Inf"} 3
+queue_depth_gcount 3
+queue_depth_gsum 210
+# TYPE requests_total counter
+requests_total 1
+`, b.String())
+}
+
+func TestPullReporterEmptyBuckets(t *testing.T) {
+	r := NewPullReporter(PullOptions{})
+	scope, closer := tally.NewRootScope(tally.ScopeOptions{
+		Reporter:      r,
+		MetricsOption: tally.OmitInternalMetrics,
+	}, 0)
+	scope.Histogram("size", tally.ValueBuckets{1, 10, 100}).RecordValue(5)
+	scope.Histogram("wait", tally.DurationBuckets{time.Second, time.Minute}).RecordDuration(time.Hour)
+	require.NoError(t, closer.Close())
+
+	var b strings.Builder
+	require.NoError(t, r.WriteText(&b))
+	assert.Equal(t, `# TYPE size histogram
+size_bucket{le="1"} 0
+size_bucket{le="10"} 1
+size_bucket{le="100"} 1
+size_bucket{le="+Inf"} 1
+size_sum 10
+size_count 1
+# TYPE wait histogram
+wait_bucket{le="1"} 0
+wait_bucket{le="60"} 0
+wait_bucket{le="+Inf"} 1
+wait_sum 60
+wait_count 1
+`, b.String())
+}
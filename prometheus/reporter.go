@@ -22,6 +22,7 @@ package prometheus
 
 import (
 	"net/http"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -143,6 +144,7 @@ type metricID string
 type reporter struct {
 	sync.RWMutex
 	registerer      prom.Registerer
+	registerers     []PrefixRegisterer
 	gatherer        prom.Gatherer
 	timerType       TimerType
 	objectives      map[float64]float64
@@ -151,6 +153,8 @@ type reporter struct {
 	counters        map[metricID]*prom.CounterVec
 	gauges          map[metricID]*prom.GaugeVec
 	timers          map[metricID]*promTimerVec
+	annotations     map[string]map[string]string
+	descriptions    map[string]string
 }
 
 type promTimerVec struct {
@@ -170,6 +174,10 @@ func (m *cachedMetric) ReportCount(value int64) {
 	m.counter.Add(float64(value))
 }
 
+func (m *cachedMetric) ReportFloatCount(value float64) {
+	m.counter.Add(value)
+}
+
 func (m *cachedMetric) ReportGauge(value float64) {
 	m.gauge.Set(value)
 }
@@ -213,6 +221,7 @@ func (b cachedHistogramBucket) ReportSamples(value int64) {
 type noopMetric struct{}
 
 func (m noopMetric) ReportCount(value int64)            {}
+func (m noopMetric) ReportFloatCount(value float64)     {}
 func (m noopMetric) ReportGauge(value float64)          {}
 func (m noopMetric) ReportTimer(interval time.Duration) {}
 func (m noopMetric) ReportSamples(value int64)          {}
@@ -265,6 +274,26 @@ type Options struct {
 	// a metric with the registerer fails. Use nil to specify
 	// to panic by default when registering fails.
 	OnRegisterError func(err error)
+
+	// PrefixRegisterers are registerers to register metrics with instead
+	// of Registerer by metric name prefix, e.g. to serve the metrics of
+	// some subscopes on a separate endpoint. The longest matching prefix
+	// wins. The HTTPHandler of the reporter only serves the metrics
+	// gathered by Gatherer.
+	PrefixRegisterers []PrefixRegisterer
+}
+
+// PrefixRegisterer is a registerer for the metrics whose name starts with
+// a prefix.
+type PrefixRegisterer struct {
+	// Prefix is matched against fully qualified metric names, so it
+	// includes the prefix of the root scope and the separator if the
+	// metrics of a subscope are to be matched, e.g. "root_internal_".
+	Prefix string
+
+	// Registerer is the prometheus registerer to register metrics whose
+	// name starts with Prefix with.
+	Registerer prom.Registerer
 }
 
 // NewReporter returns a new Reporter for Prometheus client backed metrics
@@ -309,6 +338,7 @@ func NewReporter(opts Options) Reporter {
 
 	return &reporter{
 		registerer:      opts.Registerer,
+		registerers:     opts.PrefixRegisterers,
 		gatherer:        opts.Gatherer,
 		timerType:       opts.DefaultTimerType,
 		buckets:         opts.DefaultHistogramBuckets,
@@ -317,7 +347,80 @@ func NewReporter(opts Options) Reporter {
 		counters:        make(map[metricID]*prom.CounterVec),
 		gauges:          make(map[metricID]*prom.GaugeVec),
 		timers:          make(map[metricID]*promTimerVec),
+		annotations:     make(map[string]map[string]string),
+		descriptions:    make(map[string]string),
+	}
+}
+
+// AnnotateMetric implements tally.MetricAnnotator, the annotations of a
+// metric are appended to its HELP text when it's registered.
+func (r *reporter) AnnotateMetric(name string, annotations map[string]string) {
+	r.Lock()
+	defer r.Unlock()
+
+	r.annotations[name] = annotations
+}
+
+// DescribeMetric implements tally.MetricDescriber, the help text of a
+// metric replaces its default HELP text when it's registered.
+func (r *reporter) DescribeMetric(_ tally.MetricKind, name string, metadata tally.MetricMetadata) {
+	if metadata.Help == "" {
+		return
 	}
+
+	r.Lock()
+	defer r.Unlock()
+
+	r.descriptions[name] = metadata.Help
+}
+
+// help returns the help text of the metric with the given name and
+// description, must be called with the lock held.
+func (r *reporter) help(name, desc string) string {
+	if d, ok := r.descriptions[name]; ok {
+		desc = d
+	}
+
+	annotations := r.annotations[name]
+	if len(annotations) == 0 {
+		return desc
+	}
+
+	keys := make([]string, 0, len(annotations))
+	for k := range annotations {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	b.WriteString(desc)
+	b.WriteString(" (")
+	for i, k := range keys {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString(k)
+		b.WriteString("=")
+		b.WriteString(annotations[k])
+	}
+	b.WriteString(")")
+	return b.String()
+}
+
+// registererFor returns the registerer to register the metric with the
+// given name with.
+func (r *reporter) registererFor(name string) prom.Registerer {
+	var (
+		registerer = r.registerer
+		longest    = -1
+	)
+	for _, pr := range r.registerers {
+		if len(pr.Prefix) > longest && strings.HasPrefix(name, pr.Prefix) {
+			registerer = pr.Registerer
+			longest = len(pr.Prefix)
+		}
+	}
+	return registerer
 }
 
 func (r *reporter) RegisterCounter(
@@ -345,12 +448,12 @@ func (r *reporter) counterVec(
 	ctr := prom.NewCounterVec(
 		prom.CounterOpts{
 			Name: name,
-			Help: desc,
+			Help: r.help(name, desc),
 		},
 		tagKeys,
 	)
 
-	if err := r.registerer.Register(ctr); err != nil {
+	if err := r.registererFor(name).Register(ctr); err != nil {
 		return nil, err
 	}
 
@@ -369,6 +472,18 @@ func (r *reporter) AllocateCounter(name string, tags map[string]string) tally.Ca
 	return &cachedMetric{counter: counterVec.With(tags)}
 }
 
+// AllocateFloatCounter implements tally.CachedFloatCounterReporter, float
+// counters are Prometheus counters like counters.
+func (r *reporter) AllocateFloatCounter(name string, tags map[string]string) tally.CachedFloatCount {
+	tagKeys := keysFromMap(tags)
+	counterVec, err := r.counterVec(name, tagKeys, name+" counter")
+	if err != nil {
+		r.onRegisterError(err)
+		return noopMetric{}
+	}
+	return &cachedMetric{counter: counterVec.With(tags)}
+}
+
 func (r *reporter) RegisterGauge(
 	name string,
 	tagKeys []string,
@@ -394,12 +509,12 @@ func (r *reporter) gaugeVec(
 	g := prom.NewGaugeVec(
 		prom.GaugeOpts{
 			Name: name,
-			Help: desc,
+			Help: r.help(name, desc),
 		},
 		tagKeys,
 	)
 
-	if err := r.registerer.Register(g); err != nil {
+	if err := r.registererFor(name).Register(g); err != nil {
 		return nil, err
 	}
 
@@ -476,13 +591,13 @@ func (r *reporter) summaryVec(
 	s := prom.NewSummaryVec(
 		prom.SummaryOpts{
 			Name:       name,
-			Help:       desc,
+			Help:       r.help(name, desc),
 			Objectives: objectives,
 		},
 		tagKeys,
 	)
 
-	if err := r.registerer.Register(s); err != nil {
+	if err := r.registererFor(name).Register(s); err != nil {
 		return nil, err
 	}
 
@@ -508,13 +623,13 @@ func (r *reporter) histogramVec(
 	h := prom.NewHistogramVec(
 		prom.HistogramOpts{
 			Name:    name,
-			Help:    desc,
+			Help:    r.help(name, desc),
 			Buckets: buckets,
 		},
 		tagKeys,
 	)
 
-	if err := r.registerer.Register(h); err != nil {
+	if err := r.registererFor(name).Register(h); err != nil {
 		return nil, err
 	}
 
@@ -416,6 +416,110 @@ func TestAlreadyRegisteredCounter(t *testing.T) {
 	}
 }
 
+func TestPrefixRegisterers(t *testing.T) {
+	var (
+		public   = prom.NewRegistry()
+		internal = prom.NewRegistry()
+		debug    = prom.NewRegistry()
+	)
+	r := NewReporter(Options{
+		Registerer: public,
+		PrefixRegisterers: []PrefixRegisterer{
+			{Prefix: "svc_internal_", Registerer: internal},
+			{Prefix: "svc_internal_debug_", Registerer: debug},
+		},
+	})
+
+	scope, closer := tally.NewRootScope(tally.ScopeOptions{
+		Prefix:         "svc",
+		Separator:      DefaultSeparator,
+		CachedReporter: r,
+	}, 0)
+	scope.Counter("requests").Inc(1)
+	scope.SubScope("internal").Gauge("queue").Update(2)
+	scope.SubScope("internal").SubScope("debug").Timer("lock").Record(time.Millisecond)
+	require.NoError(t, closer.Close())
+
+	names := func(g prom.Gatherer) []string {
+		var result []string
+		for _, m := range gather(t, g) {
+			result = append(result, m.GetName())
+		}
+		return result
+	}
+	assert.Equal(t, []string{"svc_requests"}, names(public))
+	assert.Equal(t, []string{"svc_internal_queue"}, names(internal))
+	assert.Equal(t, []string{"svc_internal_debug_lock"}, names(debug))
+}
+
+func TestAnnotateMetric(t *testing.T) {
+	registry := prom.NewRegistry()
+	r := NewReporter(Options{Registerer: registry})
+	scope, closer := tally.NewRootScope(tally.ScopeOptions{
+		Separator:      DefaultSeparator,
+		CachedReporter: r,
+	}, 0)
+	defer closer.Close()
+
+	tally.Annotate(scope, "charges_failed", map[string]string{
+		"team":    "payments",
+		"runbook": "https://runbooks/charges",
+	})
+	scope.Counter("charges_failed").Inc(1)
+	scope.Counter("charges").Inc(1)
+
+	help := make(map[string]string)
+	for _, m := range gather(t, registry) {
+		help[m.GetName()] = m.GetHelp()
+	}
+	assert.Equal(t, map[string]string{
+		"charges":        "charges counter",
+		"charges_failed": "charges_failed counter (runbook=https://runbooks/charges, team=payments)",
+	}, help)
+}
+
+func TestDescribeMetric(t *testing.T) {
+	registry := prom.NewRegistry()
+	r := NewReporter(Options{Registerer: registry})
+	scope, closer := tally.NewRootScope(tally.ScopeOptions{
+		Separator:      DefaultSeparator,
+		CachedReporter: r,
+	}, 0)
+	defer closer.Close()
+
+	tally.Annotate(scope, "charges_failed", map[string]string{"team": "payments"})
+	tally.CounterWith(scope, "charges_failed", tally.WithHelp("Failed charges.")).Inc(1)
+	tally.GaugeWith(scope, "queue", tally.WithUnit("1")).Update(1)
+
+	help := make(map[string]string)
+	for _, m := range gather(t, registry) {
+		help[m.GetName()] = m.GetHelp()
+	}
+	assert.Equal(t, map[string]string{
+		"charges_failed": "Failed charges. (team=payments)",
+		"queue":          "queue gauge",
+	}, help)
+}
+
+func TestFloatCounter(t *testing.T) {
+	registry := prom.NewRegistry()
+	r := NewReporter(Options{Registerer: registry})
+	scope, closer := tally.NewRootScope(tally.ScopeOptions{
+		Separator:      DefaultSeparator,
+		CachedReporter: r,
+		MetricsOption:  tally.OmitInternalMetrics,
+	}, 0)
+
+	cost := tally.CounterFloat(scope, "cost")
+	cost.Inc(0.25)
+	cost.Inc(1.5)
+	require.NoError(t, closer.Close())
+
+	metrics := gather(t, registry)
+	require.Len(t, metrics, 1)
+	assert.Equal(t, 1.75, metrics[0].GetMetric()[0].GetCounter().GetValue())
+}
+
 func gather(t *testing.T, r prom.Gatherer) []*dto.MetricFamily {
 	metrics, err := r.Gather()
 	require.NoError(t, err)
@@ -0,0 +1,65 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tally
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQuietStart(t *testing.T) {
+	r := newTestStatsReporter()
+	root, closer := NewRootScope(ScopeOptions{
+		Reporter:      r,
+		MetricsOption: OmitInternalMetrics,
+		QuietStart:    100 * time.Millisecond,
+	}, time.Millisecond)
+	defer closer.Close()
+
+	root.Counter("foo").Inc(1)
+	time.Sleep(20 * time.Millisecond)
+	root.Counter("foo").Inc(2)
+	assert.Zero(t, atomic.LoadInt32(&r.flushes))
+
+	// The values accumulated during the window are reported once it ends.
+	r.cg.Add(1)
+	r.WaitAll()
+	assert.EqualValues(t, 3, r.getCounters()["foo"].val)
+}
+
+func TestQuietStartWindow(t *testing.T) {
+	now := time.Unix(1e9, 0)
+	defer func(prev func() time.Time) { globalNow = prev }(globalNow)
+	globalNow = func() time.Time { return now }
+
+	s := newRootScope(ScopeOptions{QuietStart: time.Minute}, 0)
+	assert.True(t, s.registry.suppressed(now))
+	assert.True(t, s.registry.suppressed(now.Add(59*time.Second)))
+	assert.False(t, s.registry.suppressed(now.Add(time.Minute)))
+
+	s = newRootScope(ScopeOptions{}, 0)
+	assert.False(t, s.registry.suppressed(now))
+	s.registry.paused.Store(true)
+	assert.True(t, s.registry.suppressed(now))
+}
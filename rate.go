@@ -0,0 +1,169 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tally
+
+import (
+	"sort"
+	"sync/atomic"
+)
+
+// Rate counts events like a counter, but is reported as a gauge of the
+// number of events per second over the last report interval, for push
+// backends such as statsd which can't derive rates from counters. Unlike
+// ScopeOptions.CounterRateSuffix, which reports a rate alongside every
+// counter, rates are opted into per metric.
+type Rate interface {
+	// Inc increments the number of events by delta.
+	Inc(delta int64)
+
+	// RatePerSecond returns the number of events per second over the
+	// interval between the last two reports, zero before the first
+	// report.
+	RatePerSecond() float64
+}
+
+// RateScope is a Scope which can create rates. As Scope can't be extended,
+// rates are created by the function RateOf rather than by a method of
+// Scope.
+type RateScope interface {
+	Scope
+
+	// Rate returns the rate with the given name. It must not share its
+	// name with a gauge of the scope, as they are both reported as gauges.
+	Rate(name string) Rate
+}
+
+// RateOf returns s.Rate(name) if s is a RateScope, otherwise a rate
+// discarding its events.
+func RateOf(s Scope, name string) Rate {
+	if rs, ok := s.(RateScope); ok {
+		return rs.Rate(name)
+	}
+	return noopRate{}
+}
+
+func (s *scope) Rate(name string) Rate {
+	name = s.sanitizer.Name(name)
+	if r, ok := s.rate(name); ok {
+		return r
+	}
+
+	s.registry.checkName(name)
+	if !s.metricEnabled(name) {
+		return noopRate{}
+	}
+	if o, ok := s.overflowScope(rateSeries, name); ok {
+		return o.Rate(name)
+	}
+
+	s.cm.Lock()
+	defer s.cm.Unlock()
+
+	if r, ok := s.rates[name]; ok {
+		return r
+	}
+
+	var cachedGauge CachedGauge
+	if s.cachedReporter != nil {
+		cachedGauge = s.cachedReporter.AllocateGauge(s.fullyQualifiedName(name), s.tags)
+	}
+	r := &rate{rate: newCounterRate("", cachedGauge), guard: s.guard}
+	if s.rates == nil {
+		s.rates = make(map[string]*rate)
+	}
+	s.rates[name] = r
+	s.registry.churn.record(s.tags, 1, false)
+	s.ratesSlice = append(s.ratesSlice, r)
+
+	return r
+}
+
+func (s *scope) rate(sanitizedName string) (Rate, bool) {
+	s.cm.RLock()
+	defer s.cm.RUnlock()
+
+	r, ok := s.rates[sanitizedName]
+	return r, ok
+}
+
+func (s *leveledScope) Rate(name string) Rate {
+	return leveledRate{s, s.scope.Rate(name)}
+}
+
+type leveledRate struct {
+	scope *leveledScope
+	Rate
+}
+
+func (r leveledRate) Inc(delta int64) {
+	if r.scope.enabled() {
+		r.Rate.Inc(delta)
+	}
+}
+
+type noopRate struct{}
+
+func (noopRate) Inc(int64)              {}
+func (noopRate) RatePerSecond() float64 { return 0 }
+
+// rate is the rate of a scope.
+type rate struct {
+	curr  counterValue
+	prev  int64
+	rate  *counterRate
+	guard *closeGuard
+}
+
+func (r *rate) Inc(delta int64) {
+	if !r.guard.begin() {
+		return
+	}
+	r.curr.add(delta)
+	r.guard.end()
+}
+
+func (r *rate) RatePerSecond() float64 {
+	return r.rate.rate()
+}
+
+// delta returns the events since it was last called.
+func (r *rate) delta() int64 {
+	curr := r.curr.load()
+	prev := atomic.SwapInt64(&r.prev, curr)
+	return curr - prev
+}
+
+func (r *rate) report(name string, tags map[string]string, sr StatsReporter) {
+	r.rate.report(name, tags, r.delta(), sr)
+}
+
+func (r *rate) cachedReport() {
+	r.rate.cachedReport(r.delta())
+}
+
+func sortedRateNames(m map[string]*rate) []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,91 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tally
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type gaugeValueRecordingReporter struct {
+	nullStatsReporter
+	gauges map[string][]float64
+}
+
+func (r *gaugeValueRecordingReporter) ReportGauge(name string, _ map[string]string, value float64) {
+	r.gauges[name] = append(r.gauges[name], value)
+}
+
+func TestRate(t *testing.T) {
+	now := time.Unix(1e9, 0)
+	defer func(prev func() time.Time) { globalNow = prev }(globalNow)
+	globalNow = func() time.Time { return now }
+
+	r := &gaugeValueRecordingReporter{gauges: make(map[string][]float64)}
+	root, closer := NewRootScope(ScopeOptions{Reporter: r, MetricsOption: OmitInternalMetrics}, 0)
+	defer closer.Close()
+	s := root.(*scope)
+
+	requests := RateOf(root, "requests")
+	assert.Equal(t, requests, RateOf(root, "requests"))
+	assert.Equal(t, 0.0, requests.RatePerSecond())
+
+	requests.Inc(20)
+	requests.Inc(10)
+	now = now.Add(10 * time.Second)
+	s.reportRegistry()
+	assert.Equal(t, 3.0, requests.RatePerSecond())
+
+	// A rate is reported once more when it drops to zero.
+	now = now.Add(10 * time.Second)
+	s.reportRegistry()
+	now = now.Add(10 * time.Second)
+	s.reportRegistry()
+
+	assert.Equal(t, []float64{3, 0}, r.gauges["requests"])
+	assert.Equal(t, 0.0, requests.RatePerSecond())
+	assert.Equal(t, noopRate{}, RateOf(struct{ Scope }{root}, "requests"))
+}
+
+func TestRateShards(t *testing.T) {
+	now := time.Unix(1e9, 0)
+	defer func(prev func() time.Time) { globalNow = prev }(globalNow)
+	globalNow = func() time.Time { return now }
+
+	r := &gaugeValueRecordingReporter{gauges: make(map[string][]float64)}
+	root, closer := NewRootScope(ScopeOptions{
+		Reporter:      r,
+		MetricsOption: OmitInternalMetrics,
+		// NB: the root scope is registered in every shard.
+		registryShardCount: 4,
+	}, 0)
+	defer closer.Close()
+
+	requests := RateOf(root, "requests")
+	requests.Inc(30)
+	now = now.Add(10 * time.Second)
+	root.(*scope).reportRegistry()
+
+	assert.Equal(t, 3.0, requests.RatePerSecond())
+	assert.Equal(t, []float64{3}, r.gauges["requests"])
+}
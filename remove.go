@@ -0,0 +1,228 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tally
+
+// RemovableScope is a Scope whose metrics and tagged child scopes can be
+// removed, so that long-running processes creating series with dynamic
+// tags, e.g. per connection, can reclaim them once they are no longer
+// needed.
+type RemovableScope interface {
+	Scope
+
+	// RemoveCounter removes the counter with the given name from the
+	// scope, returning whether it existed. The counter is no longer
+	// reported: its unreported value and any later write to it are lost,
+	// and Counter returns a new counter for the name.
+	RemoveCounter(name string) bool
+
+	// RemoveGauge is RemoveCounter for gauges.
+	RemoveGauge(name string) bool
+
+	// RemoveTimer is RemoveCounter for timers.
+	RemoveTimer(name string) bool
+
+	// RemoveHistogram is RemoveCounter for histograms.
+	RemoveHistogram(name string) bool
+
+	// RemoveTagged closes the child scope which Tagged would return for
+	// tags, returning whether it existed. Its metrics are reported a
+	// final time by the next report, which then removes the scope from
+	// the registry.
+	RemoveTagged(tags map[string]string) bool
+}
+
+// RemoveCounter returns s.RemoveCounter(name) if s is a RemovableScope,
+// otherwise false.
+func RemoveCounter(s Scope, name string) bool {
+	if rs, ok := s.(RemovableScope); ok {
+		return rs.RemoveCounter(name)
+	}
+	return false
+}
+
+// RemoveGauge returns s.RemoveGauge(name) if s is a RemovableScope,
+// otherwise false.
+func RemoveGauge(s Scope, name string) bool {
+	if rs, ok := s.(RemovableScope); ok {
+		return rs.RemoveGauge(name)
+	}
+	return false
+}
+
+// RemoveTimer returns s.RemoveTimer(name) if s is a RemovableScope,
+// otherwise false.
+func RemoveTimer(s Scope, name string) bool {
+	if rs, ok := s.(RemovableScope); ok {
+		return rs.RemoveTimer(name)
+	}
+	return false
+}
+
+// RemoveHistogram returns s.RemoveHistogram(name) if s is a
+// RemovableScope, otherwise false.
+func RemoveHistogram(s Scope, name string) bool {
+	if rs, ok := s.(RemovableScope); ok {
+		return rs.RemoveHistogram(name)
+	}
+	return false
+}
+
+// RemoveTagged returns s.RemoveTagged(tags) if s is a RemovableScope,
+// otherwise false.
+func RemoveTagged(s Scope, tags map[string]string) bool {
+	if rs, ok := s.(RemovableScope); ok {
+		return rs.RemoveTagged(tags)
+	}
+	return false
+}
+
+func (s *scope) RemoveCounter(name string) bool {
+	name = s.sanitizer.Name(name)
+
+	s.cm.Lock()
+	defer s.cm.Unlock()
+
+	return s.removeCounter(name)
+}
+
+// removeCounter removes the counter with the given sanitized name, it
+// must be called with cm held.
+func (s *scope) removeCounter(name string) bool {
+	c, ok := s.counters[name]
+	if !ok {
+		return false
+	}
+	delete(s.counters, name)
+	s.registry.churn.record(s.tags, 1, true)
+	s.releaseSeries(counterSeries, name)
+	s.undeclare(CounterKind, name)
+	for i, sc := range s.countersSlice {
+		if sc == c {
+			s.countersSlice = append(s.countersSlice[:i], s.countersSlice[i+1:]...)
+			break
+		}
+	}
+	return true
+}
+
+func (s *scope) RemoveGauge(name string) bool {
+	name = s.sanitizer.Name(name)
+
+	s.gm.Lock()
+	defer s.gm.Unlock()
+
+	return s.removeGauge(name)
+}
+
+// removeGauge removes the gauge with the given sanitized name, it
+// must be called with gm held.
+func (s *scope) removeGauge(name string) bool {
+	g, ok := s.gauges[name]
+	if !ok {
+		return false
+	}
+	delete(s.gauges, name)
+	s.registry.churn.record(s.tags, 1, true)
+	s.releaseSeries(gaugeSeries, name)
+	s.undeclare(GaugeKind, name)
+	delete(s.gaugeFuncs, name)
+	for i, sg := range s.gaugesSlice {
+		if sg == g {
+			s.gaugesSlice = append(s.gaugesSlice[:i], s.gaugesSlice[i+1:]...)
+			break
+		}
+	}
+	return true
+}
+
+func (s *scope) RemoveTimer(name string) bool {
+	name = s.sanitizer.Name(name)
+
+	s.tm.Lock()
+	defer s.tm.Unlock()
+
+	if _, ok := s.timers[name]; !ok {
+		return false
+	}
+	delete(s.timers, name)
+	s.registry.churn.record(s.tags, 1, true)
+	s.releaseSeries(timerSeries, name)
+	s.undeclare(TimerKind, name)
+	return true
+}
+
+func (s *scope) RemoveHistogram(name string) bool {
+	name = s.sanitizer.Name(name)
+
+	s.hm.Lock()
+	defer s.hm.Unlock()
+
+	return s.removeHistogram(name)
+}
+
+// removeHistogram removes the histogram with the given sanitized name, it
+// must be called with hm held.
+func (s *scope) removeHistogram(name string) bool {
+	h, ok := s.histograms[name]
+	if !ok {
+		return false
+	}
+	delete(s.histograms, name)
+	s.registry.churn.record(s.tags, 1, true)
+	s.releaseSeries(histogramSeries, name)
+	s.undeclare(HistogramKind, name)
+	for i, sh := range s.histogramsSlice {
+		if sh == h {
+			s.histogramsSlice = append(s.histogramsSlice[:i], s.histogramsSlice[i+1:]...)
+			break
+		}
+	}
+	return true
+}
+
+func (s *scope) RemoveTagged(tags map[string]string) bool {
+	ss, ok := s.LookupTagged(tags)
+	// NB: the scope itself is never removed, e.g. for tags it already has.
+	if !ok || ss == Scope(s) {
+		return false
+	}
+	return ss.(*scope).Close() == nil
+}
+
+func (s *leveledScope) RemoveCounter(name string) bool {
+	return s.scope.RemoveCounter(name)
+}
+
+func (s *leveledScope) RemoveGauge(name string) bool {
+	return s.scope.RemoveGauge(name)
+}
+
+func (s *leveledScope) RemoveTimer(name string) bool {
+	return s.scope.RemoveTimer(name)
+}
+
+func (s *leveledScope) RemoveHistogram(name string) bool {
+	return s.scope.RemoveHistogram(name)
+}
+
+func (s *leveledScope) RemoveTagged(tags map[string]string) bool {
+	return s.scope.RemoveTagged(tags)
+}
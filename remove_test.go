@@ -0,0 +1,82 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tally
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRemoveMetrics(t *testing.T) {
+	root := NewTestScope("", nil)
+	s := AtLevel(root, DebugLevel)
+
+	c := root.Counter("requests")
+	c.Inc(1)
+	root.Gauge("workers").Update(1)
+	root.Timer("latency")
+	root.Histogram("size", ValueBuckets{1})
+
+	assert.True(t, RemoveCounter(s, "requests"))
+	assert.False(t, RemoveCounter(s, "requests"))
+	assert.True(t, RemoveGauge(root, "workers"))
+	assert.True(t, RemoveTimer(root, "latency"))
+	assert.True(t, RemoveHistogram(root, "size"))
+	assert.False(t, RemoveHistogram(root, "missing"))
+
+	snapshot := root.Snapshot()
+	assert.Empty(t, snapshot.Counters())
+	assert.Empty(t, snapshot.Gauges())
+	assert.Empty(t, snapshot.Timers())
+	assert.Empty(t, snapshot.Histograms())
+	assert.Empty(t, root.(*scope).countersSlice)
+
+	// A removed metric is created anew.
+	assert.NotSame(t, c, root.Counter("requests"))
+
+	assert.False(t, RemoveCounter(NoopScope, "requests"))
+}
+
+func TestRemoveTagged(t *testing.T) {
+	root, closer := NewRootScope(ScopeOptions{
+		Tags:     map[string]string{"service": "svc"},
+		Reporter: NullStatsReporter,
+	}, 0)
+	defer closer.Close()
+	conn := root.Tagged(map[string]string{"conn": "1"})
+	conn.Counter("bytes").Inc(1)
+
+	assert.False(t, RemoveTagged(root, map[string]string{"conn": "2"}))
+	assert.False(t, RemoveTagged(root, map[string]string{"service": "svc"}))
+	assert.True(t, RemoveTagged(root, map[string]string{"conn": "1"}))
+
+	// The scope is removed once reported a final time.
+	_, ok := LookupTagged(root, map[string]string{"conn": "1"})
+	assert.False(t, ok)
+	root.(*scope).reportRegistry()
+	root.(*scope).registry.ForEachScope(func(ss *scope) {
+		assert.NotEqual(t, conn, ss)
+	})
+	assert.NotSame(t, conn, root.Tagged(map[string]string{"conn": "1"}))
+
+	assert.False(t, RemoveTagged(NoopScope, map[string]string{"conn": "1"}))
+}
@@ -0,0 +1,59 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tally
+
+const reportErrorsName = "tally_internal_report_errors"
+
+// ReportErrorNotifier is implemented by reporters which can fail to send
+// the values reported to them, e.g. on network errors. As reporters can't
+// return errors, they notify them to a handler instead.
+type ReportErrorNotifier interface {
+	// NotifyReportErrors sets the handler the reporter calls with its
+	// errors, in addition to any handler set by the reporter's options.
+	// It is called before the reporter is first used.
+	NotifyReportErrors(onError func(error))
+}
+
+// notifyReportErrors makes the reporter of the registry notify its errors
+// to the registry, which counts them and calls onError if set.
+func (r *scopeRegistry) notifyReportErrors(reporter BaseStatsReporter, onError func(error)) {
+	n, ok := reporter.(ReportErrorNotifier)
+	if !ok {
+		return
+	}
+	n.NotifyReportErrors(func(err error) {
+		r.reportErrors.Inc()
+		r.totalReportErrors.Inc()
+		if onError != nil {
+			onError(err)
+		}
+	})
+}
+
+// NotifyReportErrors implements ReportErrorNotifier by setting the handler
+// of every reporter which is a ReportErrorNotifier.
+func (m multiBaseReporters) NotifyReportErrors(onError func(error)) {
+	for _, r := range m {
+		if n, ok := r.(ReportErrorNotifier); ok {
+			n.NotifyReportErrors(onError)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tally
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type failingReporter struct {
+	nullStatsReporter
+	counters map[string]int64
+	onError  func(error)
+}
+
+func (r *failingReporter) ReportCounter(name string, _ map[string]string, value int64) {
+	r.counters[name] += value
+}
+
+func (r *failingReporter) NotifyReportErrors(onError func(error)) {
+	r.onError = onError
+}
+
+func (r *failingReporter) Flush() {
+	r.onError(errors.New("connection refused"))
+}
+
+func TestOnReportError(t *testing.T) {
+	var errs []error
+	r := &failingReporter{counters: make(map[string]int64)}
+	root, closer := NewRootScope(ScopeOptions{
+		Reporter:      NewMultiReporter(r),
+		MetricsOption: SendInternalMetrics,
+		OnReportError: func(err error) { errs = append(errs, err) },
+	}, 0)
+	defer closer.Close()
+
+	root.(*scope).reportRegistry()
+	root.(*scope).reportRegistry()
+
+	assert.Len(t, errs, 2)
+	// Errors are counted from the report following them.
+	assert.EqualValues(t, 1, r.counters[reportErrorsName])
+}
@@ -0,0 +1,75 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tally
+
+// ReportTagsHook returns the tags with which a value of a metric is
+// reported, given the kind, fully qualified name and tags of the metric,
+// e.g. to add a shard ID rotating over time. The given tags must not be
+// modified, the hook returns a new map or the given one unchanged. The
+// returned tags are sanitized like the tags of scopes.
+//
+// Hooks are called every time a value is reported, from multiple
+// goroutines for timers, so they must be fast and safe for concurrent use.
+type ReportTagsHook func(kind MetricKind, name string, tags map[string]string) map[string]string
+
+// newReportTagsAugment returns a function adding the tags of providers to
+// the tags of reported values then applying hook, or nil if there are
+// neither providers nor a hook.
+func newReportTagsAugment(
+	providers map[string]TagProvider,
+	hook ReportTagsHook,
+	sanitizer Sanitizer,
+) func(MetricKind, string, map[string]string) map[string]string {
+	var provide func(MetricKind, string, map[string]string) map[string]string
+	if len(providers) > 0 {
+		provide = newTagProviders(providers, sanitizer)
+	}
+
+	switch {
+	case hook == nil:
+		return provide
+	case provide == nil:
+		return func(kind MetricKind, name string, tags map[string]string) map[string]string {
+			return sanitizeTags(hook(kind, name, tags), sanitizer)
+		}
+	default:
+		return func(kind MetricKind, name string, tags map[string]string) map[string]string {
+			return sanitizeTags(hook(kind, name, provide(kind, name, tags)), sanitizer)
+		}
+	}
+}
+
+// sanitizeTags returns tags sanitized by sanitizer, tags itself if they
+// are already sanitized.
+func sanitizeTags(tags map[string]string, sanitizer Sanitizer) map[string]string {
+	for k, v := range tags {
+		if sanitizer.Key(k) == k && sanitizer.Value(v) == v {
+			continue
+		}
+
+		sanitized := make(map[string]string, len(tags))
+		for k, v := range tags {
+			sanitized[sanitizer.Key(k)] = sanitizer.Value(v)
+		}
+		return sanitized
+	}
+	return tags
+}
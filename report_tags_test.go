@@ -0,0 +1,82 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tally
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReportTagsHook(t *testing.T) {
+	var (
+		shard = "a"
+		r     = &orderRecordingReporter{}
+	)
+	root, closer := NewRootScope(ScopeOptions{
+		Reporter:        r,
+		MetricsOption:   OmitInternalMetrics,
+		SanitizeOptions: &alphanumericSanitizerOpts,
+		TagProviders: map[string]TagProvider{
+			"version": func() string { return "1" },
+		},
+		ReportTagsHook: func(kind MetricKind, name string, tags map[string]string) map[string]string {
+			if kind != CounterKind {
+				return tags
+			}
+			augmented := map[string]string{"shard.id": shard}
+			for k, v := range tags {
+				augmented[k] = v
+			}
+			delete(augmented, "host")
+			return augmented
+		},
+	}, 0)
+	defer closer.Close()
+
+	s := root.Tagged(map[string]string{"host": "h1"})
+	s.Counter("requests").Inc(1)
+	s.Gauge("workers").Update(1)
+	root.(*scope).reportRegistry()
+
+	shard = "b"
+	s.Counter("requests").Inc(1)
+	root.(*scope).reportRegistry()
+
+	assert.Equal(t, []string{
+		"requests+shard_id=a,version=1",
+		"workers+host=h1,version=1",
+		"requests+shard_id=b,version=1",
+	}, r.names)
+
+	// The registry identity of the metrics is unchanged.
+	assert.Contains(t, root.(TestScope).Snapshot().Counters(), "requests+host=h1")
+}
+
+func TestSanitizeTags(t *testing.T) {
+	s := NewSanitizer(alphanumericSanitizerOpts)
+	tags := map[string]string{"a": "b"}
+	assert.Equal(t, tags, sanitizeTags(tags, s))
+	assert.Equal(t,
+		map[string]string{"a_b": "c_d", "e": "f"},
+		sanitizeTags(map[string]string{"a.b": "c.d", "e": "f"}, s),
+	)
+}
@@ -77,6 +77,77 @@ type StatsReporter interface {
 	)
 }
 
+// CumulativeCounterReporter is implemented by StatsReporters which also
+// report the cumulative value of counters since they were created, e.g. for
+// backends expecting cumulative counters, alongside the deltas reported to
+// every reporter.
+type CumulativeCounterReporter interface {
+	// ReportCounterTotal reports the cumulative value of a counter, it is
+	// called right after ReportCounter reports the counter's delta.
+	ReportCounterTotal(
+		name string,
+		tags map[string]string,
+		total int64,
+	)
+}
+
+// TimestampedStatsReporter is implemented by StatsReporters which accept an
+// explicit observation timestamp for each value, e.g. to backfill values
+// from spools or replay tools rather than stamping them with their arrival
+// time. Scopes report values to them with the time of the report, or the
+// time timers are recorded at.
+type TimestampedStatsReporter interface {
+	StatsReporter
+
+	// ReportCounterAt reports a counter value observed at ts.
+	ReportCounterAt(
+		name string,
+		tags map[string]string,
+		value int64,
+		ts time.Time,
+	)
+
+	// ReportGaugeAt reports a gauge value observed at ts.
+	ReportGaugeAt(
+		name string,
+		tags map[string]string,
+		value float64,
+		ts time.Time,
+	)
+
+	// ReportTimerAt reports a timer value observed at ts.
+	ReportTimerAt(
+		name string,
+		tags map[string]string,
+		interval time.Duration,
+		ts time.Time,
+	)
+
+	// ReportHistogramValueSamplesAt reports histogram samples for a bucket
+	// observed at ts.
+	ReportHistogramValueSamplesAt(
+		name string,
+		tags map[string]string,
+		buckets Buckets,
+		bucketLowerBound,
+		bucketUpperBound float64,
+		samples int64,
+		ts time.Time,
+	)
+
+	// ReportHistogramDurationSamplesAt reports histogram samples for a
+	// bucket observed at ts.
+	ReportHistogramDurationSamplesAt(
+		name string,
+		tags map[string]string,
+		buckets Buckets,
+		bucketLowerBound,
+		bucketUpperBound time.Duration,
+		samples int64,
+		ts time.Time,
+	)
+}
+
 // CachedStatsReporter is a backend for Scopes that pre allocates all
 // counter, gauges, timers & histograms. This is harder to implement but more performant.
 type CachedStatsReporter interface {
@@ -119,6 +190,14 @@ type CachedGauge interface {
 	ReportGauge(value float64)
 }
 
+// CachedCumulativeCount is implemented by CachedCounts which also report
+// the cumulative value of their counter since it was created.
+type CachedCumulativeCount interface {
+	// ReportTotal reports the cumulative value of the counter, it is called
+	// right after ReportCount reports the counter's delta.
+	ReportTotal(total int64)
+}
+
 // CachedTimer interface for reporting an individual timer
 type CachedTimer interface {
 	ReportTimer(interval time.Duration)
@@ -0,0 +1,398 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tally
+
+import (
+	"path"
+	"sync"
+	"time"
+)
+
+// RollupRule configures a rollup: the counters and histograms whose name
+// matches the rule are additionally reported with some of their tags
+// removed, summing the values of all series which only differ by those
+// tags. This produces common aggregations in process rather than in the
+// storage tier. Gauges and timers are not rolled up as their values can't
+// be summed across series.
+type RollupRule struct {
+	// Name is a path.Match pattern matched against fully qualified metric
+	// names.
+	Name string
+
+	// DropTags are the keys of the tags removed from the rolled up series.
+	// Series which have none of these tags are not rolled up.
+	DropTags []string
+
+	// Suffix if set is appended to the name of the rolled up series, for
+	// reporters which require all series of a name to have the same tag
+	// keys.
+	Suffix string
+}
+
+// rollups aggregates the values reported for the metrics matching a set of
+// rollup rules until they are reported at the end of each report.
+type rollups struct {
+	rules []RollupRule
+
+	mu      sync.Mutex
+	matches map[string][]int
+	series  map[string]*rollupSeries
+	cached  map[string]*rollupCachedSeries
+}
+
+type rollupSeries struct {
+	name    string
+	tags    map[string]string
+	value   int64
+	buckets Buckets
+	samples map[rollupBucket]int64
+}
+
+type rollupBucket struct {
+	valueLowerBound    float64
+	valueUpperBound    float64
+	durationLowerBound time.Duration
+	durationUpperBound time.Duration
+}
+
+type rollupCachedSeries struct {
+	count     CachedCount
+	histogram CachedHistogram
+	buckets   map[rollupBucket]CachedHistogramBucket
+}
+
+func newRollups(rules []RollupRule) *rollups {
+	if len(rules) == 0 {
+		return nil
+	}
+	return &rollups{
+		rules:   rules,
+		matches: make(map[string][]int),
+		series:  make(map[string]*rollupSeries),
+		cached:  make(map[string]*rollupCachedSeries),
+	}
+}
+
+// matching returns the indexes of the rules matching name, must be called
+// with mu held.
+func (r *rollups) matching(name string) []int {
+	if m, ok := r.matches[name]; ok {
+		return m
+	}
+	var m []int
+	for i, rule := range r.rules {
+		if ok, _ := path.Match(rule.Name, name); ok {
+			m = append(m, i)
+		}
+	}
+	r.matches[name] = m
+	return m
+}
+
+// forEachSeries calls f with the rolled up series of every rule matching
+// the metric, creating the series as needed. It must be called with mu
+// held.
+func (r *rollups) forEachSeries(
+	name string,
+	tags map[string]string,
+	f func(*rollupSeries),
+) {
+	for _, i := range r.matching(name) {
+		rule := r.rules[i]
+
+		dropped := false
+		for _, k := range rule.DropTags {
+			if _, ok := tags[k]; ok {
+				dropped = true
+				break
+			}
+		}
+		if !dropped {
+			continue
+		}
+
+		rolledTags := make(map[string]string, len(tags))
+		for k, v := range tags {
+			rolledTags[k] = v
+		}
+		for _, k := range rule.DropTags {
+			delete(rolledTags, k)
+		}
+
+		rolledName := name + rule.Suffix
+		key := KeyForPrefixedStringMap(rolledName, rolledTags)
+		series, ok := r.series[key]
+		if !ok {
+			series = &rollupSeries{name: rolledName, tags: rolledTags}
+			r.series[key] = series
+		}
+		f(series)
+	}
+}
+
+func (r *rollups) addCounter(name string, tags map[string]string, value int64) {
+	r.mu.Lock()
+	r.forEachSeries(name, tags, func(s *rollupSeries) {
+		s.value += value
+	})
+	r.mu.Unlock()
+}
+
+func (r *rollups) addSamples(
+	name string,
+	tags map[string]string,
+	buckets Buckets,
+	bucket rollupBucket,
+	samples int64,
+) {
+	r.mu.Lock()
+	r.forEachSeries(name, tags, func(s *rollupSeries) {
+		if s.samples == nil {
+			s.buckets = buckets
+			s.samples = make(map[rollupBucket]int64)
+		}
+		s.samples[bucket] += samples
+	})
+	r.mu.Unlock()
+}
+
+// report reports the rolled up series aggregated since the last report.
+func (r *rollups) report(reporter StatsReporter) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for key, s := range r.series {
+		if s.value != 0 {
+			reporter.ReportCounter(s.name, s.tags, s.value)
+		}
+		for b, samples := range s.samples {
+			if _, ok := s.buckets.(DurationBuckets); ok {
+				reporter.ReportHistogramDurationSamples(
+					s.name, s.tags, s.buckets,
+					b.durationLowerBound, b.durationUpperBound, samples,
+				)
+			} else {
+				reporter.ReportHistogramValueSamples(
+					s.name, s.tags, s.buckets,
+					b.valueLowerBound, b.valueUpperBound, samples,
+				)
+			}
+		}
+		delete(r.series, key)
+	}
+}
+
+// cachedReport reports the rolled up series aggregated since the last
+// report, allocating their cached metrics the first time they are reported.
+func (r *rollups) cachedReport(reporter CachedStatsReporter) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for key, s := range r.series {
+		cs, ok := r.cached[key]
+		if !ok {
+			cs = &rollupCachedSeries{}
+			r.cached[key] = cs
+		}
+
+		if s.value != 0 {
+			if cs.count == nil {
+				cs.count = reporter.AllocateCounter(s.name, s.tags)
+			}
+			cs.count.ReportCount(s.value)
+		}
+
+		if len(s.samples) > 0 && cs.histogram == nil {
+			cs.histogram = reporter.AllocateHistogram(s.name, s.tags, s.buckets)
+			cs.buckets = make(map[rollupBucket]CachedHistogramBucket)
+		}
+		for b, samples := range s.samples {
+			cb, ok := cs.buckets[b]
+			if !ok {
+				if _, isDuration := s.buckets.(DurationBuckets); isDuration {
+					cb = cs.histogram.DurationBucket(b.durationLowerBound, b.durationUpperBound)
+				} else {
+					cb = cs.histogram.ValueBucket(b.valueLowerBound, b.valueUpperBound)
+				}
+				cs.buckets[b] = cb
+			}
+			cb.ReportSamples(samples)
+		}
+
+		delete(r.series, key)
+	}
+}
+
+// wrapReporter returns a reporter which also aggregates the values
+// reported to reporter for rollups.
+func (r *rollups) wrapReporter(reporter StatsReporter) StatsReporter {
+	return rollupReporter{StatsReporter: reporter, rollups: r}
+}
+
+// wrapCount returns a cached counter which also aggregates the values
+// reported to c for rollups, or c if no rollup rule matches.
+func (r *rollups) wrapCount(name string, tags map[string]string, c CachedCount) CachedCount {
+	if r == nil || !r.matchesAny(name) {
+		return c
+	}
+	return rollupCachedCount{CachedCount: c, rollups: r, name: name, tags: tags}
+}
+
+// wrapHistogram returns a cached histogram which also aggregates the
+// samples reported to h for rollups, or h if no rollup rule matches.
+func (r *rollups) wrapHistogram(
+	name string,
+	tags map[string]string,
+	buckets Buckets,
+	h CachedHistogram,
+) CachedHistogram {
+	if r == nil || !r.matchesAny(name) {
+		return h
+	}
+	return rollupCachedHistogram{
+		CachedHistogram: h,
+		rollups:         r,
+		name:            name,
+		tags:            tags,
+		buckets:         buckets,
+	}
+}
+
+func (r *rollups) matchesAny(name string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.matching(name)) > 0
+}
+
+type rollupReporter struct {
+	StatsReporter
+	rollups *rollups
+}
+
+func (r rollupReporter) ReportCounter(name string, tags map[string]string, value int64) {
+	r.StatsReporter.ReportCounter(name, tags, value)
+	r.rollups.addCounter(name, tags, value)
+}
+
+func (r rollupReporter) ReportCounterTotal(name string, tags map[string]string, total int64) {
+	if cr, ok := r.StatsReporter.(CumulativeCounterReporter); ok {
+		cr.ReportCounterTotal(name, tags, total)
+	}
+}
+
+func (r rollupReporter) ReportHistogramValueSamples(
+	name string,
+	tags map[string]string,
+	buckets Buckets,
+	bucketLowerBound,
+	bucketUpperBound float64,
+	samples int64,
+) {
+	r.StatsReporter.ReportHistogramValueSamples(
+		name, tags, buckets, bucketLowerBound, bucketUpperBound, samples,
+	)
+	r.rollups.addSamples(name, tags, buckets, rollupBucket{
+		valueLowerBound: bucketLowerBound,
+		valueUpperBound: bucketUpperBound,
+	}, samples)
+}
+
+func (r rollupReporter) ReportHistogramDurationSamples(
+	name string,
+	tags map[string]string,
+	buckets Buckets,
+	bucketLowerBound,
+	bucketUpperBound time.Duration,
+	samples int64,
+) {
+	r.StatsReporter.ReportHistogramDurationSamples(
+		name, tags, buckets, bucketLowerBound, bucketUpperBound, samples,
+	)
+	r.rollups.addSamples(name, tags, buckets, rollupBucket{
+		durationLowerBound: bucketLowerBound,
+		durationUpperBound: bucketUpperBound,
+	}, samples)
+}
+
+type rollupCachedCount struct {
+	CachedCount
+	rollups *rollups
+	name    string
+	tags    map[string]string
+}
+
+func (c rollupCachedCount) ReportCount(value int64) {
+	c.CachedCount.ReportCount(value)
+	c.rollups.addCounter(c.name, c.tags, value)
+}
+
+func (c rollupCachedCount) ReportTotal(total int64) {
+	// NB: rolled up series are deltas only, their totals are not tracked.
+	if cc, ok := c.CachedCount.(CachedCumulativeCount); ok {
+		cc.ReportTotal(total)
+	}
+}
+
+type rollupCachedHistogram struct {
+	CachedHistogram
+	rollups *rollups
+	name    string
+	tags    map[string]string
+	buckets Buckets
+}
+
+func (h rollupCachedHistogram) ValueBucket(
+	bucketLowerBound, bucketUpperBound float64,
+) CachedHistogramBucket {
+	return rollupCachedHistogramBucket{
+		CachedHistogramBucket: h.CachedHistogram.ValueBucket(bucketLowerBound, bucketUpperBound),
+		histogram:             h,
+		bucket: rollupBucket{
+			valueLowerBound: bucketLowerBound,
+			valueUpperBound: bucketUpperBound,
+		},
+	}
+}
+
+func (h rollupCachedHistogram) DurationBucket(
+	bucketLowerBound, bucketUpperBound time.Duration,
+) CachedHistogramBucket {
+	return rollupCachedHistogramBucket{
+		CachedHistogramBucket: h.CachedHistogram.DurationBucket(bucketLowerBound, bucketUpperBound),
+		histogram:             h,
+		bucket: rollupBucket{
+			durationLowerBound: bucketLowerBound,
+			durationUpperBound: bucketUpperBound,
+		},
+	}
+}
+
+type rollupCachedHistogramBucket struct {
+	CachedHistogramBucket
+	histogram rollupCachedHistogram
+	bucket    rollupBucket
+}
+
+func (b rollupCachedHistogramBucket) ReportSamples(value int64) {
+	b.CachedHistogramBucket.ReportSamples(value)
+	h := b.histogram
+	h.rollups.addSamples(h.name, h.tags, h.buckets, b.bucket, value)
+}
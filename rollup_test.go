@@ -0,0 +1,176 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tally
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// rollupRecordingReporter sums the reported counters and histogram samples
+// by series, both as a StatsReporter and as a CachedStatsReporter.
+type rollupRecordingReporter struct {
+	nullStatsReporter
+	counters   map[string]int64
+	histograms map[string]map[float64]int64
+}
+
+func newRollupRecordingReporter() *rollupRecordingReporter {
+	return &rollupRecordingReporter{
+		counters:   make(map[string]int64),
+		histograms: make(map[string]map[float64]int64),
+	}
+}
+
+func (r *rollupRecordingReporter) ReportCounter(name string, tags map[string]string, value int64) {
+	r.counters[KeyForPrefixedStringMap(name, tags)] += value
+}
+
+func (r *rollupRecordingReporter) ReportHistogramValueSamples(
+	name string,
+	tags map[string]string,
+	buckets Buckets,
+	bucketLowerBound,
+	bucketUpperBound float64,
+	samples int64,
+) {
+	key := KeyForPrefixedStringMap(name, tags)
+	if r.histograms[key] == nil {
+		r.histograms[key] = make(map[float64]int64)
+	}
+	r.histograms[key][bucketUpperBound] += samples
+}
+
+func (r *rollupRecordingReporter) AllocateCounter(name string, tags map[string]string) CachedCount {
+	return rollupRecordingCount{r, name, tags}
+}
+
+func (r *rollupRecordingReporter) AllocateGauge(string, map[string]string) CachedGauge {
+	return rollupRecordingNoop{}
+}
+
+func (r *rollupRecordingReporter) AllocateTimer(string, map[string]string) CachedTimer {
+	return rollupRecordingNoop{}
+}
+
+func (r *rollupRecordingReporter) AllocateHistogram(
+	name string,
+	tags map[string]string,
+	buckets Buckets,
+) CachedHistogram {
+	return rollupRecordingHistogram{r, name, tags}
+}
+
+type rollupRecordingNoop struct{}
+
+func (rollupRecordingNoop) ReportGauge(float64)       {}
+func (rollupRecordingNoop) ReportTimer(time.Duration) {}
+
+type rollupRecordingCount struct {
+	r    *rollupRecordingReporter
+	name string
+	tags map[string]string
+}
+
+func (c rollupRecordingCount) ReportCount(v int64) {
+	c.r.ReportCounter(c.name, c.tags, v)
+}
+
+type rollupRecordingHistogram struct {
+	r    *rollupRecordingReporter
+	name string
+	tags map[string]string
+}
+
+func (h rollupRecordingHistogram) ValueBucket(lower, upper float64) CachedHistogramBucket {
+	return rollupRecordingBucket{h, upper}
+}
+
+func (h rollupRecordingHistogram) DurationBucket(lower, upper time.Duration) CachedHistogramBucket {
+	return rollupRecordingBucket{h, float64(upper)}
+}
+
+type rollupRecordingBucket struct {
+	h     rollupRecordingHistogram
+	upper float64
+}
+
+func (b rollupRecordingBucket) ReportSamples(v int64) {
+	b.h.r.ReportHistogramValueSamples(b.h.name, b.h.tags, nil, 0, b.upper, v)
+}
+
+func TestRollups(t *testing.T) {
+	for _, cached := range []bool{false, true} {
+		r := newRollupRecordingReporter()
+		opts := ScopeOptions{
+			MetricsOption: OmitInternalMetrics,
+			Rollups: []RollupRule{
+				{Name: "svc.requests", DropTags: []string{"host"}},
+				{Name: "svc.lat*", DropTags: []string{"host", "dc"}, Suffix: "_all"},
+			},
+		}
+		if cached {
+			opts.CachedReporter = r
+		} else {
+			opts.Reporter = r
+		}
+		root, closer := NewRootScope(opts, 0)
+		s := root.SubScope("svc")
+
+		a := s.Tagged(map[string]string{"host": "a", "dc": "x"})
+		b := s.Tagged(map[string]string{"host": "b", "dc": "x"})
+		c := s.Tagged(map[string]string{"host": "c", "dc": "y"})
+		a.Counter("requests").Inc(1)
+		b.Counter("requests").Inc(2)
+		c.Counter("requests").Inc(4)
+		s.Tagged(map[string]string{"dc": "x"}).Counter("requests").Inc(8)
+		a.Counter("other").Inc(1)
+		a.Histogram("latency", ValueBuckets{1, 2}).RecordValue(0.5)
+		b.Histogram("latency", ValueBuckets{1, 2}).RecordValue(0.5)
+		c.Histogram("latency", ValueBuckets{1, 2}).RecordValue(1.5)
+
+		root.(*scope).reportRegistry()
+
+		assert.Equal(t, map[string]int64{
+			"svc.requests+dc=x,host=a": 1,
+			"svc.requests+dc=x,host=b": 2,
+			"svc.requests+dc=y,host=c": 4,
+			"svc.requests+dc=x":        8 + 1 + 2,
+			"svc.requests+dc=y":        4,
+			"svc.other+dc=x,host=a":    1,
+		}, r.counters, "cached=%v", cached)
+		assert.Equal(t, map[float64]int64{1: 2, 2: 1}, r.histograms["svc.latency_all+"], "cached=%v", cached)
+		assert.Len(t, r.histograms, 4, "cached=%v", cached)
+
+		// Rolled up values are deltas like the values they are summed from.
+		r.counters = make(map[string]int64)
+		a.Counter("requests").Inc(1)
+		root.(*scope).reportRegistry()
+		assert.Equal(t, map[string]int64{
+			"svc.requests+dc=x,host=a": 1,
+			"svc.requests+dc=x":        1,
+		}, r.counters, "cached=%v", cached)
+
+		assert.NoError(t, closer.Close())
+	}
+}
@@ -0,0 +1,93 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tally
+
+import "sync"
+
+// roots are the open root scopes of the process.
+var roots = newRootRegistry()
+
+// rootRegistry tracks open global root scopes which have a reporter.
+type rootRegistry struct {
+	mu     sync.Mutex
+	scopes map[*scope]struct{}
+}
+
+func newRootRegistry() *rootRegistry {
+	return &rootRegistry{scopes: make(map[*scope]struct{})}
+}
+
+func (r *rootRegistry) register(s *scope) {
+	if s.baseReporter == nil {
+		// Nothing to flush, e.g. test scopes.
+		return
+	}
+	r.mu.Lock()
+	r.scopes[s] = struct{}{}
+	r.mu.Unlock()
+}
+
+func (r *rootRegistry) unregister(s *scope) {
+	r.mu.Lock()
+	delete(r.scopes, s)
+	r.mu.Unlock()
+}
+
+func (r *rootRegistry) list() []Scope {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	scopes := make([]Scope, 0, len(r.scopes))
+	for s := range r.scopes {
+		scopes = append(scopes, s)
+	}
+	return scopes
+}
+
+// RootScopes returns the open root scopes of the process created with
+// ScopeOptions.Global and a reporter, including those created by
+// libraries, in no particular order.
+func RootScopes() []Scope {
+	return roots.list()
+}
+
+// FlushAll reports every open global root scope of the process and flushes
+// their reporters, like Flush.
+func FlushAll() {
+	for _, s := range RootScopes() {
+		_ = Flush(s)
+	}
+}
+
+// CloseAll closes every open global root scope of the process, which
+// reports them a final time and closes their reporters, e.g. at the end of
+// main so that the metrics of root scopes created by libraries aren't lost
+// on exit. It returns the first error closing a root scope, all of them are
+// closed regardless.
+func CloseAll() error {
+	var firstErr error
+	for _, s := range RootScopes() {
+		if err := s.(*scope).Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
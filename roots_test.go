@@ -0,0 +1,84 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tally
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type closeErrorReporter struct {
+	nullStatsReporter
+	flushes int32
+	err     error
+}
+
+func (r *closeErrorReporter) Flush() {
+	atomic.AddInt32(&r.flushes, 1)
+}
+
+func (r *closeErrorReporter) Close() error {
+	return r.err
+}
+
+func withTestRoots(t *testing.T) {
+	prev := roots
+	roots = newRootRegistry()
+	t.Cleanup(func() { roots = prev })
+}
+
+func TestRootScopes(t *testing.T) {
+	withTestRoots(t)
+
+	a, _ := NewRootScope(ScopeOptions{Reporter: NullStatsReporter, Global: true}, 0)
+	b, closer := NewRootScope(ScopeOptions{Reporter: NullStatsReporter, Global: true}, 0)
+	NewRootScope(ScopeOptions{Reporter: NullStatsReporter}, 0)
+	NewTestScope("", nil)
+	assert.ElementsMatch(t, []Scope{a, b}, RootScopes())
+
+	require.NoError(t, closer.Close())
+	assert.Equal(t, []Scope{a}, RootScopes())
+}
+
+func TestFlushAllAndCloseAll(t *testing.T) {
+	withTestRoots(t)
+
+	var (
+		a = &closeErrorReporter{}
+		b = &closeErrorReporter{err: errors.New("closing")}
+	)
+	NewRootScope(ScopeOptions{Reporter: a, Global: true}, 0)
+	NewRootScope(ScopeOptions{Reporter: b, Global: true}, 0)
+
+	FlushAll()
+	assert.EqualValues(t, 1, atomic.LoadInt32(&a.flushes))
+	assert.EqualValues(t, 1, atomic.LoadInt32(&b.flushes))
+
+	assert.Equal(t, b.err, CloseAll())
+	assert.EqualValues(t, 2, atomic.LoadInt32(&a.flushes))
+	assert.EqualValues(t, 2, atomic.LoadInt32(&b.flushes))
+	assert.Empty(t, RootScopes())
+	assert.NoError(t, CloseAll())
+}
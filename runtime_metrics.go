@@ -0,0 +1,194 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tally
+
+import (
+	"io"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultRuntimeMetricsInterval is the default interval the Go runtime
+	// metrics are collected at.
+	DefaultRuntimeMetricsInterval = 10 * time.Second
+	// DefaultRuntimeMetricsPrefix is the default prefix of the subscope the
+	// Go runtime metrics are reported to.
+	DefaultRuntimeMetricsPrefix = "runtime"
+)
+
+// DefaultGCPauseBuckets returns the default buckets of the GC pause
+// histogram, from 10µs to about 1s.
+func DefaultGCPauseBuckets() DurationBuckets {
+	return MustMakeExponentialDurationBuckets(10*time.Microsecond, 4, 9)
+}
+
+// RuntimeMetricsOptions are the options of the Go runtime metrics.
+type RuntimeMetricsOptions struct {
+	// Interval is the interval the metrics are collected at, it defaults
+	// to DefaultRuntimeMetricsInterval.
+	Interval time.Duration
+	// Prefix is the prefix of the subscope the metrics are reported to, it
+	// defaults to DefaultRuntimeMetricsPrefix.
+	Prefix string
+	// GCPauseBuckets are the buckets of the GC pause histogram, they
+	// default to DefaultGCPauseBuckets.
+	GCPauseBuckets DurationBuckets
+	// RuntimeMetrics are the names of runtime/metrics values reported as
+	// gauges, e.g. "/sched/goroutines:goroutines", which is reported as
+	// the gauge "sched_goroutines_goroutines". Only scalar values are
+	// supported, and they require Go 1.16 or later.
+	RuntimeMetrics []string
+}
+
+// RegisterRuntimeMetrics collects the metrics of the Go runtime every
+// interval into a subscope of s, until the returned closer is closed:
+//
+//   - goroutines, the number of goroutines.
+//   - heap_alloc_bytes, heap_inuse_bytes, heap_objects, next_gc_bytes and
+//     sys_bytes, gauges from runtime.MemStats.
+//   - gc_count, a counter of completed GC cycles.
+//   - gc_pause, a histogram of GC stop-the-world pauses.
+//   - the runtime/metrics values of opts.RuntimeMetrics.
+func RegisterRuntimeMetrics(s Scope, opts RuntimeMetricsOptions) io.Closer {
+	if opts.Interval <= 0 {
+		opts.Interval = DefaultRuntimeMetricsInterval
+	}
+	if opts.Prefix == "" {
+		opts.Prefix = DefaultRuntimeMetricsPrefix
+	}
+	if opts.GCPauseBuckets == nil {
+		opts.GCPauseBuckets = DefaultGCPauseBuckets()
+	}
+
+	scope := s.SubScope(opts.Prefix)
+	c := &runtimeCollector{
+		goroutines:     scope.Gauge("goroutines"),
+		heapAlloc:      scope.Gauge("heap_alloc_bytes"),
+		heapInuse:      scope.Gauge("heap_inuse_bytes"),
+		heapObjects:    scope.Gauge("heap_objects"),
+		nextGC:         scope.Gauge("next_gc_bytes"),
+		sys:            scope.Gauge("sys_bytes"),
+		gcCount:        scope.Counter("gc_count"),
+		gcPause:        scope.Histogram("gc_pause", opts.GCPauseBuckets),
+		runtimeMetrics: newRuntimeMetricsReader(opts.RuntimeMetrics),
+		runtimeGauges:  make(map[string]Gauge, len(opts.RuntimeMetrics)),
+		done:           make(chan struct{}),
+	}
+	for _, name := range opts.RuntimeMetrics {
+		c.runtimeGauges[name] = scope.Gauge(runtimeMetricGaugeName(name))
+	}
+
+	// NB: the GC cycles before registration aren't counted.
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	c.lastNumGC = stats.NumGC
+	c.collect()
+
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		c.run(opts.Interval)
+	}()
+	return c
+}
+
+// runtimeMetricGaugeName returns the name of the gauge of a runtime/metrics
+// value, e.g. "sched_goroutines_goroutines" for
+// "/sched/goroutines:goroutines".
+func runtimeMetricGaugeName(name string) string {
+	return strings.NewReplacer("/", "_", ":", "_", "-", "_").
+		Replace(strings.TrimPrefix(name, "/"))
+}
+
+type runtimeCollector struct {
+	goroutines  Gauge
+	heapAlloc   Gauge
+	heapInuse   Gauge
+	heapObjects Gauge
+	nextGC      Gauge
+	sys         Gauge
+	gcCount     Counter
+	gcPause     Histogram
+
+	runtimeMetrics func(report func(name string, value float64))
+	runtimeGauges  map[string]Gauge
+
+	lastNumGC uint32
+
+	closeOnce sync.Once
+	done      chan struct{}
+	wg        sync.WaitGroup
+}
+
+func (c *runtimeCollector) run(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			c.collect()
+		case <-c.done:
+			return
+		}
+	}
+}
+
+func (c *runtimeCollector) collect() {
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+
+	c.goroutines.Update(float64(runtime.NumGoroutine()))
+	c.heapAlloc.Update(float64(stats.HeapAlloc))
+	c.heapInuse.Update(float64(stats.HeapInuse))
+	c.heapObjects.Update(float64(stats.HeapObjects))
+	c.nextGC.Update(float64(stats.NextGC))
+	c.sys.Update(float64(stats.Sys))
+
+	// The pauses of the GC cycles since the last collection are the
+	// latest of the circular buffer of the 256 most recent pauses.
+	cycles := stats.NumGC - c.lastNumGC
+	c.gcCount.Inc(int64(cycles))
+	if cycles > uint32(len(stats.PauseNs)) {
+		cycles = uint32(len(stats.PauseNs))
+	}
+	for i := uint32(0); i < cycles; i++ {
+		idx := (stats.NumGC - i + uint32(len(stats.PauseNs)) - 1) % uint32(len(stats.PauseNs))
+		c.gcPause.RecordDuration(time.Duration(stats.PauseNs[idx]))
+	}
+	c.lastNumGC = stats.NumGC
+
+	c.runtimeMetrics(func(name string, value float64) {
+		c.runtimeGauges[name].Update(value)
+	})
+}
+
+// Close stops collecting the runtime metrics.
+func (c *runtimeCollector) Close() error {
+	c.closeOnce.Do(func() {
+		close(c.done)
+	})
+	c.wg.Wait()
+	return nil
+}
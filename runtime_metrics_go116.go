@@ -0,0 +1,50 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+//go:build go1.16
+// +build go1.16
+
+package tally
+
+import "runtime/metrics"
+
+// newRuntimeMetricsReader returns a function reading the scalar
+// runtime/metrics values with the given names.
+func newRuntimeMetricsReader(names []string) func(report func(name string, value float64)) {
+	samples := make([]metrics.Sample, len(names))
+	for i, name := range names {
+		samples[i].Name = name
+	}
+
+	return func(report func(name string, value float64)) {
+		if len(samples) == 0 {
+			return
+		}
+		metrics.Read(samples)
+		for _, sample := range samples {
+			switch sample.Value.Kind() {
+			case metrics.KindUint64:
+				report(sample.Name, float64(sample.Value.Uint64()))
+			case metrics.KindFloat64:
+				report(sample.Name, sample.Value.Float64())
+			}
+		}
+	}
+}
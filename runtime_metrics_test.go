@@ -0,0 +1,64 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tally
+
+import (
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegisterRuntimeMetrics(t *testing.T) {
+	s := NewTestScope("", nil)
+	closer := RegisterRuntimeMetrics(s, RuntimeMetricsOptions{
+		Interval:       time.Millisecond,
+		RuntimeMetrics: []string{"/sched/goroutines:goroutines"},
+	})
+
+	runtime.GC()
+	assert.Eventually(t, func() bool {
+		snap := s.Snapshot()
+		count, ok := snap.Counters()["runtime.gc_count+"]
+		return ok && count.Value() > 0
+	}, time.Second, time.Millisecond)
+	require.NoError(t, closer.Close())
+	require.NoError(t, closer.Close())
+
+	snap := s.Snapshot()
+	gauges := snap.Gauges()
+	assert.True(t, gauges["runtime.goroutines+"].Value() > 0)
+	assert.True(t, gauges["runtime.heap_alloc_bytes+"].Value() > 0)
+	assert.True(t, gauges["runtime.sched_goroutines_goroutines+"].Value() > 0)
+
+	var pauses int64
+	for _, n := range snap.Histograms()["runtime.gc_pause+"].Durations() {
+		pauses += n
+	}
+	assert.True(t, pauses > 0)
+}
+
+func TestRuntimeMetricGaugeName(t *testing.T) {
+	assert.Equal(t, "gc_heap_allocs_bytes", runtimeMetricGaugeName("/gc/heap/allocs:bytes"))
+	assert.Equal(t, "sched_gomaxprocs_threads", runtimeMetricGaugeName("/sched/gomaxprocs:threads"))
+}
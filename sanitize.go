@@ -22,6 +22,7 @@ package tally
 
 import (
 	"bytes"
+	"strings"
 	"sync"
 )
 
@@ -75,6 +76,12 @@ type SanitizeOptions struct {
 }
 
 // Sanitizer sanitizes the provided input based on the function executed.
+//
+// The sanitizers of this package are total, they return a result for any
+// input including invalid UTF-8 without panicking, and idempotent,
+// sanitizing an already sanitized input returns it unchanged. This allows
+// identifiers to be sanitized ahead of time, e.g. with ScopeSanitizer, and
+// passed to a scope sanitizing them again without changing them.
 type Sanitizer interface {
 	// Name sanitizes the provided `name` string.
 	Name(n string) string
@@ -107,6 +114,64 @@ func NewNoOpSanitizer() Sanitizer {
 	}
 }
 
+// NewSanitizerFromFuncs returns a sanitizer sanitizing names, keys and values
+// with the given functions, a nil function returns its input untouched.
+func NewSanitizerFromFuncs(nameFn, keyFn, valueFn SanitizeFn) Sanitizer {
+	if nameFn == nil {
+		nameFn = NoOpSanitizeFn
+	}
+	if keyFn == nil {
+		keyFn = NoOpSanitizeFn
+	}
+	if valueFn == nil {
+		valueFn = NoOpSanitizeFn
+	}
+	return sanitizer{
+		nameFn:  nameFn,
+		keyFn:   keyFn,
+		valueFn: valueFn,
+	}
+}
+
+// ScopeSanitizer returns the sanitizer of s, which applies the rules s
+// sanitizes metric names and tags with, or a sanitizer returning its inputs
+// untouched if s wasn't created by this package.
+func ScopeSanitizer(s Scope) Sanitizer {
+	switch v := s.(type) {
+	case *scope:
+		return v.sanitizer
+	case *leveledScope:
+		return v.scope.sanitizer
+	default:
+		return NewNoOpSanitizer()
+	}
+}
+
+// ComposeSanitizeFns returns a function applying fns in order. The result
+// is idempotent if every function is idempotent and none of them produces
+// output which an earlier function would change, e.g. truncating after
+// replacing characters.
+func ComposeSanitizeFns(fns ...SanitizeFn) SanitizeFn {
+	return func(v string) string {
+		for _, fn := range fns {
+			v = fn(v)
+		}
+		return v
+	}
+}
+
+// LowercaseSanitizeFn returns the input with all letters lowercased.
+func LowercaseSanitizeFn(v string) string {
+	return strings.ToLower(v)
+}
+
+// TruncateSanitizeFn returns a function truncating its input to at most
+// maxBytes bytes, without splitting multi byte characters, like
+// TruncateTagValue.
+func TruncateSanitizeFn(maxBytes int) SanitizeFn {
+	return SanitizeFn(TruncateTagValue(maxBytes))
+}
+
 type sanitizer struct {
 	nameFn  SanitizeFn
 	keyFn   SanitizeFn
@@ -140,6 +205,12 @@ func putSanitizeBuffer(b *bytes.Buffer) {
 	_sanitizeBuffers.Put(b)
 }
 
+// SanitizeFn returns a function replacing the characters of its input which
+// aren't valid characters with repChar.
+func (c ValidCharacters) SanitizeFn(repChar rune) SanitizeFn {
+	return c.sanitizeFn(repChar)
+}
+
 func (c *ValidCharacters) sanitizeFn(repChar rune) SanitizeFn {
 	return func(value string) string {
 		var buf *bytes.Buffer
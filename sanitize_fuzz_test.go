@@ -0,0 +1,46 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+//go:build go1.18
+// +build go1.18
+
+package tally
+
+import "testing"
+
+func FuzzSanitizer(f *testing.F) {
+	for _, seed := range []string{"", "foo", "foo.bar", "a! b", "\xff\xfe", "日本"} {
+		f.Add(seed)
+	}
+
+	sanitizers := []SanitizeFn{
+		newTestSanitizer(),
+		ValidCharacters{Ranges: AlphanumericRange}.SanitizeFn('.'),
+		ComposeSanitizeFns(LowercaseSanitizeFn, newTestSanitizer(), TruncateSanitizeFn(8)),
+	}
+	f.Fuzz(func(t *testing.T, v string) {
+		for _, fn := range sanitizers {
+			once := fn(v)
+			if twice := fn(once); twice != once {
+				t.Fatalf("sanitizing %q is not idempotent: %q then %q", v, once, twice)
+			}
+		}
+	})
+}
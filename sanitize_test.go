@@ -59,6 +59,50 @@ func TestSanitizeTestCases(t *testing.T) {
 	}
 }
 
+func TestComposeSanitizeFns(t *testing.T) {
+	fn := ComposeSanitizeFns(
+		LowercaseSanitizeFn,
+		ValidCharacters{Ranges: AlphanumericRange}.SanitizeFn('_'),
+		TruncateSanitizeFn(5),
+	)
+	require.Equal(t, "ab_cd", fn("AB-CDEF"))
+	require.Equal(t, "ab_cd", fn(fn("AB-CDEF")))
+}
+
+func TestTruncateSanitizeFn(t *testing.T) {
+	fn := TruncateSanitizeFn(4)
+	require.Equal(t, "abc", fn("abc"))
+	require.Equal(t, "abcd", fn("abcde"))
+	// The 2 byte é isn't split.
+	require.Equal(t, "abc", fn("abcé"))
+	require.Equal(t, "", TruncateSanitizeFn(0)("abc"))
+	require.Equal(t, "", TruncateSanitizeFn(-1)("abc"))
+}
+
+func TestNewSanitizerFromFuncs(t *testing.T) {
+	s := NewSanitizerFromFuncs(LowercaseSanitizeFn, nil, TruncateSanitizeFn(1))
+	require.Equal(t, "abc", s.Name("ABC"))
+	require.Equal(t, "ABC", s.Key("ABC"))
+	require.Equal(t, "A", s.Value("ABC"))
+}
+
+func TestScopeSanitizer(t *testing.T) {
+	root, closer := NewRootScope(ScopeOptions{
+		SanitizeOptions: &alphanumericSanitizerOpts,
+	}, 0)
+	defer closer.Close()
+
+	s := ScopeSanitizer(root)
+	name := s.Name("foo.bar")
+	require.Equal(t, "foo_bar", name)
+	root.Counter(name).Inc(1)
+	require.Contains(t, root.(TestScope).Snapshot().Counters(), "foo_bar+")
+
+	leveled := root.(LeveledScope).AtLevel(DebugLevel)
+	require.Equal(t, "foo_bar", ScopeSanitizer(leveled).Name("foo.bar"))
+	require.Equal(t, "foo.bar", ScopeSanitizer(NoopScope).Name("foo.bar"))
+}
+
 func BenchmarkSanitizeFn(b *testing.B) {
 	sanitize := newTestSanitizer()
 	b.ResetTimer()
@@ -21,13 +21,46 @@
 package tally
 
 import (
+	"context"
 	"io"
+	"math/rand"
+	"sort"
 	"sync"
 	"time"
 
 	"go.uber.org/atomic"
 )
 
+// WriteAfterClosePolicy is the behavior of metrics written to after their
+// scope was closed.
+type WriteAfterClosePolicy int
+
+const (
+	// DropWritesAfterClose reports writes made between closing a scope and
+	// the next report, later writes are dropped. This is the default.
+	DropWritesAfterClose WriteAfterClosePolicy = iota
+	// PanicOnWriteAfterClose panics on writes which would be dropped,
+	// which is useful to catch shutdown ordering bugs in tests.
+	PanicOnWriteAfterClose
+	// BufferWritesAfterClose keeps reporting closed scopes until their root
+	// scope is closed, so that writes made after closing a scope are
+	// reported at the latest by the final flush of the root scope. Closed
+	// scopes are only released once the root scope is closed.
+	BufferWritesAfterClose
+)
+
+// Temporality is how the values of counters are reported.
+type Temporality int
+
+const (
+	// DeltaTemporality reports the increments of counters since their
+	// last report. This is the default.
+	DeltaTemporality Temporality = iota
+	// CumulativeTemporality reports the totals of counters since they
+	// were created, as monotonically increasing values.
+	CumulativeTemporality
+)
+
 // InternalMetricOption is used to configure internal metrics.
 type InternalMetricOption int
 
@@ -44,8 +77,10 @@ const (
 )
 
 var (
-	// NoopScope is a scope that does nothing
-	NoopScope, _ = NewRootScope(ScopeOptions{Reporter: NullStatsReporter}, 0)
+	// NoopScope is a scope that does nothing. All of its methods, including
+	// Tagged and SubScope, return without allocating so that libraries can
+	// instrument unconditionally and default to NoopScope.
+	NoopScope Scope = noopScope{}
 	// DefaultSeparator is the default separator used to join nested scopes
 	DefaultSeparator = "."
 
@@ -77,8 +112,16 @@ type scope struct {
 	reporter       StatsReporter
 	cachedReporter CachedStatsReporter
 	baseReporter   BaseStatsReporter
-	defaultBuckets Buckets
-	sanitizer      Sanitizer
+	// contextReporter is the adapter of the root's ContextStatsReporter,
+	// if it has one.
+	contextReporter *contextReporter
+	defaultBuckets  Buckets
+	sanitizer       Sanitizer
+	filter          *MetricFilter
+	tagTransforms   map[string]TagValueTransform
+
+	padHistogramBuckets bool
+	sortedReporting     bool
 
 	registry *scopeRegistry
 
@@ -87,13 +130,30 @@ type scope struct {
 	tm sync.RWMutex
 	hm sync.RWMutex
 
-	counters        map[string]*counter
-	countersSlice   []*counter
-	gauges          map[string]*gauge
-	gaugesSlice     []*gauge
+	counters      map[string]*counter
+	countersSlice []*counter
+	// floatCounters are guarded by cm, they are allocated lazily.
+	floatCounters      map[string]*floatCounter
+	floatCountersSlice []*floatCounter
+	// rates are guarded by cm, they are allocated lazily.
+	rates       map[string]*rate
+	ratesSlice  []*rate
+	gauges      map[string]*gauge
+	gaugesSlice []*gauge
+	// gaugeFuncs are guarded by gm, they are allocated lazily.
+	gaugeFuncs map[string]gaugeFunc
+	// sets are guarded by gm, they are allocated lazily.
+	sets            map[string]*set
+	setsSlice       []*set
 	histograms      map[string]*histogram
 	histogramsSlice []*histogram
-	timers          map[string]*timer
+	// gaugeHistograms are guarded by hm, they are allocated lazily.
+	gaugeHistograms      map[string]*gaugeHistogram
+	gaugeHistogramsSlice []*gaugeHistogram
+	// summaries are guarded by hm, they are allocated lazily.
+	summaries      map[string]*summary
+	summariesSlice []*summary
+	timers         map[string]*timer
 	// nb: deliberately skipping timersSlice as we report timers immediately,
 	// no buffering is involved.
 
@@ -102,6 +162,13 @@ type scope struct {
 	done        chan struct{}
 	wg          sync.WaitGroup
 	root        bool
+	group       *ScopeGroup
+	guard       *closeGuard
+	// transient scopes are removed from the registry once reported.
+	transient bool
+	// overflowed are the names routed to overflow scopes by the tag
+	// cardinality limit, see overflowScope.
+	overflowed sync.Map
 }
 
 // ScopeOptions is a set of options to construct a scope.
@@ -115,6 +182,196 @@ type ScopeOptions struct {
 	SanitizeOptions    *SanitizeOptions
 	registryShardCount uint
 	MetricsOption      InternalMetricOption
+
+	// MetricFilter if set disables metrics whose names match its
+	// patterns, such metrics are created as noops.
+	MetricFilter *MetricFilter
+
+	// Level is the initial level threshold of the scope, metrics created
+	// via AtLevel with a more verbose level discard their values.
+	Level Level
+
+	// OnWriteAfterClose if set is called with the prefix and tags of a
+	// scope whenever one of its metrics is written to after the scope was
+	// closed and its metrics were reported for the last time. Such writes
+	// are lost, they are also counted by an internal metric.
+	OnWriteAfterClose func(prefix string, tags map[string]string)
+
+	// WriteAfterClosePolicy is the behavior of metrics written to after
+	// their scope was closed.
+	WriteAfterClosePolicy WriteAfterClosePolicy
+
+	// PadHistogramBuckets pads histogram bucket counters to a cache line
+	// each. This avoids false sharing between adjacent buckets recorded to
+	// concurrently, at the cost of memory per histogram.
+	PadHistogramBuckets bool
+
+	// SortedReporting reports scopes ordered by their prefix and tags, and
+	// the metrics of each scope ordered by name, on every flush. This makes
+	// the output of reporters stable, e.g. for golden file tests, at the
+	// cost of sorting on each flush.
+	SortedReporting bool
+
+	// TagValueTransforms are transforms applied by sanitized tag key to the
+	// values of tags of the scope and its subscopes before they are
+	// registered.
+	TagValueTransforms map[string]TagValueTransform
+
+	// Rollups are rules for counters and histograms to additionally report
+	// summed across some of their tags.
+	Rollups []RollupRule
+
+	// TagConflictPolicy is the behavior of child scopes created with a tag
+	// conflicting with a tag of their parent.
+	TagConflictPolicy TagConflictPolicy
+
+	// Tap if set receives a copy of every metric value reported.
+	Tap Tap
+
+	// Governor if set throttles the reporting loop while the process is
+	// under memory pressure.
+	Governor *GovernorOptions
+
+	// ReportTiers are additional reporters with their own reporting
+	// intervals.
+	ReportTiers []ReportTier
+
+	// ContextReporter is a reporter whose methods take the context of the
+	// report, it is used if neither Reporter nor CachedReporter are set.
+	ContextReporter ContextStatsReporter
+
+	// StrictNames makes creating a metric whose name wasn't built with N
+	// panic, to catch metric names built dynamically in tests.
+	StrictNames bool
+
+	// ReportZeroValues reports counters and histogram buckets every
+	// interval once they are created, with zero values when they weren't
+	// incremented, rather than only after their first increment. This
+	// keeps series present right after deploys for dashboards and
+	// absence based alerts.
+	ReportZeroValues bool
+
+	// TagProviders are tags whose values are computed by their provider
+	// every time a value is reported, rather than fixed when a scope is
+	// created, so that slowly changing dimensions stay current. Tags of
+	// the metrics take precedence over provided tags with the same key.
+	// They only apply to Reporter and ContextReporter, as cached reporters
+	// allocate their metrics with fixed tags.
+	//
+	// A series is identified by its provided tags at the backend, a
+	// provided value change starts a new series from the next report.
+	TagProviders map[string]TagProvider
+
+	// ReportTagsHook if set returns the tags with which values are
+	// reported, after TagProviders are applied, without changing the
+	// identity of metrics in the registry. Like TagProviders, it only
+	// applies to Reporter and ContextReporter.
+	//
+	// The backend identifies series by their reported tags: the value of
+	// a metric reported with tags returned by the hook is attributed
+	// entirely to those tags, e.g. the delta of a counter since the
+	// previous report. Hooks must not return the same tags for distinct
+	// metrics of the same name, as the backend would then receive several
+	// values for a single series in each report.
+	ReportTagsHook ReportTagsHook
+
+	// MetricSlabSize if positive allocates counters, gauges and histogram
+	// buckets in slabs of this many metrics rather than individually,
+	// which reduces the garbage collection cost of registries with
+	// millions of series. Slabs are only released once none of their
+	// metrics is referenced, so the metrics of closed scopes may be
+	// retained. This option is experimental.
+	MetricSlabSize int
+
+	// LazyCachedAllocation defers allocating the metrics of CachedReporter
+	// until they are first reported, rather than when they are created,
+	// which reduces the startup cost of declaring many metrics upfront.
+	// The metrics first reported during a report are allocated at the end
+	// of the report, in a single batch if CachedReporter is a
+	// BatchCachedStatsReporter.
+	LazyCachedAllocation bool
+
+	// CounterRateSuffix if set reports a gauge alongside every counter,
+	// named after the counter with this suffix appended, e.g.
+	// "_per_second", holding the per second rate of the counter between
+	// the last two reports. It is meant for backends and dashboards that
+	// can't compute rates from counters.
+	CounterRateSuffix string
+
+	// TimerThresholds are rules counting rather than recording the short
+	// durations recorded to timers.
+	TimerThresholds []TimerThresholdRule
+
+	// HistogramOutliers are rules clamping or dropping the absurd values
+	// recorded to histograms.
+	HistogramOutliers []HistogramOutlierRule
+
+	// HistogramResolutions are rules recording the values of histograms
+	// to additional histograms with finer buckets.
+	HistogramResolutions []HistogramResolutionRule
+
+	// NoiseRules are rules adding noise to the values reported for the
+	// metrics derived from user behavior.
+	NoiseRules []NoiseRule
+
+	// OnNamespaceConflict if set is called with the conflicts detected
+	// by ReserveNamespace, which are also counted by an internal metric.
+	OnNamespaceConflict func(NamespaceConflict)
+
+	// OnReportError if set is called with the errors of the scope's
+	// reporter if it is a ReportErrorNotifier, e.g. failed flushes, and
+	// with the errors flushing its ContextReporter. The errors are also
+	// counted by an internal metric. It is called by the reporter,
+	// possibly while it is flushing, and must not block.
+	OnReportError func(error)
+
+	// ReportTracer if set traces the registry walk and flush phases of
+	// the scope's reports. Reporters accepting a ReportTracer trace their
+	// own phases.
+	ReportTracer ReportTracer
+
+	// MetricTTL if positive removes the counters, gauges and histograms
+	// which weren't written to within the TTL from the registry, so that
+	// metrics with ephemeral tags, e.g. pod names, don't grow the registry
+	// forever. Writes to an expired metric are lost, so such metrics should
+	// be looked up from their scope on every use rather than kept.
+	MetricTTL time.Duration
+
+	// MaxTagCardinality if positive caps the distinct sets of tags each
+	// metric name is created with. Metrics past the cap are routed to a
+	// single series tagged overflow=true, and counted by an internal
+	// metric. The tags of removed and expired metrics, and of the metrics
+	// of closed scopes, no longer count towards the cap.
+	MaxTagCardinality int
+
+	// SeriesChurn if set tracks the series created and evicted per tag
+	// key, to find the tags churning the series of the backend.
+	SeriesChurn *SeriesChurnOptions
+
+	// Global registers the root scope in the process wide list of
+	// RootScopes until it is closed, so that FlushAll and CloseAll
+	// report it, e.g. for the root scopes of libraries. Root scopes that
+	// aren't global are not referenced by the package once unused, even
+	// when they are never closed.
+	Global bool
+
+	// Drainable tracks the writes in flight to the metrics of the scope
+	// so that Drain can wait for them, at the cost of two more atomic
+	// operations per write. Drain fails unless it is set.
+	Drainable bool
+
+	// QuietStart if positive suppresses the periodic reports of the scope
+	// for this long after it is created, while metrics keep accumulating
+	// their values, so that the first report after a restart covers a
+	// whole window rather than a partial interval skewing rates and
+	// dashboards. Flush and Close still report the scope, and timers,
+	// which are reported as they are recorded, aren't suppressed.
+	QuietStart time.Duration
+
+	// CounterTemporality is how the values of counters are reported to
+	// the reporter, as deltas by default. Reporters accumulating deltas
+	// themselves, such as the Prometheus and OTLP reporters, expect deltas.
+	CounterTemporality Temporality
 }
 
 // NewRootScope creates a new root Scope with a set of options and
@@ -154,6 +411,12 @@ func newRootScope(opts ScopeOptions, interval time.Duration) *scope {
 		opts.Separator = DefaultSeparator
 	}
 
+	var contextReporter *contextReporter
+	if opts.Reporter == nil && opts.CachedReporter == nil && opts.ContextReporter != nil {
+		contextReporter = newContextReporter(opts.ContextReporter)
+		opts.Reporter = contextReporter
+	}
+
 	var baseReporter BaseStatsReporter
 	if opts.Reporter != nil {
 		baseReporter = opts.Reporter
@@ -161,12 +424,63 @@ func newRootScope(opts ScopeOptions, interval time.Duration) *scope {
 		baseReporter = opts.CachedReporter
 	}
 
+	summaries := reportsSummaries(opts.Reporter, opts.CachedReporter)
+
 	if opts.DefaultBuckets == nil || opts.DefaultBuckets.Len() < 1 {
 		opts.DefaultBuckets = defaultScopeBuckets
 	}
 
+	// NB: the values are stamped by the innermost wrapper, so that the
+	// values reported by every other wrapper are stamped too.
+	clock := &reportClock{}
+	backfillReporters := timestampedReporters(opts.Reporter)
+	if tr, ok := opts.Reporter.(TimestampedStatsReporter); ok {
+		opts.Reporter = timestampedReporter{TimestampedStatsReporter: tr, clock: clock}
+	}
+
+	// NB: noise is added first, so that taps and every other wrapper see
+	// the values actually exported.
+	if n := newNoise(opts.NoiseRules, rand.NewSource(time.Now().UnixNano())); n != nil {
+		if opts.Reporter != nil {
+			opts.Reporter = noiseReporter{StatsReporter: opts.Reporter, noise: n}
+		}
+		if opts.CachedReporter != nil {
+			opts.CachedReporter = noiseCachedReporter{CachedStatsReporter: opts.CachedReporter, noise: n}
+		}
+	}
+
+	if opts.Tap != nil {
+		// NB: the base reporter is left untapped, it is only used to
+		// flush and close the reporter.
+		if opts.Reporter != nil {
+			opts.Reporter = tapReporter{StatsReporter: opts.Reporter, tap: opts.Tap}
+		}
+		if opts.CachedReporter != nil {
+			opts.CachedReporter = tapCachedReporter{CachedStatsReporter: opts.CachedReporter, tap: opts.Tap}
+		}
+	}
+
+	// NB: lazy allocation wraps the tapped reporter so that the metrics it
+	// allocates are deferred too.
+	var lazy *lazyCachedReporter
+	if opts.LazyCachedAllocation && opts.CachedReporter != nil {
+		lazy = newLazyCachedReporter(opts.CachedReporter)
+		opts.CachedReporter = lazy
+	}
+
+	// NB: the tags are augmented before being tapped, so that taps see the
+	// reported tags.
+	augment := newReportTagsAugment(opts.TagProviders, opts.ReportTagsHook, sanitizer)
+	if augment != nil && opts.Reporter != nil {
+		opts.Reporter = augmentTagsReporter{
+			StatsReporter: opts.Reporter,
+			augment:       augment,
+		}
+	}
+
 	s := &scope{
 		baseReporter:    baseReporter,
+		contextReporter: contextReporter,
 		bucketCache:     newBucketCache(),
 		cachedReporter:  opts.CachedReporter,
 		counters:        make(map[string]*counter),
@@ -183,6 +497,11 @@ func newRootScope(opts ScopeOptions, interval time.Duration) *scope {
 		separator:       sanitizer.Name(opts.Separator),
 		timers:          make(map[string]*timer),
 		root:            true,
+		filter:          opts.MetricFilter,
+		tagTransforms:   copyTagValueTransforms(opts.TagValueTransforms),
+
+		padHistogramBuckets: opts.PadHistogramBuckets,
+		sortedReporting:     opts.SortedReporting,
 	}
 
 	// NB(r): Take a copy of the tags on creation
@@ -191,8 +510,44 @@ func newRootScope(opts ScopeOptions, interval time.Duration) *scope {
 
 	// Register the root scope
 	s.registry = newScopeRegistryWithShardCount(s, opts.registryShardCount, opts.MetricsOption)
+	s.registry.level.Store(int32(opts.Level))
+	s.registry.onWriteAfterClose = opts.OnWriteAfterClose
+	s.registry.writeAfterClosePolicy = opts.WriteAfterClosePolicy
+	s.registry.rollups = newRollups(opts.Rollups)
+	s.registry.tagConflictPolicy = opts.TagConflictPolicy
+	s.registry.governor = newGovernor(opts.Governor)
+	s.registry.tiers = newReportTiers(opts.ReportTiers)
+	s.registry.strictNames = opts.StrictNames
+	s.registry.reportZeroValues = opts.ReportZeroValues
+	s.registry.slabs = newMetricSlabs(opts.MetricSlabSize)
+	s.registry.lazy = lazy
+	s.registry.timerThresholds = opts.TimerThresholds
+	s.registry.histogramOutliers = opts.HistogramOutliers
+	s.registry.histogramResolutions = opts.HistogramResolutions
+	s.registry.onNamespaceConflict = opts.OnNamespaceConflict
+	s.registry.tracer = opts.ReportTracer
+	s.registry.metricTTL = opts.MetricTTL
+	s.registry.cardinality = newTagCardinality(opts.MaxTagCardinality, s)
+	s.registry.churn = newSeriesChurn(opts.SeriesChurn, s)
+	s.registry.counterTemporality = opts.CounterTemporality
+	s.registry.clock = clock
+	s.registry.backfillReporters = backfillReporters
+	s.registry.notifyReportErrors(baseReporter, opts.OnReportError)
+	s.registry.flushed = make(chan struct{})
+	s.registry.reportsSummaries = summaries
+	if opts.Drainable {
+		s.registry.drained = make(chan struct{}, 1)
+	}
+	s.guard = newCloseGuard(s)
+	if opts.QuietStart > 0 {
+		s.registry.quietUntil = globalNow().Add(opts.QuietStart)
+	}
+	if opts.CounterRateSuffix != "" {
+		s.registry.counterRateSuffix = s.sanitizer.Name(opts.CounterRateSuffix)
+	}
 
 	if interval > 0 {
+		s.registry.initReportIntervalHistogram(interval)
 		s.wg.Add(1)
 		go func() {
 			defer s.wg.Done()
@@ -200,21 +555,52 @@ func newRootScope(opts ScopeOptions, interval time.Duration) *scope {
 		}()
 	}
 
+	for _, tier := range s.registry.tiers {
+		tier := tier
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			runReportLoop(tier.interval, s.done, func() {
+				if s.registry.suppressed(globalNow()) {
+					return
+				}
+				tier.report(s.registry)
+			})
+		}()
+	}
+
+	if opts.Global {
+		roots.register(s)
+	}
 	return s
 }
 
 // report dumps all aggregated stats into the reporter. Should be called automatically by the root scope periodically.
 func (s *scope) report(r StatsReporter) {
+	if s.sortedReporting {
+		s.reportSorted(r)
+		return
+	}
+
 	s.cm.RLock()
 	for name, counter := range s.counters {
 		counter.report(s.fullyQualifiedName(name), s.tags, r)
 	}
+	for name, counter := range s.floatCounters {
+		counter.report(s.fullyQualifiedName(name), s.tags, r)
+	}
+	for name, rate := range s.rates {
+		rate.report(s.fullyQualifiedName(name), s.tags, r)
+	}
 	s.cm.RUnlock()
 
 	s.gm.RLock()
 	for name, gauge := range s.gauges {
 		gauge.report(s.fullyQualifiedName(name), s.tags, r)
 	}
+	for name, set := range s.sets {
+		set.report(s.fullyQualifiedName(name), s.tags, r)
+	}
 	s.gm.RUnlock()
 
 	// we do nothing for timers here because timers report directly to ths StatsReporter without buffering
@@ -223,20 +609,77 @@ func (s *scope) report(r StatsReporter) {
 	for name, histogram := range s.histograms {
 		histogram.report(s.fullyQualifiedName(name), s.tags, r)
 	}
+	for name, histogram := range s.gaugeHistograms {
+		histogram.report(s.fullyQualifiedName(name), s.tags, r)
+	}
+	// NB: summaries report to the scope's reporter directly, like timers.
+	for _, summary := range s.summariesSlice {
+		summary.report()
+	}
+	s.hm.RUnlock()
+}
+
+// reportSorted is report with metrics reported in name order.
+func (s *scope) reportSorted(r StatsReporter) {
+	s.cm.RLock()
+	for _, name := range sortedCounterNames(s.counters) {
+		s.counters[name].report(s.fullyQualifiedName(name), s.tags, r)
+	}
+	for _, name := range sortedFloatCounterNames(s.floatCounters) {
+		s.floatCounters[name].report(s.fullyQualifiedName(name), s.tags, r)
+	}
+	for _, name := range sortedRateNames(s.rates) {
+		s.rates[name].report(s.fullyQualifiedName(name), s.tags, r)
+	}
+	s.cm.RUnlock()
+
+	s.gm.RLock()
+	for _, name := range sortedGaugeNames(s.gauges) {
+		s.gauges[name].report(s.fullyQualifiedName(name), s.tags, r)
+	}
+	for _, name := range sortedSetNames(s.sets) {
+		s.sets[name].report(s.fullyQualifiedName(name), s.tags, r)
+	}
+	s.gm.RUnlock()
+
+	s.hm.RLock()
+	for _, name := range sortedHistogramNames(s.histograms) {
+		s.histograms[name].report(s.fullyQualifiedName(name), s.tags, r)
+	}
+	for _, name := range sortedGaugeHistogramNames(s.gaugeHistograms) {
+		s.gaugeHistograms[name].report(s.fullyQualifiedName(name), s.tags, r)
+	}
+	for _, name := range sortedSummaryNames(s.summaries) {
+		s.summaries[name].report()
+	}
 	s.hm.RUnlock()
 }
 
 func (s *scope) cachedReport() {
+	if s.sortedReporting {
+		s.cachedReportSorted()
+		return
+	}
+
 	s.cm.RLock()
 	for _, counter := range s.countersSlice {
 		counter.cachedReport()
 	}
+	for _, counter := range s.floatCountersSlice {
+		counter.cachedReport()
+	}
+	for _, rate := range s.ratesSlice {
+		rate.cachedReport()
+	}
 	s.cm.RUnlock()
 
 	s.gm.RLock()
 	for _, gauge := range s.gaugesSlice {
 		gauge.cachedReport()
 	}
+	for _, set := range s.setsSlice {
+		set.cachedReport()
+	}
 	s.gm.RUnlock()
 
 	// we do nothing for timers here because timers report directly to ths StatsReporter without buffering
@@ -245,24 +688,142 @@ func (s *scope) cachedReport() {
 	for _, histogram := range s.histogramsSlice {
 		histogram.cachedReport()
 	}
+	for _, histogram := range s.gaugeHistogramsSlice {
+		histogram.cachedReport()
+	}
+	for _, summary := range s.summariesSlice {
+		summary.report()
+	}
 	s.hm.RUnlock()
 }
 
-// reportLoop is used by the root scope for periodic reporting
+// cachedReportSorted is cachedReport with metrics reported in name order.
+func (s *scope) cachedReportSorted() {
+	s.cm.RLock()
+	for _, name := range sortedCounterNames(s.counters) {
+		s.counters[name].cachedReport()
+	}
+	for _, name := range sortedFloatCounterNames(s.floatCounters) {
+		s.floatCounters[name].cachedReport()
+	}
+	for _, name := range sortedRateNames(s.rates) {
+		s.rates[name].cachedReport()
+	}
+	s.cm.RUnlock()
+
+	s.gm.RLock()
+	for _, name := range sortedGaugeNames(s.gauges) {
+		s.gauges[name].cachedReport()
+	}
+	for _, name := range sortedSetNames(s.sets) {
+		s.sets[name].cachedReport()
+	}
+	s.gm.RUnlock()
+
+	s.hm.RLock()
+	for _, name := range sortedHistogramNames(s.histograms) {
+		s.histograms[name].cachedReport()
+	}
+	for _, name := range sortedGaugeHistogramNames(s.gaugeHistograms) {
+		s.gaugeHistograms[name].cachedReport()
+	}
+	for _, name := range sortedSummaryNames(s.summaries) {
+		s.summaries[name].report()
+	}
+	s.hm.RUnlock()
+}
+
+func sortedCounterNames(m map[string]*counter) []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func sortedGaugeNames(m map[string]*gauge) []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func sortedHistogramNames(m map[string]*histogram) []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// reportLoop is used by the root scope for periodic reporting.
 func (s *scope) reportLoop(interval time.Duration) {
-	ticker := time.NewTicker(interval)
-	defer ticker.Stop()
+	var lastReport time.Time
+	runReportLoop(interval, s.done, func() {
+		now := globalNow()
+		if s.registry.suppressed(now) {
+			// NB: the pause isn't recorded as a report interval.
+			lastReport = time.Time{}
+			return
+		}
+		if g := s.registry.governor; g != nil && g.skip(now) {
+			return
+		}
+		if !lastReport.IsZero() {
+			s.registry.RecordReportInterval(now.Sub(lastReport))
+		}
+		lastReport = now
+
+		if s.contextReporter == nil {
+			s.reportLoopRun()
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), interval)
+		_ = s.reportContext(ctx)
+		cancel()
+	})
+}
+
+// runReportLoop calls report every interval until done is closed. Reports
+// are scheduled against fixed boundaries derived from the loop start time
+// so that a slow report only delays the current report rather than
+// shifting every subsequent one.
+func runReportLoop(interval time.Duration, done <-chan struct{}, report func()) {
+	var (
+		next  = globalNow().Add(interval)
+		timer = time.NewTimer(interval)
+	)
+	defer timer.Stop()
 
 	for {
 		select {
-		case <-ticker.C:
-			s.reportLoopRun()
-		case <-s.done:
+		case <-timer.C:
+			report()
+
+			now := globalNow()
+			next = nextReportTime(next, now, interval)
+			timer.Reset(next.Sub(now))
+		case <-done:
 			return
 		}
 	}
 }
 
+// nextReportTime returns the first report boundary strictly after now,
+// skipping any boundaries that were missed while reporting.
+func nextReportTime(prev, now time.Time, interval time.Duration) time.Time {
+	next := prev.Add(interval)
+	if next.After(now) {
+		return next
+	}
+	missed := now.Sub(next)/interval + 1
+	return next.Add(missed * interval)
+}
+
 func (s *scope) reportLoopRun() {
 	if s.closed.Load() {
 		return
@@ -272,12 +833,27 @@ func (s *scope) reportLoopRun() {
 }
 
 func (s *scope) reportRegistry() {
+	s.reportRegistryWithoutFlush()
+	if s.baseReporter != nil {
+		errs := s.registry.totalReportErrors.Load()
+		end := StartReportPhase(s.registry.tracer, FlushPhase)
+		s.baseReporter.Flush()
+		end()
+		if s.registry.totalReportErrors.Load() == errs {
+			s.registry.markFlushed()
+		}
+	}
+}
+
+// reportRegistryWithoutFlush reports the registry to the reporter but
+// leaves flushing the reporter to the caller.
+func (s *scope) reportRegistryWithoutFlush() {
+	defer StartReportPhase(s.registry.tracer, RegistryWalkPhase)()
+
 	if s.reporter != nil {
 		s.registry.Report(s.reporter)
-		s.reporter.Flush()
 	} else if s.cachedReporter != nil {
 		s.registry.CachedReport()
-		s.cachedReporter.Flush()
 	}
 }
 
@@ -287,6 +863,14 @@ func (s *scope) Counter(name string) Counter {
 		return c
 	}
 
+	s.registry.checkName(name)
+	if !s.metricEnabled(name) {
+		return noopMetric{}
+	}
+	if o, ok := s.overflowScope(counterSeries, name); ok {
+		return o.Counter(name)
+	}
+
 	s.cm.Lock()
 	defer s.cm.Unlock()
 
@@ -300,10 +884,30 @@ func (s *scope) Counter(name string) Counter {
 			s.fullyQualifiedName(name),
 			s.tags,
 		)
+		cachedCounter = s.registry.rollups.wrapCount(
+			s.fullyQualifiedName(name), s.tags, cachedCounter,
+		)
 	}
 
-	c := newCounter(cachedCounter)
+	c := s.registry.slabs.counter(cachedCounter)
+	c.guard = s.guard
+	if s.registry.reportZeroValues {
+		c.reportZero = 1
+	}
+	if s.registry.counterTemporality == CumulativeTemporality {
+		c.cumulative = true
+	}
+	if suffix := s.registry.counterRateSuffix; suffix != "" {
+		var cachedGauge CachedGauge
+		if s.cachedReporter != nil {
+			cachedGauge = s.cachedReporter.AllocateGauge(
+				s.fullyQualifiedName(name)+suffix, s.tags,
+			)
+		}
+		c.rate = newCounterRate(suffix, cachedGauge)
+	}
 	s.counters[name] = c
+	s.registry.churn.record(s.tags, 1, false)
 	s.countersSlice = append(s.countersSlice, c)
 
 	return c
@@ -323,6 +927,14 @@ func (s *scope) Gauge(name string) Gauge {
 		return g
 	}
 
+	s.registry.checkName(name)
+	if !s.metricEnabled(name) {
+		return noopMetric{}
+	}
+	if o, ok := s.overflowScope(gaugeSeries, name); ok {
+		return o.Gauge(name)
+	}
+
 	s.gm.Lock()
 	defer s.gm.Unlock()
 
@@ -337,8 +949,10 @@ func (s *scope) Gauge(name string) Gauge {
 		)
 	}
 
-	g := newGauge(cachedGauge)
+	g := s.registry.slabs.gauge(cachedGauge)
+	g.guard = s.guard
 	s.gauges[name] = g
+	s.registry.churn.record(s.tags, 1, false)
 	s.gaugesSlice = append(s.gaugesSlice, g)
 
 	return g
@@ -358,6 +972,14 @@ func (s *scope) Timer(name string) Timer {
 		return t
 	}
 
+	s.registry.checkName(name)
+	if !s.metricEnabled(name) {
+		return noopMetric{}
+	}
+	if o, ok := s.overflowScope(timerSeries, name); ok {
+		return o.Timer(name)
+	}
+
 	s.tm.Lock()
 	defer s.tm.Unlock()
 
@@ -375,7 +997,16 @@ func (s *scope) Timer(name string) Timer {
 	t := newTimer(
 		s.fullyQualifiedName(name), s.tags, s.reporter, cachedTimer,
 	)
+	t.guard = s.guard
+	t.tiers = s.registry.tiers
+	if rule, ok := timerThreshold(
+		s.registry.timerThresholds, s.fullyQualifiedName(name),
+	); ok {
+		t.minDuration = rule.MinDuration
+		t.below = s.Counter(derivedName(name, rule.Suffix))
+	}
 	s.timers[name] = t
+	s.registry.churn.record(s.tags, 1, false)
 
 	return t
 }
@@ -394,6 +1025,14 @@ func (s *scope) Histogram(name string, b Buckets) Histogram {
 		return h
 	}
 
+	s.registry.checkName(name)
+	if !s.metricEnabled(name) {
+		return noopMetric{}
+	}
+	if o, ok := s.overflowScope(histogramSeries, name); ok {
+		return o.Histogram(name, b)
+	}
+
 	if b == nil {
 		b = s.defaultBuckets
 	}
@@ -410,11 +1049,23 @@ func (s *scope) Histogram(name string, b Buckets) Histogram {
 		return h
 	}
 
+	h := s.newHistogram(name, b, htype)
+	s.addFineResolution(name, h)
+
+	return h
+}
+
+// newHistogram creates the histogram with the given sanitized name, it
+// must be called with hm held.
+func (s *scope) newHistogram(name string, b Buckets, htype histogramType) *histogram {
 	var cachedHistogram CachedHistogram
 	if s.cachedReporter != nil {
 		cachedHistogram = s.cachedReporter.AllocateHistogram(
 			s.fullyQualifiedName(name), s.tags, b,
 		)
+		cachedHistogram = s.registry.rollups.wrapHistogram(
+			s.fullyQualifiedName(name), s.tags, b, cachedHistogram,
+		)
 	}
 
 	h := newHistogram(
@@ -424,8 +1075,23 @@ func (s *scope) Histogram(name string, b Buckets) Histogram {
 		s.reporter,
 		s.bucketCache.Get(htype, b),
 		cachedHistogram,
+		s.padHistogramBuckets,
+		s.registry.slabs,
 	)
+	h.guard = s.guard
+	if s.registry.reportZeroValues {
+		h.reportZero = 1
+	}
+	if rule, ok := histogramOutlierRule(
+		s.registry.histogramOutliers, s.fullyQualifiedName(name),
+	); ok {
+		h.outliers = &histogramOutliers{rule: rule}
+		if rule.Policy == OutlierDrop {
+			h.outliers.dropped = s.Counter(derivedName(name, rule.Suffix))
+		}
+	}
 	s.histograms[name] = h
+	s.registry.churn.record(s.tags, 1, false)
 	s.histogramsSlice = append(s.histogramsSlice, h)
 
 	return h
@@ -439,16 +1105,44 @@ func (s *scope) histogram(sanitizedName string) (Histogram, bool) {
 	return h, ok
 }
 
+// metricEnabled returns whether the metric with the given sanitized name
+// passes the scope's metric filter.
+func (s *scope) metricEnabled(sanitizedName string) bool {
+	if s.registry.draining.Load() {
+		return false
+	}
+	return s.filter == nil || s.filter.Enabled(s.fullyQualifiedName(sanitizedName))
+}
+
 func (s *scope) Tagged(tags map[string]string) Scope {
 	return s.subscope(s.prefix, tags)
 }
 
+func (s *scope) TaggedPairs(pairs ...string) Scope {
+	if s.registry.root.closed.Load() || s.closed.Load() {
+		return NoopScope
+	}
+	return s.registry.SubscopeWithPairs(s, s.prefix, pairs)
+}
+
+func (s *scope) TaggedKV(key, value string) Scope {
+	pairs := [2]string{key, value}
+	return s.TaggedPairs(pairs[:]...)
+}
+
 func (s *scope) SubScope(prefix string) Scope {
 	prefix = s.sanitizer.Name(prefix)
 	return s.subscope(s.fullyQualifiedName(prefix), nil)
 }
 
+func (s *scope) Fork(prefix string, tags map[string]string) Scope {
+	return s.subscope(s.sanitizer.Name(prefix), tags)
+}
+
 func (s *scope) subscope(prefix string, tags map[string]string) Scope {
+	if s.registry.root.closed.Load() || s.closed.Load() {
+		return NoopScope
+	}
 	return s.registry.Subscope(s, prefix, tags)
 }
 
@@ -509,6 +1203,7 @@ func (s *scope) Snapshot() Snapshot {
 			snap.histograms[id] = &histogramSnapshot{
 				name:      name,
 				tags:      tags,
+				buckets:   h.specification,
 				values:    h.snapshotValues(),
 				durations: h.snapshotDurations(),
 			}
@@ -520,25 +1215,57 @@ func (s *scope) Snapshot() Snapshot {
 }
 
 func (s *scope) Close() error {
+	_, err := s.close(false)
+	return err
+}
+
+// close closes the scope, if snapshot is set it returns the snapshot of a
+// root scope taken right before its final report.
+func (s *scope) close(snapshot bool) (Snapshot, error) {
 	// n.b. Once this flag is set, the next scope report will remove it from
 	//      the registry and clear its metrics.
 	if !s.closed.CAS(false, true) {
-		return nil
+		return nil, nil
 	}
 
 	close(s.done)
 
 	if s.root {
+		roots.unregister(s)
+
+		// NB: tiers are reported before the final report, which removes
+		// every scope of the registry.
+		tierErr := s.registry.closeTiers()
+
+		var snap Snapshot
+		if snapshot {
+			snap = s.Snapshot()
+		}
+
+		if s.group != nil {
+			// The reporter is owned by the group, which flushes and
+			// closes it.
+			s.reportRegistryWithoutFlush()
+			return snap, tierErr
+		}
+
 		s.reportRegistry()
 		if closer, ok := s.baseReporter.(io.Closer); ok {
-			return closer.Close()
+			if err := closer.Close(); err != nil {
+				return snap, err
+			}
 		}
+		return snap, tierErr
 	}
 
-	return nil
+	return nil, nil
 }
 
 func (s *scope) clearMetrics() {
+	// NB: the metrics are no longer reported once cleared, any write to
+	// them from now on is lost.
+	s.guard.clear()
+
 	s.cm.Lock()
 	s.gm.Lock()
 	s.tm.Lock()
@@ -548,15 +1275,36 @@ func (s *scope) clearMetrics() {
 	defer s.tm.Unlock()
 	defer s.hm.Unlock()
 
+	s.registry.churn.record(s.tags, s.seriesCount(), true)
+	s.releaseAllSeries()
+	s.undeclareAll()
+
 	for k := range s.counters {
 		delete(s.counters, k)
 	}
 	s.countersSlice = nil
 
+	for k := range s.floatCounters {
+		delete(s.floatCounters, k)
+	}
+	s.floatCountersSlice = nil
+
+	for k := range s.rates {
+		delete(s.rates, k)
+	}
+	s.ratesSlice = nil
+
 	for k := range s.gauges {
 		delete(s.gauges, k)
 	}
 	s.gaugesSlice = nil
+	for k := range s.gaugeFuncs {
+		delete(s.gaugeFuncs, k)
+	}
+	for k := range s.sets {
+		delete(s.sets, k)
+	}
+	s.setsSlice = nil
 
 	for k := range s.timers {
 		delete(s.timers, k)
@@ -566,6 +1314,16 @@ func (s *scope) clearMetrics() {
 		delete(s.histograms, k)
 	}
 	s.histogramsSlice = nil
+
+	for k := range s.gaugeHistograms {
+		delete(s.gaugeHistograms, k)
+	}
+	s.gaugeHistogramsSlice = nil
+
+	for k := range s.summaries {
+		delete(s.summaries, k)
+	}
+	s.summariesSlice = nil
 }
 
 // NB(prateek): We assume concatenation of sanitized inputs is
@@ -587,12 +1345,28 @@ func (s *scope) copyAndSanitizeMap(tags map[string]string) map[string]string {
 	result := make(map[string]string, len(tags))
 	for k, v := range tags {
 		k = s.sanitizer.Key(k)
+		if transform, ok := s.tagTransforms[k]; ok {
+			v = transform(v)
+		}
 		v = s.sanitizer.Value(v)
 		result[k] = v
 	}
 	return result
 }
 
+func copyTagValueTransforms(
+	transforms map[string]TagValueTransform,
+) map[string]TagValueTransform {
+	if len(transforms) == 0 {
+		return nil
+	}
+	result := make(map[string]TagValueTransform, len(transforms))
+	for k, t := range transforms {
+		result[k] = t
+	}
+	return result
+}
+
 // TestScope is a metrics collector that has no reporting, ensuring that
 // all emitted values have a given prefix or set of tags
 type TestScope interface {
@@ -603,6 +1377,55 @@ type TestScope interface {
 	Snapshot() Snapshot
 }
 
+// PairTaggedScope is a Scope which can create tagged child scopes without
+// the caller building a map of tags.
+type PairTaggedScope interface {
+	Scope
+
+	// TaggedPairs returns a new child scope with the given tags, passed as
+	// alternating keys and values, and current tags. A trailing key without
+	// a value is ignored.
+	TaggedPairs(pairs ...string) Scope
+
+	// TaggedKV returns a new child scope with the given tag and current tags.
+	TaggedKV(key, value string) Scope
+}
+
+// TaggedPairs returns s.TaggedPairs(pairs...) if s is a PairTaggedScope,
+// otherwise it builds a map of the tags and calls s.Tagged.
+func TaggedPairs(s Scope, pairs ...string) Scope {
+	if ps, ok := s.(PairTaggedScope); ok {
+		return ps.TaggedPairs(pairs...)
+	}
+	tags := make(map[string]string, len(pairs)/2)
+	for i := 0; i+1 < len(pairs); i += 2 {
+		tags[pairs[i]] = pairs[i+1]
+	}
+	return s.Tagged(tags)
+}
+
+// TaggedKV returns s.TaggedKV(key, value) if s is a PairTaggedScope,
+// otherwise it calls s.Tagged with a single tag map.
+func TaggedKV(s Scope, key, value string) Scope {
+	if ps, ok := s.(PairTaggedScope); ok {
+		return ps.TaggedKV(key, value)
+	}
+	return s.Tagged(map[string]string{key: value})
+}
+
+// ForkableScope is a Scope which can create child scopes under an
+// unrelated prefix.
+type ForkableScope interface {
+	Scope
+
+	// Fork returns a new child scope whose prefix is replaced by the given
+	// prefix rather than appended to, with the given tags and current tags.
+	// The forked scope is reported by the same report loop and reporter as
+	// the scope it was forked from. This allows embedding the metrics of a
+	// component under its own namespace in a host process.
+	Fork(prefix string, tags map[string]string) Scope
+}
+
 // Snapshot is a snapshot of values since last report execution
 type Snapshot interface {
 	// Counters returns a snapshot of all counter summations since last report execution
@@ -667,6 +1490,26 @@ type HistogramSnapshot interface {
 
 	// Durations returns the sample values by upper bound for a durationHistogram
 	Durations() map[time.Duration]int64
+
+	// Buckets returns the buckets of the histogram, the default buckets of
+	// its scope if it was created without buckets.
+	Buckets() Buckets
+
+	// Count returns the number of samples.
+	Count() int64
+
+	// Sum returns the sum of the samples, approximating each sample by
+	// the upper bound of its bucket, or the lower bound for the overflow
+	// bucket. Durations are summed in nanoseconds.
+	Sum() float64
+
+	// Quantile returns the q-quantile of the samples, 0 <= q <= 1,
+	// interpolated linearly within the bucket it falls in, or NaN if q is
+	// out of range or there are no samples. The first bucket is assumed
+	// to start at zero if its upper bound is positive, and quantiles in
+	// the overflow bucket are its lower bound. Durations are returned in
+	// nanoseconds, e.g. time.Duration(s.Quantile(0.99)).
+	Quantile(q float64) float64
 }
 
 // mergeRightTags merges 2 sets of tags with the tags from tagsRight overriding values from tagsLeft
@@ -780,6 +1623,7 @@ func (s *timerSnapshot) Values() []time.Duration {
 type histogramSnapshot struct {
 	name      string
 	tags      map[string]string
+	buckets   Buckets
 	values    map[float64]int64
 	durations map[time.Duration]int64
 }
@@ -799,3 +1643,7 @@ func (s *histogramSnapshot) Values() map[float64]int64 {
 func (s *histogramSnapshot) Durations() map[time.Duration]int64 {
 	return s.durations
 }
+
+func (s *histogramSnapshot) Buckets() Buckets {
+	return s.buckets
+}
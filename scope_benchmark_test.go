@@ -113,6 +113,29 @@ func BenchmarkScopeTaggedCachedSubscopes(b *testing.B) {
 	}
 }
 
+func BenchmarkScopeTaggedPairsCachedSubscopes(b *testing.B) {
+	root, _ := NewRootScope(ScopeOptions{
+		Prefix:   "funkytown",
+		Reporter: NullStatsReporter,
+		Tags: map[string]string{
+			"style":     "funky",
+			"hair":      "wavy",
+			"jefferson": "starship",
+		},
+	}, 0)
+	s := root.(PairTaggedScope)
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for n := 0; n < b.N; n++ {
+		s.TaggedPairs(
+			"foo", "bar",
+			"baz", "qux",
+			"qux", "quux",
+		)
+	}
+}
+
 func BenchmarkScopeTaggedNoCachedSubscopes(b *testing.B) {
 	root, _ := NewRootScope(ScopeOptions{
 		Prefix:   "funkytown",
@@ -325,3 +348,24 @@ func (n noopCachedReporter) AllocateTimer(name string, tags map[string]string) C
 func (n noopCachedReporter) AllocateHistogram(name string, tags map[string]string, buckets Buckets) CachedHistogram {
 	return noopStat{}
 }
+
+func BenchmarkNoopScopeTaggedCounter(b *testing.B) {
+	tags := map[string]string{"foo": "bar"}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		NoopScope.Tagged(tags).Counter("counter").Inc(1)
+	}
+}
+
+func BenchmarkHotMetricsCounter(b *testing.B) {
+	root, _ := NewRootScope(ScopeOptions{
+		Reporter: NullStatsReporter,
+	}, 0)
+	m := NewHotMetrics(root, HotMetricsOptions{Counters: []string{"foo"}})
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		m.Counter(0).Inc(1)
+	}
+}
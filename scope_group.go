@@ -0,0 +1,151 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tally
+
+import (
+	"io"
+	"sync"
+	"time"
+
+	"go.uber.org/atomic"
+)
+
+// ScopeGroupOptions is a set of options to construct a scope group.
+type ScopeGroupOptions struct {
+	Reporter       StatsReporter
+	CachedReporter CachedStatsReporter
+}
+
+// ScopeGroup reports several root scopes, which may each have their own
+// prefix, tags and sanitization, with a single report loop and reporter.
+// This avoids running a report loop and a reporter connection per root
+// scope in processes hosting several logical services.
+type ScopeGroup struct {
+	reporter       StatsReporter
+	cachedReporter CachedStatsReporter
+	baseReporter   BaseStatsReporter
+
+	mu     sync.Mutex
+	scopes []*scope
+
+	closed atomic.Bool
+	done   chan struct{}
+	wg     sync.WaitGroup
+}
+
+// NewScopeGroup creates a new ScopeGroup reporting its scopes every
+// interval. Must provide either a StatsReporter or a CachedStatsReporter.
+func NewScopeGroup(opts ScopeGroupOptions, interval time.Duration) *ScopeGroup {
+	g := &ScopeGroup{
+		reporter:       opts.Reporter,
+		cachedReporter: opts.CachedReporter,
+		done:           make(chan struct{}),
+	}
+	if opts.Reporter != nil {
+		g.baseReporter = opts.Reporter
+	} else if opts.CachedReporter != nil {
+		g.baseReporter = opts.CachedReporter
+	}
+
+	if interval > 0 {
+		g.wg.Add(1)
+		go func() {
+			defer g.wg.Done()
+			runReportLoop(interval, g.done, g.report)
+		}()
+	}
+
+	return g
+}
+
+// NewRootScope creates a new root scope reported by the group. The
+// Reporter and CachedReporter of opts are ignored in favor of the group's.
+// Closing the returned scope stops reporting it, the group's reporter is
+// only flushed and closed by the group.
+func (g *ScopeGroup) NewRootScope(opts ScopeOptions) Scope {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.closed.Load() {
+		return NoopScope
+	}
+
+	opts.Reporter = g.reporter
+	opts.CachedReporter = g.cachedReporter
+
+	s := newRootScope(opts, 0)
+	s.group = g
+	g.scopes = append(g.scopes, s)
+
+	return s
+}
+
+// report reports every open scope of the group then flushes the reporter
+// once.
+func (g *ScopeGroup) report() {
+	g.mu.Lock()
+	open := g.scopes[:0]
+	for _, s := range g.scopes {
+		if s.closed.Load() {
+			continue
+		}
+		open = append(open, s)
+		if s.registry.suppressed(globalNow()) {
+			continue
+		}
+		s.reportRegistryWithoutFlush()
+	}
+	g.scopes = open
+	g.mu.Unlock()
+
+	if g.baseReporter != nil {
+		g.baseReporter.Flush()
+	}
+}
+
+// Close closes every scope of the group, reports them a final time and
+// flushes the reporter, then closes the reporter if it implements
+// io.Closer.
+func (g *ScopeGroup) Close() error {
+	if !g.closed.CAS(false, true) {
+		return nil
+	}
+
+	close(g.done)
+	g.wg.Wait()
+
+	g.mu.Lock()
+	scopes := g.scopes
+	g.scopes = nil
+	g.mu.Unlock()
+
+	for _, s := range scopes {
+		_ = s.Close()
+	}
+
+	if g.baseReporter != nil {
+		g.baseReporter.Flush()
+	}
+	if closer, ok := g.baseReporter.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tally
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScopeGroup(t *testing.T) {
+	r := newTestStatsReporter()
+	g := NewScopeGroup(ScopeGroupOptions{Reporter: r}, 0)
+
+	a := g.NewRootScope(ScopeOptions{Prefix: "a", MetricsOption: OmitInternalMetrics})
+	b := g.NewRootScope(ScopeOptions{
+		Prefix:          "b",
+		Separator:       "_",
+		MetricsOption:   OmitInternalMetrics,
+		SanitizeOptions: &alphanumericSanitizerOpts,
+	})
+
+	r.cg.Add(2)
+	a.Counter("requests").Inc(1)
+	b.SubScope("db").Counter("queries!").Inc(2)
+
+	g.report()
+	r.WaitAll()
+
+	counters := r.getCounters()
+	require.NotNil(t, counters["a.requests"])
+	assert.EqualValues(t, 1, counters["a.requests"].val)
+	require.NotNil(t, counters["b_db_queries_"])
+	assert.EqualValues(t, 2, counters["b_db_queries_"].val)
+	assert.EqualValues(t, 1, atomic.LoadInt32(&r.flushes))
+
+	// Closing a member scope reports it without flushing the shared
+	// reporter.
+	r.cg.Add(1)
+	a.Counter("requests").Inc(3)
+	require.NoError(t, a.(*scope).Close())
+	r.WaitAll()
+	assert.EqualValues(t, 3, r.getCounters()["a.requests"].val)
+	assert.EqualValues(t, 1, atomic.LoadInt32(&r.flushes))
+
+	r.cg.Add(1)
+	b.SubScope("db").Counter("queries!").Inc(4)
+	require.NoError(t, g.Close())
+	r.WaitAll()
+	assert.EqualValues(t, 4, r.getCounters()["b_db_queries_"].val)
+	assert.EqualValues(t, 2, atomic.LoadInt32(&r.flushes))
+
+	assert.Equal(t, NoopScope, g.NewRootScope(ScopeOptions{}))
+	require.NoError(t, g.Close())
+}
+
+func TestScopeGroupReportLoop(t *testing.T) {
+	r := newTestStatsReporter()
+	g := NewScopeGroup(ScopeGroupOptions{CachedReporter: r}, 10*time.Millisecond)
+	defer g.Close()
+
+	r.cg.Add(2)
+	g.NewRootScope(ScopeOptions{Prefix: "a", MetricsOption: OmitInternalMetrics}).Counter("foo").Inc(1)
+	g.NewRootScope(ScopeOptions{Prefix: "b", MetricsOption: OmitInternalMetrics}).Counter("foo").Inc(1)
+	r.WaitAll()
+}
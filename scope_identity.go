@@ -0,0 +1,137 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tally
+
+// InspectableScope is a Scope which exposes its identity, and can look up
+// its existing child scopes without creating them. This allows registries
+// built on top of scopes to deduplicate them deliberately.
+type InspectableScope interface {
+	Scope
+
+	// Tags returns a copy of the effective tags of the scope, i.e. its own
+	// tags merged with those inherited from its parents.
+	Tags() map[string]string
+
+	// Key returns the canonical identity of the scope, built from its
+	// prefix and effective tags. Scopes sharing a root scope are the same
+	// scope if and only if their keys are equal.
+	Key() string
+
+	// LookupSubScope returns the open child scope which SubScope would
+	// return for prefix, if it already exists.
+	LookupSubScope(prefix string) (Scope, bool)
+
+	// LookupTagged returns the open child scope which Tagged would return
+	// for tags, if it already exists.
+	LookupTagged(tags map[string]string) (Scope, bool)
+}
+
+// Tags returns s.Tags() if s is an InspectableScope, otherwise nil.
+func Tags(s Scope) map[string]string {
+	if is, ok := s.(InspectableScope); ok {
+		return is.Tags()
+	}
+	return nil
+}
+
+// ScopeKey returns s.Key() if s is an InspectableScope, otherwise an empty
+// string.
+func ScopeKey(s Scope) string {
+	if is, ok := s.(InspectableScope); ok {
+		return is.Key()
+	}
+	return ""
+}
+
+// LookupSubScope returns s.LookupSubScope(prefix) if s is an
+// InspectableScope, otherwise it reports that no such scope exists.
+func LookupSubScope(s Scope, prefix string) (Scope, bool) {
+	if is, ok := s.(InspectableScope); ok {
+		return is.LookupSubScope(prefix)
+	}
+	return nil, false
+}
+
+// LookupTagged returns s.LookupTagged(tags) if s is an InspectableScope,
+// otherwise it reports that no such scope exists.
+func LookupTagged(s Scope, tags map[string]string) (Scope, bool) {
+	if is, ok := s.(InspectableScope); ok {
+		return is.LookupTagged(tags)
+	}
+	return nil, false
+}
+
+func (s *scope) Tags() map[string]string {
+	// NB: tags are immutable, no lock required to read.
+	tags := make(map[string]string, len(s.tags))
+	for k, v := range s.tags {
+		tags[k] = v
+	}
+	return tags
+}
+
+func (s *scope) Key() string {
+	return scopeRegistryKey(s.prefix, s.tags)
+}
+
+func (s *scope) LookupSubScope(prefix string) (Scope, bool) {
+	prefix = s.sanitizer.Name(prefix)
+	return s.lookup(s.fullyQualifiedName(prefix), nil)
+}
+
+func (s *scope) LookupTagged(tags map[string]string) (Scope, bool) {
+	return s.lookup(s.prefix, tags)
+}
+
+func (s *scope) lookup(prefix string, tags map[string]string) (Scope, bool) {
+	if s.registry.root.closed.Load() || s.closed.Load() {
+		return nil, false
+	}
+	ss, ok := s.registry.Lookup(s, prefix, tags)
+	if !ok {
+		return nil, false
+	}
+	return ss, true
+}
+
+func (s *leveledScope) Tags() map[string]string {
+	return s.scope.Tags()
+}
+
+func (s *leveledScope) Key() string {
+	return s.scope.Key()
+}
+
+func (s *leveledScope) LookupSubScope(prefix string) (Scope, bool) {
+	child, ok := s.scope.LookupSubScope(prefix)
+	if !ok {
+		return nil, false
+	}
+	return s.wrap(child), true
+}
+
+func (s *leveledScope) LookupTagged(tags map[string]string) (Scope, bool) {
+	child, ok := s.scope.LookupTagged(tags)
+	if !ok {
+		return nil, false
+	}
+	return s.wrap(child), true
+}
@@ -0,0 +1,131 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tally
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTags(t *testing.T) {
+	root := NewTestScope("", map[string]string{"env": "prod"})
+	child := root.SubScope("foo").Tagged(map[string]string{"region": "us"})
+
+	tags := Tags(child)
+	assert.Equal(t, map[string]string{"env": "prod", "region": "us"}, tags)
+
+	// The returned tags are a copy.
+	tags["env"] = "staging"
+	assert.Equal(t, "prod", Tags(child)["env"])
+
+	assert.Equal(t, Tags(child), Tags(AtLevel(child, DebugLevel)))
+	assert.Nil(t, Tags(NoopScope))
+}
+
+func TestScopeKey(t *testing.T) {
+	root := NewTestScope("svc", map[string]string{"env": "prod"})
+	a := root.SubScope("foo").Tagged(map[string]string{"region": "us"})
+	b := root.Tagged(map[string]string{"region": "us"}).SubScope("foo")
+
+	assert.Same(t, a, b)
+	assert.Equal(t, ScopeKey(a), ScopeKey(b))
+	assert.Equal(t, KeyForPrefixedStringMap("svc.foo", Tags(a)), ScopeKey(a))
+	assert.NotEqual(t, ScopeKey(root), ScopeKey(a))
+	assert.Equal(t, ScopeKey(a), ScopeKey(AtLevel(a, DebugLevel)))
+	assert.Equal(t, "", ScopeKey(NoopScope))
+}
+
+func TestLookupScope(t *testing.T) {
+	root := newRootScope(ScopeOptions{
+		Prefix:             "svc",
+		Reporter:           NullStatsReporter,
+		SanitizeOptions:    &alphanumericSanitizerOpts,
+		registryShardCount: 64,
+	}, 0)
+	defer root.Close()
+
+	_, ok := LookupSubScope(root, "foo")
+	assert.False(t, ok)
+	_, ok = LookupTagged(root, map[string]string{"region": "us"})
+	assert.False(t, ok)
+
+	foo := root.SubScope("foo")
+	tagged := root.Tagged(map[string]string{"region": "us"})
+
+	s, ok := LookupSubScope(root, "foo")
+	require.True(t, ok)
+	assert.Same(t, foo, s)
+
+	s, ok = LookupTagged(root, map[string]string{"region": "us"})
+	require.True(t, ok)
+	assert.Same(t, tagged, s)
+
+	// Tags sanitizing to the same tags are found too.
+	sanitized := root.Tagged(map[string]string{"region": "u+s"})
+	s, ok = LookupTagged(root, map[string]string{"region": "u_s"})
+	require.True(t, ok)
+	assert.Same(t, sanitized, s)
+	assert.Same(t, sanitized, root.Tagged(map[string]string{"region": "u_s"}))
+
+	// Closed scopes aren't found.
+	require.NoError(t, foo.(*scope).Close())
+	_, ok = LookupSubScope(root, "foo")
+	assert.False(t, ok)
+
+	_, ok = LookupSubScope(NoopScope, "foo")
+	assert.False(t, ok)
+}
+
+func TestLookupScopeLeveled(t *testing.T) {
+	root := NewTestScope("", nil)
+	debug := AtLevel(root, DebugLevel)
+	debug.SubScope("foo")
+
+	s, ok := LookupSubScope(debug, "foo")
+	require.True(t, ok)
+	_, leveled := s.(*leveledScope)
+	assert.True(t, leveled)
+}
+
+func TestLookupScopeTagConflict(t *testing.T) {
+	for _, policy := range []TagConflictPolicy{KeepParentTags, PanicOnTagConflict} {
+		root := newRootScope(ScopeOptions{
+			Tags:               map[string]string{"env": "prod"},
+			Reporter:           NullStatsReporter,
+			TagConflictPolicy:  policy,
+			registryShardCount: 64,
+		}, 0)
+
+		child := root.Tagged(map[string]string{"region": "us"})
+		s, ok := LookupTagged(root, map[string]string{"env": "staging", "region": "us"})
+		if policy == KeepParentTags {
+			require.True(t, ok)
+			assert.Same(t, child, s)
+			assert.Same(t, child, root.Tagged(map[string]string{"env": "staging", "region": "us"}))
+		} else {
+			assert.False(t, ok)
+		}
+
+		assert.NoError(t, root.Close())
+	}
+}
@@ -21,9 +21,12 @@
 package tally
 
 import (
+	"fmt"
 	"hash/maphash"
 	"runtime"
+	"sort"
 	"sync"
+	"time"
 	"unsafe"
 
 	"go.uber.org/atomic"
@@ -37,6 +40,14 @@ var (
 	counterCardinalityName   = "tally_internal_counter_cardinality"
 	gaugeCardinalityName     = "tally_internal_gauge_cardinality"
 	histogramCardinalityName = "tally_internal_histogram_cardinality"
+	reportIntervalName       = "tally_internal_report_interval"
+	writesAfterCloseName     = "tally_internal_writes_after_close"
+	namespaceConflictsName   = "tally_internal_namespace_conflicts"
+	cardinalityOverflowsName = "tally_internal_cardinality_overflows"
+
+	// reportIntervalBucketFactors are multiples of the configured reporting
+	// interval used as the buckets of the report interval histogram.
+	reportIntervalBucketFactors = []float64{0.5, 0.9, 0.99, 1.01, 1.1, 1.5, 2, 5, 10}
 )
 
 type scopeRegistry struct {
@@ -49,6 +60,80 @@ type scopeRegistry struct {
 	sanitizedCounterCardinalityName   string
 	sanitizedGaugeCardinalityName     string
 	sanitizedHistogramCardinalityName string
+	reportIntervals                   *histogram
+	// Level threshold shared by all scopes of the registry.
+	level atomic.Int32
+	// Writes to metrics of closed scopes since the last report.
+	writesAfterClose      atomic.Int64
+	onWriteAfterClose     func(prefix string, tags map[string]string)
+	writeAfterClosePolicy WriteAfterClosePolicy
+	// Errors notified by the reporter since the last report, and since
+	// the registry was created.
+	reportErrors      atomic.Int64
+	totalReportErrors atomic.Int64
+	// Closed once the reporter was first flushed successfully.
+	flushed     chan struct{}
+	flushedOnce sync.Once
+	// Rollups of the registry's metrics, nil if none are configured.
+	rollups *rollups
+	// Behavior of subscopes with tags conflicting with their parent's.
+	tagConflictPolicy TagConflictPolicy
+	// Throttles the report loop, nil if not configured.
+	governor *governor
+	// Additional reporters with their own reporting intervals.
+	tiers []*reportTier
+	// Whether metric names must be built with N.
+	strictNames bool
+	// Whether counters and histograms report zero values.
+	reportZeroValues bool
+	// Metrics declared in the registry.
+	declarations declarations
+	// Slabs metrics are allocated from, nil if disabled.
+	slabs *metricSlabs
+	// Lazily allocating cached reporter, nil if disabled.
+	lazy *lazyCachedReporter
+	// Suffix of the rate gauges reported alongside counters, empty if
+	// disabled.
+	counterRateSuffix string
+	// Rules counting the short durations recorded to timers.
+	timerThresholds []TimerThresholdRule
+	// Rules handling the outliers recorded to histograms.
+	histogramOutliers []HistogramOutlierRule
+	// Rules recording histograms to finer histograms too.
+	histogramResolutions []HistogramResolutionRule
+	// Namespaces reserved in the registry, and the conflicting
+	// reservations since the last report.
+	namespaces          namespaces
+	namespaceConflicts  atomic.Int64
+	onNamespaceConflict func(NamespaceConflict)
+	// Traces the phases of reports, nil if not configured.
+	tracer ReportTracer
+	// Whether periodic reports are paused.
+	paused atomic.Bool
+	// Periodic reports are suppressed until then, zero if disabled.
+	quietUntil time.Time
+	// Whether the registry is draining, dropping new metrics and writes.
+	draining atomic.Bool
+	// Whether the reporter of the root scope supports summaries.
+	reportsSummaries bool
+	// Signalled by the writes ending while draining, nil unless drainable.
+	drained chan struct{}
+	// Duration after which idle metrics are removed, zero if disabled.
+	metricTTL time.Duration
+	// Caps the tags of each metric name, nil if disabled.
+	cardinality *tagCardinality
+	// Counts the series churn per tag key, nil if disabled.
+	churn *seriesChurn
+	// How the values of counters are reported.
+	counterTemporality Temporality
+	// Sequence making the registry keys of transient scopes unique.
+	transientSeq atomic.Uint64
+	// Time of the report in progress, the observation time of its values.
+	clock *reportClock
+	// Reporters accepting backfilled values.
+	backfillReporters []TimestampedStatsReporter
+	// Serializes reports, which compute the deltas of counters.
+	reportMu sync.Mutex
 }
 
 type scopeBucket struct {
@@ -84,42 +169,85 @@ func newScopeRegistryWithShardCount(
 }
 
 func (r *scopeRegistry) Report(reporter StatsReporter) {
+	r.reportMu.Lock()
+	defer r.reportMu.Unlock()
 	defer r.purgeIfRootClosed()
+	r.clock.start()
 	r.reportInternalMetrics()
+	r.updateGaugeFuncs()
+	r.expireIdle()
 
-	for _, subscopeBucket := range r.subscopes {
-		subscopeBucket.mu.RLock()
+	if r.rollups != nil {
+		defer r.rollups.report(reporter)
+		reporter = r.rollups.wrapReporter(reporter)
+	}
 
-		for name, s := range subscopeBucket.s {
-			s.report(reporter)
+	r.reportScopes(func(s *scope) { s.report(reporter) })
+}
 
-			if s.closed.Load() {
-				r.removeWithRLock(subscopeBucket, name)
-				s.clearMetrics()
-			}
+func (r *scopeRegistry) CachedReport() {
+	r.reportMu.Lock()
+	defer r.reportMu.Unlock()
+	defer r.purgeIfRootClosed()
+	if r.lazy != nil {
+		r.lazy.beginBatch()
+		defer r.lazy.endBatch()
+	}
+	r.reportInternalMetrics()
+	r.updateGaugeFuncs()
+	r.expireIdle()
+
+	if r.rollups != nil {
+		defer r.rollups.cachedReport(r.root.cachedReporter)
+	}
+
+	r.reportScopes(func(s *scope) { s.cachedReport() })
+}
+
+// reportScopes calls report once for every scope of the registry, in
+// prefix and tags order when sorted reporting is enabled, then removes the
+// scopes that are closed.
+func (r *scopeRegistry) reportScopes(report func(*scope)) {
+	scopes := r.uniqueScopes()
+	if r.root.sortedReporting {
+		keys := make(map[*scope]string, len(scopes))
+		for _, s := range scopes {
+			keys[s] = scopeRegistryKey(s.prefix, s.tags)
 		}
+		sort.Slice(scopes, func(i, j int) bool {
+			return keys[scopes[i]] < keys[scopes[j]]
+		})
+	}
 
-		subscopeBucket.mu.RUnlock()
+	var closed bool
+	for _, s := range scopes {
+		report(s)
+		closed = closed || r.removable(s)
+	}
+
+	if closed {
+		r.removeClosed()
 	}
 }
 
-func (r *scopeRegistry) CachedReport() {
-	defer r.purgeIfRootClosed()
-	r.reportInternalMetrics()
+// removable returns whether the scope can be removed from the registry
+// after being reported.
+func (r *scopeRegistry) removable(s *scope) bool {
+	return s.transient || s.closed.Load() && r.writeAfterClosePolicy != BufferWritesAfterClose
+}
 
+// removeClosed removes closed scopes from the registry and clears their
+// metrics.
+func (r *scopeRegistry) removeClosed() {
 	for _, subscopeBucket := range r.subscopes {
-		subscopeBucket.mu.RLock()
-
+		subscopeBucket.mu.Lock()
 		for name, s := range subscopeBucket.s {
-			s.cachedReport()
-
-			if s.closed.Load() {
-				r.removeWithRLock(subscopeBucket, name)
+			if r.removable(s) {
+				delete(subscopeBucket.s, name)
 				s.clearMetrics()
 			}
 		}
-
-		subscopeBucket.mu.RUnlock()
+		subscopeBucket.mu.Unlock()
 	}
 }
 
@@ -133,19 +261,41 @@ func (r *scopeRegistry) ForEachScope(f func(*scope)) {
 	}
 }
 
-func (r *scopeRegistry) Subscope(parent *scope, prefix string, tags map[string]string) *scope {
-	if r.root.closed.Load() || parent.closed.Load() {
-		return NoopScope.(*scope)
-	}
+func (r *scopeRegistry) bucketFor(key []byte) *scopeBucket {
+	var h maphash.Hash
+	h.SetSeed(r.seed)
+	_, _ = h.Write(key)
+	return r.subscopes[h.Sum64()%uint64(len(r.subscopes))]
+}
 
+// SubscopeWithPairs is Subscope for tags given as key/value pairs, it
+// avoids building a map of the tags when the subscope already exists.
+func (r *scopeRegistry) SubscopeWithPairs(parent *scope, prefix string, pairs []string) *scope {
 	var (
-		buf = keyForPrefixedStringMapsAsKey(make([]byte, 0, 256), prefix, parent.tags, tags)
-		h   maphash.Hash
+		buf            = keyForPrefixedStringMapAndPairsAsKey(make([]byte, 0, 256), prefix, parent.tags, pairs)
+		subscopeBucket = r.bucketFor(buf)
 	)
 
-	h.SetSeed(r.seed)
-	_, _ = h.Write(buf)
-	subscopeBucket := r.subscopes[h.Sum64()%uint64(len(r.subscopes))]
+	subscopeBucket.mu.RLock()
+	// NB: see Subscope for why this cast is safe.
+	if s, ok := r.lockedLookup(subscopeBucket, *(*string)(unsafe.Pointer(&buf))); ok {
+		subscopeBucket.mu.RUnlock()
+		return s
+	}
+	subscopeBucket.mu.RUnlock()
+
+	tags := make(map[string]string, len(pairs)/2)
+	for i := 0; i+1 < len(pairs); i += 2 {
+		tags[pairs[i]] = pairs[i+1]
+	}
+	return r.Subscope(parent, prefix, tags)
+}
+
+func (r *scopeRegistry) Subscope(parent *scope, prefix string, tags map[string]string) *scope {
+	var (
+		buf            = keyForPrefixedStringMapsAsKey(make([]byte, 0, 256), prefix, parent.tags, tags)
+		subscopeBucket = r.bucketFor(buf)
+	)
 
 	subscopeBucket.mu.RLock()
 	// buf is stack allocated and casting it to a string for lookup from the cache
@@ -161,18 +311,48 @@ func (r *scopeRegistry) Subscope(parent *scope, prefix string, tags map[string]s
 	// heap allocating the buf as a string to keep the key in the subscopes map
 	preSanitizeKey := string(buf)
 	tags = parent.copyAndSanitizeMap(tags)
+	r.resolveTagConflicts(parent, tags)
 	key := scopeRegistryKey(prefix, parent.tags, tags)
 
+	// The subscope is registered under its sanitized key in the bucket of
+	// that key, so that it is found by every set of tags sanitizing to the
+	// same key, and aliased under its pre-sanitized key for the lookup
+	// above.
+	subscope := r.lockedOrNewSubscope(r.bucketFor([]byte(key)), parent, prefix, tags, key)
+	if preSanitizeKey != key {
+		subscopeBucket.mu.Lock()
+		if _, ok := r.lockedLookup(subscopeBucket, preSanitizeKey); !ok {
+			subscopeBucket.s[preSanitizeKey] = subscope
+		}
+		subscopeBucket.mu.Unlock()
+	}
+	return subscope
+}
+
+// lockedOrNewSubscope returns the subscope registered under key in the
+// given bucket, creating it if it doesn't exist.
+func (r *scopeRegistry) lockedOrNewSubscope(
+	subscopeBucket *scopeBucket,
+	parent *scope,
+	prefix string,
+	tags map[string]string,
+	key string,
+) *scope {
 	subscopeBucket.mu.Lock()
 	defer subscopeBucket.mu.Unlock()
 
 	if s, ok := r.lockedLookup(subscopeBucket, key); ok {
-		if _, ok = r.lockedLookup(subscopeBucket, preSanitizeKey); !ok {
-			subscopeBucket.s[preSanitizeKey] = s
-		}
 		return s
 	}
 
+	subscope := newSubscope(parent, prefix, tags)
+	subscopeBucket.s[key] = subscope
+	return subscope
+}
+
+// newSubscope returns a new subscope of parent with the given prefix and
+// sanitized tags, without registering it.
+func newSubscope(parent *scope, prefix string, tags map[string]string) *scope {
 	allTags := mergeRightTags(parent.tags, tags)
 	subscope := &scope{
 		separator: parent.separator,
@@ -185,8 +365,13 @@ func (r *scopeRegistry) Subscope(parent *scope, prefix string, tags map[string]s
 		baseReporter:   parent.baseReporter,
 		defaultBuckets: parent.defaultBuckets,
 		sanitizer:      parent.sanitizer,
+		filter:         parent.filter,
+		tagTransforms:  parent.tagTransforms,
 		registry:       parent.registry,
 
+		padHistogramBuckets: parent.padHistogramBuckets,
+		sortedReporting:     parent.sortedReporting,
+
 		counters:        make(map[string]*counter),
 		countersSlice:   make([]*counter, 0, _defaultInitialSliceSize),
 		gauges:          make(map[string]*gauge),
@@ -197,13 +382,40 @@ func (r *scopeRegistry) Subscope(parent *scope, prefix string, tags map[string]s
 		bucketCache:     parent.bucketCache,
 		done:            make(chan struct{}),
 	}
-	subscopeBucket.s[key] = subscope
-	if _, ok := r.lockedLookup(subscopeBucket, preSanitizeKey); !ok {
-		subscopeBucket.s[preSanitizeKey] = subscope
-	}
+	subscope.guard = newCloseGuard(subscope)
 	return subscope
 }
 
+// Lookup returns the open subscope of parent with the given prefix and
+// tags, without creating it if it doesn't exist.
+func (r *scopeRegistry) Lookup(parent *scope, prefix string, tags map[string]string) (*scope, bool) {
+	tags = parent.copyAndSanitizeMap(tags)
+	if conflicts := tagConflicts(parent, tags); len(conflicts) > 0 {
+		switch r.tagConflictPolicy {
+		case KeepParentTags:
+			for _, k := range conflicts {
+				delete(tags, k)
+			}
+		case PanicOnTagConflict:
+			// Such a subscope can't have been created.
+			return nil, false
+		}
+	}
+
+	var (
+		key            = scopeRegistryKey(prefix, parent.tags, tags)
+		subscopeBucket = r.bucketFor([]byte(key))
+	)
+
+	subscopeBucket.mu.RLock()
+	s, ok := r.lockedLookup(subscopeBucket, key)
+	subscopeBucket.mu.RUnlock()
+	if !ok || s.closed.Load() {
+		return nil, false
+	}
+	return s, true
+}
+
 func (r *scopeRegistry) lockedLookup(subscopeBucket *scopeBucket, key string) (*scope, bool) {
 	ss, ok := subscopeBucket.s[key]
 	return ss, ok
@@ -235,12 +447,116 @@ func (r *scopeRegistry) removeWithRLock(subscopeBucket *scopeBucket, key string)
 	delete(subscopeBucket.s, key)
 }
 
+// initReportIntervalHistogram creates the internal histogram tracking the
+// observed time between reports of the root scope's report loop.
+func (r *scopeRegistry) initReportIntervalHistogram(interval time.Duration) {
+	if r.internalMetricsOption != SendInternalMetrics {
+		return
+	}
+
+	buckets := make(DurationBuckets, 0, len(reportIntervalBucketFactors))
+	for _, f := range reportIntervalBucketFactors {
+		buckets = append(buckets, time.Duration(float64(interval)*f))
+	}
+
+	name := r.root.sanitizer.Name(reportIntervalName)
+	var cachedHistogram CachedHistogram
+	if r.root.cachedReporter != nil {
+		cachedHistogram = r.root.cachedReporter.AllocateHistogram(name, internalTags, buckets)
+	}
+
+	r.reportIntervals = newHistogram(
+		durationHistogramType,
+		name,
+		internalTags,
+		r.root.reporter,
+		newBucketStorage(durationHistogramType, buckets),
+		cachedHistogram,
+		false,
+		nil,
+	)
+}
+
+// RecordReportInterval records the time elapsed between two consecutive
+// reports of the report loop.
+func (r *scopeRegistry) RecordReportInterval(d time.Duration) {
+	if r.reportIntervals == nil {
+		return
+	}
+	r.reportIntervals.RecordDuration(d)
+}
+
+// recordWriteAfterClose records a write to a metric of the given scope
+// made after the scope was closed and cleared.
+func (r *scopeRegistry) recordWriteAfterClose(s *scope) {
+	if r.writeAfterClosePolicy == PanicOnWriteAfterClose {
+		panic(fmt.Sprintf(
+			"tally: write to metric of closed scope with prefix %q and tags %v", s.prefix, s.tags,
+		))
+	}
+
+	r.writesAfterClose.Inc()
+	if r.onWriteAfterClose != nil {
+		r.onWriteAfterClose(s.prefix, s.tags)
+	}
+}
+
+// reportInternalCounter reports the internal counter with the given name
+// if it was incremented by n since the last report.
+func (r *scopeRegistry) reportInternalCounter(name string, n int64) {
+	r.reportInternalCounterWithTags(name, internalTags, n)
+}
+
+// reportInternalCounterWithTags is reportInternalCounter for internal
+// counters with more tags than internalTags.
+func (r *scopeRegistry) reportInternalCounterWithTags(name string, tags map[string]string, n int64) {
+	if n == 0 {
+		return
+	}
+	name = r.root.sanitizer.Name(name)
+	if r.root.reporter != nil {
+		r.root.reporter.ReportCounter(name, tags, n)
+	} else if r.root.cachedReporter != nil {
+		r.root.cachedReporter.AllocateCounter(name, tags).ReportCount(n)
+	}
+}
+
 // Records internal Metrics' cardinalities.
 func (r *scopeRegistry) reportInternalMetrics() {
+	// NB: the throttle factor is reported regardless of the internal
+	// metrics option, as the governor is opted into.
+	if g := r.governor; g != nil {
+		name := r.root.sanitizer.Name(throttleFactorName)
+		if r.root.reporter != nil {
+			r.root.reporter.ReportGauge(name, internalTags, g.throttleFactor())
+		} else if r.root.cachedReporter != nil {
+			r.root.cachedReporter.AllocateGauge(name, internalTags).ReportGauge(g.throttleFactor())
+		}
+	}
+
+	// NB: the churn callback is called regardless of the internal metrics
+	// option too.
+	r.reportSeriesChurn()
+
 	if r.internalMetricsOption != SendInternalMetrics {
 		return
 	}
 
+	r.reportInternalCounter(writesAfterCloseName, r.writesAfterClose.Swap(0))
+	r.reportInternalCounter(namespaceConflictsName, r.namespaceConflicts.Swap(0))
+	r.reportInternalCounter(reportErrorsName, r.reportErrors.Swap(0))
+	if c := r.cardinality; c != nil {
+		r.reportInternalCounter(cardinalityOverflowsName, c.overflows.Swap(0))
+	}
+
+	if h := r.reportIntervals; h != nil {
+		if r.root.reporter != nil {
+			h.report(h.name, h.tags, r.root.reporter)
+		} else if r.root.cachedReporter != nil {
+			h.cachedReport()
+		}
+	}
+
 	counters, gauges, histograms := atomic.Int64{}, atomic.Int64{}, atomic.Int64{}
 	rootCounters, rootGauges, rootHistograms := atomic.Int64{}, atomic.Int64{}, atomic.Int64{}
 	r.ForEachScope(
@@ -277,3 +593,13 @@ func (r *scopeRegistry) reportInternalMetrics() {
 		numHistograms.ReportCount(histograms.Load())
 	}
 }
+
+// writesInFlight returns the number of writes in flight to the metrics of
+// the registry.
+func (r *scopeRegistry) writesInFlight() int64 {
+	var n int64
+	r.ForEachScope(func(s *scope) {
+		n += s.guard.writesInFlight()
+	})
+	return n
+}
@@ -23,8 +23,10 @@ package tally
 import (
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 var (
@@ -181,3 +183,162 @@ func TestForEachScopeConcurrent(t *testing.T) {
 
 	<-done
 }
+
+type counterReportsReporter struct {
+	nullStatsReporter
+	reports map[string]int
+}
+
+func (r *counterReportsReporter) ReportCounter(name string, tags map[string]string, value int64) {
+	r.reports[name]++
+}
+
+func TestReportAliasedScopesOnce(t *testing.T) {
+	r := &counterReportsReporter{reports: make(map[string]int)}
+	root, closer := NewRootScope(ScopeOptions{
+		Reporter:           r,
+		MetricsOption:      OmitInternalMetrics,
+		SanitizeOptions:    &alphanumericSanitizerOpts,
+		ReportZeroValues:   true,
+		registryShardCount: 4,
+	}, 0)
+	defer closer.Close()
+
+	// Reporting a scope twice would report its unchanged counters again
+	// as zero. The root scope is registered in every shard and the subscope under
+	// both its sanitized and its pre-sanitized key.
+	root.Counter("root").Inc(1)
+	sub := root.Tagged(map[string]string{"host": "a.b"})
+	sub.Counter("sub").Inc(1)
+	require.True(t, sub == root.Tagged(map[string]string{"host": "a_b"}))
+
+	root.(*scope).reportRegistry()
+	assert.Equal(t, map[string]int{
+		"root": 1,
+		"sub":  1,
+	}, r.reports)
+}
+
+func TestReportIntervalHistogram(t *testing.T) {
+	r := newTestStatsReporter()
+	root, closer := NewRootScope(ScopeOptions{Reporter: r, MetricsOption: SendInternalMetrics}, time.Hour)
+	s := root.(*scope)
+
+	s.registry.RecordReportInterval(time.Hour)
+	s.registry.RecordReportInterval(3 * time.Hour)
+
+	r.cg.Add(numInternalMetrics)
+	r.hg.Add(2)
+	require.NoError(t, closer.Close())
+	r.WaitAll()
+
+	histograms := r.getHistograms()
+	require.NotNil(t, histograms[reportIntervalName])
+	assert.Equal(t, 1, histograms[reportIntervalName].durationSamples[time.Duration(1.01*float64(time.Hour))])
+	assert.Equal(t, 1, histograms[reportIntervalName].durationSamples[5*time.Hour])
+}
+
+func TestReportIntervalHistogramOmitted(t *testing.T) {
+	root, closer := NewRootScope(ScopeOptions{Reporter: NullStatsReporter, MetricsOption: OmitInternalMetrics}, time.Hour)
+	defer closer.Close()
+
+	s := root.(*scope)
+	assert.Nil(t, s.registry.reportIntervals)
+	s.registry.RecordReportInterval(time.Hour)
+}
+
+func TestWritesAfterClose(t *testing.T) {
+	var (
+		r        = newTestStatsReporter()
+		prefixes []string
+	)
+	root, closer := NewRootScope(ScopeOptions{
+		Reporter:      r,
+		MetricsOption: SendInternalMetrics,
+		OnWriteAfterClose: func(prefix string, tags map[string]string) {
+			prefixes = append(prefixes, prefix)
+		},
+	}, 0)
+	s := root.(*scope)
+
+	sub := root.SubScope("sub")
+	counter := sub.Counter("counter")
+	histogram := sub.Histogram("histogram", ValueBuckets{1})
+
+	// Writes after close but before the final report are not lost.
+	require.NoError(t, sub.(*scope).Close())
+	r.cg.Add(numInternalMetrics + 1)
+	counter.Inc(1)
+	s.reportRegistry()
+	r.WaitAll()
+	assert.Empty(t, prefixes)
+
+	counter.Inc(1)
+	histogram.RecordValue(1)
+	assert.Equal(t, []string{"sub", "sub"}, prefixes)
+
+	r.cg.Add(numInternalMetrics + 1)
+	s.reportRegistry()
+	r.WaitAll()
+	require.NotNil(t, r.counters[writesAfterCloseName])
+	assert.EqualValues(t, 2, r.counters[writesAfterCloseName].val)
+
+	// The counter is reset once reported.
+	r.cg.Add(numInternalMetrics)
+	s.reportRegistry()
+	r.WaitAll()
+	assert.Zero(t, s.registry.writesAfterClose.Load())
+
+	r.cg.Add(numInternalMetrics)
+	require.NoError(t, closer.Close())
+}
+
+func TestWriteAfterClosePolicyPanic(t *testing.T) {
+	root, closer := NewRootScope(ScopeOptions{
+		Reporter:              NullStatsReporter,
+		WriteAfterClosePolicy: PanicOnWriteAfterClose,
+	}, 0)
+	defer closer.Close()
+
+	sub := root.SubScope("sub")
+	counter := sub.Counter("counter")
+	require.NoError(t, sub.(*scope).Close())
+
+	counter.Inc(1)
+	root.(*scope).reportRegistry()
+	assert.Panics(t, func() { counter.Inc(1) })
+}
+
+func TestWriteAfterClosePolicyBuffer(t *testing.T) {
+	r := newTestStatsReporter()
+	root, closer := NewRootScope(ScopeOptions{
+		Reporter:              r,
+		MetricsOption:         OmitInternalMetrics,
+		WriteAfterClosePolicy: BufferWritesAfterClose,
+	}, 0)
+	s := root.(*scope)
+
+	sub := root.SubScope("sub")
+	counter := sub.Counter("counter")
+	require.NoError(t, sub.(*scope).Close())
+
+	r.cg.Add(1)
+	counter.Inc(1)
+	s.reportRegistry()
+	r.WaitAll()
+
+	// The closed scope is still reported.
+	r.cg.Add(1)
+	counter.Inc(2)
+	s.reportRegistry()
+	r.WaitAll()
+	assert.EqualValues(t, 2, r.getCounters()["sub.counter"].val)
+
+	// The final flush of the root scope reports the latest writes.
+	r.cg.Add(1)
+	counter.Inc(3)
+	require.NoError(t, closer.Close())
+	r.WaitAll()
+	assert.EqualValues(t, 3, r.getCounters()["sub.counter"].val)
+	assert.Zero(t, s.registry.writesAfterClose.Load())
+}
@@ -1329,3 +1329,233 @@ func TestScopeFlushOnClose(t *testing.T) {
 	assert.EqualValues(t, 1, counters["foo"].val)
 	assert.NoError(t, closer.Close())
 }
+
+func TestNextReportTime(t *testing.T) {
+	var (
+		start    = time.Unix(1000, 0)
+		interval = 10 * time.Second
+	)
+
+	tests := []struct {
+		name string
+		now  time.Time
+		want time.Time
+	}{
+		{"on schedule", start.Add(time.Second), start.Add(interval)},
+		{"exactly on boundary", start.Add(interval), start.Add(2 * interval)},
+		{"one missed", start.Add(interval + time.Second), start.Add(2 * interval)},
+		{"many missed", start.Add(5*interval + time.Second), start.Add(6 * interval)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, nextReportTime(start, tt.now, interval))
+		})
+	}
+}
+
+func TestTaggedPairsReturnsSameScope(t *testing.T) {
+	root, closer := NewRootScope(
+		ScopeOptions{
+			Prefix: "foo", Tags: map[string]string{"env": "test"}, Reporter: NullStatsReporter,
+		}, 0,
+	)
+	defer closer.Close()
+
+	fooScope := root.Tagged(map[string]string{"foo": "bar"})
+	assert.Equal(t, fooScope, TaggedKV(root, "foo", "bar"))
+	assert.Equal(t, fooScope, TaggedPairs(root, "foo", "bar"))
+
+	fooBarScope := TaggedPairs(root, "foo", "bar", "bar", "baz")
+	assert.Equal(t, fooBarScope, fooScope.Tagged(map[string]string{"bar": "baz"}))
+	assert.Equal(t, map[string]string{"env": "test", "foo": "bar", "bar": "baz"}, fooBarScope.(*scope).tags)
+
+	allocs := testing.AllocsPerRun(1000, func() {
+		TaggedKV(root, "foo", "bar")
+	})
+	assert.Zero(t, allocs)
+
+	require.NoError(t, closer.Close())
+	assert.Equal(t, NoopScope, TaggedKV(root, "foo", "qux"))
+}
+
+type orderRecordingReporter struct {
+	nullStatsReporter
+	names []string
+}
+
+func (r *orderRecordingReporter) ReportCounter(name string, tags map[string]string, value int64) {
+	r.names = append(r.names, KeyForPrefixedStringMap(name, tags))
+}
+
+func (r *orderRecordingReporter) ReportGauge(name string, tags map[string]string, value float64) {
+	r.names = append(r.names, KeyForPrefixedStringMap(name, tags))
+}
+
+func TestSortedReporting(t *testing.T) {
+	r := &orderRecordingReporter{}
+	root, closer := NewRootScope(ScopeOptions{
+		Reporter:        r,
+		MetricsOption:   OmitInternalMetrics,
+		SortedReporting: true,
+	}, 0)
+	defer closer.Close()
+
+	record := func() {
+		for _, v := range []string{"c", "a", "d", "b"} {
+			s := root.Tagged(map[string]string{"key": v})
+			for _, name := range []string{"z", "x", "y"} {
+				s.Counter(name).Inc(1)
+				s.Gauge(name + "_gauge").Update(1)
+			}
+		}
+		root.SubScope("sub").Counter("c").Inc(1)
+		root.Counter("b").Inc(1)
+		root.Counter("a").Inc(1)
+	}
+
+	record()
+	root.(*scope).reportRegistry()
+	first := r.names
+
+	assert.Len(t, first, 4*6+3)
+	assert.Equal(t, []string{"a+", "b+", "x+key=a", "y+key=a", "z+key=a", "x_gauge+key=a"}, first[:6])
+	assert.Equal(t, "sub.c+", first[len(first)-1])
+
+	r.names = nil
+	record()
+	root.(*scope).reportRegistry()
+	assert.Equal(t, first, r.names)
+
+	sub := root.SubScope("sub").(*scope)
+	require.NoError(t, sub.Close())
+	root.(*scope).reportRegistry()
+	root.(*scope).registry.ForEachScope(func(s *scope) {
+		assert.NotEqual(t, sub, s)
+	})
+}
+
+func TestFork(t *testing.T) {
+	r := newTestStatsReporter()
+	root, closer := NewRootScope(ScopeOptions{
+		Prefix:        "host",
+		Tags:          map[string]string{"env": "test"},
+		Reporter:      r,
+		MetricsOption: OmitInternalMetrics,
+	}, 0)
+	defer closer.Close()
+
+	fork := root.(ForkableScope).Fork("component", map[string]string{"owner": "host"})
+	assert.Equal(t, fork, root.(ForkableScope).Fork("component", map[string]string{"owner": "host"}))
+
+	r.cg.Add(2)
+	fork.Counter("requests").Inc(1)
+	fork.SubScope("db").Counter("queries").Inc(2)
+	root.(*scope).reportRegistry()
+	r.WaitAll()
+
+	counters := r.getCounters()
+	require.NotNil(t, counters["component.requests"])
+	assert.EqualValues(t, 1, counters["component.requests"].val)
+	assert.Equal(t, map[string]string{"env": "test", "owner": "host"}, counters["component.requests"].tags)
+	require.NotNil(t, counters["component.db.queries"])
+	assert.EqualValues(t, 2, counters["component.db.queries"].val)
+}
+
+type valueRecordingReporter struct {
+	nullStatsReporter
+	counters   map[string]int64
+	gauges     []string
+	histograms map[string]int64
+}
+
+func (r *valueRecordingReporter) ReportCounter(name string, tags map[string]string, value int64) {
+	r.counters[name] = value
+}
+
+func (r *valueRecordingReporter) ReportGauge(name string, tags map[string]string, value float64) {
+	r.gauges = append(r.gauges, KeyForPrefixedStringMap(name, tags))
+}
+
+func (r *valueRecordingReporter) ReportHistogramValueSamples(
+	name string,
+	tags map[string]string,
+	buckets Buckets,
+	bucketLowerBound float64,
+	bucketUpperBound float64,
+	samples int64,
+) {
+	r.histograms[fmt.Sprintf("%s:%v", name, bucketUpperBound)] = samples
+}
+
+func TestCounterTemporality(t *testing.T) {
+	r := &valueRecordingReporter{
+		counters:   make(map[string]int64),
+		histograms: make(map[string]int64),
+	}
+	root, closer := NewRootScope(ScopeOptions{
+		Reporter:           r,
+		MetricsOption:      OmitInternalMetrics,
+		CounterTemporality: CumulativeTemporality,
+	}, 0)
+	defer closer.Close()
+
+	root.Counter("requests").Inc(2)
+	root.(*scope).reportRegistry()
+	assert.Equal(t, int64(2), r.counters["requests"])
+
+	root.Counter("requests").Inc(3)
+	root.(*scope).reportRegistry()
+	assert.Equal(t, int64(5), r.counters["requests"])
+
+	cr := newTestStatsReporter()
+	cached, cachedCloser := NewRootScope(ScopeOptions{
+		CachedReporter:     cr,
+		MetricsOption:      OmitInternalMetrics,
+		CounterTemporality: CumulativeTemporality,
+	}, 0)
+	defer cachedCloser.Close()
+
+	cr.cg.Add(2)
+	cached.Counter("requests").Inc(2)
+	cached.(*scope).reportRegistry()
+	cached.Counter("requests").Inc(1)
+	cached.(*scope).reportRegistry()
+	cr.WaitAll()
+	assert.EqualValues(t, 3, cr.getCounters()["requests"].val)
+}
+
+func TestReportZeroValues(t *testing.T) {
+	for _, zero := range []bool{false, true} {
+		r := &valueRecordingReporter{
+			counters:   make(map[string]int64),
+			histograms: make(map[string]int64),
+		}
+		root, closer := NewRootScope(ScopeOptions{
+			Reporter:         r,
+			MetricsOption:    OmitInternalMetrics,
+			ReportZeroValues: zero,
+		}, 0)
+
+		root.Counter("declared")
+		root.Histogram("latency", ValueBuckets{10})
+		root.(*scope).reportRegistry()
+
+		if !zero {
+			assert.Empty(t, r.counters)
+			assert.Empty(t, r.histograms)
+		} else {
+			assert.Equal(t, map[string]int64{"declared": 0}, r.counters)
+			assert.Equal(t, map[string]int64{
+				"latency:10": 0,
+				fmt.Sprintf("latency:%v", math.MaxFloat64): 0,
+			}, r.histograms)
+		}
+
+		root.Counter("declared").Inc(2)
+		root.(*scope).reportRegistry()
+		assert.Equal(t, int64(2), r.counters["declared"])
+
+		require.NoError(t, closer.Close())
+	}
+}
@@ -0,0 +1,314 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tally
+
+import (
+	"hash/maphash"
+	"math"
+	"math/bits"
+	"sort"
+	"sync"
+)
+
+const (
+	minSetPrecision = 4
+	maxSetPrecision = 16
+)
+
+// Set counts the unique values observed between reports, like statsd sets,
+// e.g. the users making requests. Only the number of unique values is
+// reported, and nothing if no value was observed since the previous
+// report.
+type Set interface {
+	// Observe observes a value.
+	Observe(value string)
+}
+
+// SetOptions are the options of a set.
+type SetOptions struct {
+	// Precision if set estimates the number of unique values with a
+	// HyperLogLog sketch of 2^Precision registers rather than by keeping
+	// the values observed, so that the memory of the set is bounded. It is
+	// clamped to [4, 16], the standard error of the estimates is about
+	// 1.04/sqrt(2^Precision), e.g. 1.6% for a precision of 12.
+	Precision uint8
+}
+
+// SetScope is a Scope which can create sets. As Scope can't be extended,
+// sets are created by the function SetOf rather than by a method of Scope.
+type SetScope interface {
+	Scope
+
+	// Set returns the set with the given name, the options of an existing
+	// set are left as is.
+	Set(name string, opts SetOptions) Set
+}
+
+// SetReporter is implemented by StatsReporters which support sets
+// natively. Other reporters receive the number of unique values of sets as
+// gauges.
+type SetReporter interface {
+	// ReportSet reports the number of unique values observed by a set
+	// since the previous report.
+	ReportSet(name string, tags map[string]string, unique int64)
+}
+
+// CachedSetReporter is implemented by CachedStatsReporters which support
+// sets natively, other reporters allocate them as gauges like
+// SetReporter.
+type CachedSetReporter interface {
+	// AllocateSet pre allocates a set data structure with name and tags.
+	AllocateSet(name string, tags map[string]string) CachedSet
+}
+
+// CachedSet interface for reporting an individual set
+type CachedSet interface {
+	ReportSet(unique int64)
+}
+
+// SetOf returns s.Set(name, opts) if s is a SetScope, otherwise a set
+// discarding its values.
+func SetOf(s Scope, name string, opts SetOptions) Set {
+	if ss, ok := s.(SetScope); ok {
+		return ss.Set(name, opts)
+	}
+	return noopSet{}
+}
+
+func (s *scope) Set(name string, opts SetOptions) Set {
+	name = s.sanitizer.Name(name)
+	if set, ok := s.set(name); ok {
+		return set
+	}
+
+	s.registry.checkName(name)
+	if !s.metricEnabled(name) {
+		return noopSet{}
+	}
+	if o, ok := s.overflowScope(setSeries, name); ok {
+		return o.Set(name, opts)
+	}
+
+	s.gm.Lock()
+	defer s.gm.Unlock()
+
+	if set, ok := s.sets[name]; ok {
+		return set
+	}
+
+	st := newSet(opts, s.registry.seed, s.guard)
+	if s.cachedReporter != nil {
+		fullName := s.fullyQualifiedName(name)
+		if sr, ok := s.cachedReporter.(CachedSetReporter); ok {
+			st.cached = sr.AllocateSet(fullName, s.tags)
+		} else {
+			st.cachedGauge = s.cachedReporter.AllocateGauge(fullName, s.tags)
+		}
+	}
+	if s.sets == nil {
+		s.sets = make(map[string]*set)
+	}
+	s.sets[name] = st
+	s.registry.churn.record(s.tags, 1, false)
+	s.setsSlice = append(s.setsSlice, st)
+
+	return st
+}
+
+func (s *scope) set(sanitizedName string) (Set, bool) {
+	s.gm.RLock()
+	defer s.gm.RUnlock()
+
+	set, ok := s.sets[sanitizedName]
+	return set, ok
+}
+
+func (s *leveledScope) Set(name string, opts SetOptions) Set {
+	return leveledSet{s, s.scope.Set(name, opts)}
+}
+
+type leveledSet struct {
+	scope *leveledScope
+	set   Set
+}
+
+func (s leveledSet) Observe(value string) {
+	if s.scope.enabled() {
+		s.set.Observe(value)
+	}
+}
+
+type noopSet struct{}
+
+func (noopSet) Observe(string) {}
+
+// set is the set of a scope. It keeps either the values observed since the
+// previous report or, with a precision, the registers of a HyperLogLog
+// sketch of them.
+type set struct {
+	seed  maphash.Seed
+	guard *closeGuard
+
+	cached      CachedSet
+	cachedGauge CachedGauge
+
+	mu        sync.Mutex
+	values    map[string]struct{}
+	precision uint8
+	registers []uint8
+	observed  bool
+}
+
+func newSet(opts SetOptions, seed maphash.Seed, guard *closeGuard) *set {
+	s := &set{seed: seed, guard: guard}
+	if p := opts.Precision; p > 0 {
+		if p < minSetPrecision {
+			p = minSetPrecision
+		} else if p > maxSetPrecision {
+			p = maxSetPrecision
+		}
+		s.precision = p
+		s.registers = make([]uint8, 1<<p)
+	} else {
+		s.values = make(map[string]struct{})
+	}
+	return s
+}
+
+func (s *set) Observe(value string) {
+	if !s.guard.begin() {
+		return
+	}
+
+	var hash uint64
+	if s.registers != nil {
+		var h maphash.Hash
+		h.SetSeed(s.seed)
+		h.WriteString(value)
+		hash = h.Sum64()
+	}
+
+	s.mu.Lock()
+	s.observed = true
+	if s.registers == nil {
+		s.values[value] = struct{}{}
+	} else {
+		// NB: the first bits of the hash select the register, which keeps
+		// the longest run of leading zeros of the remaining bits.
+		i := hash >> (64 - s.precision)
+		rank := uint8(bits.LeadingZeros64(hash<<s.precision|1<<(s.precision-1))) + 1
+		if rank > s.registers[i] {
+			s.registers[i] = rank
+		}
+	}
+	s.mu.Unlock()
+	s.guard.end()
+}
+
+// take returns the number of unique values observed since it was last
+// called and resets the set, or false if no value was observed.
+func (s *set) take() (int64, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.observed {
+		return 0, false
+	}
+	s.observed = false
+
+	if s.registers == nil {
+		n := int64(len(s.values))
+		s.values = make(map[string]struct{}, len(s.values))
+		return n, true
+	}
+	n := hyperLogLogEstimate(s.registers)
+	for i := range s.registers {
+		s.registers[i] = 0
+	}
+	return n, true
+}
+
+// hyperLogLogEstimate returns the estimate of the number of unique values
+// of the registers of a HyperLogLog sketch, corrected by linear counting
+// for small cardinalities.
+func hyperLogLogEstimate(registers []uint8) int64 {
+	m := float64(len(registers))
+	var (
+		sum   float64
+		zeros int
+	)
+	for _, r := range registers {
+		sum += 1 / float64(uint64(1)<<r)
+		if r == 0 {
+			zeros++
+		}
+	}
+
+	var alpha float64
+	switch len(registers) {
+	case 16:
+		alpha = 0.673
+	case 32:
+		alpha = 0.697
+	case 64:
+		alpha = 0.709
+	default:
+		alpha = 0.7213 / (1 + 1.079/m)
+	}
+	estimate := alpha * m * m / sum
+	if estimate <= 2.5*m && zeros > 0 {
+		estimate = m * math.Log(m/float64(zeros))
+	}
+	return int64(math.Round(estimate))
+}
+
+func (s *set) report(name string, tags map[string]string, r StatsReporter) {
+	n, ok := s.take()
+	if !ok {
+		return
+	}
+	if sr, ok := r.(SetReporter); ok {
+		sr.ReportSet(name, tags, n)
+		return
+	}
+	r.ReportGauge(name, tags, float64(n))
+}
+
+func (s *set) cachedReport() {
+	n, ok := s.take()
+	if !ok {
+		return
+	}
+	if s.cached != nil {
+		s.cached.ReportSet(n)
+		return
+	}
+	s.cachedGauge.ReportGauge(float64(n))
+}
+
+func sortedSetNames(m map[string]*set) []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
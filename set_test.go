@@ -0,0 +1,90 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tally
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type setRecordingReporter struct {
+	nullStatsReporter
+	sets map[string][]int64
+}
+
+func (r *setRecordingReporter) ReportSet(name string, _ map[string]string, unique int64) {
+	r.sets[name] = append(r.sets[name], unique)
+}
+
+func TestSet(t *testing.T) {
+	r := &setRecordingReporter{sets: make(map[string][]int64)}
+	root, closer := NewRootScope(ScopeOptions{Reporter: r, MetricsOption: OmitInternalMetrics}, 0)
+	defer closer.Close()
+
+	users := SetOf(root, "users", SetOptions{})
+	assert.Equal(t, users, SetOf(root, "users", SetOptions{Precision: 12}))
+	users.Observe("a")
+	users.Observe("b")
+	users.Observe("a")
+	root.(*scope).reportRegistry()
+
+	// Nothing is reported without values since the previous report.
+	root.(*scope).reportRegistry()
+	users.Observe("a")
+	root.(*scope).reportRegistry()
+
+	assert.Equal(t, []int64{2, 1}, r.sets["users"])
+}
+
+func TestSetHyperLogLog(t *testing.T) {
+	r := &setRecordingReporter{sets: make(map[string][]int64)}
+	root, closer := NewRootScope(ScopeOptions{Reporter: r, MetricsOption: OmitInternalMetrics}, 0)
+	defer closer.Close()
+
+	users := SetOf(root, "users", SetOptions{Precision: 12})
+	for i := 0; i < 3; i++ {
+		users.Observe("a")
+	}
+	root.(*scope).reportRegistry()
+	for i := 0; i < 100000; i++ {
+		users.Observe(strconv.Itoa(i % 50000))
+	}
+	root.(*scope).reportRegistry()
+
+	assert.Len(t, root.(*scope).sets["users"].registers, 4096)
+	assert.Equal(t, int64(1), r.sets["users"][0])
+	// NB: the standard error is 1.6% but the hash seed is random, the
+	// bound is loose enough for the test not to be flaky.
+	assert.InEpsilon(t, 50000, r.sets["users"][1], 0.1)
+}
+
+func TestSetAsGauge(t *testing.T) {
+	r := &valueRecordingReporter{counters: make(map[string]int64)}
+	root, closer := NewRootScope(ScopeOptions{Reporter: r, MetricsOption: OmitInternalMetrics}, 0)
+	defer closer.Close()
+
+	SetOf(root.Tagged(map[string]string{"region": "eu"}), "users", SetOptions{}).Observe("a")
+	SetOf(NoopScope, "users", SetOptions{}).Observe("a")
+	root.(*scope).reportRegistry()
+	assert.Equal(t, []string{"users+region=eu"}, r.gauges)
+}
@@ -0,0 +1,219 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+//go:build linux || darwin
+// +build linux darwin
+
+package shm
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	tally "github.com/extrasalt/tally/v4"
+)
+
+// CollectorOptions is a set of options for a collector.
+type CollectorOptions struct {
+	// Capacity is the number of updates the ring can hold between two
+	// collections, it defaults to 65536.
+	Capacity int
+
+	// Interval is the interval at which updates are collected from the
+	// ring, if zero updates are only collected by calls to Collect.
+	Interval time.Duration
+
+	// HistogramBuckets are the buckets of histograms by fully qualified
+	// name, histograms which are not listed use the default buckets of
+	// the scope updates are collected into.
+	HistogramBuckets map[string]tally.Buckets
+}
+
+// Collector collects the metric updates written to a ring by the scopes of
+// any number of processes into a single scope, which reports them. It is
+// meant for pre-fork servers and similar workloads where each worker
+// process can't run its own report loop.
+//
+// A worker process which dies between reserving and writing an update
+// stalls collection, this is experimental.
+type Collector struct {
+	ring    *ring
+	scope   tally.Scope
+	buckets map[string]tally.Buckets
+
+	mu         sync.Mutex
+	counters   map[string]tally.Counter
+	gauges     map[string]tally.Gauge
+	timers     map[string]tally.Timer
+	histograms map[string]tally.Histogram
+	closed     bool
+	dropped    uint64
+
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewCollector creates the ring at path, truncating any existing file, and
+// returns a collector which collects the updates written to it into scope.
+// Worker processes write to the ring with the scope returned by OpenScope.
+func NewCollector(path string, scope tally.Scope, opts CollectorOptions) (*Collector, error) {
+	r, err := createRing(path, opts.Capacity)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Collector{
+		ring:       r,
+		scope:      scope,
+		buckets:    opts.HistogramBuckets,
+		counters:   make(map[string]tally.Counter),
+		gauges:     make(map[string]tally.Gauge),
+		timers:     make(map[string]tally.Timer),
+		histograms: make(map[string]tally.Histogram),
+		done:       make(chan struct{}),
+	}
+
+	if opts.Interval > 0 {
+		c.wg.Add(1)
+		go func() {
+			defer c.wg.Done()
+			c.collectLoop(opts.Interval)
+		}()
+	}
+
+	return c, nil
+}
+
+func (c *Collector) collectLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			c.Collect()
+		case <-c.done:
+			return
+		}
+	}
+}
+
+// Collect applies the updates written to the ring since the last
+// collection to the collector's scope and returns how many were applied.
+func (c *Collector) Collect() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return 0
+	}
+	return c.ring.read(c.apply)
+}
+
+// Dropped returns the number of updates dropped by writers since the ring
+// was created.
+func (c *Collector) Dropped() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return c.dropped
+	}
+	return c.ring.dropped()
+}
+
+// Close stops collecting, collects the remaining updates and unmaps the
+// ring. It does not close the collector's scope.
+func (c *Collector) Close() error {
+	close(c.done)
+	c.wg.Wait()
+	c.Collect()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.closed = true
+	c.dropped = c.ring.dropped()
+	return c.ring.Close()
+}
+
+func (c *Collector) apply(kind recordKind, key []byte, value uint64) {
+	switch kind {
+	case counterKind:
+		if m, ok := c.counters[string(key)]; ok {
+			m.Inc(int64(value))
+			return
+		}
+		if name, s, ok := c.decode(key); ok {
+			m := s.Counter(name)
+			c.counters[string(key)] = m
+			m.Inc(int64(value))
+		}
+	case gaugeKind:
+		if m, ok := c.gauges[string(key)]; ok {
+			m.Update(math.Float64frombits(value))
+			return
+		}
+		if name, s, ok := c.decode(key); ok {
+			m := s.Gauge(name)
+			c.gauges[string(key)] = m
+			m.Update(math.Float64frombits(value))
+		}
+	case timerKind:
+		if m, ok := c.timers[string(key)]; ok {
+			m.Record(time.Duration(value))
+			return
+		}
+		if name, s, ok := c.decode(key); ok {
+			m := s.Timer(name)
+			c.timers[string(key)] = m
+			m.Record(time.Duration(value))
+		}
+	case valueHistogramKind, durationHistogramKind:
+		m, ok := c.histograms[string(key)]
+		if !ok {
+			var (
+				name string
+				s    tally.Scope
+			)
+			if name, s, ok = c.decode(key); !ok {
+				return
+			}
+			m = s.Histogram(name, c.buckets[name])
+			c.histograms[string(key)] = m
+		}
+		if kind == valueHistogramKind {
+			m.RecordValue(math.Float64frombits(value))
+		} else {
+			m.RecordDuration(time.Duration(value))
+		}
+	}
+}
+
+// decode returns the name of the metric with the given key and the
+// subscope of the collector's scope with its tags.
+func (c *Collector) decode(key []byte) (string, tally.Scope, bool) {
+	name, tags, ok := decodeKey(key)
+	if !ok {
+		return "", nil, false
+	}
+	return name, c.scope.Tagged(tags), true
+}
@@ -0,0 +1,276 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+//go:build linux || darwin
+// +build linux darwin
+
+package shm
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync/atomic"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	ringMagic = 0x74616c6c79726e67 // "tallyrng"
+
+	headerSize = 64
+	magicOff   = 0
+	capOff     = 8
+	headOff    = 16
+	tailOff    = 24
+	droppedOff = 32
+
+	recordSize = 256
+	commitOff  = 0
+	kindOff    = 8
+	valueOff   = 16
+	keyOff     = 24
+	maxKeySize = recordSize - keyOff
+
+	defaultCapacity = 1 << 16
+)
+
+type recordKind uint8
+
+const (
+	counterKind recordKind = iota + 1
+	gaugeKind
+	timerKind
+	valueHistogramKind
+	durationHistogramKind
+)
+
+var errInvalidRing = errors.New("file is not a metrics ring")
+
+// ring is a multi producer, single consumer ring of fixed size metric
+// update records in a memory mapped file shared between processes.
+//
+// Producers reserve a record by advancing the head of the ring, fill it
+// in and commit it by storing its sequence number. The consumer reads
+// committed records in order and advances the tail. Records are dropped
+// rather than overwritten when the ring is full.
+type ring struct {
+	data     []byte
+	capacity uint64
+}
+
+func createRing(path string, capacity int) (*ring, error) {
+	if capacity <= 0 {
+		capacity = defaultCapacity
+	}
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	if err := f.Truncate(int64(headerSize + capacity*recordSize)); err != nil {
+		return nil, err
+	}
+	r, err := mapRing(f, headerSize+capacity*recordSize)
+	if err != nil {
+		return nil, err
+	}
+	atomic.StoreUint64(r.word(capOff), uint64(capacity))
+	atomic.StoreUint64(r.word(magicOff), ringMagic)
+	r.capacity = uint64(capacity)
+	return r, nil
+}
+
+func openRing(path string) (*ring, error) {
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	size := int(info.Size())
+	if size < headerSize {
+		return nil, errInvalidRing
+	}
+	r, err := mapRing(f, size)
+	if err != nil {
+		return nil, err
+	}
+	r.capacity = atomic.LoadUint64(r.word(capOff))
+	if atomic.LoadUint64(r.word(magicOff)) != ringMagic ||
+		uint64(size) != headerSize+r.capacity*recordSize {
+		_ = r.Close()
+		return nil, errInvalidRing
+	}
+	return r, nil
+}
+
+func mapRing(f *os.File, size int) (*ring, error) {
+	data, err := syscall.Mmap(
+		int(f.Fd()), 0, size,
+		syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("mmap: %v", err)
+	}
+	return &ring{data: data}, nil
+}
+
+// Close unmaps the ring.
+func (r *ring) Close() error {
+	return syscall.Munmap(r.data)
+}
+
+// word returns the 8 byte aligned word at off.
+func (r *ring) word(off uint64) *uint64 {
+	return (*uint64)(unsafe.Pointer(&r.data[off]))
+}
+
+func (r *ring) record(seq uint64) uint64 {
+	return headerSize + (seq%r.capacity)*recordSize
+}
+
+func (r *ring) dropped() uint64 {
+	return atomic.LoadUint64(r.word(droppedOff))
+}
+
+func (r *ring) drop() {
+	atomic.AddUint64(r.word(droppedOff), 1)
+}
+
+// write appends a record to the ring, dropping it if the ring is full.
+func (r *ring) write(kind recordKind, key []byte, value uint64) {
+	if len(key) > maxKeySize {
+		r.drop()
+		return
+	}
+
+	var seq uint64
+	for {
+		head := atomic.LoadUint64(r.word(headOff))
+		if head-atomic.LoadUint64(r.word(tailOff)) >= r.capacity {
+			r.drop()
+			return
+		}
+		if atomic.CompareAndSwapUint64(r.word(headOff), head, head+1) {
+			seq = head
+			break
+		}
+	}
+
+	off := r.record(seq)
+	*r.word(off + kindOff) = uint64(kind) | uint64(len(key))<<8
+	*r.word(off + valueOff) = value
+	copy(r.data[off+keyOff:off+recordSize], key)
+	atomic.StoreUint64(r.word(off+commitOff), seq+1)
+}
+
+// read calls f with every committed record in order, stopping at the
+// first record which is reserved but not yet committed. The key passed to
+// f is only valid until f returns.
+func (r *ring) read(f func(kind recordKind, key []byte, value uint64)) int {
+	var n int
+	for {
+		tail := atomic.LoadUint64(r.word(tailOff))
+		off := r.record(tail)
+		if atomic.LoadUint64(r.word(off+commitOff)) != tail+1 {
+			return n
+		}
+
+		kindAndLen := *r.word(off + kindOff)
+		keyLen := kindAndLen >> 8
+		f(
+			recordKind(kindAndLen&0xff),
+			r.data[off+keyOff:off+keyOff+keyLen],
+			*r.word(off + valueOff),
+		)
+		atomic.StoreUint64(r.word(tailOff), tail+1)
+		n++
+	}
+}
+
+// appendKey appends the encoding of a metric name and its tags to buf,
+// strings are length prefixed and tags are sorted by key. It returns false
+// if a string is too long to encode.
+func appendKey(buf []byte, name string, tags map[string]string) ([]byte, bool) {
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	if len(keys) > 0xff {
+		return buf, false
+	}
+	buf, ok := appendString(buf, name)
+	buf = append(buf, byte(len(keys)))
+	for _, k := range keys {
+		var okKey, okValue bool
+		buf, okKey = appendString(buf, k)
+		buf, okValue = appendString(buf, tags[k])
+		ok = ok && okKey && okValue
+	}
+	return buf, ok
+}
+
+func appendString(buf []byte, s string) ([]byte, bool) {
+	if len(s) > 0xff {
+		return buf, false
+	}
+	buf = append(buf, byte(len(s)))
+	return append(buf, s...), true
+}
+
+// decodeKey decodes a metric name and its tags encoded by appendKey.
+func decodeKey(key []byte) (string, map[string]string, bool) {
+	name, key, ok := decodeString(key)
+	if !ok || len(key) < 1 {
+		return "", nil, false
+	}
+	n := int(key[0])
+	key = key[1:]
+
+	tags := make(map[string]string, n)
+	for i := 0; i < n; i++ {
+		var k, v string
+		if k, key, ok = decodeString(key); !ok {
+			return "", nil, false
+		}
+		if v, key, ok = decodeString(key); !ok {
+			return "", nil, false
+		}
+		tags[k] = v
+	}
+	return name, tags, true
+}
+
+func decodeString(b []byte) (string, []byte, bool) {
+	if len(b) < 1 || len(b) < 1+int(b[0]) {
+		return "", nil, false
+	}
+	n := int(b[0])
+	return string(b[1 : 1+n]), b[1+n:], true
+}
@@ -0,0 +1,187 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+//go:build linux || darwin
+// +build linux darwin
+
+package shm
+
+import (
+	"io"
+	"math"
+	"time"
+
+	tally "github.com/extrasalt/tally/v4"
+)
+
+// OpenScope returns a scope whose metrics write every update to the ring at
+// path, which must have been created by NewCollector. The returned scope
+// has no report loop, the collector which created the ring aggregates and
+// reports the updates of all the processes writing to it.
+//
+// Updates are dropped if the ring is full, or if a metric name, tag key or
+// tag value is longer than 255 bytes or the encoded name and tags of a
+// metric are longer than 232 bytes.
+func OpenScope(path string, prefix string, tags map[string]string) (tally.Scope, io.Closer, error) {
+	r, err := openRing(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	s := &scope{
+		ring:      r,
+		prefix:    prefix,
+		separator: tally.DefaultSeparator,
+		tags:      copyTags(tags, nil),
+	}
+	return s, r, nil
+}
+
+type scope struct {
+	ring      *ring
+	prefix    string
+	separator string
+	tags      map[string]string
+}
+
+func (s *scope) Counter(name string) tally.Counter {
+	return counter{s.metric(name)}
+}
+
+func (s *scope) Gauge(name string) tally.Gauge {
+	return gauge{s.metric(name)}
+}
+
+func (s *scope) Timer(name string) tally.Timer {
+	return timer{s.metric(name)}
+}
+
+// Histogram returns a histogram which writes the recorded values to the
+// ring, the buckets are those configured on the collector.
+func (s *scope) Histogram(name string, _ tally.Buckets) tally.Histogram {
+	return histogram{s.metric(name)}
+}
+
+func (s *scope) Tagged(tags map[string]string) tally.Scope {
+	return &scope{
+		ring:      s.ring,
+		prefix:    s.prefix,
+		separator: s.separator,
+		tags:      copyTags(s.tags, tags),
+	}
+}
+
+func (s *scope) SubScope(prefix string) tally.Scope {
+	return &scope{
+		ring:      s.ring,
+		prefix:    s.fullyQualifiedName(prefix),
+		separator: s.separator,
+		tags:      s.tags,
+	}
+}
+
+func (s *scope) Capabilities() tally.Capabilities {
+	return capabilities{}
+}
+
+func (s *scope) fullyQualifiedName(name string) string {
+	if len(s.prefix) == 0 {
+		return name
+	}
+	return s.prefix + s.separator + name
+}
+
+func (s *scope) metric(name string) metric {
+	key, ok := appendKey(nil, s.fullyQualifiedName(name), s.tags)
+	return metric{ring: s.ring, key: key, valid: ok}
+}
+
+func copyTags(tags, other map[string]string) map[string]string {
+	result := make(map[string]string, len(tags)+len(other))
+	for k, v := range tags {
+		result[k] = v
+	}
+	for k, v := range other {
+		result[k] = v
+	}
+	return result
+}
+
+type capabilities struct{}
+
+func (capabilities) Reporting() bool { return true }
+func (capabilities) Tagging() bool   { return true }
+
+type metric struct {
+	ring  *ring
+	key   []byte
+	valid bool
+}
+
+func (m metric) write(kind recordKind, value uint64) {
+	if !m.valid {
+		m.ring.drop()
+		return
+	}
+	m.ring.write(kind, m.key, value)
+}
+
+type counter struct{ metric }
+
+func (c counter) Inc(delta int64) {
+	c.write(counterKind, uint64(delta))
+}
+
+type gauge struct{ metric }
+
+func (g gauge) Update(value float64) {
+	g.write(gaugeKind, math.Float64bits(value))
+}
+
+type timer struct{ metric }
+
+func (t timer) Record(d time.Duration) {
+	t.write(timerKind, uint64(d))
+}
+
+func (t timer) Start() tally.Stopwatch {
+	return tally.NewStopwatch(time.Now(), t)
+}
+
+func (t timer) RecordStopwatch(start time.Time) {
+	t.Record(time.Since(start))
+}
+
+type histogram struct{ metric }
+
+func (h histogram) RecordValue(value float64) {
+	h.write(valueHistogramKind, math.Float64bits(value))
+}
+
+func (h histogram) RecordDuration(d time.Duration) {
+	h.write(durationHistogramKind, uint64(d))
+}
+
+func (h histogram) Start() tally.Stopwatch {
+	return tally.NewStopwatch(time.Now(), h)
+}
+
+func (h histogram) RecordStopwatch(start time.Time) {
+	h.RecordDuration(time.Since(start))
+}
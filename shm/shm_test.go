@@ -0,0 +1,130 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+//go:build linux || darwin
+// +build linux darwin
+
+package shm
+
+import (
+	"math"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	tally "github.com/extrasalt/tally/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCollector(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "metrics")
+	root := tally.NewTestScope("", nil)
+	c, err := NewCollector(path, root, CollectorOptions{
+		HistogramBuckets: map[string]tally.Buckets{
+			"worker.latency": tally.ValueBuckets{1, 2},
+		},
+	})
+	require.NoError(t, err)
+
+	// Each worker maps the ring separately as another process would.
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		s, closer, err := OpenScope(path, "worker", map[string]string{"env": "test"})
+		require.NoError(t, err)
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer closer.Close()
+
+			tagged := s.Tagged(map[string]string{"pool": "a"})
+			for j := 0; j < 100; j++ {
+				tagged.Counter("requests").Inc(1)
+			}
+			s.Gauge("workers").Update(4)
+			s.SubScope("db").Timer("query").Record(time.Second)
+			s.Histogram("latency", nil).RecordValue(1.5)
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, 4*103, c.Collect())
+	assert.Equal(t, 0, c.Collect())
+	require.NoError(t, c.Close())
+	assert.Zero(t, c.Dropped())
+
+	snap := root.Snapshot()
+	assert.EqualValues(t, 400, snap.Counters()["worker.requests+env=test,pool=a"].Value())
+	assert.EqualValues(t, 4, snap.Gauges()["worker.workers+env=test"].Value())
+	assert.Len(t, snap.Timers()["worker.db.query+env=test"].Values(), 4)
+	assert.Equal(t, map[float64]int64{
+		1:               0,
+		2:               4,
+		math.MaxFloat64: 0,
+	}, snap.Histograms()["worker.latency+env=test"].Values())
+}
+
+func TestCollectorDropsWhenFull(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "metrics")
+	root := tally.NewTestScope("", nil)
+	c, err := NewCollector(path, root, CollectorOptions{Capacity: 2})
+	require.NoError(t, err)
+	defer c.Close()
+
+	s, closer, err := OpenScope(path, "", nil)
+	require.NoError(t, err)
+	defer closer.Close()
+
+	counter := s.Counter("requests")
+	for i := 0; i < 3; i++ {
+		counter.Inc(1)
+	}
+	s.Counter(strings.Repeat("x", 300)).Inc(1)
+
+	assert.EqualValues(t, 2, c.Dropped())
+	assert.Equal(t, 2, c.Collect())
+	counter.Inc(1)
+	assert.Equal(t, 1, c.Collect())
+	assert.EqualValues(t, 3, root.Snapshot().Counters()["requests+"].Value())
+}
+
+func TestOpenScopeInvalidRing(t *testing.T) {
+	_, _, err := OpenScope(filepath.Join(t.TempDir(), "missing"), "", nil)
+	assert.Error(t, err)
+}
+
+func TestKeyEncoding(t *testing.T) {
+	tags := map[string]string{"b": "2", "a": "1"}
+	key, ok := appendKey(nil, "name", tags)
+	require.True(t, ok)
+
+	name, decoded, ok := decodeKey(key)
+	require.True(t, ok)
+	assert.Equal(t, "name", name)
+	assert.Equal(t, tags, decoded)
+
+	_, ok = appendKey(nil, strings.Repeat("x", 256), nil)
+	assert.False(t, ok)
+	_, _, ok = decodeKey(key[:len(key)-1])
+	assert.False(t, ok)
+}
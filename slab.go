@@ -0,0 +1,80 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tally
+
+import "sync"
+
+// metricSlabs allocates counters and gauges from slabs, contiguous slices
+// of metrics, rather than individually. Registries with millions of series
+// then hold thousands of heap objects rather than millions, which reduces
+// the allocation rate and the number of objects the garbage collector
+// marks. A slab is only released once none of its metrics is referenced,
+// so the metrics of closed scopes may be retained.
+//
+// A nil *metricSlabs allocates metrics individually.
+type metricSlabs struct {
+	size int
+
+	mu       sync.Mutex
+	counters []counter
+	gauges   []gauge
+}
+
+func newMetricSlabs(size int) *metricSlabs {
+	if size <= 0 {
+		return nil
+	}
+	return &metricSlabs{size: size}
+}
+
+func (s *metricSlabs) counter(cachedCount CachedCount) *counter {
+	if s == nil {
+		return newCounter(cachedCount)
+	}
+
+	s.mu.Lock()
+	if len(s.counters) == 0 {
+		s.counters = make([]counter, s.size)
+	}
+	c := &s.counters[0]
+	s.counters = s.counters[1:]
+	s.mu.Unlock()
+
+	c.cachedCount = cachedCount
+	return c
+}
+
+func (s *metricSlabs) gauge(cachedGauge CachedGauge) *gauge {
+	if s == nil {
+		return newGauge(cachedGauge)
+	}
+
+	s.mu.Lock()
+	if len(s.gauges) == 0 {
+		s.gauges = make([]gauge, s.size)
+	}
+	g := &s.gauges[0]
+	s.gauges = s.gauges[1:]
+	s.mu.Unlock()
+
+	g.cachedGauge = cachedGauge
+	return g
+}
@@ -0,0 +1,116 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tally
+
+import (
+	"fmt"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetricSlabs(t *testing.T) {
+	var nilSlabs *metricSlabs
+	assert.NotSame(t, nilSlabs.counter(nil), nilSlabs.counter(nil))
+	assert.Nil(t, newMetricSlabs(0))
+
+	slabs := newMetricSlabs(2)
+	c1, c2 := slabs.counter(nil), slabs.counter(nil)
+	assert.Empty(t, slabs.counters)
+	slabs.counter(nil)
+	assert.Len(t, slabs.counters, 1)
+	c1.Inc(1)
+	c2.Inc(2)
+	assert.Equal(t, int64(1), c1.value())
+	assert.Equal(t, int64(2), c2.value())
+
+	g := slabs.gauge(nil)
+	g.Update(3)
+	assert.Equal(t, float64(3), g.value())
+	assert.Len(t, slabs.gauges, 1)
+}
+
+func TestMetricSlabSizeOption(t *testing.T) {
+	r := newTestStatsReporter()
+	root, closer := NewRootScope(ScopeOptions{
+		Reporter:       r,
+		MetricsOption:  OmitInternalMetrics,
+		MetricSlabSize: 4,
+	}, 0)
+
+	for i := 0; i < 10; i++ {
+		root.Counter(fmt.Sprintf("c%d", i)).Inc(int64(i + 1))
+	}
+	root.Gauge("g").Update(1)
+	root.Histogram("h", ValueBuckets{1}).RecordValue(2)
+
+	r.cg.Add(10)
+	r.gg.Add(1)
+	r.hg.Add(1)
+	root.(*scope).reportRegistry()
+	r.WaitAll()
+
+	counters := r.getCounters()
+	for i := 0; i < 10; i++ {
+		assert.Equal(t, int64(i+1), counters[fmt.Sprintf("c%d", i)].val)
+	}
+	assert.Equal(t, float64(1), r.getGauges()["g"].val)
+	require.NoError(t, closer.Close())
+}
+
+// benchmarkRegistryGC measures the duration of a garbage collection, and the
+// heap, of a registry with 200k metrics.
+func benchmarkRegistryGC(b *testing.B, slabSize int) {
+	root, closer := NewRootScope(ScopeOptions{
+		Reporter:       NullStatsReporter,
+		MetricSlabSize: slabSize,
+	}, 0)
+	defer closer.Close()
+
+	for i := 0; i < 100000; i++ {
+		s := root.Tagged(map[string]string{"id": fmt.Sprint(i % 1000)})
+		s.Counter(fmt.Sprint("counter", i))
+		s.Gauge(fmt.Sprint("gauge", i))
+	}
+	runtime.GC()
+
+	b.ResetTimer()
+
+	for n := 0; n < b.N; n++ {
+		runtime.GC()
+	}
+
+	b.StopTimer()
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	b.ReportMetric(float64(stats.HeapObjects), "heap-objects")
+	b.ReportMetric(float64(stats.HeapInuse), "heap-bytes")
+}
+
+func BenchmarkRegistryGCIndividualMetrics(b *testing.B) {
+	benchmarkRegistryGC(b, 0)
+}
+
+func BenchmarkRegistryGCSlabMetrics(b *testing.B) {
+	benchmarkRegistryGC(b, 1024)
+}
@@ -0,0 +1,226 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tally
+
+// IterableScope is a TestScope whose snapshot values can be visited one at
+// a time rather than materialized as maps, which matters for registries
+// with hundreds of thousands of series.
+//
+// The values of each scope are copied before they are visited, the visit
+// functions are called without holding any lock and may create metrics.
+// Returning false from a visit function stops iteration.
+type IterableScope interface {
+	TestScope
+
+	// ForEachCounter calls fn with a snapshot of every counter.
+	ForEachCounter(fn func(CounterSnapshot) bool)
+
+	// ForEachGauge calls fn with a snapshot of every gauge.
+	ForEachGauge(fn func(GaugeSnapshot) bool)
+
+	// ForEachTimer calls fn with a snapshot of every timer.
+	ForEachTimer(fn func(TimerSnapshot) bool)
+
+	// ForEachHistogram calls fn with a snapshot of every histogram.
+	ForEachHistogram(fn func(HistogramSnapshot) bool)
+}
+
+// MetricSnapshot is a single value of a streamed snapshot, exactly one of
+// its fields is set.
+type MetricSnapshot struct {
+	Counter   CounterSnapshot
+	Gauge     GaugeSnapshot
+	Timer     TimerSnapshot
+	Histogram HistogramSnapshot
+}
+
+// StreamSnapshot streams a snapshot of all counters, gauges, timers and
+// histograms of s, in that order, over the returned channel. The channel
+// is closed once every value has been sent or done is closed, callers that
+// stop receiving early must close done to release the streaming goroutine.
+func StreamSnapshot(s IterableScope, done <-chan struct{}) <-chan MetricSnapshot {
+	ch := make(chan MetricSnapshot)
+	go func() {
+		defer close(ch)
+
+		send := func(m MetricSnapshot) bool {
+			select {
+			case ch <- m:
+				return true
+			case <-done:
+				return false
+			}
+		}
+
+		stopped := false
+		s.ForEachCounter(func(c CounterSnapshot) bool {
+			stopped = !send(MetricSnapshot{Counter: c})
+			return !stopped
+		})
+		if stopped {
+			return
+		}
+		s.ForEachGauge(func(g GaugeSnapshot) bool {
+			stopped = !send(MetricSnapshot{Gauge: g})
+			return !stopped
+		})
+		if stopped {
+			return
+		}
+		s.ForEachTimer(func(t TimerSnapshot) bool {
+			stopped = !send(MetricSnapshot{Timer: t})
+			return !stopped
+		})
+		if stopped {
+			return
+		}
+		s.ForEachHistogram(func(h HistogramSnapshot) bool {
+			return send(MetricSnapshot{Histogram: h})
+		})
+	}()
+	return ch
+}
+
+func (s *scope) ForEachCounter(fn func(CounterSnapshot) bool) {
+	s.registry.forEachUniqueScope(func(ss *scope, tags map[string]string) bool {
+		// NB: the values are copied under the lock and visited without it,
+		// so that visit functions don't block metric creation or reports.
+		ss.cm.RLock()
+		snaps := make([]CounterSnapshot, 0, len(ss.counters))
+		for key, c := range ss.counters {
+			snaps = append(snaps, &counterSnapshot{
+				name:  ss.fullyQualifiedName(key),
+				tags:  tags,
+				value: c.snapshot(),
+			})
+		}
+		ss.cm.RUnlock()
+
+		for _, snap := range snaps {
+			if !fn(snap) {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+func (s *scope) ForEachGauge(fn func(GaugeSnapshot) bool) {
+	s.registry.forEachUniqueScope(func(ss *scope, tags map[string]string) bool {
+		ss.gm.RLock()
+		snaps := make([]GaugeSnapshot, 0, len(ss.gauges))
+		for key, g := range ss.gauges {
+			snaps = append(snaps, &gaugeSnapshot{
+				name:  ss.fullyQualifiedName(key),
+				tags:  tags,
+				value: g.snapshot(),
+			})
+		}
+		ss.gm.RUnlock()
+
+		for _, snap := range snaps {
+			if !fn(snap) {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+func (s *scope) ForEachTimer(fn func(TimerSnapshot) bool) {
+	s.registry.forEachUniqueScope(func(ss *scope, tags map[string]string) bool {
+		ss.tm.RLock()
+		snaps := make([]TimerSnapshot, 0, len(ss.timers))
+		for key, t := range ss.timers {
+			snaps = append(snaps, &timerSnapshot{
+				name:   ss.fullyQualifiedName(key),
+				tags:   tags,
+				values: t.snapshot(),
+			})
+		}
+		ss.tm.RUnlock()
+
+		for _, snap := range snaps {
+			if !fn(snap) {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+func (s *scope) ForEachHistogram(fn func(HistogramSnapshot) bool) {
+	s.registry.forEachUniqueScope(func(ss *scope, tags map[string]string) bool {
+		ss.hm.RLock()
+		snaps := make([]HistogramSnapshot, 0, len(ss.histograms))
+		for key, h := range ss.histograms {
+			snaps = append(snaps, &histogramSnapshot{
+				name:      ss.fullyQualifiedName(key),
+				tags:      tags,
+				buckets:   h.specification,
+				values:    h.snapshotValues(),
+				durations: h.snapshotDurations(),
+			})
+		}
+		ss.hm.RUnlock()
+
+		for _, snap := range snaps {
+			if !fn(snap) {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+// forEachUniqueScope calls f once for every scope of the registry, along
+// with a copy of the scope's tags, until f returns false. Unlike
+// ForEachScope, scopes registered under several keys are only visited
+// once and no registry lock is held while f is called.
+func (r *scopeRegistry) forEachUniqueScope(f func(s *scope, tags map[string]string) bool) {
+	for _, s := range r.uniqueScopes() {
+		// NB: tags are immutable, no lock required to read.
+		tags := make(map[string]string, len(s.tags))
+		for k, v := range s.tags {
+			tags[k] = v
+		}
+		if !f(s, tags) {
+			return
+		}
+	}
+}
+
+// uniqueScopes returns every scope of the registry once, even those
+// registered under several keys.
+func (r *scopeRegistry) uniqueScopes() []*scope {
+	var (
+		seen   = make(map[*scope]struct{})
+		scopes []*scope
+	)
+	r.ForEachScope(func(s *scope) {
+		if _, ok := seen[s]; ok {
+			return
+		}
+		seen[s] = struct{}{}
+		scopes = append(scopes, s)
+	})
+	return scopes
+}
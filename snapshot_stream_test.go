@@ -0,0 +1,146 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tally
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newIterableTestScope(t *testing.T) IterableScope {
+	s := NewTestScope("foo", map[string]string{"env": "test"})
+	s.Counter("beep").Inc(1)
+	s.Tagged(map[string]string{"service": "test"}).Counter("boop").Inc(2)
+	s.Gauge("bzzt").Update(2)
+	s.Timer("brrr").Record(time.Second)
+	s.Histogram("fizz", ValueBuckets{0, 2, 4}).RecordValue(1)
+
+	is, ok := s.(IterableScope)
+	require.True(t, ok)
+	return is
+}
+
+func TestForEachMatchesSnapshot(t *testing.T) {
+	s := newIterableTestScope(t)
+	snap := s.Snapshot()
+
+	counters := make(map[string]int64)
+	s.ForEachCounter(func(c CounterSnapshot) bool {
+		counters[KeyForPrefixedStringMap(c.Name(), c.Tags())] = c.Value()
+		return true
+	})
+	require.Len(t, counters, len(snap.Counters()))
+	for id, c := range snap.Counters() {
+		assert.Equal(t, c.Value(), counters[id], id)
+	}
+
+	var gauges, timers, histograms int
+	s.ForEachGauge(func(g GaugeSnapshot) bool {
+		assert.Equal(t, snap.Gauges()["foo.bzzt+env=test"].Value(), g.Value())
+		gauges++
+		return true
+	})
+	s.ForEachTimer(func(tm TimerSnapshot) bool {
+		assert.Equal(t, []time.Duration{time.Second}, tm.Values())
+		timers++
+		return true
+	})
+	s.ForEachHistogram(func(h HistogramSnapshot) bool {
+		assert.Equal(t, snap.Histograms()["foo.fizz+env=test"].Values(), h.Values())
+		histograms++
+		return true
+	})
+	assert.Equal(t, 1, gauges)
+	assert.Equal(t, 1, timers)
+	assert.Equal(t, 1, histograms)
+}
+
+func TestForEachStops(t *testing.T) {
+	s := newIterableTestScope(t)
+
+	var visited int
+	s.ForEachCounter(func(CounterSnapshot) bool {
+		visited++
+		return false
+	})
+	assert.Equal(t, 1, visited)
+}
+
+func TestStreamSnapshot(t *testing.T) {
+	s := newIterableTestScope(t)
+
+	var counters, gauges, timers, histograms int
+	for m := range StreamSnapshot(s, nil) {
+		switch {
+		case m.Counter != nil:
+			counters++
+		case m.Gauge != nil:
+			gauges++
+		case m.Timer != nil:
+			timers++
+		case m.Histogram != nil:
+			histograms++
+		}
+	}
+	assert.Equal(t, 2, counters)
+	assert.Equal(t, 1, gauges)
+	assert.Equal(t, 1, timers)
+	assert.Equal(t, 1, histograms)
+}
+
+func TestStreamSnapshotDone(t *testing.T) {
+	s := newIterableTestScope(t)
+
+	done := make(chan struct{})
+	ch := StreamSnapshot(s, done)
+	<-ch
+	close(done)
+	for range ch {
+	}
+}
+
+func TestStreamSnapshotConsumerCreatesMetrics(t *testing.T) {
+	s := NewTestScope("", nil)
+	s.Counter("beep").Inc(1)
+	s.Counter("bzzt").Inc(1)
+
+	// Creating a metric in the streamed scope while receiving takes its
+	// metric lock for writing, which must not deadlock with the stream.
+	received := make(chan struct{})
+	go func() {
+		defer close(received)
+		for m := range StreamSnapshot(s.(IterableScope), nil) {
+			if m.Counter != nil {
+				s.Counter("boop").Inc(1)
+			}
+		}
+	}()
+
+	select {
+	case <-received:
+	case <-time.After(time.Second):
+		require.FailNow(t, "stream deadlocked")
+	}
+	assert.Contains(t, s.Snapshot().Counters(), "boop+")
+}
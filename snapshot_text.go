@@ -0,0 +1,206 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tally
+
+import (
+	"bufio"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// textSeries is a series of a snapshot rendered as text.
+type textSeries struct {
+	name   string
+	typ    string
+	labels string
+	lines  []string
+}
+
+// WriteSnapshotText writes snap to w in a human-readable text format
+// modeled after the Prometheus text exposition format, sorted by name and
+// tags, e.g. to dump the metrics of a test scope in a test, a REPL or a
+// crash handler.
+//
+// Timers are written as summaries with their count and sum, and
+// histograms with their cumulative buckets, count and approximate sum.
+// Durations are written in seconds. Names are written as they are, without
+// sanitization.
+func WriteSnapshotText(w io.Writer, snap Snapshot) error {
+	var series []textSeries
+	for _, c := range snap.Counters() {
+		labels := formatTextLabels(c.Tags(), "")
+		series = append(series, textSeries{
+			name:   c.Name(),
+			typ:    "counter",
+			labels: labels,
+			lines:  []string{c.Name() + labels + " " + strconv.FormatInt(c.Value(), 10)},
+		})
+	}
+	for _, g := range snap.Gauges() {
+		labels := formatTextLabels(g.Tags(), "")
+		series = append(series, textSeries{
+			name:   g.Name(),
+			typ:    "gauge",
+			labels: labels,
+			lines:  []string{g.Name() + labels + " " + formatTextFloat(g.Value())},
+		})
+	}
+	for _, t := range snap.Timers() {
+		var sum time.Duration
+		for _, v := range t.Values() {
+			sum += v
+		}
+		labels := formatTextLabels(t.Tags(), "")
+		series = append(series, textSeries{
+			name:   t.Name(),
+			typ:    "summary",
+			labels: labels,
+			lines: []string{
+				t.Name() + "_count" + labels + " " + strconv.Itoa(len(t.Values())),
+				t.Name() + "_sum" + labels + " " + formatTextFloat(sum.Seconds()),
+			},
+		})
+	}
+	for _, h := range snap.Histograms() {
+		series = append(series, histogramTextSeries(h))
+	}
+
+	sort.Slice(series, func(i, j int) bool {
+		if series[i].name != series[j].name {
+			return series[i].name < series[j].name
+		}
+		if series[i].typ != series[j].typ {
+			return series[i].typ < series[j].typ
+		}
+		return series[i].labels < series[j].labels
+	})
+
+	bw := bufio.NewWriter(w)
+	for i, s := range series {
+		if i == 0 || s.name != series[i-1].name || s.typ != series[i-1].typ {
+			bw.WriteString("# TYPE " + s.name + " " + s.typ + "\n")
+		}
+		for _, line := range s.lines {
+			bw.WriteString(line + "\n")
+		}
+	}
+	return bw.Flush()
+}
+
+// histogramTextSeries renders a histogram snapshot with its cumulative
+// buckets, count and sum.
+func histogramTextSeries(h HistogramSnapshot) textSeries {
+	var (
+		name      = h.Name()
+		tags      = h.Tags()
+		durations = len(h.Durations()) > 0
+		lines     []string
+		count     int64
+		overflow  bool
+	)
+	// NB: the buckets are sorted by a histogramSnapshot so that snapshots
+	// of other implementations are supported.
+	buckets := (&histogramSnapshot{values: h.Values(), durations: h.Durations()}).sortedBuckets()
+	for _, b := range buckets {
+		count += b.samples
+		le := "+Inf"
+		switch {
+		case b.overflow:
+			overflow = true
+		case durations:
+			le = formatTextFloat(time.Duration(b.upperBound).Seconds())
+		default:
+			le = formatTextFloat(b.upperBound)
+		}
+		lines = append(lines,
+			name+"_bucket"+formatTextLabels(tags, le)+" "+strconv.FormatInt(count, 10))
+	}
+	if !overflow {
+		lines = append(lines,
+			name+"_bucket"+formatTextLabels(tags, "+Inf")+" "+strconv.FormatInt(count, 10))
+	}
+
+	sum := h.Sum()
+	if durations {
+		sum = time.Duration(sum).Seconds()
+	}
+	labels := formatTextLabels(tags, "")
+	lines = append(lines,
+		name+"_sum"+labels+" "+formatTextFloat(sum),
+		name+"_count"+labels+" "+strconv.FormatInt(count, 10),
+	)
+	return textSeries{name: name, typ: "histogram", labels: labels, lines: lines}
+}
+
+var textLabelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// formatTextLabels formats tags as sorted labels, followed by the le
+// label of a histogram bucket if set.
+func formatTextLabels(tags map[string]string, le string) string {
+	if len(tags) == 0 && le == "" {
+		return ""
+	}
+
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, k := range keys {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(k)
+		b.WriteString(`="`)
+		b.WriteString(textLabelEscaper.Replace(tags[k]))
+		b.WriteByte('"')
+	}
+	if le != "" {
+		if len(keys) > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(`le="`)
+		b.WriteString(le)
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+func formatTextFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	default:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+}
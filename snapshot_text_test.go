@@ -0,0 +1,67 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tally
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteSnapshotText(t *testing.T) {
+	s := NewTestScope("svc", map[string]string{"region": "us"})
+	s.Counter("requests").Inc(2)
+	s.Tagged(map[string]string{"method": `a"b`}).Counter("requests").Inc(1)
+	s.Gauge("workers").Update(1.5)
+	s.Timer("latency").Record(time.Second)
+	s.Timer("latency").Record(500 * time.Millisecond)
+	h := s.Histogram("size", ValueBuckets{1, 10})
+	h.RecordValue(0.5)
+	h.RecordValue(5)
+	h.RecordValue(50)
+	s.Histogram("wait", DurationBuckets{time.Millisecond}).RecordDuration(time.Microsecond)
+
+	var b strings.Builder
+	require.NoError(t, WriteSnapshotText(&b, s.Snapshot()))
+	assert.Equal(t, `# TYPE svc.latency summary
+svc.latency_count{region="us"} 2
+svc.latency_sum{region="us"} 1.5
+# TYPE svc.requests counter
+svc.requests{method="a\"b",region="us"} 1
+svc.requests{region="us"} 2
+# TYPE svc.size histogram
+svc.size_bucket{region="us",le="1"} 1
+svc.size_bucket{region="us",le="10"} 2
+svc.size_bucket{region="us",le="+Inf"} 3
+svc.size_sum{region="us"} 21
+svc.size_count{region="us"} 3
+# TYPE svc.wait histogram
+svc.wait_bucket{region="us",le="0.001"} 1
+svc.wait_bucket{region="us",le="+Inf"} 1
+svc.wait_sum{region="us"} 0.001
+svc.wait_count{region="us"} 1
+# TYPE svc.workers gauge
+svc.workers{region="us"} 1.5
+`, b.String())
+}
@@ -27,6 +27,7 @@ import (
 	"sync"
 	"sync/atomic"
 	"time"
+	"unsafe"
 
 	"github.com/extrasalt/tally/v4/internal/identity"
 )
@@ -59,10 +60,123 @@ func (c *capabilities) Tagging() bool {
 	return c.tagging
 }
 
+// AdditiveGauge is a Gauge which can also be incremented and decremented
+// atomically, e.g. to maintain the number of requests in flight or the
+// depth of a queue without shadowing the gauge with an atomic counter.
+type AdditiveGauge interface {
+	Gauge
+
+	// Add adds delta to the value of the gauge.
+	Add(delta float64)
+
+	// Sub subtracts delta from the value of the gauge.
+	Sub(delta float64)
+}
+
+// AddGauge adds delta to g and returns true if g is an AdditiveGauge,
+// otherwise it returns false and g is left unchanged.
+func AddGauge(g Gauge, delta float64) bool {
+	if ag, ok := g.(AdditiveGauge); ok {
+		ag.Add(delta)
+		return true
+	}
+	return false
+}
+
+// SubGauge is AddGauge subtracting delta from g.
+func SubGauge(g Gauge, delta float64) bool {
+	return AddGauge(g, -delta)
+}
+
+// closeGuard is shared by the metrics of a scope to detect writes made
+// after the scope was closed and its metrics were reported for the last
+// time, which are otherwise silently lost. If the registry is drainable,
+// it also tracks the writes in flight so that draining can wait for them.
+type closeGuard struct {
+	inFlight counterValue
+	cleared  uint32
+	scope    *scope
+	// drained is the registry's channel signalled by the writes ending
+	// while it drains, nil if the registry isn't drainable.
+	drained chan struct{}
+}
+
+func newCloseGuard(s *scope) *closeGuard {
+	return &closeGuard{scope: s, drained: s.registry.drained}
+}
+
+// begin starts a write, it returns false if the write must be dropped as
+// the registry is draining. Otherwise end must be called once the write is
+// done.
+func (g *closeGuard) begin() bool {
+	if g == nil || g.drained == nil {
+		return true
+	}
+	// NB: the write is counted in flight before checking whether the
+	// registry is draining, so that Drain either waits for it or the write
+	// sees the registry draining.
+	g.inFlight.add(1)
+	if g.scope.registry.draining.Load() {
+		g.inFlight.add(-1)
+		g.signalDrained()
+		return false
+	}
+	return true
+}
+
+// writesInFlight returns the number of writes started and not ended yet.
+func (g *closeGuard) writesInFlight() int64 {
+	return g.inFlight.load()
+}
+
+// end ends a write started by begin.
+func (g *closeGuard) end() {
+	if g == nil {
+		return
+	}
+	if g.drained != nil {
+		g.inFlight.add(-1)
+		if g.scope.registry.draining.Load() {
+			g.signalDrained()
+		}
+	}
+	g.checkWrite()
+}
+
+// signalDrained wakes Drain up to check the writes in flight again, it
+// never blocks as a pending signal is enough.
+func (g *closeGuard) signalDrained() {
+	select {
+	case g.drained <- struct{}{}:
+	default:
+	}
+}
+
+func (g *closeGuard) clear() {
+	atomic.StoreUint32(&g.cleared, 1)
+}
+
+func (g *closeGuard) checkWrite() {
+	if g != nil && atomic.LoadUint32(&g.cleared) == 1 {
+		g.scope.registry.recordWriteAfterClose(g.scope)
+	}
+}
+
 type counter struct {
 	prev        int64
-	curr        int64
+	curr        counterValue
 	cachedCount CachedCount
+	guard       *closeGuard
+	// reportZero is set to 1 to report the counter even if it wasn't
+	// incremented.
+	reportZero uint32
+	// rate reports the per second rate of the counter, nil if disabled.
+	rate *counterRate
+	// cumulative reports the total of the counter rather than its delta.
+	cumulative bool
+	// lastActive is when the counter was last seen incremented by a
+	// report, in Unix nanoseconds, if a metric TTL is set.
+	lastActive int64
 }
 
 func newCounter(cachedCount CachedCount) *counter {
@@ -70,46 +184,82 @@ func newCounter(cachedCount CachedCount) *counter {
 }
 
 func (c *counter) Inc(v int64) {
-	atomic.AddInt64(&c.curr, v)
+	if !c.guard.begin() {
+		return
+	}
+	c.curr.add(v)
+	c.guard.end()
 }
 
 func (c *counter) value() int64 {
-	curr := atomic.LoadInt64(&c.curr)
+	delta, _ := c.valueAndTotal()
+	return delta
+}
+
+// valueAndTotal returns the delta of the counter since it was last
+// reported, and its cumulative value since it was created.
+func (c *counter) valueAndTotal() (int64, int64) {
+	curr := c.curr.load()
 
 	prev := atomic.LoadInt64(&c.prev)
 	if prev == curr {
-		return 0
+		return 0, curr
 	}
 	atomic.StoreInt64(&c.prev, curr)
-	return curr - prev
+	return curr - prev, curr
+}
+
+// reported returns the value of the counter reported given its delta and
+// total.
+func (c *counter) reported(delta, total int64) int64 {
+	if c.cumulative {
+		return total
+	}
+	return delta
 }
 
 func (c *counter) report(name string, tags map[string]string, r StatsReporter) {
-	delta := c.value()
-	if delta == 0 {
+	delta, total := c.valueAndTotal()
+	if c.rate != nil {
+		c.rate.report(name, tags, delta, r)
+	}
+	if delta == 0 && atomic.LoadUint32(&c.reportZero) == 0 {
 		return
 	}
 
-	r.ReportCounter(name, tags, delta)
+	r.ReportCounter(name, tags, c.reported(delta, total))
+	if cr, ok := r.(CumulativeCounterReporter); ok {
+		cr.ReportCounterTotal(name, tags, total)
+	}
 }
 
 func (c *counter) cachedReport() {
-	delta := c.value()
-	if delta == 0 {
+	delta, total := c.valueAndTotal()
+	if c.rate != nil {
+		c.rate.cachedReport(delta)
+	}
+	if delta == 0 && atomic.LoadUint32(&c.reportZero) == 0 {
 		return
 	}
 
-	c.cachedCount.ReportCount(delta)
+	c.cachedCount.ReportCount(c.reported(delta, total))
+	if cc, ok := c.cachedCount.(CachedCumulativeCount); ok {
+		cc.ReportTotal(total)
+	}
 }
 
 func (c *counter) snapshot() int64 {
-	return atomic.LoadInt64(&c.curr) - atomic.LoadInt64(&c.prev)
+	return c.curr.load() - atomic.LoadInt64(&c.prev)
 }
 
 type gauge struct {
 	updated     uint64
 	curr        uint64
 	cachedGauge CachedGauge
+	guard       *closeGuard
+	// lastActive is when the gauge was last seen updated by a report, in
+	// Unix nanoseconds, if a metric TTL is set.
+	lastActive int64
 }
 
 func newGauge(cachedGauge CachedGauge) *gauge {
@@ -117,8 +267,33 @@ func newGauge(cachedGauge CachedGauge) *gauge {
 }
 
 func (g *gauge) Update(v float64) {
+	if !g.guard.begin() {
+		return
+	}
 	atomic.StoreUint64(&g.curr, math.Float64bits(v))
 	atomic.StoreUint64(&g.updated, 1)
+	g.guard.end()
+}
+
+// Add implements AdditiveGauge.
+func (g *gauge) Add(delta float64) {
+	if !g.guard.begin() {
+		return
+	}
+	for {
+		curr := atomic.LoadUint64(&g.curr)
+		next := math.Float64bits(math.Float64frombits(curr) + delta)
+		if atomic.CompareAndSwapUint64(&g.curr, curr, next) {
+			break
+		}
+	}
+	atomic.StoreUint64(&g.updated, 1)
+	g.guard.end()
+}
+
+// Sub implements AdditiveGauge.
+func (g *gauge) Sub(delta float64) {
+	g.Add(-delta)
 }
 
 func (g *gauge) value() float64 {
@@ -150,6 +325,12 @@ type timer struct {
 	reporter    StatsReporter
 	cachedTimer CachedTimer
 	unreported  timerValues
+	guard       *closeGuard
+	tiers       []*reportTier
+	// Durations shorter than minDuration are counted by below rather than
+	// recorded, if minDuration is positive.
+	minDuration time.Duration
+	below       Counter
 }
 
 type timerValues struct {
@@ -176,11 +357,22 @@ func newTimer(
 }
 
 func (t *timer) Record(interval time.Duration) {
+	if t.below != nil && interval < t.minDuration {
+		t.below.Inc(1)
+		return
+	}
+	if !t.guard.begin() {
+		return
+	}
 	if t.cachedTimer != nil {
 		t.cachedTimer.ReportTimer(interval)
 	} else {
 		t.reporter.ReportTimer(t.name, t.tags, interval)
 	}
+	for _, tier := range t.tiers {
+		tier.reporter.ReportTimer(t.name, t.tags, interval)
+	}
+	t.guard.end()
 }
 
 func (t *timer) Start() Stopwatch {
@@ -256,6 +448,16 @@ func (r *timerNoReporterSink) Capabilities() Capabilities {
 func (r *timerNoReporterSink) Flush() {
 }
 
+// cacheLineSize is the assumed size of a CPU cache line.
+const cacheLineSize = 64
+
+// paddedCounter is a counter followed by a cache line of padding so that
+// adjacent counters in a slice never share a cache line.
+type paddedCounter struct {
+	counter
+	_ [cacheLineSize]byte
+}
+
 type sampleCounter struct {
 	counter      *counter
 	cachedBucket CachedHistogramBucket
@@ -269,6 +471,22 @@ type histogram struct {
 	specification Buckets
 	buckets       []histogramBucket
 	samples       []sampleCounter
+	guard         *closeGuard
+	// reportZero is set to 1 to report the buckets even if they have no
+	// samples.
+	reportZero uint32
+	// shadow is a *histogram with finer buckets which values are also
+	// recorded to while a high resolution mode is enabled.
+	shadow unsafe.Pointer
+	// outliers handles the values outside of the histogram's outlier
+	// rule, nil if it has none.
+	outliers *histogramOutliers
+	// fine is the histogram with finer buckets of the histogram's
+	// resolution rule, if any.
+	fine *fineResolution
+	// lastActive is when the histogram was last seen recorded to by a
+	// report, in Unix nanoseconds, if a metric TTL is set.
+	lastActive int64
 }
 
 type histogramType int
@@ -285,6 +503,8 @@ func newHistogram(
 	reporter StatsReporter,
 	storage bucketStorage,
 	cachedHistogram CachedHistogram,
+	padBuckets bool,
+	slabs *metricSlabs,
 ) *histogram {
 	h := &histogram{
 		htype:         htype,
@@ -296,8 +516,21 @@ func newHistogram(
 		samples:       make([]sampleCounter, len(storage.hbuckets)),
 	}
 
+	// NB: bucket counters are allocated individually by default, which can
+	// place the counters of adjacent buckets on the same cache line and
+	// cause false sharing when different buckets are recorded to
+	// concurrently. Padded buckets trade memory to avoid that.
+	var padded []paddedCounter
+	if padBuckets {
+		padded = make([]paddedCounter, len(storage.hbuckets))
+	}
+
 	for i := range h.samples {
-		h.samples[i].counter = newCounter(nil)
+		if padded != nil {
+			h.samples[i].counter = &padded[i].counter
+		} else {
+			h.samples[i].counter = slabs.counter(nil)
+		}
 
 		if cachedHistogram != nil {
 			switch htype {
@@ -321,37 +554,48 @@ func newHistogram(
 func (h *histogram) report(name string, tags map[string]string, r StatsReporter) {
 	for i := range h.buckets {
 		samples := h.samples[i].counter.value()
-		if samples == 0 {
+		if samples == 0 && atomic.LoadUint32(&h.reportZero) == 0 {
 			continue
 		}
 
-		switch h.htype {
-		case valueHistogramType:
-			r.ReportHistogramValueSamples(
-				name,
-				tags,
-				h.specification,
-				valueLowerBound(h.buckets, i),
-				h.buckets[i].valueUpperBound,
-				samples,
-			)
-		case durationHistogramType:
-			r.ReportHistogramDurationSamples(
-				name,
-				tags,
-				h.specification,
-				durationLowerBound(h.buckets, i),
-				h.buckets[i].durationUpperBound,
-				samples,
-			)
-		}
+		h.reportBucket(name, tags, r, i, samples)
+	}
+}
+
+// reportBucket reports samples of the i-th bucket of the histogram.
+func (h *histogram) reportBucket(
+	name string,
+	tags map[string]string,
+	r StatsReporter,
+	i int,
+	samples int64,
+) {
+	switch h.htype {
+	case valueHistogramType:
+		r.ReportHistogramValueSamples(
+			name,
+			tags,
+			h.specification,
+			valueLowerBound(h.buckets, i),
+			h.buckets[i].valueUpperBound,
+			samples,
+		)
+	case durationHistogramType:
+		r.ReportHistogramDurationSamples(
+			name,
+			tags,
+			h.specification,
+			durationLowerBound(h.buckets, i),
+			h.buckets[i].durationUpperBound,
+			samples,
+		)
 	}
 }
 
 func (h *histogram) cachedReport() {
 	for i := range h.buckets {
 		samples := h.samples[i].counter.value()
-		if samples == 0 {
+		if samples == 0 && atomic.LoadUint32(&h.reportZero) == 0 {
 			continue
 		}
 
@@ -368,6 +612,12 @@ func (h *histogram) RecordValue(value float64) {
 	if h.htype != valueHistogramType {
 		return
 	}
+	if h.outliers != nil {
+		var ok bool
+		if value, ok = h.outliers.value(value); !ok {
+			return
+		}
+	}
 
 	// Find the highest inclusive of the bucket upper bound
 	// and emit directly to it. Since we use BucketPairs to derive
@@ -376,13 +626,30 @@ func (h *histogram) RecordValue(value float64) {
 	idx := sort.Search(len(h.buckets), func(i int) bool {
 		return h.buckets[i].valueUpperBound >= value
 	})
+	if !h.guard.begin() {
+		return
+	}
 	h.samples[idx].counter.Inc(1)
+	h.guard.end()
+
+	if shadow := h.loadShadow(); shadow != nil {
+		shadow.RecordValue(value)
+	}
+	if h.fine != nil && h.fine.sampled() {
+		h.fine.histogram.RecordValue(value)
+	}
 }
 
 func (h *histogram) RecordDuration(value time.Duration) {
 	if h.htype != durationHistogramType {
 		return
 	}
+	if h.outliers != nil {
+		var ok bool
+		if value, ok = h.outliers.duration(value); !ok {
+			return
+		}
+	}
 
 	// Find the highest inclusive of the bucket upper bound
 	// and emit directly to it. Since we use BucketPairs to derive
@@ -391,7 +658,22 @@ func (h *histogram) RecordDuration(value time.Duration) {
 	idx := sort.Search(len(h.buckets), func(i int) bool {
 		return h.buckets[i].durationUpperBound >= value
 	})
+	if !h.guard.begin() {
+		return
+	}
 	h.samples[idx].counter.Inc(1)
+	h.guard.end()
+
+	if shadow := h.loadShadow(); shadow != nil {
+		shadow.RecordDuration(value)
+	}
+	if h.fine != nil && h.fine.sampled() {
+		h.fine.histogram.RecordDuration(value)
+	}
+}
+
+func (h *histogram) loadShadow() *histogram {
+	return (*histogram)(atomic.LoadPointer(&h.shadow))
 }
 
 func (h *histogram) Start() Stopwatch {
@@ -403,6 +685,10 @@ func (h *histogram) RecordStopwatch(stopwatchStart time.Time) {
 	h.RecordDuration(d)
 }
 
+func (h *histogram) Buckets() Buckets {
+	return h.specification
+}
+
 func (h *histogram) snapshotValues() map[float64]int64 {
 	if h.htype != valueHistogramType {
 		return nil
@@ -21,6 +21,7 @@
 package tally
 
 import (
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -32,6 +33,15 @@ func BenchmarkCounterInc(b *testing.B) {
 	}
 }
 
+func BenchmarkCounterIncParallel(b *testing.B) {
+	c := &counter{}
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			c.Inc(1)
+		}
+	})
+}
+
 func BenchmarkReportCounterNoData(b *testing.B) {
 	c := &counter{}
 	for n := 0; n < b.N; n++ {
@@ -91,3 +101,31 @@ func BenchmarkTimerReport(b *testing.B) {
 		t.Record(time.Since(start))
 	}
 }
+
+func benchmarkHistogramSharedBuckets(b *testing.B, padBuckets bool) {
+	var (
+		buckets = MustMakeLinearValueBuckets(10, 10, 8)
+		storage = newBucketStorage(valueHistogramType, buckets)
+		h       = newHistogram(valueHistogramType, "h1", nil, NullStatsReporter, storage, nil, padBuckets, nil)
+		next    int64
+	)
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		// Each goroutine records into its own bucket, adjacent to the
+		// buckets of other goroutines.
+		value := float64(atomic.AddInt64(&next, 1)%int64(len(buckets))) * 10
+		for pb.Next() {
+			h.RecordValue(value)
+		}
+	})
+}
+
+func BenchmarkHistogramSharedBuckets(b *testing.B) {
+	b.Run("unpadded", func(b *testing.B) {
+		benchmarkHistogramSharedBuckets(b, false)
+	})
+	b.Run("padded", func(b *testing.B) {
+		benchmarkHistogramSharedBuckets(b, true)
+	})
+}
@@ -22,8 +22,10 @@ package tally
 
 import (
 	"math/rand"
+	"sync"
 	"testing"
 	"time"
+	"unsafe"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -100,6 +102,65 @@ func TestCounter(t *testing.T) {
 	assert.Equal(t, int64(1), r.last)
 }
 
+type cumulativeTestReporter struct {
+	statsTestReporter
+	total int64
+}
+
+func (r *cumulativeTestReporter) ReportCounterTotal(name string, tags map[string]string, total int64) {
+	r.total = total
+}
+
+type cumulativeTestCount struct {
+	delta int64
+	total int64
+}
+
+func (c *cumulativeTestCount) ReportCount(value int64) { c.delta = value }
+func (c *cumulativeTestCount) ReportTotal(total int64) { c.total = total }
+
+func TestCounterTotal(t *testing.T) {
+	counter := newCounter(nil)
+	r := &cumulativeTestReporter{}
+
+	counter.Inc(2)
+	counter.report("", nil, r)
+	assert.Equal(t, int64(2), r.last)
+	assert.Equal(t, int64(2), r.total)
+
+	counter.Inc(3)
+	counter.report("", nil, r)
+	assert.Equal(t, int64(3), r.last)
+	assert.Equal(t, int64(5), r.total)
+
+	cached := &cumulativeTestCount{}
+	counter = newCounter(cached)
+	counter.Inc(2)
+	counter.cachedReport()
+	counter.Inc(3)
+	counter.cachedReport()
+	assert.Equal(t, int64(3), cached.delta)
+	assert.Equal(t, int64(5), cached.total)
+}
+
+func TestCounterTotalWrappedReporter(t *testing.T) {
+	r := &cumulativeTestReporter{}
+	root := newRootScope(ScopeOptions{
+		Reporter:      r,
+		Tap:           TapFunc(func(MetricUpdate) {}),
+		Rollups:       []RollupRule{{Name: "*", DropTags: []string{"host"}}},
+		MetricsOption: OmitInternalMetrics,
+	}, 0)
+	defer root.Close()
+
+	c := root.Counter("requests")
+	c.Inc(2)
+	root.reportRegistry()
+	c.Inc(3)
+	root.reportRegistry()
+	assert.Equal(t, int64(5), r.total)
+}
+
 func TestGauge(t *testing.T) {
 	gauge := newGauge(nil)
 	r := newStatsTestReporter()
@@ -114,6 +175,33 @@ func TestGauge(t *testing.T) {
 	assert.Equal(t, float64(5678), r.last)
 }
 
+func TestGaugeAdd(t *testing.T) {
+	gauge := newGauge(nil)
+	r := newStatsTestReporter()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				gauge.Add(2)
+				gauge.Sub(1)
+			}
+		}()
+	}
+	wg.Wait()
+	gauge.report("", nil, r)
+	assert.Equal(t, float64(1000), r.last)
+
+	assert.True(t, SubGauge(gauge, 500))
+	gauge.report("", nil, r)
+	assert.Equal(t, float64(500), r.last)
+
+	assert.True(t, AddGauge(NoopScope.Gauge("g"), 1))
+	assert.False(t, AddGauge(struct{ Gauge }{gauge}, 1))
+}
+
 func TestTimer(t *testing.T) {
 	r := newStatsTestReporter()
 	timer := newTimer("t1", nil, r, nil)
@@ -129,7 +217,7 @@ func TestHistogramValueSamples(t *testing.T) {
 	r := newStatsTestReporter()
 	buckets := MustMakeLinearValueBuckets(0, 10, 10)
 	storage := newBucketStorage(valueHistogramType, buckets)
-	h := newHistogram(valueHistogramType, "h1", nil, r, storage, nil)
+	h := newHistogram(valueHistogramType, "h1", nil, r, storage, nil, false, nil)
 
 	var offset float64
 	for i := 0; i < 3; i++ {
@@ -151,7 +239,7 @@ func TestHistogramDurationSamples(t *testing.T) {
 	r := newStatsTestReporter()
 	buckets := MustMakeLinearDurationBuckets(0, 10*time.Millisecond, 10)
 	storage := newBucketStorage(durationHistogramType, buckets)
-	h := newHistogram(durationHistogramType, "h1", nil, r, storage, nil)
+	h := newHistogram(durationHistogramType, "h1", nil, r, storage, nil, false, nil)
 
 	var offset time.Duration
 	for i := 0; i < 3; i++ {
@@ -170,3 +258,24 @@ func TestHistogramDurationSamples(t *testing.T) {
 	assert.Equal(t, 5, r.durationSamples[60*time.Millisecond])
 	assert.Equal(t, buckets, r.buckets)
 }
+
+func TestHistogramPaddedBuckets(t *testing.T) {
+	r := newStatsTestReporter()
+	buckets := MustMakeLinearValueBuckets(0, 10, 10)
+	storage := newBucketStorage(valueHistogramType, buckets)
+	h := newHistogram(valueHistogramType, "h1", nil, r, storage, nil, true, nil)
+
+	for i := 1; i < len(h.samples); i++ {
+		prev := uintptr(unsafe.Pointer(h.samples[i-1].counter))
+		curr := uintptr(unsafe.Pointer(h.samples[i].counter))
+		assert.True(t, curr-prev >= cacheLineSize)
+	}
+
+	h.RecordValue(5)
+	h.RecordValue(55)
+	h.RecordValue(56)
+	h.report(h.name, h.tags, r)
+
+	assert.Equal(t, 1, r.valueSamples[10.0])
+	assert.Equal(t, 2, r.valueSamples[60.0])
+}
@@ -41,6 +41,7 @@ type cactusStatsReporter struct {
 	statter    statsd.Statter
 	sampleRate float32
 	bucketFmt  string
+	onError    func(error)
 }
 
 // Options is a set of options for the tally reporter.
@@ -53,6 +54,11 @@ type Options struct {
 	// formatting the metric name with the histogram bucket bound values.
 	// By default this will be set to the const DefaultHistogramBucketPrecision.
 	HistogramBucketNamePrecision uint
+
+	// OnError if set is called with the errors of the reporter, e.g. of
+	// the writes to the statsd server, which are dropped otherwise as
+	// reporters can't return errors.
+	OnError func(error)
 }
 
 // NewReporter wraps a statsd.Statter for use with tally. Use either
@@ -69,19 +75,20 @@ func NewReporter(statsd statsd.Statter, opts Options) tally.StatsReporter {
 		statter:    statsd,
 		sampleRate: opts.SampleRate,
 		bucketFmt:  "%." + strconv.Itoa(int(opts.HistogramBucketNamePrecision)) + "f",
+		onError:    opts.OnError,
 	}
 }
 
 func (r *cactusStatsReporter) ReportCounter(name string, tags map[string]string, value int64) {
-	r.statter.Inc(name, value, r.sampleRate)
+	r.handleError(r.statter.Inc(name, value, r.sampleRate))
 }
 
 func (r *cactusStatsReporter) ReportGauge(name string, tags map[string]string, value float64) {
-	r.statter.Gauge(name, int64(value), r.sampleRate)
+	r.handleError(r.statter.Gauge(name, int64(value), r.sampleRate))
 }
 
 func (r *cactusStatsReporter) ReportTimer(name string, tags map[string]string, interval time.Duration) {
-	r.statter.TimingDuration(name, interval, r.sampleRate)
+	r.handleError(r.statter.TimingDuration(name, interval, r.sampleRate))
 }
 
 func (r *cactusStatsReporter) ReportHistogramValueSamples(
@@ -92,11 +99,11 @@ func (r *cactusStatsReporter) ReportHistogramValueSamples(
 	bucketUpperBound float64,
 	samples int64,
 ) {
-	r.statter.Inc(
+	r.handleError(r.statter.Inc(
 		fmt.Sprintf("%s.%s-%s", name,
-			r.valueBucketString(bucketLowerBound),
-			r.valueBucketString(bucketUpperBound)),
-		samples, r.sampleRate)
+			valueBucketString(r.bucketFmt, bucketLowerBound),
+			valueBucketString(r.bucketFmt, bucketUpperBound)),
+		samples, r.sampleRate))
 }
 
 func (r *cactusStatsReporter) ReportHistogramDurationSamples(
@@ -107,14 +114,38 @@ func (r *cactusStatsReporter) ReportHistogramDurationSamples(
 	bucketUpperBound time.Duration,
 	samples int64,
 ) {
-	r.statter.Inc(
+	r.handleError(r.statter.Inc(
 		fmt.Sprintf("%s.%s-%s", name,
-			r.durationBucketString(bucketLowerBound),
-			r.durationBucketString(bucketUpperBound)),
-		samples, r.sampleRate)
+			durationBucketString(bucketLowerBound),
+			durationBucketString(bucketUpperBound)),
+		samples, r.sampleRate))
+}
+
+// handleError calls the error handler of the reporter with err, if any.
+func (r *cactusStatsReporter) handleError(err error) {
+	if err != nil && r.onError != nil {
+		r.onError(err)
+	}
+}
+
+// NotifyReportErrors implements tally.ReportErrorNotifier.
+func (r *cactusStatsReporter) NotifyReportErrors(onError func(error)) {
+	r.onError = chainErrorHandlers(r.onError, onError)
+}
+
+// chainErrorHandlers returns an error handler calling both first and then.
+func chainErrorHandlers(first, then func(error)) func(error) {
+	if first == nil {
+		return then
+	}
+	return func(err error) {
+		first(err)
+		then(err)
+	}
 }
 
-func (r *cactusStatsReporter) valueBucketString(
+func valueBucketString(
+	bucketFmt string,
 	upperBound float64,
 ) string {
 	if upperBound == math.MaxFloat64 {
@@ -123,10 +154,10 @@ func (r *cactusStatsReporter) valueBucketString(
 	if upperBound == -math.MaxFloat64 {
 		return "-infinity"
 	}
-	return fmt.Sprintf(r.bucketFmt, upperBound)
+	return fmt.Sprintf(bucketFmt, upperBound)
 }
 
-func (r *cactusStatsReporter) durationBucketString(
+func durationBucketString(
 	upperBound time.Duration,
 ) string {
 	if upperBound == time.Duration(math.MaxInt64) {
@@ -0,0 +1,315 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package statsd
+
+import (
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	tally "github.com/extrasalt/tally/v4"
+)
+
+const (
+	// DefaultMaxPacketSize is the default maximum size of the UDP packets
+	// written by a UDP reporter, it fits the payload of a packet in the
+	// common 1500 bytes MTU of Ethernet networks.
+	DefaultMaxPacketSize = 1432
+
+	counterType = "c"
+	gaugeType   = "g"
+	timerType   = "ms"
+)
+
+// UDPOptions is a set of options for a UDP reporter.
+type UDPOptions struct {
+	Options
+
+	// MaxPacketSize is the maximum size of a UDP packet. Lines are
+	// buffered until the next line doesn't fit in a packet or the
+	// reporter is flushed, and then written as a single packet. Lines
+	// larger than a packet are dropped and passed as errors to OnError.
+	// Use zero to specify DefaultMaxPacketSize.
+	MaxPacketSize int
+
+	// Tracer if set traces the writes of packets.
+	Tracer tally.ReportTracer
+}
+
+// UDPReporter is a tally reporter writing the statsd protocol over UDP
+// itself, without a statsd client.
+type UDPReporter interface {
+	tally.StatsReporter
+	io.Closer
+}
+
+type udpReporter struct {
+	conn       net.Conn
+	sampleRate float32
+	bucketFmt  string
+	maxPacket  int
+	tagging    bool
+	tracer     tally.ReportTracer
+	onError    func(error)
+
+	mu   sync.Mutex
+	buf  []byte
+	rand *rand.Rand
+}
+
+// NewUDPReporter returns a reporter writing to the statsd server at addr,
+// e.g. "127.0.0.1:8125". Counters, gauges and timers are written as
+// statsd counters, gauges and timers in milliseconds, histograms as one
+// counter per bucket like NewReporter. Tags aren't supported by the
+// statsd protocol and are dropped.
+func NewUDPReporter(addr string, opts UDPOptions) (UDPReporter, error) {
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	return newUDPReporter(conn, opts), nil
+}
+
+// NewDogStatsDReporter returns a reporter writing to the DogStatsD agent
+// at addr like NewUDPReporter, with the tags of metrics appended to their
+// lines in the DogStatsD format "name:value|type|#key:value,...".
+func NewDogStatsDReporter(addr string, opts UDPOptions) (UDPReporter, error) {
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	r := newUDPReporter(conn, opts)
+	r.tagging = true
+	return r, nil
+}
+
+func newUDPReporter(conn net.Conn, opts UDPOptions) *udpReporter {
+	if opts.SampleRate == 0 {
+		opts.SampleRate = 1
+	}
+	if opts.HistogramBucketNamePrecision == 0 {
+		opts.HistogramBucketNamePrecision = DefaultHistogramBucketNamePrecision
+	}
+	if opts.MaxPacketSize <= 0 {
+		opts.MaxPacketSize = DefaultMaxPacketSize
+	}
+	return &udpReporter{
+		conn:       conn,
+		sampleRate: opts.SampleRate,
+		bucketFmt:  "%." + strconv.Itoa(int(opts.HistogramBucketNamePrecision)) + "f",
+		maxPacket:  opts.MaxPacketSize,
+		tracer:     opts.Tracer,
+		onError:    opts.OnError,
+		buf:        make([]byte, 0, opts.MaxPacketSize),
+		rand:       rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+func (r *udpReporter) ReportCounter(name string, tags map[string]string, value int64) {
+	r.write(name, counterType, tags, func(b []byte) []byte {
+		return strconv.AppendInt(b, value, 10)
+	})
+}
+
+func (r *udpReporter) ReportGauge(name string, tags map[string]string, value float64) {
+	r.write(name, gaugeType, tags, func(b []byte) []byte {
+		return strconv.AppendFloat(b, value, 'f', -1, 64)
+	})
+}
+
+func (r *udpReporter) ReportTimer(name string, tags map[string]string, interval time.Duration) {
+	r.write(name, timerType, tags, func(b []byte) []byte {
+		return strconv.AppendFloat(b, float64(interval)/float64(time.Millisecond), 'f', -1, 64)
+	})
+}
+
+func (r *udpReporter) ReportHistogramValueSamples(
+	name string,
+	tags map[string]string,
+	buckets tally.Buckets,
+	bucketLowerBound,
+	bucketUpperBound float64,
+	samples int64,
+) {
+	r.ReportCounter(
+		fmt.Sprintf("%s.%s-%s", name,
+			valueBucketString(r.bucketFmt, bucketLowerBound),
+			valueBucketString(r.bucketFmt, bucketUpperBound)),
+		tags, samples)
+}
+
+func (r *udpReporter) ReportHistogramDurationSamples(
+	name string,
+	tags map[string]string,
+	buckets tally.Buckets,
+	bucketLowerBound,
+	bucketUpperBound time.Duration,
+	samples int64,
+) {
+	r.ReportCounter(
+		fmt.Sprintf("%s.%s-%s", name,
+			durationBucketString(bucketLowerBound),
+			durationBucketString(bucketUpperBound)),
+		tags, samples)
+}
+
+// write buffers the line "name:value|type", with the sample rate if it
+// isn't 1 and the tags if the reporter is tagging, writing the buffered
+// lines first if the line doesn't fit in the current packet.
+func (r *udpReporter) write(
+	name, typ string,
+	tags map[string]string,
+	appendValue func([]byte) []byte,
+) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.sampleRate < 1 && r.rand.Float32() >= r.sampleRate {
+		return
+	}
+
+	start := len(r.buf)
+	if start > 0 {
+		r.buf = append(r.buf, '\n')
+	}
+	r.buf = append(r.buf, name...)
+	r.buf = append(r.buf, ':')
+	r.buf = appendValue(r.buf)
+	r.buf = append(r.buf, '|')
+	r.buf = append(r.buf, typ...)
+	if r.sampleRate < 1 {
+		r.buf = append(r.buf, "|@"...)
+		r.buf = strconv.AppendFloat(r.buf, float64(r.sampleRate), 'f', -1, 32)
+	}
+	if r.tagging && len(tags) > 0 {
+		r.buf = appendDogStatsDTags(r.buf, tags)
+	}
+
+	lineStart := start
+	if start > 0 {
+		lineStart++
+	}
+	if n := len(r.buf) - lineStart; n > r.maxPacket {
+		// The line doesn't fit in any packet, drop it rather than
+		// sending an oversized datagram.
+		r.buf = r.buf[:start]
+		if r.onError != nil {
+			r.onError(fmt.Errorf(
+				"statsd: dropped %s line of %d bytes larger than the max packet size %d",
+				name, n, r.maxPacket))
+		}
+		return
+	}
+	if len(r.buf) <= r.maxPacket {
+		return
+	}
+
+	// The line doesn't fit, send the previous lines and keep it buffered.
+	line := append([]byte(nil), r.buf[lineStart:]...)
+	r.buf = r.buf[:start]
+	r.send()
+	r.buf = append(r.buf, line...)
+}
+
+var (
+	// dogStatsDTagReplacer replaces the characters delimiting lines and
+	// tags in the DogStatsD format.
+	dogStatsDTagReplacer = strings.NewReplacer(
+		",", "_", "|", "_", "#", "_", "\n", "_",
+	)
+	// dogStatsDTagKeyReplacer also replaces the character separating tag
+	// keys from values.
+	dogStatsDTagKeyReplacer = strings.NewReplacer(
+		",", "_", "|", "_", "#", "_", "\n", "_", ":", "_",
+	)
+)
+
+// appendDogStatsDTags appends "|#key:value,..." to b with tags sorted by
+// key.
+func appendDogStatsDTags(b []byte, tags map[string]string) []byte {
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	b = append(b, "|#"...)
+	for i, k := range keys {
+		if i > 0 {
+			b = append(b, ',')
+		}
+		b = append(b, dogStatsDTagKeyReplacer.Replace(k)...)
+		b = append(b, ':')
+		b = append(b, dogStatsDTagReplacer.Replace(tags[k])...)
+	}
+	return b
+}
+
+// send writes the buffered lines as a packet, it must be called with mu
+// held.
+func (r *udpReporter) send() {
+	if len(r.buf) == 0 {
+		return
+	}
+	end := tally.StartReportPhase(r.tracer, tally.SendPhase)
+	// NB: UDP writes are fire and forget, they only fail locally, e.g.
+	// when the server's port is known to be unreachable.
+	_, err := r.conn.Write(r.buf)
+	end()
+	if err != nil && r.onError != nil {
+		r.onError(err)
+	}
+	r.buf = r.buf[:0]
+}
+
+// NotifyReportErrors implements tally.ReportErrorNotifier.
+func (r *udpReporter) NotifyReportErrors(onError func(error)) {
+	r.onError = chainErrorHandlers(r.onError, onError)
+}
+
+func (r *udpReporter) Capabilities() tally.Capabilities {
+	return r
+}
+
+func (r *udpReporter) Reporting() bool {
+	return true
+}
+
+func (r *udpReporter) Tagging() bool {
+	return r.tagging
+}
+
+func (r *udpReporter) Flush() {
+	r.mu.Lock()
+	r.send()
+	r.mu.Unlock()
+}
+
+func (r *udpReporter) Close() error {
+	r.Flush()
+	return r.conn.Close()
+}
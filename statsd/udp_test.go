@@ -0,0 +1,196 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package statsd
+
+import (
+	"math"
+	"net"
+	"testing"
+	"time"
+
+	tally "github.com/extrasalt/tally/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestUDPServer(t *testing.T) net.PacketConn {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	return conn
+}
+
+func readPacket(t *testing.T, conn net.PacketConn) string {
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	buf := make([]byte, 65536)
+	n, _, err := conn.ReadFrom(buf)
+	require.NoError(t, err)
+	return string(buf[:n])
+}
+
+func TestUDPReporter(t *testing.T) {
+	server := newTestUDPServer(t)
+	defer server.Close()
+
+	r, err := NewUDPReporter(server.LocalAddr().String(), UDPOptions{})
+	require.NoError(t, err)
+
+	r.ReportCounter("requests", map[string]string{"dropped": "tag"}, 2)
+	r.ReportGauge("workers", nil, 1.5)
+	r.ReportTimer("latency", nil, 1500*time.Microsecond)
+	r.ReportHistogramValueSamples("size", nil, nil, 0, math.MaxFloat64, 3)
+	r.Flush()
+
+	assert.Equal(t, "requests:2|c\nworkers:1.5|g\nlatency:1.5|ms\nsize.0.000000-infinity:3|c",
+		readPacket(t, server))
+	assert.False(t, r.Capabilities().Tagging())
+	require.NoError(t, r.Close())
+}
+
+func TestUDPReporterBatching(t *testing.T) {
+	server := newTestUDPServer(t)
+	defer server.Close()
+
+	r, err := NewUDPReporter(server.LocalAddr().String(), UDPOptions{MaxPacketSize: 25})
+	require.NoError(t, err)
+
+	// Each line is 11 bytes, two fit in a packet with their separator.
+	for i := 0; i < 5; i++ {
+		r.ReportCounter("counter", nil, int64(i))
+	}
+	assert.Equal(t, "counter:0|c\ncounter:1|c", readPacket(t, server))
+	assert.Equal(t, "counter:2|c\ncounter:3|c", readPacket(t, server))
+	require.NoError(t, r.Close())
+	assert.Equal(t, "counter:4|c", readPacket(t, server))
+}
+
+func TestUDPReporterOversizedLine(t *testing.T) {
+	server := newTestUDPServer(t)
+	defer server.Close()
+
+	var errs []error
+	r, err := NewUDPReporter(server.LocalAddr().String(), UDPOptions{
+		Options:       Options{OnError: func(err error) { errs = append(errs, err) }},
+		MaxPacketSize: 25,
+	})
+	require.NoError(t, err)
+
+	r.ReportCounter("counter", nil, 1)
+	r.ReportCounter("a_counter_with_a_long_name", nil, 1)
+	r.ReportCounter("counter", nil, 2)
+	require.NoError(t, r.Close())
+
+	assert.Equal(t, "counter:1|c\ncounter:2|c", readPacket(t, server))
+	require.Len(t, errs, 1)
+	assert.Contains(t, errs[0].Error(), "a_counter_with_a_long_name")
+}
+
+func TestUDPReporterSampleRate(t *testing.T) {
+	server := newTestUDPServer(t)
+	defer server.Close()
+
+	r, err := NewUDPReporter(server.LocalAddr().String(), UDPOptions{
+		Options: Options{SampleRate: 0.5},
+	})
+	require.NoError(t, err)
+
+	for i := 0; i < 100; i++ {
+		r.ReportCounter("c", nil, 1)
+	}
+	require.NoError(t, r.Close())
+
+	packet := readPacket(t, server)
+	assert.Contains(t, packet, "c:1|c|@0.5")
+	assert.True(t, len(packet) < 100*len("c:1|c|@0.5\n"))
+}
+
+func TestUDPReporterScope(t *testing.T) {
+	server := newTestUDPServer(t)
+	defer server.Close()
+
+	r, err := NewUDPReporter(server.LocalAddr().String(), UDPOptions{})
+	require.NoError(t, err)
+
+	scope, closer := tally.NewRootScope(tally.ScopeOptions{
+		Prefix:        "svc",
+		Reporter:      r,
+		MetricsOption: tally.OmitInternalMetrics,
+	}, 0)
+	scope.Counter("requests").Inc(1)
+	require.NoError(t, closer.Close())
+
+	assert.Equal(t, "svc.requests:1|c", readPacket(t, server))
+}
+
+func TestDogStatsDReporter(t *testing.T) {
+	server := newTestUDPServer(t)
+	defer server.Close()
+
+	r, err := NewDogStatsDReporter(server.LocalAddr().String(), UDPOptions{})
+	require.NoError(t, err)
+
+	r.ReportCounter("requests", map[string]string{"region": "us", "env": "prod"}, 2)
+	r.ReportGauge("workers", nil, 1)
+	r.ReportTimer("latency", map[string]string{"path": "a,b|c", "a:b,c": "d"}, time.Millisecond)
+	r.Flush()
+
+	assert.Equal(t, "requests:2|c|#env:prod,region:us\nworkers:1|g\nlatency:1|ms|#a_b_c:d,path:a_b_c",
+		readPacket(t, server))
+	assert.True(t, r.Capabilities().Tagging())
+	require.NoError(t, r.Close())
+}
+
+func TestDogStatsDReporterScope(t *testing.T) {
+	server := newTestUDPServer(t)
+	defer server.Close()
+
+	r, err := NewDogStatsDReporter(server.LocalAddr().String(), UDPOptions{})
+	require.NoError(t, err)
+
+	scope, closer := tally.NewRootScope(tally.ScopeOptions{
+		Reporter:      r,
+		MetricsOption: tally.OmitInternalMetrics,
+	}, 0)
+	scope.Tagged(map[string]string{"host": "a"}).Counter("requests").Inc(1)
+	require.NoError(t, closer.Close())
+
+	assert.Equal(t, "requests:1|c|#host:a", readPacket(t, server))
+}
+
+func TestUDPReporterOnError(t *testing.T) {
+	server := newTestUDPServer(t)
+	defer server.Close()
+
+	var errs []error
+	r, err := NewUDPReporter(server.LocalAddr().String(), UDPOptions{
+		Options: Options{OnError: func(err error) { errs = append(errs, err) }},
+	})
+	require.NoError(t, err)
+	var notified int
+	r.(tally.ReportErrorNotifier).NotifyReportErrors(func(error) { notified++ })
+
+	// Writes fail once the connection is closed.
+	require.NoError(t, r.Close())
+	r.ReportCounter("requests", nil, 1)
+	r.Flush()
+
+	assert.Len(t, errs, 1)
+	assert.Equal(t, 1, notified)
+}
@@ -0,0 +1,66 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tally
+
+import "time"
+
+// LapStopwatch times a multi-phase operation, recording the duration of
+// each phase as a lap and the duration of the whole operation once it is
+// stopped. It is not safe for concurrent use.
+type LapStopwatch struct {
+	total   Timer
+	laps    Scope
+	start   time.Time
+	lastLap time.Time
+}
+
+// StartLaps returns a LapStopwatch started now, recording the duration of
+// the whole operation to the timer name of s and its laps to the timers of
+// the subscope name of s.
+func StartLaps(s Scope, name string) *LapStopwatch {
+	now := globalNow()
+	return &LapStopwatch{
+		total:   s.Timer(name),
+		laps:    s.SubScope(name),
+		start:   now,
+		lastLap: now,
+	}
+}
+
+// Lap records the time elapsed since the previous lap, or since the
+// stopwatch was started for the first lap, to the timer of the lap with
+// the given name and returns it.
+func (sw *LapStopwatch) Lap(name string) time.Duration {
+	now := globalNow()
+	d := now.Sub(sw.lastLap)
+	sw.lastLap = now
+	sw.laps.Timer(name).Record(d)
+	return d
+}
+
+// Stop records the time elapsed since the stopwatch was started and
+// returns it. The time elapsed since the last lap isn't recorded as a lap,
+// call Lap first to record it.
+func (sw *LapStopwatch) Stop() time.Duration {
+	d := globalNow().Sub(sw.start)
+	sw.total.Record(d)
+	return d
+}
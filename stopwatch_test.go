@@ -0,0 +1,48 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tally
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLapStopwatch(t *testing.T) {
+	now := time.Unix(1e9, 0)
+	defer func(prev func() time.Time) { globalNow = prev }(globalNow)
+	globalNow = func() time.Time { return now }
+
+	s := NewTestScope("", nil)
+	sw := StartLaps(s, "request")
+	now = now.Add(time.Second)
+	assert.Equal(t, time.Second, sw.Lap("decode"))
+	now = now.Add(2 * time.Second)
+	assert.Equal(t, 2*time.Second, sw.Lap("handle"))
+	now = now.Add(time.Second)
+	assert.Equal(t, 4*time.Second, sw.Stop())
+
+	timers := s.Snapshot().Timers()
+	assert.Equal(t, []time.Duration{time.Second}, timers["request.decode+"].Values())
+	assert.Equal(t, []time.Duration{2 * time.Second}, timers["request.handle+"].Values())
+	assert.Equal(t, []time.Duration{4 * time.Second}, timers["request+"].Values())
+}
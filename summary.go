@@ -0,0 +1,414 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tally
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	defaultSummaryWindow     = 10 * time.Minute
+	defaultSummaryMaxSamples = 1000
+
+	// summaryAgeBuckets is the number of parts of the window of a summary,
+	// the oldest of which is dropped when the window slides.
+	summaryAgeBuckets = 5
+)
+
+// DefaultSummaryQuantiles are the quantiles of summaries created without
+// quantiles.
+var DefaultSummaryQuantiles = []float64{0.5, 0.9, 0.99}
+
+// Summary estimates quantiles of the values observed over a sliding window,
+// e.g. the median and 99th percentile of the latency of the last 10
+// minutes, for backends which want precomputed quantiles rather than
+// histograms.
+type Summary interface {
+	// Observe observes a value.
+	Observe(value float64)
+}
+
+// SummaryOptions are the options of a summary.
+type SummaryOptions struct {
+	// Quantiles are the quantiles to estimate, in [0, 1]. They default to
+	// DefaultSummaryQuantiles.
+	Quantiles []float64
+
+	// Window is the duration over which quantiles are estimated, it
+	// defaults to 10 minutes. The window slides by a fifth of its
+	// duration at a time.
+	Window time.Duration
+
+	// MaxSamples bounds the number of values kept for the window, it
+	// defaults to 1000. Past it, quantiles are estimated from a uniform
+	// sample of the values observed.
+	MaxSamples int
+
+	// Buckets are the buckets of the histogram the summary is created as
+	// if the scope's reporter doesn't support summaries, the default
+	// buckets of the scope if nil.
+	Buckets Buckets
+}
+
+// SummaryScope is a Scope which can create summaries. As Scope can't be
+// extended, summaries are created by the function SummaryOf rather than by
+// a method of Scope.
+type SummaryScope interface {
+	Scope
+
+	// Summary returns the summary with the given name, the options of an
+	// existing summary are left as is. If the scope's reporter doesn't
+	// implement SummaryReporter, or CachedSummaryReporter for a cached
+	// reporter, the summary is created as a histogram with opts.Buckets.
+	Summary(name string, opts SummaryOptions) Summary
+}
+
+// SummaryReporter is implemented by StatsReporters which support
+// summaries, e.g. as statsd or OpenTelemetry summaries.
+type SummaryReporter interface {
+	// ReportSummary reports the quantiles of a summary: values[i] is the
+	// estimate of quantiles[i] over the summary's window, in which count
+	// values summing to sum were observed. Summaries are only reported
+	// while their window isn't empty.
+	ReportSummary(
+		name string,
+		tags map[string]string,
+		quantiles []float64,
+		values []float64,
+		count int64,
+		sum float64,
+	)
+}
+
+// CachedSummaryReporter is implemented by CachedStatsReporters which
+// support summaries.
+type CachedSummaryReporter interface {
+	// AllocateSummary pre allocates a summary data structure with name,
+	// tags and quantiles.
+	AllocateSummary(
+		name string,
+		tags map[string]string,
+		quantiles []float64,
+	) CachedSummary
+}
+
+// CachedSummary interface for reporting an individual summary
+type CachedSummary interface {
+	ReportSummary(values []float64, count int64, sum float64)
+}
+
+// reportsSummaries returns whether the reporters of a root scope, before
+// they are wrapped, support summaries.
+func reportsSummaries(r StatsReporter, cr CachedStatsReporter) bool {
+	if r != nil {
+		_, ok := r.(SummaryReporter)
+		return ok
+	}
+	_, ok := cr.(CachedSummaryReporter)
+	return ok
+}
+
+// allocateSummary allocates a summary of r, which must have been checked to
+// support summaries. It's used by the wrappers of cached reporters.
+func allocateSummary(
+	r CachedStatsReporter,
+	name string,
+	tags map[string]string,
+	quantiles []float64,
+) CachedSummary {
+	return r.(CachedSummaryReporter).AllocateSummary(name, tags, quantiles)
+}
+
+// SummaryOf returns s.Summary(name, opts) if s is a SummaryScope,
+// otherwise s.Histogram(name, opts.Buckets) observing the values.
+func SummaryOf(s Scope, name string, opts SummaryOptions) Summary {
+	if ss, ok := s.(SummaryScope); ok {
+		return ss.Summary(name, opts)
+	}
+	return histogramSummary{s.Histogram(name, opts.Buckets)}
+}
+
+func (s *scope) Summary(name string, opts SummaryOptions) Summary {
+	name = s.sanitizer.Name(name)
+	if sum, ok := s.summary(name); ok {
+		return sum
+	}
+
+	// NB: support for summaries is checked on the reporter of the root
+	// scope before it's wrapped, the wrappers pass summaries through.
+	var (
+		reporter       SummaryReporter
+		cachedReporter CachedSummaryReporter
+		ok             = s.registry.reportsSummaries
+	)
+	if ok && s.reporter != nil {
+		reporter, ok = s.reporter.(SummaryReporter)
+	} else if ok && s.cachedReporter != nil {
+		cachedReporter, ok = s.cachedReporter.(CachedSummaryReporter)
+	}
+	if !ok {
+		return histogramSummary{s.Histogram(name, opts.Buckets)}
+	}
+
+	s.registry.checkName(name)
+	if !s.metricEnabled(name) {
+		return noopSummary{}
+	}
+	if o, ok := s.overflowScope(summarySeries, name); ok {
+		return o.Summary(name, opts)
+	}
+
+	s.hm.Lock()
+	defer s.hm.Unlock()
+
+	if sum, ok := s.summaries[name]; ok {
+		return sum
+	}
+
+	sum := newSummary(opts, s.guard)
+	sum.name = s.fullyQualifiedName(name)
+	sum.tags = s.tags
+	sum.reporter = reporter
+	if cachedReporter != nil {
+		sum.cached = cachedReporter.AllocateSummary(sum.name, sum.tags, sum.quantiles)
+	}
+	if s.summaries == nil {
+		s.summaries = make(map[string]*summary)
+	}
+	s.summaries[name] = sum
+	s.registry.churn.record(s.tags, 1, false)
+	s.summariesSlice = append(s.summariesSlice, sum)
+
+	return sum
+}
+
+func (s *scope) summary(sanitizedName string) (Summary, bool) {
+	s.hm.RLock()
+	defer s.hm.RUnlock()
+
+	sum, ok := s.summaries[sanitizedName]
+	return sum, ok
+}
+
+func (s *leveledScope) Summary(name string, opts SummaryOptions) Summary {
+	return leveledSummary{s, s.scope.Summary(name, opts)}
+}
+
+type leveledSummary struct {
+	scope   *leveledScope
+	summary Summary
+}
+
+func (s leveledSummary) Observe(value float64) {
+	if s.scope.enabled() {
+		s.summary.Observe(value)
+	}
+}
+
+type noopSummary struct{}
+
+func (noopSummary) Observe(float64) {}
+
+// histogramSummary is a summary created as a histogram.
+type histogramSummary struct {
+	histogram Histogram
+}
+
+func (s histogramSummary) Observe(value float64) {
+	s.histogram.RecordValue(value)
+}
+
+// summary is the summary of a scope. Its window is split into age buckets
+// each keeping a uniform sample of the values observed during its part of
+// the window.
+type summary struct {
+	name      string
+	tags      map[string]string
+	quantiles []float64
+	guard     *closeGuard
+
+	reporter SummaryReporter
+	cached   CachedSummary
+
+	mu sync.Mutex
+	// ages is a ring of the age buckets of the window, head is the index
+	// of the current one, which started at headStart.
+	ages      []summaryAge
+	head      int
+	headStart time.Time
+	ageWidth  time.Duration
+}
+
+// summaryAge is an age bucket of a summary.
+type summaryAge struct {
+	// samples is a uniform sample of the count values observed.
+	samples    []float64
+	maxSamples int
+	count      int64
+	sum        float64
+}
+
+func newSummary(opts SummaryOptions, guard *closeGuard) *summary {
+	quantiles := opts.Quantiles
+	if len(quantiles) == 0 {
+		quantiles = DefaultSummaryQuantiles
+	}
+	window := opts.Window
+	if window <= 0 {
+		window = defaultSummaryWindow
+	}
+	maxSamples := opts.MaxSamples
+	if maxSamples <= 0 {
+		maxSamples = defaultSummaryMaxSamples
+	}
+
+	ages := make([]summaryAge, summaryAgeBuckets)
+	for i := range ages {
+		ages[i].maxSamples = (maxSamples + summaryAgeBuckets - 1) / summaryAgeBuckets
+	}
+	return &summary{
+		quantiles: copyAndSortValues(quantiles),
+		guard:     guard,
+		ages:      ages,
+		headStart: globalNow(),
+		ageWidth:  window / summaryAgeBuckets,
+	}
+}
+
+func (s *summary) Observe(value float64) {
+	if !s.guard.begin() {
+		return
+	}
+	s.mu.Lock()
+	s.slide(globalNow())
+	s.ages[s.head].observe(value)
+	s.mu.Unlock()
+	s.guard.end()
+}
+
+func (a *summaryAge) observe(value float64) {
+	a.count++
+	a.sum += value
+	if len(a.samples) < a.maxSamples {
+		a.samples = append(a.samples, value)
+		return
+	}
+	// NB: reservoir sampling keeps every value observed with the same
+	// probability.
+	if i := rand.Int63n(a.count); i < int64(len(a.samples)) {
+		a.samples[i] = value
+	}
+}
+
+// slide drops the age buckets which are out of the window at now, it must
+// be called with mu held.
+func (s *summary) slide(now time.Time) {
+	for i := 0; i < len(s.ages) && now.Sub(s.headStart) >= s.ageWidth; i++ {
+		s.head = (s.head + 1) % len(s.ages)
+		s.headStart = s.headStart.Add(s.ageWidth)
+		s.ages[s.head].reset()
+	}
+	// NB: the window is empty if it slid entirely, restart it at now.
+	if now.Sub(s.headStart) >= s.ageWidth {
+		s.headStart = now
+	}
+}
+
+func (a *summaryAge) reset() {
+	a.samples = a.samples[:0]
+	a.count = 0
+	a.sum = 0
+}
+
+// weightedSample is a value of the sample of an age bucket, weighted by
+// the number of values observed it stands for.
+type weightedSample struct {
+	value  float64
+	weight float64
+}
+
+// snapshot returns the estimates of the quantiles of the summary over its
+// window, with the count and sum of the values of the window.
+func (s *summary) snapshot() (values []float64, count int64, sum float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.slide(globalNow())
+
+	var samples []weightedSample
+	for _, a := range s.ages {
+		count += a.count
+		sum += a.sum
+		weight := float64(a.count) / float64(len(a.samples))
+		for _, v := range a.samples {
+			samples = append(samples, weightedSample{v, weight})
+		}
+	}
+	if count == 0 {
+		return nil, 0, 0
+	}
+	sort.Slice(samples, func(i, j int) bool { return samples[i].value < samples[j].value })
+
+	values = make([]float64, len(s.quantiles))
+	for i, q := range s.quantiles {
+		values[i] = weightedQuantile(samples, float64(count), q)
+	}
+	return values, count, sum
+}
+
+// weightedQuantile returns the q-quantile of the sorted samples of total
+// weight total.
+func weightedQuantile(samples []weightedSample, total float64, q float64) float64 {
+	rank := math.Max(q*total, 0)
+	var seen float64
+	for _, s := range samples {
+		seen += s.weight
+		if seen >= rank {
+			return s.value
+		}
+	}
+	return samples[len(samples)-1].value
+}
+
+func (s *summary) report() {
+	values, count, sum := s.snapshot()
+	if count == 0 {
+		return
+	}
+	if s.cached != nil {
+		s.cached.ReportSummary(values, count, sum)
+		return
+	}
+	s.reporter.ReportSummary(s.name, s.tags, s.quantiles, values, count, sum)
+}
+
+func sortedSummaryNames(m map[string]*summary) []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,180 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tally
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type reportedSummary struct {
+	quantiles []float64
+	values    []float64
+	count     int64
+	sum       float64
+}
+
+type summaryRecordingReporter struct {
+	nullStatsReporter
+	summaries map[string]reportedSummary
+	reports   int
+}
+
+func (r *summaryRecordingReporter) ReportSummary(
+	name string,
+	_ map[string]string,
+	quantiles []float64,
+	values []float64,
+	count int64,
+	sum float64,
+) {
+	r.summaries[name] = reportedSummary{quantiles, values, count, sum}
+	r.reports++
+}
+
+func TestSummary(t *testing.T) {
+	now := time.Unix(1e9, 0)
+	defer func(prev func() time.Time) { globalNow = prev }(globalNow)
+	globalNow = func() time.Time { return now }
+
+	r := &summaryRecordingReporter{summaries: make(map[string]reportedSummary)}
+	root, closer := NewRootScope(ScopeOptions{Reporter: r, MetricsOption: OmitInternalMetrics}, 0)
+	defer closer.Close()
+
+	opts := SummaryOptions{Window: 5 * time.Minute}
+	latency := SummaryOf(root, "latency", opts)
+	assert.Equal(t, latency, SummaryOf(root, "latency", SummaryOptions{}))
+	for i := 1; i <= 100; i++ {
+		latency.Observe(float64(i))
+	}
+	root.(*scope).reportRegistry()
+
+	require.Contains(t, r.summaries, "latency")
+	assert.Equal(t, reportedSummary{
+		quantiles: DefaultSummaryQuantiles,
+		values:    []float64{50, 90, 99},
+		count:     100,
+		sum:       5050,
+	}, r.summaries["latency"])
+
+	// Quantiles are estimated over the whole window.
+	now = now.Add(3 * time.Minute)
+	latency.Observe(1000)
+	root.(*scope).reportRegistry()
+	assert.Equal(t, []float64{51, 91, 100}, r.summaries["latency"].values)
+
+	// The values slide out of the window a fifth of it at a time.
+	now = now.Add(3 * time.Minute)
+	root.(*scope).reportRegistry()
+	assert.Equal(t, int64(1), r.summaries["latency"].count)
+
+	// Empty windows aren't reported.
+	delete(r.summaries, "latency")
+	now = now.Add(5 * time.Minute)
+	root.(*scope).reportRegistry()
+	assert.Empty(t, r.summaries)
+}
+
+func TestSummaryMaxSamples(t *testing.T) {
+	r := &summaryRecordingReporter{summaries: make(map[string]reportedSummary)}
+	root, closer := NewRootScope(ScopeOptions{Reporter: r, MetricsOption: OmitInternalMetrics}, 0)
+	defer closer.Close()
+
+	s := SummaryOf(root, "size", SummaryOptions{Quantiles: []float64{0.5}, MaxSamples: 100})
+	for i := 0; i < 10000; i++ {
+		s.Observe(float64(45 + i%11))
+	}
+	root.(*scope).reportRegistry()
+
+	summary := r.summaries["size"]
+	assert.Equal(t, int64(10000), summary.count)
+	assert.InDelta(t, 50, summary.values[0], 5)
+	assert.Len(t, root.(*scope).summaries["size"].ages[0].samples, 20)
+}
+
+func TestSummaryAsHistogram(t *testing.T) {
+	r := &valueRecordingReporter{histograms: make(map[string]int64)}
+	root, closer := NewRootScope(ScopeOptions{Reporter: r, MetricsOption: OmitInternalMetrics}, 0)
+	defer closer.Close()
+
+	SummaryOf(root, "latency", SummaryOptions{Buckets: ValueBuckets{10}}).Observe(1)
+	SummaryOf(struct{ Scope }{root}, "size", SummaryOptions{Buckets: ValueBuckets{10}}).Observe(1)
+	root.(*scope).reportRegistry()
+	assert.Len(t, r.histograms, 2)
+}
+
+func TestSummaryShards(t *testing.T) {
+	r := &summaryRecordingReporter{summaries: make(map[string]reportedSummary)}
+	root, closer := NewRootScope(ScopeOptions{
+		Reporter:      r,
+		MetricsOption: OmitInternalMetrics,
+		// NB: the root scope is registered in every shard.
+		registryShardCount: 4,
+	}, 0)
+	defer closer.Close()
+
+	SummaryOf(root, "latency", SummaryOptions{}).Observe(1)
+	root.(*scope).reportRegistry()
+
+	assert.Equal(t, 1, r.reports)
+	assert.EqualValues(t, 1, r.summaries["latency"].count)
+}
+
+func TestSummaryWrappedReporter(t *testing.T) {
+	r := &summaryRecordingReporter{summaries: make(map[string]reportedSummary)}
+	var updates []MetricUpdate
+	root, closer := NewRootScope(ScopeOptions{
+		Reporter:      r,
+		MetricsOption: OmitInternalMetrics,
+		Tap:           TapFunc(func(u MetricUpdate) { updates = append(updates, u) }),
+		TagProviders: map[string]TagProvider{
+			"host": func() string { return "a" },
+		},
+	}, 0)
+	defer closer.Close()
+
+	latency := SummaryOf(root, "latency", SummaryOptions{})
+	require.IsType(t, &summary{}, latency)
+	latency.Observe(1)
+	latency.Observe(3)
+	root.(*scope).reportRegistry()
+
+	require.Contains(t, r.summaries, "latency")
+	assert.EqualValues(t, 2, r.summaries["latency"].count)
+	require.Len(t, updates, 1)
+	assert.Equal(t, SummaryKind, updates[0].Kind)
+	assert.Equal(t, "latency", updates[0].Name)
+	assert.Equal(t, map[string]string{"host": "a"}, updates[0].Tags)
+	assert.EqualValues(t, 2, updates[0].Value)
+	assert.Equal(t, DefaultSummaryQuantiles, updates[0].Quantiles)
+	assert.EqualValues(t, 4, updates[0].Sum)
+
+	// Summaries of reporters not supporting them are still histograms.
+	root, closer = NewRootScope(ScopeOptions{
+		Reporter: NullStatsReporter,
+		Tap:      TapFunc(func(MetricUpdate) {}),
+	}, 0)
+	defer closer.Close()
+	assert.IsType(t, histogramSummary{}, SummaryOf(root, "latency", SummaryOptions{}))
+}
@@ -0,0 +1,316 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tally
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// SwapOptions is a set of options for a SwappableCachedReporter.
+type SwapOptions struct {
+	// MaxReallocationsPerSecond limits the rate at which metrics are
+	// reallocated against a new reporter after a swap, so that swapping
+	// the reporter of a registry with many metrics doesn't allocate all
+	// of them at once. Metrics over the limit keep reporting to their
+	// previous allocation until they are reallocated on a later report.
+	// Zero means no limit.
+	MaxReallocationsPerSecond int
+}
+
+// SwappableCachedReporter is a CachedStatsReporter whose underlying
+// reporter can be swapped while scopes report to it, e.g. when the
+// connection of a reporter to its backend is recreated. The metrics
+// allocated before a swap are transparently reallocated against the new
+// reporter the next time they are reported, rather than requiring the
+// scopes to be recreated.
+type SwappableCachedReporter struct {
+	limiter *reallocationLimiter
+	gen     uint64
+
+	mu       sync.RWMutex
+	reporter CachedStatsReporter
+}
+
+var _ CachedStatsReporter = (*SwappableCachedReporter)(nil)
+
+// NewSwappableCachedReporter returns a SwappableCachedReporter initially
+// reporting to r.
+func NewSwappableCachedReporter(r CachedStatsReporter, opts SwapOptions) *SwappableCachedReporter {
+	return &SwappableCachedReporter{
+		limiter:  newReallocationLimiter(opts.MaxReallocationsPerSecond),
+		reporter: r,
+	}
+}
+
+// Swap replaces the underlying reporter with r. The metrics allocated
+// against the previous reporter are reallocated against r when they are
+// next reported, the previous reporter isn't flushed nor closed.
+func (r *SwappableCachedReporter) Swap(reporter CachedStatsReporter) {
+	r.mu.Lock()
+	r.reporter = reporter
+	atomic.AddUint64(&r.gen, 1)
+	r.mu.Unlock()
+}
+
+// Reporter returns the current underlying reporter.
+func (r *SwappableCachedReporter) Reporter() CachedStatsReporter {
+	reporter, _ := r.current()
+	return reporter
+}
+
+func (r *SwappableCachedReporter) current() (CachedStatsReporter, uint64) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.reporter, atomic.LoadUint64(&r.gen)
+}
+
+// Capabilities implements CachedStatsReporter.
+func (r *SwappableCachedReporter) Capabilities() Capabilities {
+	return r.Reporter().Capabilities()
+}
+
+// Flush implements CachedStatsReporter.
+func (r *SwappableCachedReporter) Flush() {
+	r.Reporter().Flush()
+}
+
+// AllocateCounter implements CachedStatsReporter.
+func (r *SwappableCachedReporter) AllocateCounter(name string, tags map[string]string) CachedCount {
+	return swappableCount{r.newMetric(func(reporter CachedStatsReporter) interface{} {
+		return reporter.AllocateCounter(name, tags)
+	})}
+}
+
+// AllocateGauge implements CachedStatsReporter.
+func (r *SwappableCachedReporter) AllocateGauge(name string, tags map[string]string) CachedGauge {
+	return swappableGauge{r.newMetric(func(reporter CachedStatsReporter) interface{} {
+		return reporter.AllocateGauge(name, tags)
+	})}
+}
+
+// AllocateTimer implements CachedStatsReporter.
+func (r *SwappableCachedReporter) AllocateTimer(name string, tags map[string]string) CachedTimer {
+	return swappableTimer{r.newMetric(func(reporter CachedStatsReporter) interface{} {
+		return reporter.AllocateTimer(name, tags)
+	})}
+}
+
+// AllocateHistogram implements CachedStatsReporter.
+func (r *SwappableCachedReporter) AllocateHistogram(
+	name string,
+	tags map[string]string,
+	buckets Buckets,
+) CachedHistogram {
+	return swappableHistogram{r.newMetric(func(reporter CachedStatsReporter) interface{} {
+		return reporter.AllocateHistogram(name, tags, buckets)
+	})}
+}
+
+func (r *SwappableCachedReporter) newMetric(
+	allocate func(CachedStatsReporter) interface{},
+) *swappableMetric {
+	reporter, gen := r.current()
+	m := &swappableMetric{r: r, allocate: allocate}
+	m.allocation.Store(swappableAllocation{gen: gen, metric: allocate(reporter)})
+	return m
+}
+
+// swappableAllocation is a metric allocated against the reporter of a
+// generation.
+type swappableAllocation struct {
+	gen    uint64
+	metric interface{}
+}
+
+// swappableMetric is a metric of a SwappableCachedReporter.
+type swappableMetric struct {
+	r          *SwappableCachedReporter
+	allocate   func(CachedStatsReporter) interface{}
+	allocation atomic.Value
+
+	// mu serializes reallocations.
+	mu sync.Mutex
+}
+
+// get returns the allocation of the metric, reallocating it first if the
+// reporter was swapped and the reallocation rate permits.
+func (m *swappableMetric) get() swappableAllocation {
+	curr := m.allocation.Load().(swappableAllocation)
+	if curr.gen == atomic.LoadUint64(&m.r.gen) {
+		return curr
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	curr = m.allocation.Load().(swappableAllocation)
+	reporter, gen := m.r.current()
+	if curr.gen == gen || !m.r.limiter.allow() {
+		return curr
+	}
+
+	next := swappableAllocation{gen: gen, metric: m.allocate(reporter)}
+	m.allocation.Store(next)
+	return next
+}
+
+type swappableCount struct {
+	m *swappableMetric
+}
+
+func (c swappableCount) ReportCount(value int64) {
+	c.m.get().metric.(CachedCount).ReportCount(value)
+}
+
+func (c swappableCount) ReportTotal(total int64) {
+	if cc, ok := c.m.get().metric.(CachedCumulativeCount); ok {
+		cc.ReportTotal(total)
+	}
+}
+
+type swappableGauge struct {
+	m *swappableMetric
+}
+
+func (g swappableGauge) ReportGauge(value float64) {
+	g.m.get().metric.(CachedGauge).ReportGauge(value)
+}
+
+type swappableTimer struct {
+	m *swappableMetric
+}
+
+func (t swappableTimer) ReportTimer(interval time.Duration) {
+	t.m.get().metric.(CachedTimer).ReportTimer(interval)
+}
+
+type swappableHistogram struct {
+	m *swappableMetric
+}
+
+func (h swappableHistogram) ValueBucket(
+	bucketLowerBound, bucketUpperBound float64,
+) CachedHistogramBucket {
+	return h.newBucket(func(hist CachedHistogram) CachedHistogramBucket {
+		return hist.ValueBucket(bucketLowerBound, bucketUpperBound)
+	})
+}
+
+func (h swappableHistogram) DurationBucket(
+	bucketLowerBound, bucketUpperBound time.Duration,
+) CachedHistogramBucket {
+	return h.newBucket(func(hist CachedHistogram) CachedHistogramBucket {
+		return hist.DurationBucket(bucketLowerBound, bucketUpperBound)
+	})
+}
+
+func (h swappableHistogram) newBucket(
+	allocate func(CachedHistogram) CachedHistogramBucket,
+) CachedHistogramBucket {
+	hist := h.m.get()
+	b := &swappableHistogramBucket{histogram: h.m, allocate: allocate}
+	b.allocation.Store(swappableAllocation{
+		gen:    hist.gen,
+		metric: allocate(hist.metric.(CachedHistogram)),
+	})
+	return b
+}
+
+// swappableHistogramBucket is a bucket of a swappable histogram, it is
+// reallocated from its histogram whenever the histogram is reallocated.
+type swappableHistogramBucket struct {
+	histogram  *swappableMetric
+	allocate   func(CachedHistogram) CachedHistogramBucket
+	allocation atomic.Value
+
+	// mu serializes reallocations.
+	mu sync.Mutex
+}
+
+func (b *swappableHistogramBucket) ReportSamples(value int64) {
+	b.get().ReportSamples(value)
+}
+
+func (b *swappableHistogramBucket) get() CachedHistogramBucket {
+	hist := b.histogram.get()
+	curr := b.allocation.Load().(swappableAllocation)
+	if curr.gen == hist.gen {
+		return curr.metric.(CachedHistogramBucket)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	curr = b.allocation.Load().(swappableAllocation)
+	if curr.gen != hist.gen {
+		curr = swappableAllocation{
+			gen:    hist.gen,
+			metric: b.allocate(hist.metric.(CachedHistogram)),
+		}
+		b.allocation.Store(curr)
+	}
+	return curr.metric.(CachedHistogramBucket)
+}
+
+// reallocationLimiter is a token bucket limiting the rate of
+// reallocations, a nil limiter allows every reallocation.
+type reallocationLimiter struct {
+	rate float64
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+func newReallocationLimiter(perSecond int) *reallocationLimiter {
+	if perSecond <= 0 {
+		return nil
+	}
+	return &reallocationLimiter{
+		rate:   float64(perSecond),
+		tokens: float64(perSecond),
+		last:   globalNow(),
+	}
+}
+
+func (l *reallocationLimiter) allow() bool {
+	if l == nil {
+		return true
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := globalNow()
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.rate {
+		l.tokens = l.rate
+	}
+	l.last = now
+
+	if l.tokens < 1 {
+		return false
+	}
+	l.tokens--
+	return true
+}
@@ -0,0 +1,149 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tally
+
+import (
+	"math"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSwappableCachedReporter(t *testing.T) {
+	r1, r2 := newTestStatsReporter(), newTestStatsReporter()
+	sw := NewSwappableCachedReporter(r1, SwapOptions{})
+	root, closer := NewRootScope(ScopeOptions{
+		CachedReporter: sw,
+		MetricsOption:  OmitInternalMetrics,
+	}, 0)
+
+	r1.cg.Add(1)
+	r1.hg.Add(1)
+	r1.tg.Add(1)
+	root.Counter("c").Inc(1)
+	root.Histogram("h", ValueBuckets{1}).RecordValue(2)
+	root.Timer("t").Record(time.Second)
+	root.(*scope).reportRegistry()
+	r1.WaitAll()
+	assert.Equal(t, int64(1), r1.getCounters()["c"].val)
+	assert.Equal(t, int64(time.Second), r1.getTimers()["t"].val)
+
+	sw.Swap(r2)
+	assert.Equal(t, r2, sw.Reporter())
+
+	r2.cg.Add(1)
+	r2.hg.Add(1)
+	r2.tg.Add(1)
+	root.Counter("c").Inc(2)
+	root.Histogram("h", ValueBuckets{1}).RecordValue(2)
+	root.Timer("t").Record(2 * time.Second)
+	root.(*scope).reportRegistry()
+	r2.WaitAll()
+
+	assert.Equal(t, int64(1), r1.getCounters()["c"].val)
+	assert.Equal(t, int64(2), r2.getCounters()["c"].val)
+	assert.Equal(t, int64(2*time.Second), r2.getTimers()["t"].val)
+	assert.Equal(t, 1, r2.getHistograms()["h"].valueSamples[math.MaxFloat64])
+
+	require.NoError(t, closer.Close())
+	assert.Equal(t, int32(1), atomic.LoadInt32(&r1.flushes))
+}
+
+// allocatingReporter is a CachedStatsReporter of counters recording their
+// allocations and reports.
+type allocatingReporter struct {
+	nullStatsReporter
+
+	mu          sync.Mutex
+	allocations int
+	reports     int
+}
+
+func (r *allocatingReporter) AllocateCounter(string, map[string]string) CachedCount {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.allocations++
+	return r
+}
+
+func (r *allocatingReporter) AllocateGauge(string, map[string]string) CachedGauge {
+	return nil
+}
+
+func (r *allocatingReporter) AllocateTimer(string, map[string]string) CachedTimer {
+	return nil
+}
+
+func (r *allocatingReporter) AllocateHistogram(string, map[string]string, Buckets) CachedHistogram {
+	return nil
+}
+
+func (r *allocatingReporter) ReportCount(int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.reports++
+}
+
+func TestSwappableCachedReporterReallocationLimit(t *testing.T) {
+	r1, r2 := &allocatingReporter{}, &allocatingReporter{}
+	sw := NewSwappableCachedReporter(r1, SwapOptions{MaxReallocationsPerSecond: 2})
+
+	counters := make([]CachedCount, 5)
+	for i := range counters {
+		counters[i] = sw.AllocateCounter("c", nil)
+	}
+	assert.Equal(t, 5, r1.allocations)
+
+	sw.Swap(r2)
+	for _, c := range counters {
+		c.ReportCount(1)
+	}
+	// Only the reallocations allowed by the limit were made, the other
+	// counters kept reporting to their previous allocation.
+	assert.Equal(t, 2, r2.allocations)
+	assert.Equal(t, 2, r2.reports)
+	assert.Equal(t, 3, r1.reports)
+
+	sw.limiter.last = sw.limiter.last.Add(-time.Second)
+	for _, c := range counters {
+		c.ReportCount(1)
+	}
+	assert.Equal(t, 4, r2.allocations)
+	assert.Equal(t, 6, r2.reports)
+	assert.Equal(t, 4, r1.reports)
+}
+
+func TestReallocationLimiter(t *testing.T) {
+	var unlimited *reallocationLimiter
+	assert.True(t, unlimited.allow())
+	assert.Nil(t, newReallocationLimiter(0))
+
+	l := newReallocationLimiter(1)
+	assert.True(t, l.allow())
+	assert.False(t, l.allow())
+	l.last = l.last.Add(-10 * time.Second)
+	assert.True(t, l.allow())
+	assert.False(t, l.allow())
+}
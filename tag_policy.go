@@ -0,0 +1,80 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tally
+
+import (
+	"fmt"
+	"sort"
+)
+
+// TagConflictPolicy is the behavior of child scopes created with a tag
+// whose key is already a tag of their parent with a different value.
+type TagConflictPolicy int
+
+const (
+	// OverrideParentTags gives child scopes the value of their own tag,
+	// overriding the value of their parent's tag. This is the default.
+	OverrideParentTags TagConflictPolicy = iota
+	// KeepParentTags gives child scopes the value of their parent's tag,
+	// discarding the value of their own tag.
+	KeepParentTags
+	// PanicOnTagConflict panics when creating a child scope with a
+	// conflicting tag, which is useful to catch accidental overrides in
+	// tests.
+	PanicOnTagConflict
+)
+
+// resolveTagConflicts applies the registry's tag conflict policy to the
+// sanitized tags of a child scope of parent, which it may modify.
+func (r *scopeRegistry) resolveTagConflicts(parent *scope, tags map[string]string) {
+	if r.tagConflictPolicy == OverrideParentTags {
+		return
+	}
+
+	conflicts := tagConflicts(parent, tags)
+	if len(conflicts) == 0 {
+		return
+	}
+
+	if r.tagConflictPolicy == PanicOnTagConflict {
+		sort.Strings(conflicts)
+		panic(fmt.Sprintf(
+			"tally: tags %v of scope with prefix %q conflict with its parent's tags %v",
+			conflicts, parent.prefix, parent.tags,
+		))
+	}
+
+	for _, k := range conflicts {
+		delete(tags, k)
+	}
+}
+
+// tagConflicts returns the keys of tags which are tags of parent with a
+// different value.
+func tagConflicts(parent *scope, tags map[string]string) []string {
+	var conflicts []string
+	for k, v := range tags {
+		if pv, ok := parent.tags[k]; ok && pv != v {
+			conflicts = append(conflicts, k)
+		}
+	}
+	return conflicts
+}
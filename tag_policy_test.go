@@ -0,0 +1,83 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tally
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTagConflictPolicy(t *testing.T) {
+	tests := []struct {
+		policy TagConflictPolicy
+		want   map[string]string
+	}{
+		{OverrideParentTags, map[string]string{"env": "staging", "region": "us"}},
+		{KeepParentTags, map[string]string{"env": "prod", "region": "us"}},
+	}
+	for _, tt := range tests {
+		root, closer := NewRootScope(ScopeOptions{
+			Tags:              map[string]string{"env": "prod"},
+			Reporter:          NullStatsReporter,
+			TagConflictPolicy: tt.policy,
+		}, 0)
+
+		child := root.Tagged(map[string]string{"env": "staging", "region": "us"})
+		assert.Equal(t, tt.want, Tags(child))
+		assert.Same(t, child, root.Tagged(map[string]string{"env": "staging", "region": "us"}))
+		assert.Same(t, child, TaggedPairs(root, "env", "staging", "region", "us"))
+
+		assert.NoError(t, closer.Close())
+	}
+}
+
+func TestKeepParentTagsSharesScope(t *testing.T) {
+	root := newRootScope(ScopeOptions{
+		Tags:              map[string]string{"env": "prod"},
+		Reporter:          NullStatsReporter,
+		TagConflictPolicy: KeepParentTags,
+	}, 0)
+	defer root.Close()
+
+	// Both children have the same effective tags so they are the same
+	// scope, rather than two scopes reporting the same series.
+	assert.Same(t,
+		root.Tagged(map[string]string{"region": "us"}),
+		root.Tagged(map[string]string{"env": "staging", "region": "us"}),
+	)
+}
+
+func TestPanicOnTagConflict(t *testing.T) {
+	root, closer := NewRootScope(ScopeOptions{
+		Tags:              map[string]string{"env": "prod"},
+		Reporter:          NullStatsReporter,
+		TagConflictPolicy: PanicOnTagConflict,
+	}, 0)
+	defer closer.Close()
+
+	assert.NotPanics(t, func() {
+		root.Tagged(map[string]string{"env": "prod", "region": "us"})
+	})
+	assert.Panics(t, func() {
+		root.Tagged(map[string]string{"env": "staging"})
+	})
+}
@@ -0,0 +1,119 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tally
+
+import "time"
+
+// TagProvider returns the current value of a tag, e.g. whether the process
+// is the leader or the version of its configuration. Providers are called
+// every time a value is reported, from multiple goroutines for timers, so
+// they must be fast and safe for concurrent use.
+type TagProvider func() string
+
+// newTagProviders returns a function adding the tags of providers to the
+// tags of reported values, sanitized by sanitizer. Tags of the metrics take
+// precedence over provided tags with the same key.
+func newTagProviders(
+	providers map[string]TagProvider,
+	sanitizer Sanitizer,
+) func(MetricKind, string, map[string]string) map[string]string {
+	sanitized := make(map[string]TagProvider, len(providers))
+	for k, p := range providers {
+		sanitized[sanitizer.Key(k)] = p
+	}
+
+	return func(_ MetricKind, _ string, tags map[string]string) map[string]string {
+		merged := make(map[string]string, len(tags)+len(sanitized))
+		for k, p := range sanitized {
+			merged[k] = sanitizer.Value(p())
+		}
+		for k, v := range tags {
+			merged[k] = v
+		}
+		return merged
+	}
+}
+
+// augmentTagsReporter reports values with the tags returned by augment
+// rather than the tags of their metrics.
+type augmentTagsReporter struct {
+	StatsReporter
+	augment func(kind MetricKind, name string, tags map[string]string) map[string]string
+}
+
+func (r augmentTagsReporter) ReportCounter(name string, tags map[string]string, value int64) {
+	r.StatsReporter.ReportCounter(name, r.augment(CounterKind, name, tags), value)
+}
+
+func (r augmentTagsReporter) ReportCounterTotal(name string, tags map[string]string, total int64) {
+	if cr, ok := r.StatsReporter.(CumulativeCounterReporter); ok {
+		cr.ReportCounterTotal(name, r.augment(CounterKind, name, tags), total)
+	}
+}
+
+func (r augmentTagsReporter) ReportGauge(name string, tags map[string]string, value float64) {
+	r.StatsReporter.ReportGauge(name, r.augment(GaugeKind, name, tags), value)
+}
+
+func (r augmentTagsReporter) ReportTimer(name string, tags map[string]string, interval time.Duration) {
+	r.StatsReporter.ReportTimer(name, r.augment(TimerKind, name, tags), interval)
+}
+
+func (r augmentTagsReporter) ReportHistogramValueSamples(
+	name string,
+	tags map[string]string,
+	buckets Buckets,
+	bucketLowerBound,
+	bucketUpperBound float64,
+	samples int64,
+) {
+	r.StatsReporter.ReportHistogramValueSamples(
+		name, r.augment(HistogramKind, name, tags), buckets,
+		bucketLowerBound, bucketUpperBound, samples,
+	)
+}
+
+func (r augmentTagsReporter) ReportHistogramDurationSamples(
+	name string,
+	tags map[string]string,
+	buckets Buckets,
+	bucketLowerBound,
+	bucketUpperBound time.Duration,
+	samples int64,
+) {
+	r.StatsReporter.ReportHistogramDurationSamples(
+		name, r.augment(HistogramKind, name, tags), buckets,
+		bucketLowerBound, bucketUpperBound, samples,
+	)
+}
+
+func (r augmentTagsReporter) ReportSummary(
+	name string,
+	tags map[string]string,
+	quantiles []float64,
+	values []float64,
+	count int64,
+	sum float64,
+) {
+	if sr, ok := r.StatsReporter.(SummaryReporter); ok {
+		sr.ReportSummary(name, r.augment(SummaryKind, name, tags), quantiles, values, count, sum)
+	}
+}
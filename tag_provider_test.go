@@ -0,0 +1,93 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tally
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTagProviders(t *testing.T) {
+	var (
+		leader  int32
+		r       = &orderRecordingReporter{}
+		updates []MetricUpdate
+	)
+	root, closer := NewRootScope(ScopeOptions{
+		Reporter:        r,
+		MetricsOption:   OmitInternalMetrics,
+		SanitizeOptions: &alphanumericSanitizerOpts,
+		SortedReporting: true,
+		Tap: TapFunc(func(u MetricUpdate) {
+			updates = append(updates, u)
+		}),
+		TagProviders: map[string]TagProvider{
+			"leader": func() string {
+				if atomic.LoadInt32(&leader) == 1 {
+					return "yes"
+				}
+				return "no"
+			},
+			"version": func() string { return "v1.2" },
+		},
+	}, 0)
+	defer closer.Close()
+
+	root.Counter("requests").Inc(1)
+	root.Tagged(map[string]string{"version": "pinned"}).Gauge("workers").Update(1)
+	root.(*scope).reportRegistry()
+
+	atomic.StoreInt32(&leader, 1)
+	root.Counter("requests").Inc(1)
+	root.(*scope).reportRegistry()
+
+	assert.Equal(t, []string{
+		"requests+leader=no,version=v1_2",
+		"workers+leader=no,version=pinned",
+		"requests+leader=yes,version=v1_2",
+	}, r.names)
+	require.Len(t, updates, 3)
+	assert.Equal(t, "yes", updates[2].Tags["leader"])
+
+	// Provided tags don't change the identity of the scopes.
+	assert.Empty(t, root.(InspectableScope).Tags())
+}
+
+func TestTagProvidersTimer(t *testing.T) {
+	var tags map[string]string
+	root, closer := NewRootScope(ScopeOptions{
+		Reporter: NullStatsReporter,
+		Tap: TapFunc(func(u MetricUpdate) {
+			tags = u.Tags
+		}),
+		TagProviders: map[string]TagProvider{
+			"shard": func() string { return "3" },
+		},
+	}, 0)
+	defer closer.Close()
+
+	root.Timer("latency").Record(time.Second)
+	assert.Equal(t, map[string]string{"shard": "3"}, tags)
+}
@@ -0,0 +1,102 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tally
+
+import (
+	"errors"
+	"sort"
+)
+
+var errEmptyTagKey = errors.New("tally: empty tag key")
+
+// TagSet is an immutable set of tags, validated and sorted once so that
+// the same dimensions can be applied cheaply to many scopes with
+// WithTagSet. The zero value is an empty set.
+type TagSet struct {
+	// pairs are the tags as alternating keys and values sorted by key.
+	pairs []string
+}
+
+// NewTagSet returns the set of tags, or an error if a key is empty.
+func NewTagSet(tags map[string]string) (TagSet, error) {
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		if k == "" {
+			return TagSet{}, errEmptyTagKey
+		}
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, 2*len(keys))
+	for _, k := range keys {
+		pairs = append(pairs, k, tags[k])
+	}
+	return TagSet{pairs: pairs}, nil
+}
+
+// MustNewTagSet returns NewTagSet(tags), it panics if a key is empty.
+func MustNewTagSet(tags map[string]string) TagSet {
+	ts, err := NewTagSet(tags)
+	if err != nil {
+		panic(err)
+	}
+	return ts
+}
+
+// Len returns the number of tags of the set.
+func (ts TagSet) Len() int {
+	return len(ts.pairs) / 2
+}
+
+// Map returns a copy of the tags of the set.
+func (ts TagSet) Map() map[string]string {
+	tags := make(map[string]string, ts.Len())
+	for i := 0; i < len(ts.pairs); i += 2 {
+		tags[ts.pairs[i]] = ts.pairs[i+1]
+	}
+	return tags
+}
+
+// TagSetScope is a Scope which can create tagged child scopes from a
+// TagSet.
+type TagSetScope interface {
+	Scope
+
+	// WithTagSet returns a new child scope with the tags of the set and
+	// current tags, like Tagged. The tags are sanitized by the scope the
+	// first time the set is applied to it, later calls only look the
+	// child scope up.
+	WithTagSet(ts TagSet) Scope
+}
+
+// WithTagSet returns s.WithTagSet(ts) if s is a TagSetScope, otherwise it
+// calls s.Tagged with the tags of the set.
+func WithTagSet(s Scope, ts TagSet) Scope {
+	if tss, ok := s.(TagSetScope); ok {
+		return tss.WithTagSet(ts)
+	}
+	return s.Tagged(ts.Map())
+}
+
+func (s *scope) WithTagSet(ts TagSet) Scope {
+	return s.TaggedPairs(ts.pairs...)
+}
@@ -0,0 +1,85 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tally
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTagSet(t *testing.T) {
+	tags := map[string]string{"b": "2", "a": "1"}
+	ts, err := NewTagSet(tags)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "1", "b", "2"}, ts.pairs)
+	assert.Equal(t, 2, ts.Len())
+	assert.Equal(t, tags, ts.Map())
+
+	_, err = NewTagSet(map[string]string{"": "v"})
+	assert.Equal(t, errEmptyTagKey, err)
+	assert.Panics(t, func() { MustNewTagSet(map[string]string{"": "v"}) })
+	assert.Equal(t, 0, TagSet{}.Len())
+}
+
+func TestWithTagSet(t *testing.T) {
+	root, closer := NewRootScope(ScopeOptions{
+		Tags:            map[string]string{"service": "svc"},
+		SanitizeOptions: &alphanumericSanitizerOpts,
+	}, 0)
+	defer closer.Close()
+
+	ts := MustNewTagSet(map[string]string{"region": "us east"})
+	s := WithTagSet(root, ts)
+	assert.Equal(t, root.Tagged(map[string]string{"region": "us east"}), s)
+	assert.Equal(t, map[string]string{"service": "svc", "region": "us_east"}, s.(*scope).tags)
+	assert.Equal(t, s, WithTagSet(root, ts))
+
+	// Scopes which aren't TagSetScopes are tagged with the map of the set.
+	wrapped := struct{ Scope }{root}
+	assert.Equal(t, s, WithTagSet(wrapped, ts))
+
+	leveled := WithTagSet(AtLevel(root, DebugLevel), ts)
+	assert.Equal(t, s, leveled.(*leveledScope).scope)
+	assert.Equal(t, NoopScope, WithTagSet(NoopScope, ts))
+}
+
+func BenchmarkWithTagSet(b *testing.B) {
+	root, closer := NewRootScope(ScopeOptions{}, 0)
+	defer closer.Close()
+
+	tags := map[string]string{"region": "us", "zone": "a", "host": "h"}
+	ts := MustNewTagSet(tags)
+
+	b.Run("Tagged", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			root.Tagged(tags)
+		}
+	})
+	b.Run("WithTagSet", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			WithTagSet(root, ts)
+		}
+	})
+}
@@ -0,0 +1,68 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tally
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"unicode/utf8"
+)
+
+// TagValueTransform transforms the value of a tag before the scope with the
+// tag is registered, e.g. to remove raw identifiers from emitted tags while
+// keeping their dimensionality.
+type TagValueTransform func(value string) string
+
+// HashTagValue returns a TagValueTransform which replaces values with the
+// hex encoded HMAC-SHA256 of the value keyed by key, truncated to length
+// characters if length is positive. Keying the hash prevents recovering
+// values from small domains such as IP addresses by hashing all of them.
+func HashTagValue(key []byte, length int) TagValueTransform {
+	return func(value string) string {
+		mac := hmac.New(sha256.New, key)
+		_, _ = mac.Write([]byte(value))
+		hashed := hex.EncodeToString(mac.Sum(nil))
+		if length > 0 && length < len(hashed) {
+			hashed = hashed[:length]
+		}
+		return hashed
+	}
+}
+
+// TruncateTagValue returns a TagValueTransform which truncates values to at
+// most n bytes, without splitting a UTF-8 encoded character. Values are
+// truncated to the empty string if n isn't positive.
+func TruncateTagValue(n int) TagValueTransform {
+	if n < 0 {
+		n = 0
+	}
+	return func(value string) string {
+		if len(value) <= n {
+			return value
+		}
+		i := n
+		for i > 0 && !utf8.RuneStart(value[i]) {
+			i--
+		}
+		return value[:i]
+	}
+}
@@ -0,0 +1,72 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tally
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHashTagValue(t *testing.T) {
+	hash := HashTagValue([]byte("secret"), 12)
+	assert.Len(t, hash("10.0.0.1"), 12)
+	assert.Equal(t, hash("10.0.0.1"), hash("10.0.0.1"))
+	assert.NotEqual(t, hash("10.0.0.1"), hash("10.0.0.2"))
+	assert.NotEqual(t, hash("10.0.0.1"), HashTagValue([]byte("other"), 12)("10.0.0.1"))
+	assert.Len(t, HashTagValue(nil, 0)("10.0.0.1"), 64)
+}
+
+func TestTruncateTagValue(t *testing.T) {
+	truncate := TruncateTagValue(4)
+	assert.Equal(t, "abc", truncate("abc"))
+	assert.Equal(t, "abcd", truncate("abcdef"))
+	assert.Equal(t, "abc", truncate("abcé"))
+	assert.Equal(t, "", TruncateTagValue(0)("abc"))
+	assert.Equal(t, "", TruncateTagValue(-1)("abc"))
+}
+
+func TestScopeTagValueTransforms(t *testing.T) {
+	hash := HashTagValue([]byte("secret"), 8)
+	root, closer := NewRootScope(ScopeOptions{
+		Tags: map[string]string{"account": "1234", "env": "test"},
+		TagValueTransforms: map[string]TagValueTransform{
+			"account": hash,
+			"ip":      TruncateTagValue(3),
+		},
+	}, 0)
+	defer closer.Close()
+
+	s := root.(TestScope)
+	s.Tagged(map[string]string{"ip": "10.0.0.1"}).Counter("a").Inc(1)
+	TaggedKV(s, "ip", "10.0.0.2").Counter("a").Inc(1)
+
+	counters := s.Snapshot().Counters()
+	assert.Len(t, counters, 1)
+	for _, c := range counters {
+		assert.EqualValues(t, 2, c.Value())
+		assert.Equal(t, map[string]string{
+			"account": hash("1234"),
+			"env":     "test",
+			"ip":      "10.",
+		}, c.Tags())
+	}
+}
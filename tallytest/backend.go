@@ -0,0 +1,333 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package tallytest provides an in-memory backend which stores the values
+// reported to it as time series, so that end-to-end tests can assert on
+// what a real backend would have stored rather than on individual reports.
+//
+// Every reported value is appended as a point at the current time of the
+// backend to the series identified by its name and tags:
+//
+//   - counters are stored as their cumulative total, like Prometheus
+//     counters, from the deltas reported by scopes,
+//   - gauges are stored as reported,
+//   - timers are stored in seconds, one point per recorded value,
+//   - histogram buckets are stored as the cumulative number of samples of
+//     each bucket in a series named with a "_bucket" suffix and tagged with
+//     the upper bound of the bucket as "le", "+Inf" for the overflow
+//     bucket. Unlike Prometheus, bucket counts aren't cumulative across
+//     buckets.
+package tallytest
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	tally "github.com/extrasalt/tally/v4"
+)
+
+// Point is a value of a series at a point in time.
+type Point struct {
+	Time  time.Time
+	Value float64
+}
+
+// Series is a time series of the backend.
+type Series struct {
+	Name   string
+	Tags   map[string]string
+	Points []Point
+}
+
+// Sample is the value of a series at a given time.
+type Sample struct {
+	Name  string
+	Tags  map[string]string
+	Value float64
+}
+
+// Options is a set of options for a backend.
+type Options struct {
+	// Now returns the time at which reported values are stored, it
+	// defaults to time.Now. Tests typically set it to a fake clock to
+	// query the series at known times.
+	Now func() time.Time
+}
+
+// Backend is an in-memory time series database which is also a
+// tally.StatsReporter ingesting the values reported to it. It is safe for
+// concurrent use.
+type Backend struct {
+	now func() time.Time
+
+	mu     sync.RWMutex
+	series map[string]*Series
+}
+
+var _ tally.StatsReporter = (*Backend)(nil)
+
+// NewBackend returns a new empty backend.
+func NewBackend(opts Options) *Backend {
+	if opts.Now == nil {
+		opts.Now = time.Now
+	}
+	return &Backend{
+		now:    opts.Now,
+		series: make(map[string]*Series),
+	}
+}
+
+// ReportCounter implements tally.StatsReporter.
+func (b *Backend) ReportCounter(name string, tags map[string]string, value int64) {
+	b.append(name, tags, func(last float64) float64 {
+		return last + float64(value)
+	})
+}
+
+// ReportGauge implements tally.StatsReporter.
+func (b *Backend) ReportGauge(name string, tags map[string]string, value float64) {
+	b.append(name, tags, func(float64) float64 {
+		return value
+	})
+}
+
+// ReportTimer implements tally.StatsReporter.
+func (b *Backend) ReportTimer(name string, tags map[string]string, interval time.Duration) {
+	b.append(name, tags, func(float64) float64 {
+		return interval.Seconds()
+	})
+}
+
+// ReportHistogramValueSamples implements tally.StatsReporter.
+func (b *Backend) ReportHistogramValueSamples(
+	name string,
+	tags map[string]string,
+	buckets tally.Buckets,
+	bucketLowerBound float64,
+	bucketUpperBound float64,
+	samples int64,
+) {
+	le := "+Inf"
+	if bucketUpperBound != math.MaxFloat64 {
+		le = strconv.FormatFloat(bucketUpperBound, 'g', -1, 64)
+	}
+	b.reportBucket(name, tags, le, samples)
+}
+
+// ReportHistogramDurationSamples implements tally.StatsReporter.
+func (b *Backend) ReportHistogramDurationSamples(
+	name string,
+	tags map[string]string,
+	buckets tally.Buckets,
+	bucketLowerBound time.Duration,
+	bucketUpperBound time.Duration,
+	samples int64,
+) {
+	le := "+Inf"
+	if bucketUpperBound != time.Duration(math.MaxInt64) {
+		le = strconv.FormatFloat(bucketUpperBound.Seconds(), 'g', -1, 64)
+	}
+	b.reportBucket(name, tags, le, samples)
+}
+
+func (b *Backend) reportBucket(name string, tags map[string]string, le string, samples int64) {
+	bucketTags := make(map[string]string, len(tags)+1)
+	for k, v := range tags {
+		bucketTags[k] = v
+	}
+	bucketTags["le"] = le
+	b.append(name+"_bucket", bucketTags, func(last float64) float64 {
+		return last + float64(samples)
+	})
+}
+
+// Capabilities implements tally.StatsReporter.
+func (b *Backend) Capabilities() tally.Capabilities {
+	return b
+}
+
+// Reporting implements tally.Capabilities.
+func (b *Backend) Reporting() bool {
+	return true
+}
+
+// Tagging implements tally.Capabilities.
+func (b *Backend) Tagging() bool {
+	return true
+}
+
+// Flush implements tally.StatsReporter.
+func (b *Backend) Flush() {}
+
+// append appends a point to the series of name and tags, whose value is
+// computed from the value of the last point of the series, or zero.
+func (b *Backend) append(name string, tags map[string]string, value func(last float64) float64) {
+	key := tally.KeyForPrefixedStringMap(name, tags)
+	now := b.now()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	s, ok := b.series[key]
+	if !ok {
+		s = &Series{Name: name, Tags: copyTags(tags)}
+		b.series[key] = s
+	}
+
+	var last float64
+	if n := len(s.Points); n > 0 {
+		last = s.Points[n-1].Value
+	}
+	s.Points = append(s.Points, Point{Time: now, Value: value(last)})
+}
+
+// Series returns a copy of every series named name whose tags include the
+// given tags, sorted by their tags.
+func (b *Backend) Series(name string, tags map[string]string) []Series {
+	return b.filter(name, tags, func(Point) bool { return true })
+}
+
+// Range returns a copy of the points between start and end inclusive of
+// every series named name whose tags include the given tags, sorted by
+// their tags. Series without points in the range are omitted.
+func (b *Backend) Range(name string, tags map[string]string, start, end time.Time) []Series {
+	return b.filter(name, tags, func(p Point) bool {
+		return !p.Time.Before(start) && !p.Time.After(end)
+	})
+}
+
+// filter returns a copy of the points for which keep returns true of every
+// series named name whose tags include the given tags.
+func (b *Backend) filter(name string, tags map[string]string, keep func(Point) bool) []Series {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	var result []Series
+	for _, s := range b.matching(name, tags) {
+		var points []Point
+		for _, p := range s.Points {
+			if keep(p) {
+				points = append(points, p)
+			}
+		}
+		if len(points) > 0 {
+			result = append(result, Series{Name: s.Name, Tags: copyTags(s.Tags), Points: points})
+		}
+	}
+	return result
+}
+
+// Instant returns the value at time at of every series named name whose
+// tags include the given tags, sorted by their tags. The value of a series
+// is the value of its last point at or before at, series without such
+// a point are omitted.
+func (b *Backend) Instant(name string, tags map[string]string, at time.Time) []Sample {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	var result []Sample
+	for _, s := range b.matching(name, tags) {
+		i := sort.Search(len(s.Points), func(i int) bool {
+			return s.Points[i].Time.After(at)
+		})
+		if i == 0 {
+			continue
+		}
+		result = append(result, Sample{
+			Name:  s.Name,
+			Tags:  copyTags(s.Tags),
+			Value: s.Points[i-1].Value,
+		})
+	}
+	return result
+}
+
+// Sum returns the sum of the values at time at of the series named name
+// whose tags include the given tags, as returned by Instant.
+func (b *Backend) Sum(name string, tags map[string]string, at time.Time) float64 {
+	var sum float64
+	for _, sample := range b.Instant(name, tags, at) {
+		sum += sample.Value
+	}
+	return sum
+}
+
+// Names returns the sorted names of every series of the backend.
+func (b *Backend) Names() []string {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	seen := make(map[string]struct{})
+	var names []string
+	for _, s := range b.series {
+		if _, ok := seen[s.Name]; ok {
+			continue
+		}
+		seen[s.Name] = struct{}{}
+		names = append(names, s.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Reset removes every series of the backend.
+func (b *Backend) Reset() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.series = make(map[string]*Series)
+}
+
+// matching returns the series named name whose tags include tags, sorted by
+// key. It must be called with mu held.
+func (b *Backend) matching(name string, tags map[string]string) []*Series {
+	var keys []string
+	for key, s := range b.series {
+		if s.Name == name && includes(s.Tags, tags) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	series := make([]*Series, 0, len(keys))
+	for _, key := range keys {
+		series = append(series, b.series[key])
+	}
+	return series
+}
+
+func includes(tags, subset map[string]string) bool {
+	for k, v := range subset {
+		if tv, ok := tags[k]; !ok || tv != v {
+			return false
+		}
+	}
+	return true
+}
+
+func copyTags(tags map[string]string) map[string]string {
+	c := make(map[string]string, len(tags))
+	for k, v := range tags {
+		c[k] = v
+	}
+	return c
+}
@@ -0,0 +1,89 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tallytest
+
+import (
+	"testing"
+	"time"
+
+	tally "github.com/extrasalt/tally/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBackend(t *testing.T) {
+	now := time.Unix(100, 0)
+	b := NewBackend(Options{Now: func() time.Time { return now }})
+
+	root, closer := tally.NewRootScope(tally.ScopeOptions{
+		Reporter:      b,
+		MetricsOption: tally.OmitInternalMetrics,
+	}, 0)
+	report := func() { require.NoError(t, tally.Flush(root)) }
+
+	get := root.Tagged(map[string]string{"method": "get"})
+	put := root.Tagged(map[string]string{"method": "put"})
+
+	get.Counter("requests").Inc(2)
+	put.Counter("requests").Inc(1)
+	root.Gauge("workers").Update(4)
+	root.Histogram("size", tally.ValueBuckets{10}).RecordValue(3)
+	report()
+
+	now = now.Add(time.Minute)
+	get.Counter("requests").Inc(3)
+	root.Gauge("workers").Update(2)
+	root.Histogram("size", tally.ValueBuckets{10}).RecordValue(30)
+	root.Timer("latency").Record(1500 * time.Millisecond)
+	report()
+	require.NoError(t, closer.Close())
+
+	assert.Equal(t, []string{"latency", "requests", "size_bucket", "workers"}, b.Names())
+
+	assert.Equal(t, []Series{{
+		Name: "requests",
+		Tags: map[string]string{"method": "get"},
+		Points: []Point{
+			{Time: time.Unix(100, 0), Value: 2},
+			{Time: time.Unix(160, 0), Value: 5},
+		},
+	}}, b.Series("requests", map[string]string{"method": "get"}))
+
+	assert.Equal(t, float64(3), b.Sum("requests", nil, time.Unix(130, 0)))
+	assert.Equal(t, float64(6), b.Sum("requests", nil, time.Unix(160, 0)))
+	assert.Empty(t, b.Instant("requests", nil, time.Unix(99, 0)))
+
+	workers := b.Range("workers", nil, time.Unix(150, 0), time.Unix(200, 0))
+	require.Len(t, workers, 1)
+	assert.Equal(t, []Point{{Time: time.Unix(160, 0), Value: 2}}, workers[0].Points)
+
+	assert.Equal(t, []Sample{{
+		Name:  "latency",
+		Tags:  map[string]string{},
+		Value: 1.5,
+	}}, b.Instant("latency", nil, now))
+
+	assert.Equal(t, float64(1), b.Sum("size_bucket", map[string]string{"le": "10"}, now))
+	assert.Equal(t, float64(1), b.Sum("size_bucket", map[string]string{"le": "+Inf"}, now))
+
+	b.Reset()
+	assert.Empty(t, b.Names())
+}
@@ -0,0 +1,239 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tallytest
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	tally "github.com/extrasalt/tally/v4"
+)
+
+// ErrInjectedFlush is the error of flushes failed by a ChaosReporter.
+var ErrInjectedFlush = errors.New("tallytest: injected flush failure")
+
+// ChaosOptions configures the failures a ChaosReporter injects.
+type ChaosOptions struct {
+	// Latency is added to every reported value.
+	Latency time.Duration
+	// FlushLatency is added to every flush.
+	FlushLatency time.Duration
+	// DropRate is the fraction of reported values silently dropped, to
+	// simulate partial failures.
+	DropRate float64
+	// FlushErrorRate is the fraction of flushes failing with
+	// ErrInjectedFlush.
+	FlushErrorRate float64
+	// Seed seeds the random decisions, so that tests are reproducible.
+	Seed int64
+}
+
+// ChaosReporter is a tally.ContextStatsReporter forwarding the values
+// reported to it to a StatsReporter, while injecting latency, dropped
+// values and flush failures. It is used in tests to verify the behavior
+// of scopes with slow or failing backends, set as the ContextReporter of
+// a root scope:
+//
+//	chaos := tallytest.NewChaosReporter(backend, tallytest.ChaosOptions{
+//		FlushLatency: time.Second,
+//	})
+//	scope, closer := tally.NewRootScope(tally.ScopeOptions{
+//		ContextReporter: chaos,
+//	}, time.Second)
+//
+// Latency honors the deadline of the report context: a value whose
+// latency exceeds the deadline is dropped, and a flush exceeding it fails
+// with the context's error.
+type ChaosReporter struct {
+	reporter tally.StatsReporter
+
+	dropped int64
+	flushes int64
+	failed  int64
+
+	mu   sync.Mutex
+	opts ChaosOptions
+	rand *rand.Rand
+}
+
+var _ tally.ContextStatsReporter = (*ChaosReporter)(nil)
+
+// NewChaosReporter returns a ChaosReporter forwarding to r.
+func NewChaosReporter(r tally.StatsReporter, opts ChaosOptions) *ChaosReporter {
+	return &ChaosReporter{
+		reporter: r,
+		opts:     opts,
+		rand:     rand.New(rand.NewSource(opts.Seed)),
+	}
+}
+
+// SetOptions replaces the injected failures, e.g. to simulate an outage
+// of the backend then its recovery. The random source isn't reseeded.
+func (r *ChaosReporter) SetOptions(opts ChaosOptions) {
+	r.mu.Lock()
+	r.opts = opts
+	r.mu.Unlock()
+}
+
+// Dropped returns the number of values dropped, either randomly or
+// because their latency exceeded the deadline of their report.
+func (r *ChaosReporter) Dropped() int64 {
+	return atomic.LoadInt64(&r.dropped)
+}
+
+// Flushes returns the number of flushes, and how many of them failed.
+func (r *ChaosReporter) Flushes() (total, failed int64) {
+	return atomic.LoadInt64(&r.flushes), atomic.LoadInt64(&r.failed)
+}
+
+// Capabilities implements tally.ContextStatsReporter.
+func (r *ChaosReporter) Capabilities() tally.Capabilities {
+	return r.reporter.Capabilities()
+}
+
+// ReportCounter implements tally.ContextStatsReporter.
+func (r *ChaosReporter) ReportCounter(
+	ctx context.Context,
+	name string,
+	tags map[string]string,
+	value int64,
+) {
+	if r.deliver(ctx) {
+		r.reporter.ReportCounter(name, tags, value)
+	}
+}
+
+// ReportGauge implements tally.ContextStatsReporter.
+func (r *ChaosReporter) ReportGauge(
+	ctx context.Context,
+	name string,
+	tags map[string]string,
+	value float64,
+) {
+	if r.deliver(ctx) {
+		r.reporter.ReportGauge(name, tags, value)
+	}
+}
+
+// ReportTimer implements tally.ContextStatsReporter.
+func (r *ChaosReporter) ReportTimer(
+	ctx context.Context,
+	name string,
+	tags map[string]string,
+	interval time.Duration,
+) {
+	if r.deliver(ctx) {
+		r.reporter.ReportTimer(name, tags, interval)
+	}
+}
+
+// ReportHistogramValueSamples implements tally.ContextStatsReporter.
+func (r *ChaosReporter) ReportHistogramValueSamples(
+	ctx context.Context,
+	name string,
+	tags map[string]string,
+	buckets tally.Buckets,
+	bucketLowerBound,
+	bucketUpperBound float64,
+	samples int64,
+) {
+	if r.deliver(ctx) {
+		r.reporter.ReportHistogramValueSamples(
+			name, tags, buckets, bucketLowerBound, bucketUpperBound, samples,
+		)
+	}
+}
+
+// ReportHistogramDurationSamples implements tally.ContextStatsReporter.
+func (r *ChaosReporter) ReportHistogramDurationSamples(
+	ctx context.Context,
+	name string,
+	tags map[string]string,
+	buckets tally.Buckets,
+	bucketLowerBound,
+	bucketUpperBound time.Duration,
+	samples int64,
+) {
+	if r.deliver(ctx) {
+		r.reporter.ReportHistogramDurationSamples(
+			name, tags, buckets, bucketLowerBound, bucketUpperBound, samples,
+		)
+	}
+}
+
+// Flush implements tally.ContextStatsReporter.
+func (r *ChaosReporter) Flush(ctx context.Context) error {
+	atomic.AddInt64(&r.flushes, 1)
+
+	r.mu.Lock()
+	latency := r.opts.FlushLatency
+	fail := r.opts.FlushErrorRate > 0 && r.rand.Float64() < r.opts.FlushErrorRate
+	r.mu.Unlock()
+
+	err := sleep(ctx, latency)
+	if err == nil && fail {
+		err = ErrInjectedFlush
+	}
+	if err != nil {
+		atomic.AddInt64(&r.failed, 1)
+		return err
+	}
+
+	r.reporter.Flush()
+	return nil
+}
+
+// deliver waits for the injected latency and returns whether the value
+// should be delivered.
+func (r *ChaosReporter) deliver(ctx context.Context) bool {
+	r.mu.Lock()
+	latency := r.opts.Latency
+	drop := r.opts.DropRate > 0 && r.rand.Float64() < r.opts.DropRate
+	r.mu.Unlock()
+
+	if err := sleep(ctx, latency); err != nil || drop {
+		atomic.AddInt64(&r.dropped, 1)
+		return false
+	}
+	return true
+}
+
+// sleep waits for d or until ctx is done, in which case it returns the
+// error of ctx.
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,78 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tallytest
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	tally "github.com/extrasalt/tally/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChaosReporter(t *testing.T) {
+	backend := NewBackend(Options{})
+	chaos := NewChaosReporter(backend, ChaosOptions{DropRate: 0.5, Seed: 1})
+	scope, closer := tally.NewRootScope(tally.ScopeOptions{
+		ContextReporter: chaos,
+		MetricsOption:   tally.OmitInternalMetrics,
+	}, 0)
+	defer closer.Close()
+
+	for i := 0; i < 100; i++ {
+		scope.Counter(fmt.Sprint("c", i)).Inc(1)
+	}
+	require.NoError(t, tally.Flush(scope))
+
+	dropped := chaos.Dropped()
+	assert.True(t, dropped > 20 && dropped < 80, "dropped %d values", dropped)
+	assert.Equal(t, 100-int(dropped), len(backend.Names()))
+
+	chaos.SetOptions(ChaosOptions{FlushErrorRate: 1})
+	assert.Equal(t, ErrInjectedFlush, tally.FlushContext(context.Background(), scope))
+
+	total, failed := chaos.Flushes()
+	assert.Equal(t, int64(2), total)
+	assert.Equal(t, int64(1), failed)
+}
+
+func TestChaosReporterLatency(t *testing.T) {
+	chaos := NewChaosReporter(NewBackend(Options{}), ChaosOptions{
+		Latency:      time.Minute,
+		FlushLatency: time.Minute,
+	})
+	scope, closer := tally.NewRootScope(tally.ScopeOptions{
+		ContextReporter: chaos,
+		MetricsOption:   tally.OmitInternalMetrics,
+	}, 0)
+
+	scope.Counter("c").Inc(1)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, context.DeadlineExceeded, tally.FlushContext(ctx, scope))
+	assert.Equal(t, int64(1), chaos.Dropped())
+
+	chaos.SetOptions(ChaosOptions{})
+	require.NoError(t, closer.Close())
+}
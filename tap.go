@@ -0,0 +1,345 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tally
+
+import (
+	"fmt"
+	"time"
+)
+
+// MetricKind is the kind of a metric.
+type MetricKind int
+
+const (
+	// CounterKind is the kind of counters.
+	CounterKind MetricKind = iota
+	// GaugeKind is the kind of gauges.
+	GaugeKind
+	// TimerKind is the kind of timers.
+	TimerKind
+	// HistogramKind is the kind of histograms.
+	HistogramKind
+	// SummaryKind is the kind of summaries.
+	SummaryKind
+)
+
+// String returns the name of the kind.
+func (k MetricKind) String() string {
+	switch k {
+	case CounterKind:
+		return "counter"
+	case GaugeKind:
+		return "gauge"
+	case TimerKind:
+		return "timer"
+	case HistogramKind:
+		return "histogram"
+	case SummaryKind:
+		return "summary"
+	default:
+		return fmt.Sprintf("MetricKind(%d)", int(k))
+	}
+}
+
+// MetricUpdate is a metric value reported to a reporter.
+type MetricUpdate struct {
+	Kind MetricKind
+	// Name is the fully qualified name of the metric.
+	Name string
+	// Tags are the tags of the metric, they must not be modified.
+	Tags map[string]string
+	// Value is the delta of a counter, the value of a gauge, the number
+	// of samples of a histogram bucket or the number of values observed
+	// by a summary over its window.
+	Value float64
+	// Duration is the value of a timer.
+	Duration time.Duration
+	// ValueUpperBound is the upper bound of a bucket of a value histogram.
+	ValueUpperBound float64
+	// DurationUpperBound is the upper bound of a bucket of a duration
+	// histogram.
+	DurationUpperBound time.Duration
+	// Quantiles are the quantiles of a summary, and QuantileValues their
+	// estimates. They must not be modified.
+	Quantiles      []float64
+	QuantileValues []float64
+	// Sum is the sum of the values observed by a summary over its window.
+	Sum float64
+}
+
+// Tap receives a copy of every metric value reported by a root scope, e.g.
+// for audit tooling checking the names and tags of metrics in integration
+// environments. Taps are called synchronously while reporting, from
+// multiple goroutines for timers, so they must be fast and safe for
+// concurrent use.
+type Tap interface {
+	Tap(update MetricUpdate)
+}
+
+// TapFunc is a function which is a Tap.
+type TapFunc func(update MetricUpdate)
+
+// Tap calls f.
+func (f TapFunc) Tap(update MetricUpdate) {
+	f(update)
+}
+
+type tapReporter struct {
+	StatsReporter
+	tap Tap
+}
+
+func (r tapReporter) ReportCounter(name string, tags map[string]string, value int64) {
+	r.StatsReporter.ReportCounter(name, tags, value)
+	r.tap.Tap(MetricUpdate{Kind: CounterKind, Name: name, Tags: tags, Value: float64(value)})
+}
+
+func (r tapReporter) ReportCounterTotal(name string, tags map[string]string, total int64) {
+	if cr, ok := r.StatsReporter.(CumulativeCounterReporter); ok {
+		cr.ReportCounterTotal(name, tags, total)
+	}
+}
+
+func (r tapReporter) ReportGauge(name string, tags map[string]string, value float64) {
+	r.StatsReporter.ReportGauge(name, tags, value)
+	r.tap.Tap(MetricUpdate{Kind: GaugeKind, Name: name, Tags: tags, Value: value})
+}
+
+func (r tapReporter) ReportTimer(name string, tags map[string]string, interval time.Duration) {
+	r.StatsReporter.ReportTimer(name, tags, interval)
+	r.tap.Tap(MetricUpdate{Kind: TimerKind, Name: name, Tags: tags, Duration: interval})
+}
+
+func (r tapReporter) ReportHistogramValueSamples(
+	name string,
+	tags map[string]string,
+	buckets Buckets,
+	bucketLowerBound,
+	bucketUpperBound float64,
+	samples int64,
+) {
+	r.StatsReporter.ReportHistogramValueSamples(
+		name, tags, buckets, bucketLowerBound, bucketUpperBound, samples,
+	)
+	r.tap.Tap(MetricUpdate{
+		Kind:            HistogramKind,
+		Name:            name,
+		Tags:            tags,
+		Value:           float64(samples),
+		ValueUpperBound: bucketUpperBound,
+	})
+}
+
+func (r tapReporter) ReportHistogramDurationSamples(
+	name string,
+	tags map[string]string,
+	buckets Buckets,
+	bucketLowerBound,
+	bucketUpperBound time.Duration,
+	samples int64,
+) {
+	r.StatsReporter.ReportHistogramDurationSamples(
+		name, tags, buckets, bucketLowerBound, bucketUpperBound, samples,
+	)
+	r.tap.Tap(MetricUpdate{
+		Kind:               HistogramKind,
+		Name:               name,
+		Tags:               tags,
+		Value:              float64(samples),
+		DurationUpperBound: bucketUpperBound,
+	})
+}
+
+func (r tapReporter) ReportSummary(
+	name string,
+	tags map[string]string,
+	quantiles []float64,
+	values []float64,
+	count int64,
+	sum float64,
+) {
+	if sr, ok := r.StatsReporter.(SummaryReporter); ok {
+		sr.ReportSummary(name, tags, quantiles, values, count, sum)
+	}
+	r.tap.Tap(MetricUpdate{
+		Kind:           SummaryKind,
+		Name:           name,
+		Tags:           tags,
+		Value:          float64(count),
+		Quantiles:      quantiles,
+		QuantileValues: values,
+		Sum:            sum,
+	})
+}
+
+type tapCachedReporter struct {
+	CachedStatsReporter
+	tap Tap
+}
+
+func (r tapCachedReporter) AllocateCounter(name string, tags map[string]string) CachedCount {
+	return tapCachedMetric{
+		count: r.CachedStatsReporter.AllocateCounter(name, tags),
+		tap:   r.tap,
+		name:  name,
+		tags:  tags,
+	}
+}
+
+func (r tapCachedReporter) AllocateGauge(name string, tags map[string]string) CachedGauge {
+	return tapCachedMetric{
+		gauge: r.CachedStatsReporter.AllocateGauge(name, tags),
+		tap:   r.tap,
+		name:  name,
+		tags:  tags,
+	}
+}
+
+func (r tapCachedReporter) AllocateTimer(name string, tags map[string]string) CachedTimer {
+	return tapCachedMetric{
+		timer: r.CachedStatsReporter.AllocateTimer(name, tags),
+		tap:   r.tap,
+		name:  name,
+		tags:  tags,
+	}
+}
+
+func (r tapCachedReporter) AllocateHistogram(
+	name string,
+	tags map[string]string,
+	buckets Buckets,
+) CachedHistogram {
+	return tapCachedHistogram{
+		CachedHistogram: r.CachedStatsReporter.AllocateHistogram(name, tags, buckets),
+		tap:             r.tap,
+		name:            name,
+		tags:            tags,
+	}
+}
+
+type tapCachedMetric struct {
+	count CachedCount
+	gauge CachedGauge
+	timer CachedTimer
+	tap   Tap
+	name  string
+	tags  map[string]string
+}
+
+func (m tapCachedMetric) ReportCount(value int64) {
+	m.count.ReportCount(value)
+	m.tap.Tap(MetricUpdate{Kind: CounterKind, Name: m.name, Tags: m.tags, Value: float64(value)})
+}
+
+func (m tapCachedMetric) ReportTotal(total int64) {
+	if cc, ok := m.count.(CachedCumulativeCount); ok {
+		cc.ReportTotal(total)
+	}
+}
+
+func (m tapCachedMetric) ReportGauge(value float64) {
+	m.gauge.ReportGauge(value)
+	m.tap.Tap(MetricUpdate{Kind: GaugeKind, Name: m.name, Tags: m.tags, Value: value})
+}
+
+func (m tapCachedMetric) ReportTimer(interval time.Duration) {
+	m.timer.ReportTimer(interval)
+	m.tap.Tap(MetricUpdate{Kind: TimerKind, Name: m.name, Tags: m.tags, Duration: interval})
+}
+
+type tapCachedHistogram struct {
+	CachedHistogram
+	tap  Tap
+	name string
+	tags map[string]string
+}
+
+func (h tapCachedHistogram) ValueBucket(
+	bucketLowerBound, bucketUpperBound float64,
+) CachedHistogramBucket {
+	return tapCachedHistogramBucket{
+		CachedHistogramBucket: h.CachedHistogram.ValueBucket(bucketLowerBound, bucketUpperBound),
+		histogram:             h,
+		update:                MetricUpdate{ValueUpperBound: bucketUpperBound},
+	}
+}
+
+func (h tapCachedHistogram) DurationBucket(
+	bucketLowerBound, bucketUpperBound time.Duration,
+) CachedHistogramBucket {
+	return tapCachedHistogramBucket{
+		CachedHistogramBucket: h.CachedHistogram.DurationBucket(bucketLowerBound, bucketUpperBound),
+		histogram:             h,
+		update:                MetricUpdate{DurationUpperBound: bucketUpperBound},
+	}
+}
+
+type tapCachedHistogramBucket struct {
+	CachedHistogramBucket
+	histogram tapCachedHistogram
+	update    MetricUpdate
+}
+
+func (b tapCachedHistogramBucket) ReportSamples(value int64) {
+	b.CachedHistogramBucket.ReportSamples(value)
+
+	update := b.update
+	update.Kind = HistogramKind
+	update.Name = b.histogram.name
+	update.Tags = b.histogram.tags
+	update.Value = float64(value)
+	b.histogram.tap.Tap(update)
+}
+
+func (r tapCachedReporter) AllocateSummary(
+	name string,
+	tags map[string]string,
+	quantiles []float64,
+) CachedSummary {
+	return tapCachedSummary{
+		CachedSummary: allocateSummary(r.CachedStatsReporter, name, tags, quantiles),
+		tap:           r.tap,
+		name:          name,
+		tags:          tags,
+		quantiles:     quantiles,
+	}
+}
+
+type tapCachedSummary struct {
+	CachedSummary
+	tap       Tap
+	name      string
+	tags      map[string]string
+	quantiles []float64
+}
+
+func (s tapCachedSummary) ReportSummary(values []float64, count int64, sum float64) {
+	s.CachedSummary.ReportSummary(values, count, sum)
+	s.tap.Tap(MetricUpdate{
+		Kind:           SummaryKind,
+		Name:           s.name,
+		Tags:           s.tags,
+		Value:          float64(count),
+		Quantiles:      s.quantiles,
+		QuantileValues: values,
+		Sum:            sum,
+	})
+}
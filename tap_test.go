@@ -0,0 +1,118 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tally
+
+import (
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type recordingTap struct {
+	mu      sync.Mutex
+	updates []MetricUpdate
+}
+
+func (t *recordingTap) Tap(update MetricUpdate) {
+	t.mu.Lock()
+	t.updates = append(t.updates, update)
+	t.mu.Unlock()
+}
+
+func (t *recordingTap) sorted() []MetricUpdate {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	sort.Slice(t.updates, func(i, j int) bool {
+		return t.updates[i].Kind < t.updates[j].Kind
+	})
+	return t.updates
+}
+
+func testTap(t *testing.T, opts ScopeOptions, r *testStatsReporter) {
+	tap := &recordingTap{}
+	opts.Tap = tap
+	opts.Prefix = "svc"
+	opts.MetricsOption = OmitInternalMetrics
+
+	root := newRootScope(opts, 0)
+	s := root.Tagged(map[string]string{"env": "test"})
+	tags := map[string]string{"env": "test"}
+
+	r.cg.Add(1)
+	r.gg.Add(1)
+	r.tg.Add(1)
+	r.hg.Add(1)
+	s.Counter("requests").Inc(3)
+	s.Gauge("queue").Update(7)
+	s.Timer("latency").Record(time.Second)
+	s.Histogram("size", ValueBuckets{10, 20}).RecordValue(15)
+	root.reportRegistry()
+	r.WaitAll()
+
+	assert.Equal(t, []MetricUpdate{
+		{Kind: CounterKind, Name: "svc.requests", Tags: tags, Value: 3},
+		{Kind: GaugeKind, Name: "svc.queue", Tags: tags, Value: 7},
+		{Kind: TimerKind, Name: "svc.latency", Tags: tags, Duration: time.Second},
+		{Kind: HistogramKind, Name: "svc.size", Tags: tags, Value: 1, ValueUpperBound: 20},
+	}, tap.sorted())
+
+	assert.NoError(t, root.Close())
+}
+
+func TestTap(t *testing.T) {
+	r := newTestStatsReporter()
+	testTap(t, ScopeOptions{Reporter: r}, r)
+}
+
+func TestTapCached(t *testing.T) {
+	r := newTestStatsReporter()
+	testTap(t, ScopeOptions{CachedReporter: r}, r)
+}
+
+func TestTapDurationHistogram(t *testing.T) {
+	tap := &recordingTap{}
+	root := newRootScope(ScopeOptions{
+		Reporter:      NullStatsReporter,
+		Tap:           tap,
+		MetricsOption: OmitInternalMetrics,
+	}, 0)
+	defer root.Close()
+
+	root.Histogram("latency", DurationBuckets{time.Second, 2 * time.Second}).RecordDuration(time.Second)
+	root.reportRegistry()
+
+	assert.Equal(t, []MetricUpdate{{
+		Kind:               HistogramKind,
+		Name:               "latency",
+		Tags:               map[string]string{},
+		Value:              1,
+		DurationUpperBound: time.Second,
+	}}, tap.sorted())
+}
+
+func TestMetricKindString(t *testing.T) {
+	assert.Equal(t, "counter", CounterKind.String())
+	assert.Equal(t, "histogram", HistogramKind.String())
+	assert.Equal(t, "MetricKind(7)", MetricKind(7).String())
+}
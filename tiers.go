@@ -0,0 +1,164 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tally
+
+import (
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ReportTier is an additional reporter of a root scope, flushed on its own
+// interval independently of the scope's reporter, e.g. to report to statsd
+// every second and to a remote storage every 30 seconds. Each tier tracks
+// the values it reported, so that it receives the deltas of counters and
+// histograms over its own interval.
+//
+// Gauges are reported to a tier when their value changed since the tier's
+// last report, and timers are reported to tiers as they are recorded.
+// Tiers receive the raw metrics of the scope, without rollups or taps, and
+// the values recorded to a scope since a tier's last report are lost for
+// the tier once the scope is closed and removed by a report of the root
+// scope.
+type ReportTier struct {
+	// Reporter is the reporter of the tier, it is flushed after each
+	// report of the tier and closed with the root scope if it is an
+	// io.Closer.
+	Reporter StatsReporter
+	// Interval is the reporting interval of the tier, it must be positive.
+	Interval time.Duration
+}
+
+type reportTier struct {
+	reporter StatsReporter
+	interval time.Duration
+
+	mu       sync.Mutex
+	closed   bool
+	counters map[*counter]int64
+	gauges   map[*gauge]uint64
+}
+
+func newReportTiers(tiers []ReportTier) []*reportTier {
+	var result []*reportTier
+	for _, t := range tiers {
+		if t.Reporter == nil || t.Interval <= 0 {
+			continue
+		}
+		result = append(result, &reportTier{
+			reporter: t.Reporter,
+			interval: t.Interval,
+			counters: make(map[*counter]int64),
+			gauges:   make(map[*gauge]uint64),
+		})
+	}
+	return result
+}
+
+// report reports the metrics of the registry changed since the tier's last
+// report, then flushes the tier's reporter.
+func (t *reportTier) report(r *scopeRegistry) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.closed {
+		return
+	}
+	t.reportLocked(r)
+}
+
+func (t *reportTier) reportLocked(r *scopeRegistry) {
+	// NB: the last reported values are tracked in new maps at each report
+	// so that the metrics of removed scopes are released.
+	var (
+		counters = make(map[*counter]int64, len(t.counters))
+		gauges   = make(map[*gauge]uint64, len(t.gauges))
+	)
+
+	reportCounter := func(c *counter) int64 {
+		total := c.curr.load()
+		counters[c] = total
+		return total - t.counters[c]
+	}
+
+	for _, s := range r.uniqueScopes() {
+		s.cm.RLock()
+		for name, c := range s.counters {
+			if delta := reportCounter(c); delta != 0 {
+				t.reporter.ReportCounter(s.fullyQualifiedName(name), s.tags, delta)
+			}
+		}
+		s.cm.RUnlock()
+
+		s.gm.RLock()
+		for name, g := range s.gauges {
+			curr := atomic.LoadUint64(&g.curr)
+			gauges[g] = curr
+			if last, ok := t.gauges[g]; curr != last || (!ok && curr != 0) {
+				t.reporter.ReportGauge(s.fullyQualifiedName(name), s.tags, g.value())
+			}
+		}
+		s.gm.RUnlock()
+
+		s.hm.RLock()
+		for name, h := range s.histograms {
+			for i := range h.buckets {
+				if samples := reportCounter(h.samples[i].counter); samples != 0 {
+					h.reportBucket(s.fullyQualifiedName(name), s.tags, t.reporter, i, samples)
+				}
+			}
+		}
+		s.hm.RUnlock()
+	}
+
+	t.counters, t.gauges = counters, gauges
+	t.reporter.Flush()
+}
+
+// close reports the tier a final time and closes its reporter.
+func (t *reportTier) close(r *scopeRegistry) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.closed {
+		return nil
+	}
+	t.closed = true
+
+	t.reportLocked(r)
+	if closer, ok := t.reporter.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// closeTiers closes the report tiers of the registry, returning the first
+// error closing their reporters.
+func (r *scopeRegistry) closeTiers() error {
+	var firstErr error
+	for _, t := range r.tiers {
+		if err := t.close(r); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
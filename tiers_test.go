@@ -0,0 +1,192 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tally
+
+import (
+	"math"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// tierRecordingReporter records the last value reported for each metric.
+type tierRecordingReporter struct {
+	nullStatsReporter
+
+	mu         sync.Mutex
+	counters   map[string]int64
+	gauges     map[string]float64
+	timers     map[string]time.Duration
+	histograms map[string]map[float64]int64
+	flushes    int
+	closes     int
+}
+
+func newTierRecordingReporter() *tierRecordingReporter {
+	r := &tierRecordingReporter{}
+	r.reset()
+	return r
+}
+
+func (r *tierRecordingReporter) reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.counters = make(map[string]int64)
+	r.gauges = make(map[string]float64)
+	r.timers = make(map[string]time.Duration)
+	r.histograms = make(map[string]map[float64]int64)
+}
+
+func (r *tierRecordingReporter) ReportCounter(name string, tags map[string]string, value int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.counters[name] = value
+}
+
+func (r *tierRecordingReporter) ReportGauge(name string, tags map[string]string, value float64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.gauges[name] = value
+}
+
+func (r *tierRecordingReporter) ReportTimer(name string, tags map[string]string, interval time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.timers[name] = interval
+}
+
+func (r *tierRecordingReporter) ReportHistogramValueSamples(
+	name string,
+	tags map[string]string,
+	buckets Buckets,
+	bucketLowerBound,
+	bucketUpperBound float64,
+	samples int64,
+) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.histograms[name] == nil {
+		r.histograms[name] = make(map[float64]int64)
+	}
+	r.histograms[name][bucketUpperBound] = samples
+}
+
+func (r *tierRecordingReporter) Flush() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.flushes++
+}
+
+func (r *tierRecordingReporter) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.closes++
+	return nil
+}
+
+func TestReportTiers(t *testing.T) {
+	var (
+		main = newTierRecordingReporter()
+		slow = newTierRecordingReporter()
+	)
+	root := newRootScope(ScopeOptions{
+		Reporter:      main,
+		ReportTiers:   []ReportTier{{Reporter: slow, Interval: time.Hour}},
+		MetricsOption: OmitInternalMetrics,
+	}, 0)
+	tier := root.registry.tiers[0]
+
+	s := root.SubScope("svc")
+	c := s.Counter("requests")
+	g := s.Gauge("queue")
+	h := s.Histogram("size", ValueBuckets{10})
+
+	c.Inc(2)
+	g.Update(5)
+	h.RecordValue(1)
+	root.reportRegistry()
+	tier.report(root.registry)
+	assert.Equal(t, map[string]int64{"svc.requests": 2}, main.counters)
+	assert.Equal(t, map[string]int64{"svc.requests": 2}, slow.counters)
+	assert.Equal(t, map[string]float64{"svc.queue": 5}, slow.gauges)
+	assert.Equal(t, int64(1), slow.histograms["svc.size"][10])
+	assert.Equal(t, 1, slow.flushes)
+
+	// The main reporter reports twice over the tier's interval, which
+	// receives the sum.
+	main.reset()
+	slow.reset()
+	c.Inc(3)
+	h.RecordValue(1)
+	root.reportRegistry()
+	c.Inc(4)
+	h.RecordValue(20)
+	root.reportRegistry()
+	assert.Equal(t, map[string]int64{"svc.requests": 4}, main.counters)
+
+	tier.report(root.registry)
+	assert.Equal(t, map[string]int64{"svc.requests": 7}, slow.counters)
+	assert.Empty(t, slow.gauges, "unchanged gauges are not reported")
+	assert.Equal(t, map[float64]int64{10: 1, math.MaxFloat64: 1}, slow.histograms["svc.size"])
+
+	// Timers are reported to every reporter as they are recorded.
+	s.Timer("latency").Record(time.Second)
+	assert.Equal(t, time.Second, main.timers["svc.latency"])
+	assert.Equal(t, time.Second, slow.timers["svc.latency"])
+
+	// Closing the root scope reports and closes the tiers.
+	slow.reset()
+	c.Inc(1)
+	require.NoError(t, root.Close())
+	assert.Equal(t, map[string]int64{"svc.requests": 1}, slow.counters)
+	assert.Equal(t, 1, slow.closes)
+	assert.Equal(t, 1, main.closes)
+
+	tier.report(root.registry)
+	assert.Equal(t, 3, slow.flushes)
+}
+
+func TestReportTiersLoop(t *testing.T) {
+	tier := newTierRecordingReporter()
+	root, closer := NewRootScope(ScopeOptions{
+		Reporter:      NullStatsReporter,
+		ReportTiers:   []ReportTier{{Reporter: tier, Interval: time.Millisecond}},
+		MetricsOption: OmitInternalMetrics,
+	}, 0)
+	defer closer.Close()
+
+	root.Counter("requests").Inc(1)
+	require.Eventually(t, func() bool {
+		tier.mu.Lock()
+		defer tier.mu.Unlock()
+		return tier.counters["requests"] == 1
+	}, time.Second, time.Millisecond)
+}
+
+func TestNewReportTiersSkipsInvalid(t *testing.T) {
+	assert.Empty(t, newReportTiers([]ReportTier{
+		{Reporter: NullStatsReporter},
+		{Interval: time.Second},
+	}))
+}
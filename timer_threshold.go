@@ -0,0 +1,64 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tally
+
+import (
+	"path"
+	"time"
+)
+
+// DefaultTimerThresholdSuffix is the default suffix of the counters of
+// timer threshold rules.
+const DefaultTimerThresholdSuffix = "_below_threshold"
+
+// TimerThresholdRule configures a minimum duration for the timers whose
+// name matches the rule: durations shorter than MinDuration recorded to
+// them are not reported as timer values but only counted, in a counter of
+// the same scope named after the timer with Suffix appended. This cuts the
+// volume of timers dominated by very short durations which carry no
+// information.
+type TimerThresholdRule struct {
+	// Name is a path.Match pattern matched against fully qualified timer
+	// names.
+	Name string
+
+	// MinDuration is the shortest duration reported as a timer value.
+	MinDuration time.Duration
+
+	// Suffix is appended to the name of the timer to name the counter of
+	// durations below MinDuration. Use the empty string to specify
+	// DefaultTimerThresholdSuffix.
+	Suffix string
+}
+
+// timerThreshold returns the first rule matching the fully qualified name
+// of a timer.
+func timerThreshold(rules []TimerThresholdRule, name string) (TimerThresholdRule, bool) {
+	for _, rule := range rules {
+		if ok, _ := path.Match(rule.Name, name); ok && rule.MinDuration > 0 {
+			if rule.Suffix == "" {
+				rule.Suffix = DefaultTimerThresholdSuffix
+			}
+			return rule, true
+		}
+	}
+	return TimerThresholdRule{}, false
+}
@@ -0,0 +1,78 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tally
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTimerThresholds(t *testing.T) {
+	r := newTestStatsReporter()
+	root, closer := NewRootScope(ScopeOptions{
+		Prefix:        "svc",
+		Reporter:      r,
+		MetricsOption: OmitInternalMetrics,
+		StrictNames:   true,
+		TimerThresholds: []TimerThresholdRule{
+			{Name: "svc.db.*", MinDuration: time.Microsecond},
+			{Name: "svc.cache", MinDuration: time.Millisecond, Suffix: "_fast"},
+		},
+	}, 0)
+	defer closer.Close()
+
+	db := root.SubScope("db").Timer(N("query").String())
+	cache := root.Timer(N("cache").String())
+	other := root.Timer(N("other").String())
+
+	r.tg.Add(3)
+	db.Record(100 * time.Nanosecond)
+	db.Record(200 * time.Nanosecond)
+	db.Record(time.Second)
+	cache.Record(time.Microsecond)
+	cache.Record(time.Second)
+	other.Record(time.Nanosecond)
+	r.tg.Wait()
+
+	r.cg.Add(2)
+	root.(*scope).reportRegistry()
+	r.WaitAll()
+
+	counters := r.getCounters()
+	require.Contains(t, counters, "svc.db.query_below_threshold")
+	assert.EqualValues(t, 2, counters["svc.db.query_below_threshold"].val)
+	require.Contains(t, counters, "svc.cache_fast")
+	assert.EqualValues(t, 1, counters["svc.cache_fast"].val)
+	assert.NotContains(t, counters, "svc.other_below_threshold")
+	assert.EqualValues(t, time.Second, r.getTimers()["svc.db.query"].val)
+}
+
+func TestTimerNegativeDurationWithoutThreshold(t *testing.T) {
+	s := NewTestScope("", nil)
+	s.Timer("latency").Record(-time.Millisecond)
+	assert.Equal(t,
+		[]time.Duration{-time.Millisecond},
+		s.Snapshot().Timers()["latency+"].Values(),
+	)
+}
@@ -0,0 +1,115 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tally
+
+import (
+	"time"
+
+	"go.uber.org/atomic"
+)
+
+// reportClock is the time of the report in progress, which is the
+// observation time of the values it reports.
+type reportClock struct {
+	nanos atomic.Int64
+}
+
+// start sets the time of a report starting.
+func (c *reportClock) start() {
+	c.nanos.Store(globalNow().UnixNano())
+}
+
+// now returns the time of the last report, or the current time if there
+// was none yet.
+func (c *reportClock) now() time.Time {
+	if n := c.nanos.Load(); n != 0 {
+		return time.Unix(0, n)
+	}
+	return globalNow()
+}
+
+// timestampedReporter stamps the values reported to a
+// TimestampedStatsReporter with their observation time: the time of the
+// report for the values of counters, gauges and histograms, and the
+// current time for timers which are reported as they are recorded. It
+// wraps the reporter of a scope before any other wrapper, so that the
+// values they report are stamped too.
+type timestampedReporter struct {
+	TimestampedStatsReporter
+	clock *reportClock
+}
+
+func (r timestampedReporter) ReportCounter(name string, tags map[string]string, value int64) {
+	r.ReportCounterAt(name, tags, value, r.clock.now())
+}
+
+func (r timestampedReporter) ReportCounterTotal(name string, tags map[string]string, total int64) {
+	if cr, ok := r.TimestampedStatsReporter.(CumulativeCounterReporter); ok {
+		cr.ReportCounterTotal(name, tags, total)
+	}
+}
+
+func (r timestampedReporter) ReportGauge(name string, tags map[string]string, value float64) {
+	r.ReportGaugeAt(name, tags, value, r.clock.now())
+}
+
+func (r timestampedReporter) ReportTimer(name string, tags map[string]string, interval time.Duration) {
+	r.ReportTimerAt(name, tags, interval, globalNow())
+}
+
+func (r timestampedReporter) ReportHistogramValueSamples(
+	name string,
+	tags map[string]string,
+	buckets Buckets,
+	bucketLowerBound,
+	bucketUpperBound float64,
+	samples int64,
+) {
+	r.ReportHistogramValueSamplesAt(name, tags, buckets,
+		bucketLowerBound, bucketUpperBound, samples, r.clock.now())
+}
+
+func (r timestampedReporter) ReportHistogramDurationSamples(
+	name string,
+	tags map[string]string,
+	buckets Buckets,
+	bucketLowerBound,
+	bucketUpperBound time.Duration,
+	samples int64,
+) {
+	r.ReportHistogramDurationSamplesAt(name, tags, buckets,
+		bucketLowerBound, bucketUpperBound, samples, r.clock.now())
+}
+
+// ReportSummary passes summaries through unstamped, as SummaryReporter has
+// no timestamped counterpart.
+func (r timestampedReporter) ReportSummary(
+	name string,
+	tags map[string]string,
+	quantiles []float64,
+	values []float64,
+	count int64,
+	sum float64,
+) {
+	if sr, ok := r.TimestampedStatsReporter.(SummaryReporter); ok {
+		sr.ReportSummary(name, tags, quantiles, values, count, sum)
+	}
+}
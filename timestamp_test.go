@@ -0,0 +1,106 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tally
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// timestampRecordingReporter records the timestamp of the last value of
+// each metric, keyed by its name and tags.
+type timestampRecordingReporter struct {
+	nullStatsReporter
+	timestamps map[string]time.Time
+}
+
+func newTimestampRecordingReporter() *timestampRecordingReporter {
+	return &timestampRecordingReporter{timestamps: make(map[string]time.Time)}
+}
+
+func (r *timestampRecordingReporter) ReportCounterAt(name string, tags map[string]string, _ int64, ts time.Time) {
+	r.timestamps[KeyForPrefixedStringMap(name, tags)] = ts
+}
+
+func (r *timestampRecordingReporter) ReportGaugeAt(name string, tags map[string]string, _ float64, ts time.Time) {
+	r.timestamps[KeyForPrefixedStringMap(name, tags)] = ts
+}
+
+func (r *timestampRecordingReporter) ReportTimerAt(name string, tags map[string]string, _ time.Duration, ts time.Time) {
+	r.timestamps[KeyForPrefixedStringMap(name, tags)] = ts
+}
+
+func (r *timestampRecordingReporter) ReportHistogramValueSamplesAt(
+	name string, tags map[string]string, _ Buckets, _, _ float64, _ int64, ts time.Time,
+) {
+	r.timestamps[KeyForPrefixedStringMap(name, tags)] = ts
+}
+
+func (r *timestampRecordingReporter) ReportHistogramDurationSamplesAt(
+	name string, tags map[string]string, _ Buckets, _, _ time.Duration, _ int64, ts time.Time,
+) {
+	r.timestamps[KeyForPrefixedStringMap(name, tags)] = ts
+}
+
+func TestTimestampedReporter(t *testing.T) {
+	now := time.Unix(1e9, 0)
+	defer func(prev func() time.Time) { globalNow = prev }(globalNow)
+	globalNow = func() time.Time { return now }
+
+	r := newTimestampRecordingReporter()
+	root, closer := NewRootScope(ScopeOptions{
+		Reporter:      r,
+		MetricsOption: OmitInternalMetrics,
+		TagProviders:  map[string]TagProvider{"host": func() string { return "a" }},
+	}, 0)
+	defer closer.Close()
+
+	root.Counter("counter").Inc(1)
+	root.Gauge("gauge").Update(1)
+	root.Histogram("histogram", MustMakeLinearValueBuckets(0, 1, 2)).RecordValue(1)
+	root.(*scope).reportRegistry()
+
+	// Timers are stamped as they are recorded, other values with the
+	// time of the report.
+	now = now.Add(time.Second)
+	root.Timer("timer").Record(time.Millisecond)
+	assert.Equal(t, map[string]time.Time{
+		"counter+host=a":   time.Unix(1e9, 0),
+		"gauge+host=a":     time.Unix(1e9, 0),
+		"histogram+host=a": time.Unix(1e9, 0),
+		"timer+host=a":     now,
+	}, r.timestamps)
+}
+
+func TestMultiReporterTimestamps(t *testing.T) {
+	ts := time.Unix(1e9, 0)
+	timestamped := newTimestampRecordingReporter()
+	plain := newTestStatsReporter()
+	plain.cg.Add(1)
+
+	m := NewMultiReporter(timestamped, plain).(TimestampedStatsReporter)
+	m.ReportCounterAt("counter", nil, 3, ts)
+	plain.WaitAll()
+	assert.Equal(t, ts, timestamped.timestamps["counter+"])
+	assert.EqualValues(t, 3, plain.getCounters()["counter"].val)
+}
@@ -0,0 +1,85 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tally
+
+import "time"
+
+// ReportPhase is a phase of a report.
+type ReportPhase int
+
+const (
+	// RegistryWalkPhase is the walk of the registry by a scope, reporting
+	// the metrics of every scope to the reporter.
+	RegistryWalkPhase ReportPhase = iota + 1
+	// FlushPhase is the flush of the reporter by a scope, which includes
+	// the serialization and send phases of reporters buffering metrics.
+	FlushPhase
+	// SerializePhase is the serialization of metrics by a reporter.
+	SerializePhase
+	// SendPhase is the sending of serialized metrics over the network by
+	// a reporter.
+	SendPhase
+)
+
+func (p ReportPhase) String() string {
+	switch p {
+	case RegistryWalkPhase:
+		return "registry_walk"
+	case FlushPhase:
+		return "flush"
+	case SerializePhase:
+		return "serialize"
+	case SendPhase:
+		return "send"
+	default:
+		return "unknown"
+	}
+}
+
+// ReportTracer traces the phases of reports, so that slow reports can be
+// decomposed without profiling, e.g. by starting a span per phase.
+type ReportTracer interface {
+	// StartPhase is called when a phase starts, and the function it
+	// returns when the phase ends.
+	StartPhase(phase ReportPhase) (end func())
+}
+
+// ReportTimingFunc is a ReportTracer calling itself with the duration of
+// every phase once it ended.
+type ReportTimingFunc func(phase ReportPhase, duration time.Duration)
+
+// StartPhase implements ReportTracer.
+func (f ReportTimingFunc) StartPhase(phase ReportPhase) func() {
+	start := globalNow()
+	return func() {
+		f(phase, globalNow().Sub(start))
+	}
+}
+
+// StartReportPhase starts phase with t, returning the function ending it.
+// It is a convenience for reporters, which accept a nil ReportTracer
+// tracing nothing.
+func StartReportPhase(t ReportTracer, phase ReportPhase) (end func()) {
+	if t == nil {
+		return func() {}
+	}
+	return t.StartPhase(phase)
+}
@@ -0,0 +1,95 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tally
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recordingTracer struct {
+	events []string
+}
+
+func (t *recordingTracer) StartPhase(phase ReportPhase) func() {
+	t.events = append(t.events, "start "+phase.String())
+	return func() {
+		t.events = append(t.events, "end "+phase.String())
+	}
+}
+
+func TestReportTracer(t *testing.T) {
+	tracer := &recordingTracer{}
+	root, closer := NewRootScope(ScopeOptions{
+		Reporter:     NullStatsReporter,
+		ReportTracer: tracer,
+	}, 0)
+	defer closer.Close()
+
+	root.Counter("requests").Inc(1)
+	root.(*scope).reportLoopRun()
+	assert.Equal(t, []string{
+		"start registry_walk",
+		"end registry_walk",
+		"start flush",
+		"end flush",
+	}, tracer.events)
+
+	tracer.events = nil
+	ctxRoot, ctxCloser := NewRootScope(ScopeOptions{
+		ContextReporter: newContextRecordingReporter(),
+		ReportTracer:    tracer,
+	}, 0)
+	defer ctxCloser.Close()
+	require.NoError(t, FlushContext(context.Background(), ctxRoot))
+	assert.Equal(t, []string{
+		"start registry_walk",
+		"end registry_walk",
+		"start flush",
+		"end flush",
+	}, tracer.events)
+}
+
+func TestReportTimingFunc(t *testing.T) {
+	now := time.Unix(0, 0)
+	defer func(prev func() time.Time) { globalNow = prev }(globalNow)
+	globalNow = func() time.Time { return now }
+
+	var (
+		phase    ReportPhase
+		duration time.Duration
+	)
+	end := StartReportPhase(ReportTimingFunc(func(p ReportPhase, d time.Duration) {
+		phase, duration = p, d
+	}), SendPhase)
+	now = now.Add(time.Second)
+	end()
+	assert.Equal(t, SendPhase, phase)
+	assert.Equal(t, time.Second, duration)
+
+	// A nil tracer traces nothing.
+	StartReportPhase(nil, SendPhase)()
+	assert.Equal(t, "unknown", ReportPhase(0).String())
+}
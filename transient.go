@@ -0,0 +1,64 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tally
+
+import "strconv"
+
+// TransientTagged returns a new child scope of s with the given tags, for
+// tags with genuinely transient values. Unlike Tagged, the scope isn't
+// cached for later calls with the same tags: it is reported once by the
+// next report then removed from the registry, so that transient tags don't
+// grow the registry forever. Writes to its metrics after that report are
+// lost and counted as writes after close.
+//
+// Scopes not created by NewRootScope return s.Tagged(tags).
+func TransientTagged(s Scope, tags map[string]string) Scope {
+	switch v := s.(type) {
+	case *scope:
+		return v.transientTagged(tags)
+	case *leveledScope:
+		return v.wrap(v.scope.transientTagged(tags))
+	default:
+		return s.Tagged(tags)
+	}
+}
+
+func (s *scope) transientTagged(tags map[string]string) Scope {
+	if s.registry.root.closed.Load() || s.closed.Load() {
+		return NoopScope
+	}
+
+	r := s.registry
+	tags = s.copyAndSanitizeMap(tags)
+	r.resolveTagConflicts(s, tags)
+
+	// NB: the key is unique so that the scope is never looked up.
+	key := scopeRegistryKey(s.prefix, s.tags, tags) +
+		"+transient=" + strconv.FormatUint(r.transientSeq.Inc(), 10)
+	subscope := newSubscope(s, s.prefix, tags)
+	subscope.transient = true
+
+	bucket := r.bucketFor([]byte(key))
+	bucket.mu.Lock()
+	bucket.s[key] = subscope
+	bucket.mu.Unlock()
+	return subscope
+}
@@ -0,0 +1,57 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tally
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTransientTagged(t *testing.T) {
+	r := &valueRecordingReporter{
+		counters:   make(map[string]int64),
+		histograms: make(map[string]int64),
+	}
+	root, closer := NewRootScope(ScopeOptions{Reporter: r, MetricsOption: OmitInternalMetrics}, 0)
+	defer closer.Close()
+	s := root.(*scope)
+
+	tags := map[string]string{"request": "1"}
+	transient := TransientTagged(root, tags)
+	assert.NotEqual(t, transient, TransientTagged(root, tags))
+	assert.NotEqual(t, transient, root.Tagged(tags))
+	assert.Equal(t, tags, Tags(transient))
+	transient.Counter("bytes").Inc(3)
+	TransientTagged(AtLevel(root, DebugLevel), tags).Counter("debug").Inc(1)
+
+	// The scope is reported once, then removed.
+	s.reportRegistry()
+	assert.Equal(t, int64(3), r.counters["bytes"])
+	s.registry.ForEachScope(func(ss *scope) {
+		assert.False(t, ss.transient)
+	})
+
+	transient.Counter("bytes").Inc(1)
+	assert.EqualValues(t, 1, s.registry.writesAfterClose.Load())
+
+	assert.Equal(t, NoopScope, TransientTagged(NoopScope, tags))
+}
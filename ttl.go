@@ -0,0 +1,89 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tally
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// expireIdle removes the counters, gauges and histograms of the scope
+// which weren't written to within ttl as of now. Activity is only observed
+// by reports, so metrics are expired up to a reporting interval late.
+func (s *scope) expireIdle(now time.Time, ttl time.Duration) {
+	var (
+		nanos  = now.UnixNano()
+		idle   = func(lastActive int64) bool { return nanos-lastActive >= int64(ttl) }
+		active bool
+	)
+
+	s.cm.Lock()
+	for name, c := range s.counters {
+		active = c.curr.load() != atomic.LoadInt64(&c.prev)
+		if active || c.lastActive == 0 {
+			c.lastActive = nanos
+		} else if idle(c.lastActive) {
+			s.removeCounter(name)
+		}
+	}
+	s.cm.Unlock()
+
+	s.gm.Lock()
+	for name, g := range s.gauges {
+		active = atomic.LoadUint64(&g.updated) == 1
+		if active || g.lastActive == 0 {
+			g.lastActive = nanos
+		} else if idle(g.lastActive) {
+			s.removeGauge(name)
+		}
+	}
+	s.gm.Unlock()
+
+	s.hm.Lock()
+	for name, h := range s.histograms {
+		active = false
+		for _, sample := range h.samples {
+			if sample.counter.snapshot() != 0 {
+				active = true
+				break
+			}
+		}
+		if active || h.lastActive == 0 {
+			h.lastActive = nanos
+		} else if idle(h.lastActive) {
+			s.removeHistogram(name)
+		}
+	}
+	s.hm.Unlock()
+}
+
+// expireIdle expires the idle metrics of every scope of the registry, if
+// a metric TTL is set.
+func (r *scopeRegistry) expireIdle() {
+	if r.metricTTL <= 0 {
+		return
+	}
+
+	now := globalNow()
+	r.ForEachScope(func(s *scope) {
+		s.expireIdle(now, r.metricTTL)
+	})
+}
@@ -0,0 +1,84 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tally
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetricTTL(t *testing.T) {
+	now := time.Unix(1e9, 0)
+	defer func(prev func() time.Time) { globalNow = prev }(globalNow)
+	globalNow = func() time.Time { return now }
+
+	root, closer := NewRootScope(ScopeOptions{
+		Reporter:      NullStatsReporter,
+		MetricsOption: OmitInternalMetrics,
+		MetricTTL:     time.Minute,
+	}, 0)
+	defer closer.Close()
+	s := root.(*scope)
+	pod := root.Tagged(map[string]string{"pod": "a"})
+
+	busy := root.Counter("busy")
+	pod.Counter("requests").Inc(1)
+	pod.Gauge("workers").Update(1)
+	pod.Histogram("size", ValueBuckets{1}).RecordValue(1)
+	root.Timer("latency")
+	s.reportRegistry()
+
+	now = now.Add(30 * time.Second)
+	busy.Inc(1)
+	pod.Gauge("workers").Update(2)
+	s.reportRegistry()
+	assert.Len(t, s.Snapshot().Counters(), 2)
+
+	// The counter and histogram are idle for a minute, the gauge was
+	// updated 30s ago.
+	now = now.Add(30 * time.Second)
+	busy.Inc(1)
+	s.reportRegistry()
+	snapshot := s.Snapshot()
+	assert.Equal(t, []string{"busy+"}, counterKeys(snapshot.Counters()))
+	assert.Len(t, snapshot.Gauges(), 1)
+	assert.Empty(t, snapshot.Histograms())
+	assert.Len(t, snapshot.Timers(), 1)
+
+	now = now.Add(30 * time.Second)
+	s.reportRegistry()
+	assert.Empty(t, s.Snapshot().Gauges())
+	assert.Len(t, s.Snapshot().Counters(), 1)
+
+	// Expired metrics are created anew.
+	pod.Counter("requests").Inc(1)
+	assert.EqualValues(t, 1, s.Snapshot().Counters()["requests+pod=a"].Value())
+}
+
+func counterKeys(counters map[string]CounterSnapshot) []string {
+	var ks []string
+	for k := range counters {
+		ks = append(ks, k)
+	}
+	return ks
+}